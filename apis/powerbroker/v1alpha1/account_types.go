@@ -0,0 +1,134 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"reflect"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+)
+
+// Lifecycle stages of an Account.
+const (
+	AccountActive         = "Active"
+	AccountSuspended      = "Suspended"
+	AccountDecommissioned = "Decommissioned"
+)
+
+// AccountParameters are the configurable fields of an Account.
+type AccountParameters struct {
+	// Cloud the account is in. An account is identified by its cloud and
+	// id, so the same id may name an account in each cloud.
+	// +kubebuilder:validation:Enum=aws;gcp;azure;kubernetes
+	// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="cloud is immutable"
+	// +kubebuilder:default=aws
+	// +optional
+	Cloud string `json:"cloud,omitempty"`
+
+	// ID of the account at its cloud provider, e.g. 123456789012, or the
+	// project, subscription or cluster of another cloud. It becomes the
+	// external name of the Account, and cannot be changed.
+	// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="id is immutable"
+	ID string `json:"id"`
+
+	// Alias is the human readable name of the account.
+	// +optional
+	Alias string `json:"alias,omitempty"`
+
+	// Class groups accounts of the same kind, e.g. sandbox or shared.
+	// +optional
+	Class string `json:"class,omitempty"`
+
+	// Owner is the person or team accountable for the account.
+	// +optional
+	Owner string `json:"owner,omitempty"`
+
+	// Environment the account belongs to, e.g. production.
+	// +optional
+	Environment string `json:"environment,omitempty"`
+
+	// Lifecycle is the stage the account is in.
+	// +kubebuilder:validation:Enum=Active;Suspended;Decommissioned
+	// +kubebuilder:default=Active
+	// +optional
+	Lifecycle string `json:"lifecycle,omitempty"`
+}
+
+// AccountObservation are the observable fields of an Account.
+type AccountObservation struct {
+	NodeID      string `json:"nodeId,omitempty"`
+	Status      string `json:"status,omitempty"`
+	Alias       string `json:"alias,omitempty"`
+	Class       string `json:"class,omitempty"`
+	Owner       string `json:"owner,omitempty"`
+	Environment string `json:"environment,omitempty"`
+	Lifecycle   string `json:"lifecycle,omitempty"`
+}
+
+// An AccountSpec defines the desired state of an Account.
+type AccountSpec struct {
+	xpv1.ResourceSpec `json:",inline"`
+	ForProvider       AccountParameters `json:"forProvider"`
+}
+
+// An AccountStatus represents the observed state of an Account.
+type AccountStatus struct {
+	xpv1.ResourceStatus `json:",inline"`
+	AtProvider          AccountObservation `json:"atProvider,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// An Account is a cloud account which PermissionSets delegate access to.
+// +kubebuilder:printcolumn:name="READY",type="string",JSONPath=".status.conditions[?(@.type=='Ready')].status"
+// +kubebuilder:printcolumn:name="SYNCED",type="string",JSONPath=".status.conditions[?(@.type=='Synced')].status"
+// +kubebuilder:printcolumn:name="EXTERNAL-NAME",type="string",JSONPath=".metadata.annotations.crossplane\\.io/external-name"
+// +kubebuilder:printcolumn:name="LIFECYCLE",type="string",JSONPath=".spec.forProvider.lifecycle"
+// +kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster,categories={crossplane,managed,neo4j}
+type Account struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AccountSpec   `json:"spec"`
+	Status AccountStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// AccountList contains a list of Account
+type AccountList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Account `json:"items"`
+}
+
+// Account type metadata.
+var (
+	AccountKind             = reflect.TypeOf(Account{}).Name()
+	AccountGroupKind        = schema.GroupKind{Group: Group, Kind: AccountKind}.String()
+	AccountKindAPIVersion   = AccountKind + "." + SchemeGroupVersion.String()
+	AccountGroupVersionKind = SchemeGroupVersion.WithKind(AccountKind)
+)
+
+func init() {
+	SchemeBuilder.Register(&Account{}, &AccountList{})
+}
@@ -25,28 +25,135 @@ import (
 	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
 )
 
-// AccountRoleBinding will become part of PermissionSetParameters
+// Clouds a binding may delegate access in.
+const (
+	CloudAWS        = "aws"
+	CloudGCP        = "gcp"
+	CloudAzure      = "azure"
+	CloudKubernetes = "kubernetes"
+)
+
+// An AccountRoleBinding delegates access to an account with a role. A
+// binding is identified by its account and role, so a PermissionSet binds
+// each role in each account at most once.
+//
+// The cloud of a binding determines which of its fields name the account
+// and role. An aws binding sets account and roleName, which may be selected
+// from Accounts and Roles. A binding in any other cloud sets only the fields
+// of that cloud, whose project, subscription or cluster is its account.
 type AccountRoleBinding struct {
-	Alias        string `json:"accountAlias,omitempty"`
-	Account      string `json:"account"`
+	// Cloud the binding delegates access in.
+	// +kubebuilder:validation:Enum=aws;gcp;azure;kubernetes
+	// +kubebuilder:default=aws
+	// +optional
+	Cloud string `json:"cloud,omitempty"`
+
+	// Alias and AccountClass describe an account which no Account manages.
+	// An Account keeps its own alias and class, so they must be unset
+	// when binding to one.
+	Alias string `json:"accountAlias,omitempty"`
+
+	// +crossplane:generate:reference:type=Account
+	// +crossplane:generate:reference:extractor=github.com/crossplane/crossplane-runtime/pkg/reference.ExternalName()
+	// +crossplane:generate:reference:refFieldName=AccountRef
+	// +crossplane:generate:reference:selectorFieldName=AccountRefSelector
+	// +optional
+	Account            string          `json:"account,omitempty"`
+	AccountRef         *xpv1.Reference `json:"accountRef,omitempty"`
+	AccountRefSelector *xpv1.Selector  `json:"accountSelector,omitempty"`
+
 	AccountClass string `json:"accountClass,omitempty"`
-	RoleName     string `json:"roleName"`
+
+	// +crossplane:generate:reference:type=Role
+	// +crossplane:generate:reference:extractor=github.com/crossplane/crossplane-runtime/pkg/reference.ExternalName()
+	// +crossplane:generate:reference:refFieldName=RoleRef
+	// +crossplane:generate:reference:selectorFieldName=RoleRefSelector
+	// +optional
+	RoleName        string          `json:"roleName,omitempty"`
+	RoleRef         *xpv1.Reference `json:"roleRef,omitempty"`
+	RoleRefSelector *xpv1.Selector  `json:"roleSelector,omitempty"`
+
+	// GCP grants a role in a project through workload identity federation.
+	// +optional
+	GCP *GCPBinding `json:"gcp,omitempty"`
+
+	// Azure assigns a role in a subscription through workload identity
+	// federation.
+	// +optional
+	Azure *AzureBinding `json:"azure,omitempty"`
+
+	// Kubernetes binds a ClusterRole in a cluster.
+	// +optional
+	Kubernetes *KubernetesBinding `json:"kubernetes,omitempty"`
 }
 
-// TODO (VariableExp0rt): make account role binding general for each cloud provider
-// that supports workload identity federation. Though specifically WI is not
-// meant for this purpose, GCP and Azure support it, and the temporary nature
-// of the credentials it vendors is beneficial for IAM activities
+// A GCPBinding grants a role in a GCP project.
+type GCPBinding struct {
+	// Project is the ID of the project, e.g. my-project.
+	Project string `json:"project"`
+
+	// Role is the name of the role, e.g. roles/viewer.
+	Role string `json:"role"`
+}
+
+// An AzureBinding assigns a role definition in an Azure subscription.
+type AzureBinding struct {
+	// Subscription is the ID of the subscription.
+	Subscription string `json:"subscription"`
+
+	// RoleDefinition is the name or ID of the role definition, e.g. Reader.
+	RoleDefinition string `json:"roleDefinition"`
+
+	// Scope the role is assigned at, e.g. a resource group of the
+	// subscription. It must be within the subscription, and the role is
+	// assigned at the subscription if it is unset.
+	// +optional
+	Scope string `json:"scope,omitempty"`
+}
+
+// A KubernetesBinding binds a ClusterRole in a Kubernetes cluster.
+type KubernetesBinding struct {
+	// Cluster is the name of the cluster.
+	Cluster string `json:"cluster"`
+
+	// ClusterRole is the name of the ClusterRole, e.g. view.
+	ClusterRole string `json:"clusterRole"`
+}
 
 // PermissionSetParameters are the configurable fields of a PermissionSet.
 type PermissionSetParameters struct {
-	BindTo AccountRoleBinding `json:"bindTo"`
+	// Bindings delegate access to each of their accounts with their role.
+	// The account and role of an aws binding may be selected from Accounts
+	// and Roles rather than named.
+	// +kubebuilder:validation:MinItems=1
+	Bindings []AccountRoleBinding `json:"bindings"`
+}
+
+// States of the binding of a PermissionSet.
+const (
+	BindingInSync     = "InSync"
+	BindingDrifted    = "Drifted"
+	BindingMissing    = "Missing"
+	BindingUnexpected = "Unexpected"
+)
+
+// A BindingObservation is the observed state of a binding of a
+// PermissionSet. A binding is Missing if it is not stored, and Unexpected if
+// it is stored but the PermissionSet does not have it. The account, role and
+// scope of a binding are those of its cloud.
+type BindingObservation struct {
+	Cloud    string `json:"cloud,omitempty"`
+	Account  string `json:"account,omitempty"`
+	RoleName string `json:"roleName,omitempty"`
+	Scope    string `json:"scope,omitempty"`
+	State    string `json:"state,omitempty"`
 }
 
 // PermissionSetObservation are the observable fields of a PermissionSet.
 type PermissionSetObservation struct {
-	NodeID string `json:"nodeId,omitempty"`
-	Status string `json:"status,omitempty"`
+	NodeID   string               `json:"nodeId,omitempty"`
+	Status   string               `json:"status,omitempty"`
+	Bindings []BindingObservation `json:"bindings,omitempty"`
 }
 
 // A PermissionSetSpec defines the desired state of a PermissionSet.
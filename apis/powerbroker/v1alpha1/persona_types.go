@@ -40,14 +40,21 @@ type PersonaParameters struct {
 
 // PersonaObservation are the observable fields of a Persona.
 type PersonaObservation struct {
-	NodeID string `json:"nodeId,omitempty"`
-	Status string `json:"status,omitempty"`
+	NodeID         string   `json:"nodeId,omitempty"`
+	Status         string   `json:"status,omitempty"`
+	Name           string   `json:"name,omitempty"`
+	PermissionSets []string `json:"permissionSets,omitempty"`
 }
 
 // A PersonaSpec defines the desired state of a Persona.
 type PersonaSpec struct {
 	xpv1.ResourceSpec `json:",inline"`
 	ForProvider       PersonaParameters `json:"forProvider"`
+
+	// ManagementPolicy specifies what the provider may do to the persona.
+	// +optional
+	// +kubebuilder:default=FullControl
+	ManagementPolicy ManagementPolicy `json:"managementPolicy,omitempty"`
 }
 
 // A PersonaStatus represents the observed state of a Persona.
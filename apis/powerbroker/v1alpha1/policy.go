@@ -0,0 +1,33 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+// A ManagementPolicy determines what the provider may do to the node of a
+// managed resource. It mirrors the management policies of newer releases of
+// crossplane-runtime, and is ignored unless management policies are enabled.
+// +kubebuilder:validation:Enum=FullControl;ObserveOnly
+type ManagementPolicy string
+
+const (
+	// ManagementFullControl lets the provider create, update and delete
+	// the node. It is the default.
+	ManagementFullControl ManagementPolicy = "FullControl"
+
+	// ManagementObserveOnly lets the provider only observe an existing
+	// node, which the external name identifies by its uuid or its name.
+	ManagementObserveOnly ManagementPolicy = "ObserveOnly"
+)
@@ -0,0 +1,130 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"reflect"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+)
+
+// Sensitivity tiers of a Role, from the least to the most sensitive.
+const (
+	RoleTierLow      = "Low"
+	RoleTierModerate = "Moderate"
+	RoleTierHigh     = "High"
+	RoleTierCritical = "Critical"
+)
+
+// RoleParameters are the configurable fields of a Role.
+type RoleParameters struct {
+	// Cloud the role is in. A role is identified by its cloud and name, so
+	// the same name may name a role in each cloud.
+	// +kubebuilder:validation:Enum=aws;gcp;azure;kubernetes
+	// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="cloud is immutable"
+	// +kubebuilder:default=aws
+	// +optional
+	Cloud string `json:"cloud,omitempty"`
+
+	// Name of the role at its cloud provider, e.g. Administrator. It
+	// becomes the external name of the Role, and cannot be changed.
+	// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="name is immutable"
+	Name string `json:"name"`
+
+	// Description of what the role is for.
+	// +optional
+	Description string `json:"description,omitempty"`
+
+	// Tier is how sensitive the access the role grants is.
+	// +kubebuilder:validation:Enum=Low;Moderate;High;Critical
+	// +kubebuilder:default=Low
+	// +optional
+	Tier string `json:"tier,omitempty"`
+
+	// PolicyDocument is the IAM-style JSON policy attached to the role,
+	// which describes what the role allows.
+	// +optional
+	PolicyDocument string `json:"policyDocument,omitempty"`
+
+	// MaxSessionDuration is the longest a session of the role may last.
+	// +optional
+	MaxSessionDuration *metav1.Duration `json:"maxSessionDuration,omitempty"`
+}
+
+// RoleObservation are the observable fields of a Role.
+type RoleObservation struct {
+	NodeID             string           `json:"nodeId,omitempty"`
+	Status             string           `json:"status,omitempty"`
+	Description        string           `json:"description,omitempty"`
+	Tier               string           `json:"tier,omitempty"`
+	PolicyDocument     string           `json:"policyDocument,omitempty"`
+	MaxSessionDuration *metav1.Duration `json:"maxSessionDuration,omitempty"`
+}
+
+// A RoleSpec defines the desired state of a Role.
+type RoleSpec struct {
+	xpv1.ResourceSpec `json:",inline"`
+	ForProvider       RoleParameters `json:"forProvider"`
+}
+
+// A RoleStatus represents the observed state of a Role.
+type RoleStatus struct {
+	xpv1.ResourceStatus `json:",inline"`
+	AtProvider          RoleObservation `json:"atProvider,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// A Role is a cloud role which PermissionSets delegate access with.
+// +kubebuilder:printcolumn:name="READY",type="string",JSONPath=".status.conditions[?(@.type=='Ready')].status"
+// +kubebuilder:printcolumn:name="SYNCED",type="string",JSONPath=".status.conditions[?(@.type=='Synced')].status"
+// +kubebuilder:printcolumn:name="EXTERNAL-NAME",type="string",JSONPath=".metadata.annotations.crossplane\\.io/external-name"
+// +kubebuilder:printcolumn:name="TIER",type="string",JSONPath=".spec.forProvider.tier"
+// +kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster,categories={crossplane,managed,neo4j}
+type Role struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RoleSpec   `json:"spec"`
+	Status RoleStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// RoleList contains a list of Role
+type RoleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Role `json:"items"`
+}
+
+// Role type metadata.
+var (
+	RoleKind             = reflect.TypeOf(Role{}).Name()
+	RoleGroupKind        = schema.GroupKind{Group: Group, Kind: RoleKind}.String()
+	RoleKindAPIVersion   = RoleKind + "." + SchemeGroupVersion.String()
+	RoleGroupVersionKind = SchemeGroupVersion.WithKind(RoleKind)
+)
+
+func init() {
+	SchemeBuilder.Register(&Role{}, &RoleList{})
+}
@@ -38,16 +38,49 @@ type UserParameters struct {
 	PersonaRefSelector *xpv1.Selector   `json:"personaRefSelector,omitempty"`
 }
 
+// Sources of the access of a User.
+const (
+	AccessSourceDirect = "Direct"
+	AccessSourceTeam   = "Team"
+)
+
+// An AccessObservation is an account a User can access with a role. The
+// access is Direct if a Persona granted to the User leads to it, or through
+// the named Team if a Persona the Team inherits does. Access through several
+// sources is observed once for each.
+type AccessObservation struct {
+	Cloud    string `json:"cloud,omitempty"`
+	Account  string `json:"account"`
+	RoleName string `json:"roleName"`
+	Scope    string `json:"scope,omitempty"`
+	Source   string `json:"source"`
+	Team     string `json:"team,omitempty"`
+}
+
 // UserObservation are the observable fields of a User.
 type UserObservation struct {
-	NodeID string `json:"nodeId,omitempty"`
-	Status string `json:"status,omitempty"`
+	NodeID   string   `json:"nodeId,omitempty"`
+	Status   string   `json:"status,omitempty"`
+	Name     string   `json:"name,omitempty"`
+	Personas []string `json:"personas,omitempty"`
+
+	// EffectiveAccess is every account the User can access, and with which
+	// role, through its Personas and those of its Teams.
+	EffectiveAccess []AccessObservation `json:"effectiveAccess,omitempty"`
+
+	// AccessHash changes whenever the effective access of the User does.
+	AccessHash string `json:"accessHash,omitempty"`
 }
 
 // A UserSpec defines the desired state of a User.
 type UserSpec struct {
 	xpv1.ResourceSpec `json:",inline"`
 	ForProvider       UserParameters `json:"forProvider"`
+
+	// ManagementPolicy specifies what the provider may do to the user.
+	// +optional
+	// +kubebuilder:default=FullControl
+	ManagementPolicy ManagementPolicy `json:"managementPolicy,omitempty"`
 }
 
 // A UserStatus represents the observed state of a User.
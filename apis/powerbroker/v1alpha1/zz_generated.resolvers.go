@@ -24,6 +24,53 @@ import (
 	client "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// ResolveReferences of this PermissionSet.
+func (mg *PermissionSet) ResolveReferences(ctx context.Context, c client.Reader) error {
+	r := reference.NewAPIResolver(c, mg)
+
+	var rsp reference.ResolutionResponse
+	var err error
+
+	for i3 := 0; i3 < len(mg.Spec.ForProvider.Bindings); i3++ {
+		rsp, err = r.Resolve(ctx, reference.ResolutionRequest{
+			CurrentValue: mg.Spec.ForProvider.Bindings[i3].Account,
+			Extract:      reference.ExternalName(),
+			Reference:    mg.Spec.ForProvider.Bindings[i3].AccountRef,
+			Selector:     mg.Spec.ForProvider.Bindings[i3].AccountRefSelector,
+			To: reference.To{
+				List:    &AccountList{},
+				Managed: &Account{},
+			},
+		})
+		if err != nil {
+			return errors.Wrap(err, "mg.Spec.ForProvider.Bindings[i3].Account")
+		}
+		mg.Spec.ForProvider.Bindings[i3].Account = rsp.ResolvedValue
+		mg.Spec.ForProvider.Bindings[i3].AccountRef = rsp.ResolvedReference
+
+	}
+	for i3 := 0; i3 < len(mg.Spec.ForProvider.Bindings); i3++ {
+		rsp, err = r.Resolve(ctx, reference.ResolutionRequest{
+			CurrentValue: mg.Spec.ForProvider.Bindings[i3].RoleName,
+			Extract:      reference.ExternalName(),
+			Reference:    mg.Spec.ForProvider.Bindings[i3].RoleRef,
+			Selector:     mg.Spec.ForProvider.Bindings[i3].RoleRefSelector,
+			To: reference.To{
+				List:    &RoleList{},
+				Managed: &Role{},
+			},
+		})
+		if err != nil {
+			return errors.Wrap(err, "mg.Spec.ForProvider.Bindings[i3].RoleName")
+		}
+		mg.Spec.ForProvider.Bindings[i3].RoleName = rsp.ResolvedValue
+		mg.Spec.ForProvider.Bindings[i3].RoleRef = rsp.ResolvedReference
+
+	}
+
+	return nil
+}
+
 // ResolveReferences of this Persona.
 func (mg *Persona) ResolveReferences(ctx context.Context, c client.Reader) error {
 	r := reference.NewAPIResolver(c, mg)
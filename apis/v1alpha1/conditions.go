@@ -0,0 +1,128 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+)
+
+// Condition types of a ProviderConfig.
+const (
+	// TypeStorageReady indicates whether the storage backend selected by a
+	// ProviderConfig can be used.
+	TypeStorageReady xpv1.ConditionType = "StorageReady"
+)
+
+// Reasons a ProviderConfig's storage is or is not ready.
+const (
+	ReasonStorageTypeValid   xpv1.ConditionReason = "StorageTypeValid"
+	ReasonUnknownStorageType xpv1.ConditionReason = "UnknownStorageType"
+	ReasonMissingCapability  xpv1.ConditionReason = "MissingCapability"
+	ReasonMigrationFailed    xpv1.ConditionReason = "SchemaMigrationFailed"
+	ReasonUnauthorized       xpv1.ConditionReason = "StorageUnauthorized"
+	ReasonUnavailable        xpv1.ConditionReason = "StorageUnavailable"
+	ReasonDegraded           xpv1.ConditionReason = "StorageDegraded"
+)
+
+// StorageTypeValid returns a condition indicating the ProviderConfig selects
+// a registered storage backend.
+func StorageTypeValid() xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeStorageReady,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonStorageTypeValid,
+	}
+}
+
+// UnknownStorageType returns a condition indicating the ProviderConfig
+// selects a storage backend which is not registered.
+func UnknownStorageType(t string, registered []string) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeStorageReady,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonUnknownStorageType,
+		Message:            fmt.Sprintf("storage type %q is not one of %v", t, registered),
+	}
+}
+
+// MissingCapabilities returns a condition indicating the storage backend of
+// the ProviderConfig lacks procedures or functions it requires.
+func MissingCapabilities(missing []string) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeStorageReady,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonMissingCapability,
+		Message:            fmt.Sprintf("storage lacks required procedures or functions %v", missing),
+	}
+}
+
+// MigrationFailed returns a condition indicating the schema of the
+// ProviderConfig's storage could not be brought up to date.
+func MigrationFailed(err error) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeStorageReady,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonMigrationFailed,
+		Message:            err.Error(),
+	}
+}
+
+// StorageUnauthorized returns a condition indicating the storage of the
+// ProviderConfig rejected the credentials it references.
+func StorageUnauthorized(err error) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeStorageReady,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonUnauthorized,
+		Message:            err.Error(),
+	}
+}
+
+// StorageUnavailable returns a condition indicating the storage of the
+// ProviderConfig cannot be reached or is failing transiently.
+func StorageUnavailable(err error) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeStorageReady,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonUnavailable,
+		Message:            err.Error(),
+	}
+}
+
+// StorageDegraded returns a condition indicating calls to the storage of the
+// ProviderConfig kept failing, so are being rejected until the supplied time.
+func StorageDegraded(until time.Time) xpv1.Condition {
+	return xpv1.Condition{
+		Type:               TypeStorageReady,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.Now(),
+		Reason:             ReasonDegraded,
+		Message:            fmt.Sprintf("storage keeps failing, so calls to it are rejected until %s", until.UTC().Format(time.RFC3339)),
+	}
+}
@@ -40,8 +40,155 @@ type ProviderConfigSpec struct {
 	Storage     StorageType         `json:"storage,inline"`
 }
 
+// StorageType selects the backend the provider stores the graph in.
 type StorageType struct {
+	// Type is the name of a registered storage backend, e.g. neo4j, spicedb
+	// or memory. Unknown types are reported in the ProviderConfig's status.
 	Type string `json:"type"`
+
+	// Timeout bounds each operation the provider performs on the storage
+	// for a managed resource, such as observing or creating it. Operations
+	// are only bounded by the timeout of the reconcile when it is unset.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// Neo4j configures the neo4j storage backend. It is ignored by every
+	// other backend.
+	// +optional
+	Neo4j *Neo4jStorage `json:"neo4j,omitempty"`
+
+	// GarbageCollection periodically deletes the accounts and roles which
+	// no permission set references any more. Nothing is collected when it
+	// is unset, or the storage backend cannot find orphaned nodes.
+	// +optional
+	GarbageCollection *GarbageCollection `json:"garbageCollection,omitempty"`
+}
+
+// Built-in storage backend types.
+const (
+	StorageTypeNeo4j   = "neo4j"
+	StorageTypeSpiceDB = "spicedb"
+	StorageTypeMemory  = "memory"
+)
+
+// GarbageCollection configures how the nodes of the graph which nothing
+// references are deleted.
+type GarbageCollection struct {
+	// Interval is how often the graph is searched for orphaned nodes.
+	// +kubebuilder:default="1h"
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+
+	// Retention is how long a node must have been orphaned before it is
+	// deleted, giving a permission set time to reference it again.
+	// +kubebuilder:default="24h"
+	// +optional
+	Retention *metav1.Duration `json:"retention,omitempty"`
+
+	// DryRun reports the orphaned nodes which would be deleted as events
+	// of the ProviderConfig, without deleting them. A dry run does not
+	// change the storage, so it only finds the nodes which garbage
+	// collection marked orphaned while it was not a dry run.
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// Neo4jStorage configures the neo4j storage backend.
+type Neo4jStorage struct {
+	// IDStrategy decides how the uuid of a new node is generated: by the
+	// provider (Go), by Neo4j's built-in randomUUID() (Native), or by
+	// apoc.create.uuid() (APOC), which requires the APOC plugin.
+	// +kubebuilder:validation:Enum=Go;Native;APOC
+	// +kubebuilder:default=Go
+	// +optional
+	IDStrategy string `json:"idStrategy,omitempty"`
+
+	// Database is the name of the database the graph is stored in. The
+	// default database of the server is used when it is unset.
+	// +optional
+	Database string `json:"database,omitempty"`
+
+	// Routing decides whether queries are routed across the members of a
+	// cluster (Routing, the neo4j:// scheme) or sent to the single server
+	// addressed by the credentials (Direct, the bolt:// scheme). The scheme
+	// of the URI in the credentials is used when it is unset.
+	// +kubebuilder:validation:Enum=Routing;Direct
+	// +optional
+	Routing string `json:"routing,omitempty"`
+
+	// Auth decides how the provider authenticates to Neo4j: with the
+	// databaseUsername and databasePassword of the credentials (Basic),
+	// their token (Bearer), their base64 encoded kerberosTicket (Kerberos),
+	// or not at all (None).
+	// +kubebuilder:validation:Enum=Basic;Bearer;Kerberos;None
+	// +kubebuilder:default=Basic
+	// +optional
+	Auth string `json:"auth,omitempty"`
+
+	// TLS encrypts connections to Neo4j. Connections are only encrypted if
+	// the scheme of the URI in the credentials asks for it when it is unset.
+	// +optional
+	TLS *Neo4jTLS `json:"tls,omitempty"`
+
+	// ConnectionPool configures the connections the provider keeps open
+	// to Neo4j.
+	// +optional
+	ConnectionPool *Neo4jConnectionPool `json:"connectionPool,omitempty"`
+}
+
+// ID strategies of the neo4j storage backend.
+const (
+	IDStrategyGo     = "Go"
+	IDStrategyNative = "Native"
+	IDStrategyAPOC   = "APOC"
+)
+
+// Routing modes of the neo4j storage backend.
+const (
+	RoutingRouting = "Routing"
+	RoutingDirect  = "Direct"
+)
+
+// Authentication schemes of the neo4j storage backend.
+const (
+	AuthBasic    = "Basic"
+	AuthBearer   = "Bearer"
+	AuthKerberos = "Kerberos"
+	AuthNone     = "None"
+)
+
+// Neo4jTLS configures how connections to Neo4j are encrypted.
+type Neo4jTLS struct {
+	// CABundleSecretRef references a key of a secret holding the PEM
+	// encoded certificates of the authorities the certificate of the server
+	// is verified against. The system's authorities are used when it is
+	// unset.
+	// +optional
+	CABundleSecretRef *xpv1.SecretKeySelector `json:"caBundleSecretRef,omitempty"`
+
+	// InsecureSkipVerify accepts any certificate the server presents, such
+	// as a self-signed one.
+	// +optional
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+}
+
+// Neo4jConnectionPool configures the connections the provider keeps open to
+// Neo4j. The defaults of the driver apply to every field which is unset.
+type Neo4jConnectionPool struct {
+	// MaxSize is the most connections kept open to each server.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxSize *int `json:"maxSize,omitempty"`
+
+	// MaxLifetime is how long a connection is kept open before it is
+	// replaced.
+	// +optional
+	MaxLifetime *metav1.Duration `json:"maxLifetime,omitempty"`
+
+	// AcquisitionTimeout is how long an operation waits for a connection
+	// when every one is in use.
+	// +optional
+	AcquisitionTimeout *metav1.Duration `json:"acquisitionTimeout,omitempty"`
 }
 
 type ProviderCredentials struct {
@@ -56,6 +203,12 @@ type ProviderConfigStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 	xpv1.ProviderConfigStatus `json:",inline"`
+
+	// SchemaVersion is the version of the schema the provider last applied
+	// to the ProviderConfig's storage. It is unset for storage without a
+	// schema managed by the provider.
+	// +optional
+	SchemaVersion int `json:"schemaVersion,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -64,6 +217,7 @@ type ProviderConfigStatus struct {
 // A ProviderConfig configures a Template provider.
 // +kubebuilder:subresource:status
 // +kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:printcolumn:name="SCHEMA",type="integer",JSONPath=".status.schemaVersion"
 // +kubebuilder:printcolumn:name="SECRET-NAME",type="string",JSONPath=".spec.credentials.secretRef.name",priority=1
 // +kubebuilder:resource:scope=Cluster
 type ProviderConfig struct {
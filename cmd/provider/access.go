@@ -0,0 +1,133 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+
+	"github.com/VariableExp0rt/powerbroker/apis"
+	apisv1alpha1 "github.com/VariableExp0rt/powerbroker/apis/v1alpha1"
+	"github.com/VariableExp0rt/powerbroker/internal/api"
+	"github.com/VariableExp0rt/powerbroker/internal/storage"
+)
+
+// errNotPersistent is returned for storage which the command cannot read.
+const errNotPersistent = "storage type %q is not persistent, so only the provider can read it"
+
+// runWhoCanAccess runs the who-can-access command against the API server of
+// the current kubeconfig.
+func runWhoCanAccess(ctx context.Context, providerConfig, cloud, account, role string, explain bool, w io.Writer) error {
+	cfg, err := ctrl.GetConfig()
+	if err != nil {
+		return errors.Wrap(err, "cannot get API server rest config")
+	}
+
+	s := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(s); err != nil {
+		return errors.Wrap(err, "cannot add Kubernetes APIs to scheme")
+	}
+	if err := apis.AddToScheme(s); err != nil {
+		return errors.Wrap(err, "cannot add APIs to scheme")
+	}
+	kube, err := client.New(cfg, client.Options{Scheme: s})
+	if err != nil {
+		return errors.Wrap(err, "cannot create API server client")
+	}
+
+	return whoCanAccess(ctx, kube, providerConfig, cloud, account, role, explain, w)
+}
+
+// whoCanAccess writes each user who can access the account with the role in
+// the cloud, and the shortest path which grants it, as read from the storage
+// of the named ProviderConfig. If explain is true, it then writes every path
+// which grants each user access, with the managed resource behind each hop.
+func whoCanAccess(ctx context.Context, kube client.Client, providerConfig, cloud, account, role string, explain bool, w io.Writer) error {
+	pc := &apisv1alpha1.ProviderConfig{}
+	if err := kube.Get(ctx, types.NamespacedName{Name: providerConfig}, pc); err != nil {
+		return errors.Wrap(err, "cannot get ProviderConfig")
+	}
+
+	cd := pc.Spec.Credentials
+	data, err := resource.CommonCredentialExtractor(ctx, cd.Source, kube, cd.CommonCredentialSelectors)
+	if err != nil {
+		return errors.Wrap(err, "cannot get credentials")
+	}
+
+	cfg, err := storage.ResolveConfig(ctx, kube, pc, data)
+	if err != nil {
+		return errors.Wrap(err, "cannot resolve storage config")
+	}
+
+	// The memory backend lives in the process of the provider, so this
+	// command would only ever read an empty graph of its own.
+	if cfg.Type == apisv1alpha1.StorageTypeMemory {
+		return errors.Errorf(errNotPersistent, cfg.Type)
+	}
+
+	repo, err := storage.New(cfg)
+	if err != nil {
+		return errors.Wrap(err, "cannot get storage")
+	}
+	if c, ok := repo.(storage.Closer); ok {
+		defer func() { _ = c.Close() }()
+	}
+
+	out, err := api.WhoCanAccess(ctx, repo, kube, cloud, account, role, explain)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "USER\tTEAM\tPERSONA\tPERMISSIONSET\tSCOPE")
+	for _, g := range out.Grants {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", g.UserName, orNone(g.Team), g.Persona, g.PermissionSet, orNone(g.Scope))
+	}
+
+	if err := tw.Flush(); err != nil || !explain {
+		return err
+	}
+
+	for _, g := range out.Grants {
+		fmt.Fprintf(w, "\n%s:\n", g.UserName)
+		for _, p := range g.Paths {
+			fmt.Fprintf(w, "  %s\n", p)
+		}
+	}
+
+	return nil
+}
+
+// orNone returns s, or a dash if s is empty.
+func orNone(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/VariableExp0rt/powerbroker/apis/powerbroker/v1alpha1"
+	apisv1alpha1 "github.com/VariableExp0rt/powerbroker/apis/v1alpha1"
+	"github.com/VariableExp0rt/powerbroker/internal/service"
+	"github.com/VariableExp0rt/powerbroker/internal/storage"
+	"github.com/VariableExp0rt/powerbroker/internal/storage/memory"
+)
+
+// storageTypeTest is a persistent backend as far as the command can tell,
+// which is backed by graph.
+const storageTypeTest = "who-can-access-test"
+
+var graph = memory.New()
+
+func init() {
+	storage.Register(storageTypeTest, func(storage.Config) (service.Repository, error) {
+		return graph, nil
+	})
+}
+
+// providerConfig returns a client which gets a ProviderConfig with storage of
+// the supplied type and no credentials.
+func providerConfig(storageType string) client.Client {
+	return &test.MockClient{
+		MockGet: func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+			pc, ok := obj.(*apisv1alpha1.ProviderConfig)
+			if !ok {
+				return errors.New("not a ProviderConfig")
+			}
+			pc.SetName("default")
+			pc.Spec.Credentials.Source = xpv1.CredentialsSourceNone
+			pc.Spec.Storage.Type = storageType
+			return nil
+		},
+		MockList: test.NewMockListFn(nil),
+	}
+}
+
+func TestWhoCanAccess(t *testing.T) {
+	ctx := context.Background()
+
+	ps, err := graph.CreatePermissionSet(ctx, "ps-uid", "admins", []v1alpha1.AccountRoleBinding{
+		{Cloud: v1alpha1.CloudAWS, Account: "123456789012", RoleName: "Administrator"},
+	})
+	if err != nil {
+		t.Fatalf("CreatePermissionSet(...): %v", err)
+	}
+	persona, err := graph.CreatePersona(ctx, "persona-uid", "operator", []string{ps})
+	if err != nil {
+		t.Fatalf("CreatePersona(...): %v", err)
+	}
+	if _, err := graph.CreateUser(ctx, "user-uid", "alice", []string{persona}); err != nil {
+		t.Fatalf("CreateUser(...): %v", err)
+	}
+
+	type want struct {
+		out string
+		err error
+	}
+
+	cases := map[string]struct {
+		kube    client.Client
+		cloud   string
+		explain bool
+		want    want
+	}{
+		"Granted": {
+			kube:  providerConfig(storageTypeTest),
+			cloud: v1alpha1.CloudAWS,
+			want: want{
+				out: "USER   TEAM  PERSONA   PERMISSIONSET  SCOPE\n" +
+					"alice  -     operator  admins         -\n",
+			},
+		},
+		"Explained": {
+			kube:    providerConfig(storageTypeTest),
+			cloud:   v1alpha1.CloudAWS,
+			explain: true,
+			want: want{
+				out: "USER   TEAM  PERSONA   PERMISSIONSET  SCOPE\n" +
+					"alice  -     operator  admins         -\n" +
+					"\nalice:\n" +
+					"  User alice → Persona operator → PermissionSet admins → Account 123456789012/Role Administrator\n",
+			},
+		},
+		"OtherCloud": {
+			kube:  providerConfig(storageTypeTest),
+			cloud: v1alpha1.CloudGCP,
+			want: want{
+				out: "USER  TEAM  PERSONA  PERMISSIONSET  SCOPE\n",
+			},
+		},
+		"NotPersistent": {
+			kube:  providerConfig(apisv1alpha1.StorageTypeMemory),
+			cloud: v1alpha1.CloudAWS,
+			want: want{
+				err: errors.Errorf(errNotPersistent, apisv1alpha1.StorageTypeMemory),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := whoCanAccess(ctx, tc.kube, "default", tc.cloud, "123456789012", "Administrator", tc.explain, &out)
+
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("whoCanAccess(...): -want error, +got error:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.out, out.String()); diff != "" {
+				t.Errorf("whoCanAccess(...): -want output, +got output:\n%s", diff)
+			}
+		})
+	}
+}
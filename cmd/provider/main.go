@@ -17,6 +17,7 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"time"
@@ -26,10 +27,14 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	crplctrl "github.com/crossplane/crossplane-runtime/pkg/controller"
+	"github.com/crossplane/crossplane-runtime/pkg/feature"
 	"github.com/crossplane/crossplane-runtime/pkg/logging"
 
 	"github.com/VariableExp0rt/powerbroker/apis"
+	"github.com/VariableExp0rt/powerbroker/internal/api"
 	"github.com/VariableExp0rt/powerbroker/internal/controller"
+	"github.com/VariableExp0rt/powerbroker/internal/controller/features"
+	"github.com/VariableExp0rt/powerbroker/internal/storage"
 )
 
 func main() {
@@ -37,8 +42,24 @@ func main() {
 		app        = kingpin.New(filepath.Base(os.Args[0]), "support for Crossplane.").DefaultEnvars()
 		debug      = app.Flag("debug", "Run with debug logging.").Short('d').Bool()
 		syncPeriod = app.Flag("sync", "Controller manager sync period such as 300ms, 1.5h, or 2h45m").Short('s').Default("1h").Duration()
+
+		enableManagementPolicies = app.Flag("enable-management-policies", "Enable support for the management policies of Users and Personas.").Default("false").Bool()
+		readAPIAddress           = app.Flag("read-api-address", "Address to serve the read API on, such as :8090. The read API is not served if it is empty.").String()
+
+		_              = app.Command("start", "Start the provider's controllers.").Default()
+		who            = app.Command("who-can-access", "List the users who can access an account with a role, and why.")
+		providerConfig = who.Flag("provider-config", "Name of the ProviderConfig whose storage to read.").Default("default").String()
+		cloud          = who.Flag("cloud", "Cloud the account and role are in.").Default("aws").Enum("aws", "gcp", "azure", "kubernetes")
+		explain        = who.Flag("explain", "Also list every path which grants each user access, with the managed resource behind each hop.").Bool()
+		account        = who.Arg("account", "ID of the account at its cloud provider.").Required().String()
+		role           = who.Arg("role", "Name of the role.").Required().String()
 	)
-	kingpin.MustParse(app.Parse(os.Args[1:]))
+	cmd := kingpin.MustParse(app.Parse(os.Args[1:]))
+
+	if cmd == who.FullCommand() {
+		kingpin.FatalIfError(runWhoCanAccess(context.Background(), *providerConfig, *cloud, *account, *role, *explain, os.Stdout), "Cannot list who can access account")
+		return
+	}
 
 	zl := zap.New(zap.UseDevMode(*debug))
 	log := logging.NewLogrLogger(zl.WithName("powerbroker"))
@@ -58,6 +79,16 @@ func main() {
 	kingpin.FatalIfError(err, "Cannot create controller manager")
 
 	kingpin.FatalIfError(apis.AddToScheme(mgr.GetScheme()), "Cannot add APIs to scheme")
-	kingpin.FatalIfError(controller.Setup(mgr, crplctrl.Options{Logger: log, PollInterval: time.Minute}), "Cannot setup controllers")
+	o := crplctrl.Options{Logger: log, PollInterval: time.Minute, Features: &feature.Flags{}}
+	if *enableManagementPolicies {
+		o.Features.Enable(features.EnableAlphaManagementPolicies)
+		log.Info("Alpha feature enabled", "flag", features.EnableAlphaManagementPolicies)
+	}
+
+	kingpin.FatalIfError(controller.Setup(mgr, o), "Cannot setup controllers")
+	if *readAPIAddress != "" {
+		kingpin.FatalIfError(mgr.Add(api.NewServer(*readAPIAddress, mgr.GetClient(), storage.DefaultPool)), "Cannot add read API")
+		log.Info("Serving read API", "address", *readAPIAddress)
+	}
 	kingpin.FatalIfError(mgr.Start(ctrl.SetupSignalHandler()), "Cannot start controller manager")
 }
@@ -0,0 +1,279 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package api serves read-only queries of the storage of each
+// ProviderConfig over HTTP.
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+
+	"github.com/VariableExp0rt/powerbroker/apis/powerbroker/v1alpha1"
+	apisv1alpha1 "github.com/VariableExp0rt/powerbroker/apis/v1alpha1"
+	"github.com/VariableExp0rt/powerbroker/internal/service"
+	accesssvc "github.com/VariableExp0rt/powerbroker/internal/service/access"
+	accountsvc "github.com/VariableExp0rt/powerbroker/internal/service/account"
+	"github.com/VariableExp0rt/powerbroker/internal/storage"
+)
+
+// PathWhoCanAccess is the path of the who-can-access query.
+const PathWhoCanAccess = "/v1alpha1/who-can-access"
+
+const (
+	errGetPC         = "cannot get ProviderConfig"
+	errGetCreds      = "cannot get credentials"
+	errResolveConfig = "cannot resolve storage config"
+	errGetStorage    = "cannot get storage"
+	errWhoCanAccess  = "cannot find who can access account"
+	errExplain       = "cannot explain access of user %s"
+	errMissingParam  = "the %s parameter is required"
+	errUnknownCloud  = "unknown cloud %q"
+	errExplainParam  = "the explain parameter must be a boolean"
+)
+
+// shutdownTimeout bounds waiting for the queries in flight once the Server
+// is stopped.
+const shutdownTimeout = 10 * time.Second
+
+// clouds are the clouds an account and role may be in.
+var clouds = map[string]bool{
+	v1alpha1.CloudAWS:        true,
+	v1alpha1.CloudGCP:        true,
+	v1alpha1.CloudAzure:      true,
+	v1alpha1.CloudKubernetes: true,
+}
+
+// A WhoCanAccessResponse lists each user who can access an account with a
+// role in a cloud.
+type WhoCanAccessResponse struct {
+	Cloud   string  `json:"cloud"`
+	Account string  `json:"account"`
+	Role    string  `json:"role"`
+	Grants  []Grant `json:"grants"`
+}
+
+// A Grant is a user who can access an account with a role, and the shortest
+// path which grants it. Paths lists every path which grants the user access,
+// with the managed resource behind each hop, if the query asked for them.
+type Grant struct {
+	User          string   `json:"user"`
+	UserName      string   `json:"userName"`
+	Team          string   `json:"team,omitempty"`
+	Persona       string   `json:"persona"`
+	PermissionSet string   `json:"permissionSet"`
+	Scope         string   `json:"scope,omitempty"`
+	Paths         []string `json:"paths,omitempty"`
+}
+
+// WhoCanAccess returns each user who can access the account with the role in
+// the cloud as read from repo, with the shortest path which grants it. If
+// explain is true, each grant also lists every path which grants the user
+// access, with the managed resource read by kube behind each hop.
+func WhoCanAccess(ctx context.Context, repo service.Repository, kube client.Reader, cloud, account, role string, explain bool) (*WhoCanAccessResponse, error) {
+	grants, err := accountsvc.NewService(repo).WhoCanAccess(ctx, cloud, account, role)
+	if err != nil {
+		return nil, errors.Wrap(err, errWhoCanAccess)
+	}
+
+	out := &WhoCanAccessResponse{Cloud: cloud, Account: account, Role: role, Grants: make([]Grant, 0, len(grants))}
+	for _, g := range grants {
+		out.Grants = append(out.Grants, Grant{
+			User:          g.User,
+			UserName:      g.UserName,
+			Team:          g.Team,
+			Persona:       g.Persona,
+			PermissionSet: g.PermissionSet,
+			Scope:         g.Scope,
+		})
+	}
+
+	if !explain {
+		return out, nil
+	}
+
+	explainer := accesssvc.NewService(repo, kube)
+	for i, g := range out.Grants {
+		paths, err := explainer.ExplainAccess(ctx, g.User, cloud, account, role)
+		if err != nil {
+			return nil, errors.Wrapf(err, errExplain, g.UserName)
+		}
+		for _, p := range paths {
+			out.Grants[i].Paths = append(out.Grants[i].Paths, p.String())
+		}
+	}
+
+	return out, nil
+}
+
+// A Server answers read-only queries of the storage of each ProviderConfig,
+// which it reads through the same Pool as the controllers. It is a
+// manager.Runnable, so that it is served for as long as the controllers run.
+type Server struct {
+	addr string
+	kube client.Client
+	pool *storage.Pool
+}
+
+// NewServer returns a Server which listens on addr, and reads
+// ProviderConfigs and managed resources with the supplied client.
+func NewServer(addr string, kube client.Client, pool *storage.Pool) *Server {
+	return &Server{addr: addr, kube: kube, pool: pool}
+}
+
+// Handler returns the handler of every query the Server answers.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(PathWhoCanAccess, s.whoCanAccess)
+
+	return mux
+}
+
+// Start serves queries until ctx is done, then waits for those in flight.
+func (s *Server) Start(ctx context.Context) error {
+	srv := &http.Server{Addr: s.addr, Handler: s.Handler(), ReadHeaderTimeout: 10 * time.Second}
+
+	done := make(chan error, 1)
+	go func() {
+		<-ctx.Done()
+		sctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		done <- srv.Shutdown(sctx)
+	}()
+
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return errors.Wrap(err, "cannot serve read API")
+	}
+
+	return <-done
+}
+
+// NeedLeaderElection returns false, since every replica may answer queries.
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
+
+// whoCanAccess answers the who-can-access query. It takes the account and
+// role as the parameters account and role, and optionally the cloud, which
+// is aws by default, the providerConfig, which is default by default, and
+// explain.
+func (s *Server) whoCanAccess(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	cloud, account, role := orDefault(q.Get("cloud"), v1alpha1.CloudAWS), q.Get("account"), q.Get("role")
+	for name, v := range map[string]string{"account": account, "role": role} {
+		if v == "" {
+			http.Error(w, errors.Errorf(errMissingParam, name).Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if !clouds[cloud] {
+		http.Error(w, errors.Errorf(errUnknownCloud, cloud).Error(), http.StatusBadRequest)
+		return
+	}
+	explain := false
+	if v := q.Get("explain"); v != "" {
+		var err error
+		if explain, err = strconv.ParseBool(v); err != nil {
+			http.Error(w, errExplainParam, http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx := r.Context()
+	repo, timeout, err := s.repository(ctx, orDefault(q.Get("providerConfig"), "default"))
+	if err != nil {
+		http.Error(w, err.Error(), status(err))
+		return
+	}
+
+	ctx, cancel := storage.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	out, err := WhoCanAccess(ctx, repo, s.kube, cloud, account, role, explain)
+	if err != nil {
+		http.Error(w, err.Error(), status(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// repository returns the pooled Repository of the named ProviderConfig, and
+// the timeout which bounds each operation on it.
+func (s *Server) repository(ctx context.Context, providerConfig string) (service.Repository, time.Duration, error) {
+	pc := &apisv1alpha1.ProviderConfig{}
+	if err := s.kube.Get(ctx, types.NamespacedName{Name: providerConfig}, pc); err != nil {
+		return nil, 0, errors.Wrap(err, errGetPC)
+	}
+
+	cd := pc.Spec.Credentials
+	data, err := resource.CommonCredentialExtractor(ctx, cd.Source, s.kube, cd.CommonCredentialSelectors)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, errGetCreds)
+	}
+
+	cfg, err := storage.ResolveConfig(ctx, s.kube, pc, data)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, errResolveConfig)
+	}
+
+	repo, err := s.pool.Get(cfg)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, errGetStorage)
+	}
+
+	return repo, cfg.Timeout, nil
+}
+
+// status returns the HTTP status of a query which failed with err.
+func status(err error) int {
+	switch {
+	case kerrors.IsNotFound(errors.Cause(err)):
+		return http.StatusNotFound
+	case errors.Is(err, storage.ErrCannotExplain):
+		return http.StatusNotImplemented
+	case storage.Unavailable(err):
+		return http.StatusServiceUnavailable
+	}
+
+	return http.StatusInternalServerError
+}
+
+// orDefault returns s, or def if s is empty.
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+
+	return s
+}
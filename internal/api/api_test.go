@@ -0,0 +1,188 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/VariableExp0rt/powerbroker/apis/powerbroker/v1alpha1"
+	apisv1alpha1 "github.com/VariableExp0rt/powerbroker/apis/v1alpha1"
+	"github.com/VariableExp0rt/powerbroker/internal/service"
+	"github.com/VariableExp0rt/powerbroker/internal/storage"
+	"github.com/VariableExp0rt/powerbroker/internal/storage/memory"
+)
+
+// kube returns a client which gets the ProviderConfig named default, with
+// memory storage and no credentials.
+func kube() client.Client {
+	return &test.MockClient{
+		MockGet: func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+			pc, ok := obj.(*apisv1alpha1.ProviderConfig)
+			if !ok {
+				return errors.New("not a ProviderConfig")
+			}
+			if key.Name != "default" {
+				return kerrors.NewNotFound(schema.GroupResource{Resource: "providerconfigs"}, key.Name)
+			}
+			pc.SetName("default")
+			pc.Spec.Credentials.Source = xpv1.CredentialsSourceNone
+			pc.Spec.Storage.Type = apisv1alpha1.StorageTypeMemory
+			return nil
+		},
+		MockList: test.NewMockListFn(nil),
+	}
+}
+
+func TestWhoCanAccess(t *testing.T) {
+	ctx := context.Background()
+
+	graph := memory.New()
+	ps, err := graph.CreatePermissionSet(ctx, "ps-uid", "admins", []v1alpha1.AccountRoleBinding{
+		{Cloud: v1alpha1.CloudAWS, Account: "123456789012", RoleName: "Administrator"},
+	})
+	if err != nil {
+		t.Fatalf("CreatePermissionSet(...): %v", err)
+	}
+	persona, err := graph.CreatePersona(ctx, "persona-uid", "operator", []string{ps})
+	if err != nil {
+		t.Fatalf("CreatePersona(...): %v", err)
+	}
+	user, err := graph.CreateUser(ctx, "user-uid", "alice", []string{persona})
+	if err != nil {
+		t.Fatalf("CreateUser(...): %v", err)
+	}
+
+	pool := storage.NewPool(func(storage.Config) (service.Repository, error) { return graph, nil })
+	srv := httptest.NewServer(NewServer("", kube(), pool).Handler())
+	t.Cleanup(srv.Close)
+
+	type want struct {
+		status int
+		out    *WhoCanAccessResponse
+	}
+
+	cases := map[string]struct {
+		method string
+		query  string
+		want   want
+	}{
+		"Granted": {
+			query: "?account=123456789012&role=Administrator",
+			want: want{
+				status: http.StatusOK,
+				out: &WhoCanAccessResponse{
+					Cloud:   v1alpha1.CloudAWS,
+					Account: "123456789012",
+					Role:    "Administrator",
+					Grants:  []Grant{{User: user, UserName: "alice", Persona: "operator", PermissionSet: "admins"}},
+				},
+			},
+		},
+		"Explained": {
+			query: "?account=123456789012&role=Administrator&explain=true",
+			want: want{
+				status: http.StatusOK,
+				out: &WhoCanAccessResponse{
+					Cloud:   v1alpha1.CloudAWS,
+					Account: "123456789012",
+					Role:    "Administrator",
+					Grants: []Grant{{
+						User:          user,
+						UserName:      "alice",
+						Persona:       "operator",
+						PermissionSet: "admins",
+						Paths:         []string{"User alice → Persona operator → PermissionSet admins → Account 123456789012/Role Administrator"},
+					}},
+				},
+			},
+		},
+		"OtherCloud": {
+			query: "?cloud=gcp&account=123456789012&role=Administrator",
+			want: want{
+				status: http.StatusOK,
+				out:    &WhoCanAccessResponse{Cloud: v1alpha1.CloudGCP, Account: "123456789012", Role: "Administrator", Grants: []Grant{}},
+			},
+		},
+		"MissingRole": {
+			query: "?account=123456789012",
+			want:  want{status: http.StatusBadRequest},
+		},
+		"UnknownCloud": {
+			query: "?cloud=openstack&account=123456789012&role=Administrator",
+			want:  want{status: http.StatusBadRequest},
+		},
+		"InvalidExplain": {
+			query: "?account=123456789012&role=Administrator&explain=maybe",
+			want:  want{status: http.StatusBadRequest},
+		},
+		"UnknownProviderConfig": {
+			query: "?providerConfig=other&account=123456789012&role=Administrator",
+			want:  want{status: http.StatusNotFound},
+		},
+		"NotGet": {
+			method: http.MethodPost,
+			query:  "?account=123456789012&role=Administrator",
+			want:   want{status: http.StatusMethodNotAllowed},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			method := tc.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req, err := http.NewRequestWithContext(ctx, method, srv.URL+PathWhoCanAccess+tc.query, nil)
+			if err != nil {
+				t.Fatalf("NewRequest(...): %v", err)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Do(...): %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tc.want.status {
+				t.Fatalf("%s %s: want status %d, got %d", method, tc.query, tc.want.status, resp.StatusCode)
+			}
+			if tc.want.out == nil {
+				return
+			}
+
+			got := &WhoCanAccessResponse{}
+			if err := json.NewDecoder(resp.Body).Decode(got); err != nil {
+				t.Fatalf("Decode(...): %v", err)
+			}
+			if diff := cmp.Diff(tc.want.out, got); diff != "" {
+				t.Errorf("%s %s: -want, +got:\n%s", method, tc.query, diff)
+			}
+		})
+	}
+}
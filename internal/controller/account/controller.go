@@ -0,0 +1,299 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package account
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	v1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/connection"
+	"github.com/crossplane/crossplane-runtime/pkg/controller"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+
+	"github.com/VariableExp0rt/powerbroker/apis/powerbroker/v1alpha1"
+	apisv1alpha1 "github.com/VariableExp0rt/powerbroker/apis/v1alpha1"
+	"github.com/VariableExp0rt/powerbroker/internal/controller/drift"
+	"github.com/VariableExp0rt/powerbroker/internal/controller/features"
+	svc "github.com/VariableExp0rt/powerbroker/internal/service"
+	accountsvc "github.com/VariableExp0rt/powerbroker/internal/service/account"
+	pwrbrkrtypes "github.com/VariableExp0rt/powerbroker/internal/service/types"
+	storage "github.com/VariableExp0rt/powerbroker/internal/storage"
+	storetypes "github.com/VariableExp0rt/powerbroker/internal/storage/types"
+)
+
+const (
+	errNotAccount   = "managed resource is not an Account custom resource"
+	errTrackPCUsage = "cannot track ProviderConfig usage"
+	errGetPC        = "cannot get ProviderConfig"
+	errGetCreds     = "cannot get credentials"
+	errNewService   = "cannot create new service client"
+	errLookup       = "cannot look up account by managed resource UID"
+	errImmutableID  = "id is immutable"
+)
+
+var _ Connector = &connectorHelper{}
+
+// Setup adds a controller that reconciles Account managed resources.
+func Setup(mgr ctrl.Manager, o controller.Options) error {
+	name := managed.ControllerName(v1alpha1.AccountGroupKind)
+
+	cps := []managed.ConnectionPublisher{managed.NewAPISecretPublisher(mgr.GetClient(), mgr.GetScheme())}
+	if o.Features.Enabled(features.EnableAlphaExternalSecretStores) {
+		cps = append(cps, connection.NewDetailsManager(mgr.GetClient(), apisv1alpha1.StoreConfigGroupVersionKind))
+	}
+
+	recorder := event.NewAPIRecorder(mgr.GetEventRecorderFor(name))
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(name).
+		WithOptions(o.ForControllerRuntime()).
+		For(&v1alpha1.Account{}).
+		Complete(managed.NewReconciler(mgr,
+			resource.ManagedKind(v1alpha1.AccountGroupVersionKind),
+			managed.WithExternalConnecter(&connector{
+				kube:  mgr.GetClient(),
+				usage: resource.NewProviderConfigUsageTracker(mgr.GetClient(), &apisv1alpha1.ProviderConfigUsage{}),
+				util:  &connectorHelper{},
+			}),
+			managed.WithCreationGracePeriod(10*time.Second),
+			managed.WithInitializers(managed.NewDefaultProviderConfig(mgr.GetClient())),
+			managed.WithLogger(o.Logger.WithValues("controller", name)),
+			managed.WithRecorder(recorder),
+			managed.WithPollInterval(o.PollInterval),
+			managed.WithConnectionPublishers(cps...)))
+}
+
+type Connector interface {
+	GetService(svc.Repository) accountsvc.Service
+	ExtractCredentials(context.Context, v1.CredentialsSource, client.Client, v1.CommonCredentialSelectors) ([]byte, error)
+}
+
+type connectorHelper struct{}
+
+func (c *connectorHelper) GetService(repo svc.Repository) accountsvc.Service {
+	return accountsvc.NewService(repo)
+}
+
+func (c *connectorHelper) ExtractCredentials(ctx context.Context, source v1.CredentialsSource, kube client.Client, selector v1.CommonCredentialSelectors) ([]byte, error) {
+	return resource.CommonCredentialExtractor(ctx, source, kube, selector)
+}
+
+type connector struct {
+	kube  client.Client
+	usage resource.Tracker
+	util  Connector
+}
+
+func (c *connector) Connect(ctx context.Context, mg resource.Managed) (managed.ExternalClient, error) {
+	cr, ok := mg.(*v1alpha1.Account)
+	if !ok {
+		return nil, errors.New(errNotAccount)
+	}
+
+	if err := c.usage.Track(ctx, mg); err != nil {
+		return nil, errors.New(errTrackPCUsage)
+	}
+
+	pc := &apisv1alpha1.ProviderConfig{}
+	if err := c.kube.Get(ctx, types.NamespacedName{Name: cr.GetProviderConfigReference().Name}, pc); err != nil {
+		return nil, errors.Wrap(err, errGetPC)
+	}
+
+	cd := pc.Spec.Credentials
+	data, err := c.util.ExtractCredentials(ctx, cd.Source, c.kube, cd.CommonCredentialSelectors)
+	if err != nil {
+		return nil, errors.Wrap(err, errGetCreds)
+	}
+
+	cfg, err := storage.ResolveConfig(ctx, c.kube, pc, data)
+	if err != nil {
+		return nil, errors.Wrap(err, errGetCreds)
+	}
+
+	store, err := storage.DefaultPool.Get(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, errNewService)
+	}
+
+	return &external{service: c.util.GetService(store), timeout: cfg.Timeout}, nil
+}
+
+type external struct {
+	service accountsvc.Service
+
+	// timeout bounds each operation on the storage. Operations are
+	// unbounded when it is zero.
+	timeout time.Duration
+}
+
+func (e *external) Observe(ctx context.Context, mg resource.Managed) (managed.ExternalObservation, error) {
+	cr, ok := mg.(*v1alpha1.Account)
+	if !ok {
+		return managed.ExternalObservation{}, errors.New(errNotAccount)
+	}
+
+	ctx, cancel := storage.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	ctx = storetypes.WithResource(ctx, string(cr.GetUID()))
+
+	var adopted bool
+	if meta.GetExternalName(cr) == "" {
+		var err error
+		if adopted, err = e.adopt(ctx, cr); err != nil || !adopted {
+			return managed.ExternalObservation{ResourceExists: false}, err
+		}
+	}
+	ext := meta.GetExternalName(cr)
+
+	// An account without a cloud is in aws, which is what the store returns.
+	desired := cr.Spec.ForProvider
+	desired.Cloud = pwrbrkrtypes.DefaultCloud(desired.Cloud)
+
+	resp, err := e.service.GetAccount(ctx, desired.Cloud, ext)
+	if err != nil {
+		setUnavailable(cr, err)
+		return managed.ExternalObservation{}, errors.Wrap(
+			resource.Ignore(storetypes.IsEntityNotFoundNeo4jErr, err),
+			"cannot get account")
+	}
+
+	cr.Status.AtProvider = generateAccountObservation(resp)
+
+	diff := drift.Diff(desired, resp.Account)
+
+	return postObserve(cr, managed.ExternalObservation{
+		ResourceExists:          true,
+		ResourceLateInitialized: adopted,
+		ResourceUpToDate:        diff == "",
+		Diff:                    diff,
+	}, err)
+}
+
+func (e *external) Create(ctx context.Context, mg resource.Managed) (managed.ExternalCreation, error) {
+	cr, ok := mg.(*v1alpha1.Account)
+	if !ok {
+		return managed.ExternalCreation{}, errors.New(errNotAccount)
+	}
+
+	ctx, cancel := storage.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	ctx = storetypes.WithResource(ctx, string(cr.GetUID()))
+
+	cr.SetConditions(v1.Creating())
+	id, err := e.service.CreateAccount(ctx, string(cr.GetUID()), &cr.Spec.ForProvider)
+
+	return postCreate(cr, managed.ExternalCreation{ExternalNameAssigned: true}, id, err)
+}
+
+func (e *external) Update(ctx context.Context, mg resource.Managed) (managed.ExternalUpdate, error) {
+	cr, ok := mg.(*v1alpha1.Account)
+	if !ok {
+		return managed.ExternalUpdate{}, errors.New(errNotAccount)
+	}
+
+	// The id of an account is its external name, so an Account can never
+	// move to another account.
+	if cr.Spec.ForProvider.ID != meta.GetExternalName(cr) {
+		return managed.ExternalUpdate{}, errors.New(errImmutableID)
+	}
+
+	ctx, cancel := storage.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	ctx = storetypes.WithResource(ctx, string(cr.GetUID()))
+
+	err := e.service.UpdateAccount(ctx, pwrbrkrtypes.DefaultCloud(cr.Spec.ForProvider.Cloud), meta.GetExternalName(cr), &cr.Spec.ForProvider)
+
+	return managed.ExternalUpdate{}, errors.Wrap(err, "cannot update account")
+}
+
+func (e *external) Delete(ctx context.Context, mg resource.Managed) error {
+	cr, ok := mg.(*v1alpha1.Account)
+	if !ok {
+		return errors.New(errNotAccount)
+	}
+
+	ctx, cancel := storage.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	ctx = storetypes.WithResource(ctx, string(cr.GetUID()))
+
+	cr.SetConditions(v1.Deleting())
+	err := e.service.DeleteAccount(ctx, pwrbrkrtypes.DefaultCloud(cr.Spec.ForProvider.Cloud), meta.GetExternalName(cr))
+
+	return errors.Wrap(resource.Ignore(storetypes.IsEntityNotFoundNeo4jErr, err), "cannot delete account")
+}
+
+// adopt sets the external name of an Account which has none to the id of the
+// account it manages, if any. This happens when the provider fails to record
+// the external name after creating the account.
+func (e *external) adopt(ctx context.Context, cr *v1alpha1.Account) (bool, error) {
+	id, err := e.service.LookupAccount(ctx, string(cr.GetUID()))
+	if err != nil {
+		setUnavailable(cr, err)
+		return false, errors.Wrap(resource.Ignore(storetypes.IsEntityNotFoundNeo4jErr, err), errLookup)
+	}
+
+	meta.SetExternalName(cr, id)
+	return true, nil
+}
+
+// setUnavailable marks an Account Unavailable if err means its storage cannot
+// be used. The Account then reports why it is not ready, as well as that it
+// failed to reconcile.
+func setUnavailable(cr *v1alpha1.Account, err error) {
+	if storage.Unavailable(err) {
+		cr.SetConditions(v1.Unavailable().WithMessage(err.Error()))
+	}
+}
+
+func generateAccountObservation(r *pwrbrkrtypes.GetAccountResponse) v1alpha1.AccountObservation {
+	return v1alpha1.AccountObservation{
+		NodeID:      r.NodeID,
+		Status:      string(r.Status),
+		Alias:       r.Account.Alias,
+		Class:       r.Account.Class,
+		Owner:       r.Account.Owner,
+		Environment: r.Account.Environment,
+		Lifecycle:   r.Account.Lifecycle,
+	}
+}
+
+func postObserve(cr *v1alpha1.Account, obs managed.ExternalObservation, err error) (managed.ExternalObservation, error) {
+	if err != nil {
+		return managed.ExternalObservation{}, err
+	}
+
+	cr.SetConditions(v1.Available())
+	return obs, nil
+}
+
+func postCreate(cr *v1alpha1.Account, ec managed.ExternalCreation, id string, err error) (managed.ExternalCreation, error) {
+	if err != nil {
+		return managed.ExternalCreation{}, errors.Wrap(err, "cannot create account")
+	}
+
+	meta.SetExternalName(cr, id)
+	return ec, nil
+}
@@ -0,0 +1,455 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package account
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+
+	v1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/VariableExp0rt/powerbroker/apis/powerbroker/v1alpha1"
+	"github.com/VariableExp0rt/powerbroker/internal/controller/drift"
+	"github.com/VariableExp0rt/powerbroker/internal/service"
+	"github.com/VariableExp0rt/powerbroker/internal/service/types"
+	"github.com/VariableExp0rt/powerbroker/internal/storage/neo4j/transaction"
+	storetypes "github.com/VariableExp0rt/powerbroker/internal/storage/types"
+)
+
+var _ managed.ExternalClient = &external{}
+var _ managed.ExternalConnecter = &connector{}
+
+var (
+	externalName = "111111111111"
+	params       = v1alpha1.AccountParameters{
+		Cloud:       v1alpha1.CloudAWS,
+		ID:          externalName,
+		Alias:       "my-aws-account-alias",
+		Class:       "production",
+		Owner:       "platform",
+		Environment: "production",
+		Lifecycle:   v1alpha1.AccountActive,
+	}
+	observation = v1alpha1.AccountObservation{
+		NodeID:      externalName,
+		Status:      transaction.StatusAvailable,
+		Alias:       params.Alias,
+		Class:       params.Class,
+		Owner:       params.Owner,
+		Environment: params.Environment,
+		Lifecycle:   params.Lifecycle,
+	}
+	errInternalServer = &storetypes.InternalError{}
+)
+
+type accountModifier = func(*v1alpha1.Account)
+
+func withConditions(cnds ...v1.Condition) accountModifier {
+	return func(a *v1alpha1.Account) {
+		a.SetConditions(cnds...)
+	}
+}
+
+func withSpec(p v1alpha1.AccountParameters) accountModifier {
+	return func(a *v1alpha1.Account) {
+		a.Spec.ForProvider = p
+	}
+}
+
+func withStatus(o v1alpha1.AccountObservation) accountModifier {
+	return func(a *v1alpha1.Account) {
+		a.Status.AtProvider = o
+	}
+}
+
+func withExternalName(id string) accountModifier {
+	return func(a *v1alpha1.Account) {
+		meta.SetExternalName(a, id)
+	}
+}
+
+func account(opts ...accountModifier) *v1alpha1.Account {
+	a := &v1alpha1.Account{}
+	for _, o := range opts {
+		o(a)
+	}
+
+	return a
+}
+
+type args struct {
+	cr      *v1alpha1.Account
+	service service.Repository
+}
+
+func TestObserve(t *testing.T) {
+	drifted := params
+	drifted.Lifecycle = v1alpha1.AccountSuspended
+
+	type want struct {
+		cr  *v1alpha1.Account
+		o   managed.ExternalObservation
+		err error
+	}
+
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"SuccessfulAvailable": {
+			args: args{
+				cr: account(withExternalName(externalName), withSpec(params)),
+				service: &service.MockRepository{
+					MockGetAccount: func(ctx context.Context, cloud, id string) (*types.GetAccountResponse, error) {
+						return &types.GetAccountResponse{Account: params, Status: "available", NodeID: id}, nil
+					},
+				},
+			},
+			want: want{
+				cr: account(
+					withConditions(v1.Available()),
+					withExternalName(externalName),
+					withSpec(params),
+					withStatus(observation),
+				),
+				o: managed.ExternalObservation{
+					ResourceExists:   true,
+					ResourceUpToDate: true,
+				},
+			},
+		},
+		"DefaultsCloud": {
+			args: args{
+				cr: account(withExternalName(externalName), withSpec(func() v1alpha1.AccountParameters {
+					p := params
+					p.Cloud = ""
+					return p
+				}())),
+				service: &service.MockRepository{
+					MockGetAccount: func(ctx context.Context, cloud, id string) (*types.GetAccountResponse, error) {
+						if cloud != v1alpha1.CloudAWS {
+							return &types.GetAccountResponse{Status: "deleted", NodeID: id}, &storetypes.EntityNotFoundError{}
+						}
+						return &types.GetAccountResponse{Account: params, Status: "available", NodeID: id}, nil
+					},
+				},
+			},
+			want: want{
+				cr: account(
+					withConditions(v1.Available()),
+					withExternalName(externalName),
+					withSpec(func() v1alpha1.AccountParameters {
+						p := params
+						p.Cloud = ""
+						return p
+					}()),
+					withStatus(observation),
+				),
+				o: managed.ExternalObservation{
+					ResourceExists:   true,
+					ResourceUpToDate: true,
+				},
+			},
+		},
+		"AdoptedByUID": {
+			args: args{
+				cr: account(withSpec(params)),
+				service: &service.MockRepository{
+					MockLookupAccount: func(ctx context.Context, uid string) (string, error) {
+						return externalName, nil
+					},
+					MockGetAccount: func(ctx context.Context, cloud, id string) (*types.GetAccountResponse, error) {
+						return &types.GetAccountResponse{Account: params, Status: "available", NodeID: id}, nil
+					},
+				},
+			},
+			want: want{
+				cr: account(
+					withConditions(v1.Available()),
+					withExternalName(externalName),
+					withSpec(params),
+					withStatus(observation),
+				),
+				o: managed.ExternalObservation{
+					ResourceExists:          true,
+					ResourceLateInitialized: true,
+					ResourceUpToDate:        true,
+				},
+			},
+		},
+		"LifecycleDrifted": {
+			args: args{
+				cr: account(withExternalName(externalName), withSpec(params)),
+				service: &service.MockRepository{
+					MockGetAccount: func(ctx context.Context, cloud, id string) (*types.GetAccountResponse, error) {
+						return &types.GetAccountResponse{Account: drifted, Status: "available", NodeID: id}, nil
+					},
+				},
+			},
+			want: want{
+				cr: account(
+					withConditions(v1.Available()),
+					withExternalName(externalName),
+					withSpec(params),
+					withStatus(func() v1alpha1.AccountObservation {
+						o := observation
+						o.Lifecycle = v1alpha1.AccountSuspended
+						return o
+					}()),
+				),
+				o: managed.ExternalObservation{
+					ResourceExists:   true,
+					ResourceUpToDate: false,
+					Diff:             drift.Diff(params, drifted),
+				},
+			},
+		},
+		"NotFoundByUID": {
+			args: args{
+				cr: account(),
+				service: &service.MockRepository{
+					MockLookupAccount: func(ctx context.Context, uid string) (string, error) {
+						return "", &storetypes.EntityNotFoundError{}
+					},
+				},
+			},
+			want: want{
+				cr: account(),
+				o:  managed.ExternalObservation{ResourceExists: false},
+			},
+		},
+		"NotFound": {
+			args: args{
+				cr: account(withExternalName(externalName), withSpec(params)),
+				service: &service.MockRepository{
+					MockGetAccount: func(ctx context.Context, cloud, id string) (*types.GetAccountResponse, error) {
+						return &types.GetAccountResponse{Status: "deleted", NodeID: id}, &storetypes.EntityNotFoundError{}
+					},
+				},
+			},
+			want: want{
+				cr: account(withExternalName(externalName), withSpec(params)),
+				o:  managed.ExternalObservation{ResourceExists: false},
+			},
+		},
+		"GetFailed": {
+			args: args{
+				cr: account(withExternalName(externalName), withSpec(params)),
+				service: &service.MockRepository{
+					MockGetAccount: func(ctx context.Context, cloud, id string) (*types.GetAccountResponse, error) {
+						return nil, errInternalServer
+					},
+				},
+			},
+			want: want{
+				cr:  account(withExternalName(externalName), withSpec(params)),
+				err: errors.Wrap(errInternalServer, "cannot get account"),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			e := &external{service: tc.args.service}
+			o, err := e.Observe(context.Background(), tc.args.cr)
+
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.cr, tc.args.cr, test.EquateConditions()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.o, o); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCreate(t *testing.T) {
+	errManaged := &storetypes.ConflictError{Err: errors.New("account 111111111111 is managed by another Account")}
+
+	type want struct {
+		cr  *v1alpha1.Account
+		o   managed.ExternalCreation
+		err error
+	}
+
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"SuccessfulCreate": {
+			args: args{
+				cr: account(withSpec(params)),
+				service: &service.MockRepository{
+					MockCreateAccount: func(ctx context.Context, uid string, account *v1alpha1.AccountParameters) (string, error) {
+						return account.ID, nil
+					},
+				},
+			},
+			want: want{
+				cr: account(
+					withConditions(v1.Creating()),
+					withExternalName(externalName),
+					withSpec(params),
+				),
+				o: managed.ExternalCreation{ExternalNameAssigned: true},
+			},
+		},
+		"ManagedByAnother": {
+			args: args{
+				cr: account(withSpec(params)),
+				service: &service.MockRepository{
+					MockCreateAccount: func(ctx context.Context, uid string, account *v1alpha1.AccountParameters) (string, error) {
+						return "", errManaged
+					},
+				},
+			},
+			want: want{
+				cr:  account(withConditions(v1.Creating()), withSpec(params)),
+				err: errors.Wrap(errManaged, "cannot create account"),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			e := &external{service: tc.args.service}
+			o, err := e.Create(context.Background(), tc.args.cr)
+
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.cr, tc.args.cr, test.EquateConditions()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.o, o); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	moved := params
+	moved.ID = "222222222222"
+
+	cases := map[string]struct {
+		args args
+		want error
+	}{
+		"SuccessfulUpdate": {
+			args: args{
+				cr: account(withExternalName(externalName), withSpec(params)),
+				service: &service.MockRepository{
+					MockUpdateAccount: func(ctx context.Context, cloud, id string, account *v1alpha1.AccountParameters) error {
+						if cloud != v1alpha1.CloudAWS || id != externalName {
+							t.Errorf("UpdateAccount(...): want account %q in %q, got %q in %q", externalName, v1alpha1.CloudAWS, id, cloud)
+						}
+						return nil
+					},
+				},
+			},
+		},
+		"IDChanged": {
+			args: args{
+				cr:      account(withExternalName(externalName), withSpec(moved)),
+				service: &service.MockRepository{},
+			},
+			want: errors.New(errImmutableID),
+		},
+		"UpdateFailed": {
+			args: args{
+				cr: account(withExternalName(externalName), withSpec(params)),
+				service: &service.MockRepository{
+					MockUpdateAccount: func(ctx context.Context, cloud, id string, account *v1alpha1.AccountParameters) error {
+						return errInternalServer
+					},
+				},
+			},
+			want: errors.Wrap(errInternalServer, "cannot update account"),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			e := &external{service: tc.args.service}
+			_, err := e.Update(context.Background(), tc.args.cr)
+
+			if diff := cmp.Diff(tc.want, err, test.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	cases := map[string]struct {
+		args args
+		want error
+	}{
+		"SuccessfulDelete": {
+			args: args{
+				cr: account(withExternalName(externalName)),
+				service: &service.MockRepository{
+					MockDeleteAccount: func(ctx context.Context, cloud, id string) error {
+						return nil
+					},
+				},
+			},
+		},
+		"AlreadyDeleted": {
+			args: args{
+				cr: account(withExternalName(externalName)),
+				service: &service.MockRepository{
+					MockDeleteAccount: func(ctx context.Context, cloud, id string) error {
+						return &storetypes.EntityNotFoundError{}
+					},
+				},
+			},
+		},
+		"DeleteFailed": {
+			args: args{
+				cr: account(withExternalName(externalName)),
+				service: &service.MockRepository{
+					MockDeleteAccount: func(ctx context.Context, cloud, id string) error {
+						return errInternalServer
+					},
+				},
+			},
+			want: errors.Wrap(errInternalServer, "cannot delete account"),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			e := &external{service: tc.args.service}
+			err := e.Delete(context.Background(), tc.args.cr)
+
+			if diff := cmp.Diff(tc.want, err, test.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+/*
+Copyright 2022 The Crossplane Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/crossplane/crossplane-runtime/pkg/controller"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+
+	"github.com/VariableExp0rt/powerbroker/apis/v1alpha1"
+	"github.com/VariableExp0rt/powerbroker/internal/storage"
+	storetypes "github.com/VariableExp0rt/powerbroker/internal/storage/types"
+)
+
+const (
+	gcTimeout        = 5 * time.Minute
+	defaultInterval  = 1 * time.Hour
+	defaultRetention = 24 * time.Hour
+
+	// maxListed is the most orphans an event names.
+	maxListed = 10
+
+	errMarkOrphans    = "cannot mark orphaned nodes"
+	errFindOrphans    = "cannot find orphaned nodes"
+	errCollectOrphans = "cannot delete orphaned nodes"
+
+	reasonOrphaned  event.Reason = "OrphanedNodes"
+	reasonCollected event.Reason = "CollectedOrphanedNodes"
+	reasonCollect   event.Reason = "GarbageCollection"
+)
+
+// SetupGarbageCollection adds a controller that periodically deletes the
+// nodes of the storage of each ProviderConfig which nothing references.
+func SetupGarbageCollection(mgr ctrl.Manager, o controller.Options) error {
+	name := "gc/" + strings.ToLower(v1alpha1.ProviderConfigGroupKind)
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(name).
+		WithOptions(o.ForControllerRuntime()).
+		For(&v1alpha1.ProviderConfig{}).
+		// Updates of the status of a ProviderConfig do not change how its
+		// storage is collected, and would otherwise collect it each time.
+		WithEventFilter(predicate.GenerationChangedPredicate{}).
+		Complete(NewGarbageCollector(mgr.GetClient(),
+			WithCollectorLogger(o.Logger.WithValues("controller", name)),
+			WithCollectorRecorder(event.NewAPIRecorder(mgr.GetEventRecorderFor(name)))))
+}
+
+// A GarbageCollector deletes the nodes of the storage of a ProviderConfig
+// which nothing references, such as the accounts and roles of deleted
+// permission sets, once they have been orphaned for longer than the
+// retention of the ProviderConfig's garbage collection. Orphans are
+// reported as events of the ProviderConfig. ProviderConfigs without garbage
+// collection, or whose storage cannot find orphans, are ignored.
+type GarbageCollector struct {
+	client client.Client
+	pool   *storage.Pool
+	now    func() time.Time
+
+	log    logging.Logger
+	record event.Recorder
+}
+
+// A GarbageCollectorOption configures a GarbageCollector.
+type GarbageCollectorOption func(*GarbageCollector)
+
+// WithCollectorLogger specifies how the GarbageCollector should log
+// messages.
+func WithCollectorLogger(l logging.Logger) GarbageCollectorOption {
+	return func(r *GarbageCollector) {
+		r.log = l
+	}
+}
+
+// WithCollectorRecorder specifies how the GarbageCollector should record
+// events.
+func WithCollectorRecorder(er event.Recorder) GarbageCollectorOption {
+	return func(r *GarbageCollector) {
+		r.record = er
+	}
+}
+
+// WithCollectorPool specifies the Pool the GarbageCollector should get the
+// storage of ProviderConfigs from.
+func WithCollectorPool(p *storage.Pool) GarbageCollectorOption {
+	return func(r *GarbageCollector) {
+		r.pool = p
+	}
+}
+
+// WithClock specifies how the GarbageCollector should tell the time.
+func WithClock(now func() time.Time) GarbageCollectorOption {
+	return func(r *GarbageCollector) {
+		r.now = now
+	}
+}
+
+// NewGarbageCollector returns a GarbageCollector of ProviderConfigs.
+func NewGarbageCollector(c client.Client, o ...GarbageCollectorOption) *GarbageCollector {
+	r := &GarbageCollector{
+		client: c,
+		pool:   storage.DefaultPool,
+		now:    time.Now,
+		log:    logging.NewNopLogger(),
+		record: event.NewNopRecorder(),
+	}
+
+	for _, ro := range o {
+		ro(r)
+	}
+
+	return r
+}
+
+// Reconcile a ProviderConfig by deleting the orphaned nodes of its storage.
+func (r *GarbageCollector) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	log := r.log.WithValues("request", req)
+	log.Debug("Reconciling")
+
+	ctx, cancel := context.WithTimeout(ctx, gcTimeout)
+	defer cancel()
+
+	pc := &v1alpha1.ProviderConfig{}
+	if err := r.client.Get(ctx, req.NamespacedName, pc); err != nil {
+		// The storage of a deleted ProviderConfig is closed by the
+		// StorageReconciler.
+		return reconcile.Result{}, errors.Wrap(client.IgnoreNotFound(err), errGetPC)
+	}
+
+	gc := pc.Spec.Storage.GarbageCollection
+	if gc == nil || !storage.IsRegistered(pc.Spec.Storage.Type) {
+		return reconcile.Result{}, nil
+	}
+
+	interval, retention := defaultInterval, defaultRetention
+	if gc.Interval != nil {
+		interval = gc.Interval.Duration
+	}
+	if gc.Retention != nil {
+		retention = gc.Retention.Duration
+	}
+
+	repo, err := repository(ctx, r.client, r.pool, pc)
+	if err != nil {
+		log.Debug(errGetStorage, "error", err)
+		r.record.Event(pc, event.Warning(reasonCollect, err))
+		return reconcile.Result{}, err
+	}
+
+	c, ok := repo.(storage.Collector)
+	if !ok {
+		return reconcile.Result{}, nil
+	}
+
+	// A dry run must not change the storage, so it only finds nodes which
+	// garbage collection marked orphaned while it was not a dry run.
+	now := r.now()
+	if !gc.DryRun {
+		if err := c.MarkOrphans(ctx, now); err != nil {
+			log.Debug(errMarkOrphans, "error", err)
+			r.record.Event(pc, event.Warning(reasonCollect, errors.Wrap(err, errMarkOrphans)))
+			return reconcile.Result{}, errors.Wrap(err, errMarkOrphans)
+		}
+	}
+
+	orphans, err := c.Orphans(ctx, now)
+	if err != nil {
+		log.Debug(errFindOrphans, "error", err)
+		r.record.Event(pc, event.Warning(reasonCollect, errors.Wrap(err, errFindOrphans)))
+		return reconcile.Result{}, errors.Wrap(err, errFindOrphans)
+	}
+
+	due := expired(orphans, now, retention)
+	log.Debug("Found orphaned nodes", "orphans", len(orphans), "expired", len(due), "dry-run", gc.DryRun)
+	if len(due) == 0 {
+		return reconcile.Result{RequeueAfter: interval}, nil
+	}
+
+	if gc.DryRun {
+		r.record.Event(pc, event.Normal(reasonOrphaned, fmt.Sprintf("Would delete %d nodes orphaned for longer than %s: %s", len(due), retention, describe(due))))
+		return reconcile.Result{RequeueAfter: interval}, nil
+	}
+
+	collected, err := c.Collect(ctx, due)
+	if len(collected) > 0 {
+		r.record.Event(pc, event.Normal(reasonCollected, fmt.Sprintf("Deleted %d nodes orphaned for longer than %s: %s", len(collected), retention, describe(collected))))
+	}
+	if err != nil {
+		log.Debug(errCollectOrphans, "error", err)
+		r.record.Event(pc, event.Warning(reasonCollect, errors.Wrap(err, errCollectOrphans)))
+		return reconcile.Result{}, errors.Wrap(err, errCollectOrphans)
+	}
+
+	return reconcile.Result{RequeueAfter: interval}, nil
+}
+
+// expired returns the orphans which have been orphaned for at least the
+// supplied retention at now.
+func expired(orphans []storetypes.Orphan, now time.Time, retention time.Duration) []storetypes.Orphan {
+	out := []storetypes.Orphan{}
+	for _, o := range orphans {
+		if !o.Since.Add(retention).After(now) {
+			out = append(out, o)
+		}
+	}
+
+	return out
+}
+
+// describe names the supplied orphans, or the first few of them.
+func describe(orphans []storetypes.Orphan) string {
+	names := make([]string, 0, maxListed)
+	for i, o := range orphans {
+		if i == maxListed {
+			names = append(names, fmt.Sprintf("and %d more", len(orphans)-maxListed))
+			break
+		}
+		names = append(names, o.String())
+	}
+
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,184 @@
+/*
+Copyright 2022 The Crossplane Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/VariableExp0rt/powerbroker/apis/v1alpha1"
+	"github.com/VariableExp0rt/powerbroker/internal/service"
+	"github.com/VariableExp0rt/powerbroker/internal/storage"
+	storetypes "github.com/VariableExp0rt/powerbroker/internal/storage/types"
+)
+
+var errBoom = errors.New("boom")
+
+type collector struct {
+	service.MockRepository
+
+	marked    bool
+	markErr   error
+	orphans   []storetypes.Orphan
+	err       error
+	collected []storetypes.Orphan
+}
+
+func (c *collector) MarkOrphans(context.Context, time.Time) error {
+	c.marked = true
+	return c.markErr
+}
+
+func (c *collector) Orphans(context.Context, time.Time) ([]storetypes.Orphan, error) {
+	return c.orphans, c.err
+}
+
+func (c *collector) Collect(_ context.Context, orphans []storetypes.Orphan) ([]storetypes.Orphan, error) {
+	c.collected = orphans
+	return orphans, nil
+}
+
+type recorder struct {
+	events []event.Event
+}
+
+func (r *recorder) Event(_ runtime.Object, e event.Event) { r.events = append(r.events, e) }
+
+func (r *recorder) WithAnnotations(...string) event.Recorder { return r }
+
+func TestGarbageCollector(t *testing.T) {
+	now := time.Unix(100000, 0)
+	old := storetypes.Orphan{Label: storetypes.OrphanAccount, ID: "111111111111", Since: now.Add(-2 * time.Hour)}
+	recent := storetypes.Orphan{Label: storetypes.OrphanRole, ID: "admin", Since: now.Add(-time.Minute)}
+
+	gc := func(dryRun bool) *v1alpha1.GarbageCollection {
+		return &v1alpha1.GarbageCollection{
+			Interval:  &metav1.Duration{Duration: 10 * time.Minute},
+			Retention: &metav1.Duration{Duration: time.Hour},
+			DryRun:    dryRun,
+		}
+	}
+
+	type want struct {
+		result    reconcile.Result
+		err       error
+		marked    bool
+		collected []storetypes.Orphan
+		reasons   []event.Reason
+	}
+
+	cases := map[string]struct {
+		gc   *v1alpha1.GarbageCollection
+		repo *collector
+		want want
+	}{
+		"Disabled": {
+			repo: &collector{orphans: []storetypes.Orphan{old}},
+			want: want{},
+		},
+		"NothingExpired": {
+			gc:   gc(false),
+			repo: &collector{orphans: []storetypes.Orphan{recent}},
+			want: want{result: reconcile.Result{RequeueAfter: 10 * time.Minute}, marked: true},
+		},
+		"CollectsExpired": {
+			gc:   gc(false),
+			repo: &collector{orphans: []storetypes.Orphan{old, recent}},
+			want: want{
+				result:    reconcile.Result{RequeueAfter: 10 * time.Minute},
+				marked:    true,
+				collected: []storetypes.Orphan{old},
+				reasons:   []event.Reason{reasonCollected},
+			},
+		},
+		"DryRun": {
+			gc:   gc(true),
+			repo: &collector{orphans: []storetypes.Orphan{old, recent}},
+			want: want{
+				result:  reconcile.Result{RequeueAfter: 10 * time.Minute},
+				reasons: []event.Reason{reasonOrphaned},
+			},
+		},
+		"MarkFailed": {
+			gc:   gc(false),
+			repo: &collector{markErr: errBoom, orphans: []storetypes.Orphan{old}},
+			want: want{
+				err:     errors.Wrap(errBoom, errMarkOrphans),
+				marked:  true,
+				reasons: []event.Reason{reasonCollect},
+			},
+		},
+		"OrphansFailed": {
+			gc:   gc(false),
+			repo: &collector{err: errBoom},
+			want: want{
+				err:     errors.Wrap(errBoom, errFindOrphans),
+				marked:  true,
+				reasons: []event.Reason{reasonCollect},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			kube := &test.MockClient{
+				MockGet: func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+					pc := obj.(*v1alpha1.ProviderConfig)
+					pc.SetName("default")
+					pc.Spec.Credentials.Source = xpv1.CredentialsSourceNone
+					pc.Spec.Storage = v1alpha1.StorageType{Type: v1alpha1.StorageTypeMemory, GarbageCollection: tc.gc}
+					return nil
+				},
+			}
+			pool := storage.NewPool(func(storage.Config) (service.Repository, error) { return tc.repo, nil })
+			rec := &recorder{}
+
+			r := NewGarbageCollector(kube, WithCollectorPool(pool), WithCollectorRecorder(rec), WithClock(func() time.Time { return now }))
+			result, err := r.Reconcile(context.Background(), reconcile.Request{})
+
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("Reconcile(...): -want error, +got error:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.result, result); diff != "" {
+				t.Errorf("Reconcile(...): -want, +got:\n%s", diff)
+			}
+			if tc.repo.marked != tc.want.marked {
+				t.Errorf("MarkOrphans(...): want marked %t, got %t", tc.want.marked, tc.repo.marked)
+			}
+			if diff := cmp.Diff(tc.want.collected, tc.repo.collected); diff != "" {
+				t.Errorf("Collect(...): -want, +got:\n%s", diff)
+			}
+			reasons := make([]event.Reason, 0, len(rec.events))
+			for _, e := range rec.events {
+				reasons = append(reasons, e.Reason)
+			}
+			if diff := cmp.Diff(tc.want.reasons, reasons, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("Event(...): -want reasons, +got:\n%s", diff)
+			}
+		})
+	}
+}
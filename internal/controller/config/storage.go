@@ -0,0 +1,279 @@
+/*
+Copyright 2022 The Crossplane Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrlevent "sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/controller"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+
+	"github.com/VariableExp0rt/powerbroker/apis/v1alpha1"
+	"github.com/VariableExp0rt/powerbroker/internal/service"
+	"github.com/VariableExp0rt/powerbroker/internal/storage"
+	storetypes "github.com/VariableExp0rt/powerbroker/internal/storage/types"
+)
+
+const (
+	storageTimeout = 1 * time.Minute
+	unreadyRetry   = 1 * time.Minute
+
+	errGetPC        = "cannot get ProviderConfig"
+	errGetCreds     = "cannot get credentials"
+	errGetStorage   = "cannot get storage"
+	errCapabilities = "cannot check storage capabilities"
+	errEvict        = "cannot close storage of deleted ProviderConfig"
+	errUpdateStatus = "cannot update ProviderConfig status"
+
+	reasonStorage event.Reason = "StorageValidation"
+)
+
+// SetupStorage adds a controller that reconciles ProviderConfigs by
+// validating the storage backend they select, and closing their pooled
+// storage once they are deleted.
+func SetupStorage(mgr ctrl.Manager, o controller.Options) error {
+	name := "storage/" + strings.ToLower(v1alpha1.ProviderConfigGroupKind)
+
+	// Reconcile a ProviderConfig whenever the circuit breaker of its
+	// storage opens or closes, so that its condition reflects it.
+	breakers := make(chan ctrlevent.GenericEvent)
+	storage.WatchBreakers(func(providerConfig string) {
+		pc := &v1alpha1.ProviderConfig{}
+		pc.SetName(providerConfig)
+		go func() { breakers <- ctrlevent.GenericEvent{Object: pc} }()
+	})
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(name).
+		WithOptions(o.ForControllerRuntime()).
+		For(&v1alpha1.ProviderConfig{}).
+		Watches(&source.Channel{Source: breakers}, &handler.EnqueueRequestForObject{}).
+		Complete(NewStorageReconciler(mgr.GetClient(),
+			WithLogger(o.Logger.WithValues("controller", name)),
+			WithRecorder(event.NewAPIRecorder(mgr.GetEventRecorderFor(name)))))
+}
+
+// A StorageReconciler reports whether the storage backend selected by a
+// ProviderConfig is usable in the ProviderConfig's status conditions. A
+// backend is unusable when it is not registered, when calls to it keep
+// failing, when its database lacks procedures or functions the backend
+// requires, or when the schema of its database cannot be migrated. The
+// StorageReconciler also closes the pooled storage of ProviderConfigs once
+// they are deleted.
+type StorageReconciler struct {
+	client client.Client
+	pool   *storage.Pool
+
+	log    logging.Logger
+	record event.Recorder
+}
+
+// A StorageReconcilerOption configures a StorageReconciler.
+type StorageReconcilerOption func(*StorageReconciler)
+
+// WithLogger specifies how the StorageReconciler should log messages.
+func WithLogger(l logging.Logger) StorageReconcilerOption {
+	return func(r *StorageReconciler) {
+		r.log = l
+	}
+}
+
+// WithRecorder specifies how the StorageReconciler should record events.
+func WithRecorder(er event.Recorder) StorageReconcilerOption {
+	return func(r *StorageReconciler) {
+		r.record = er
+	}
+}
+
+// WithPool specifies the Pool the StorageReconciler should evict the storage
+// of deleted ProviderConfigs from.
+func WithPool(p *storage.Pool) StorageReconcilerOption {
+	return func(r *StorageReconciler) {
+		r.pool = p
+	}
+}
+
+// NewStorageReconciler returns a StorageReconciler of ProviderConfigs.
+func NewStorageReconciler(c client.Client, o ...StorageReconcilerOption) *StorageReconciler {
+	r := &StorageReconciler{
+		client: c,
+		pool:   storage.DefaultPool,
+		log:    logging.NewNopLogger(),
+		record: event.NewNopRecorder(),
+	}
+
+	for _, ro := range o {
+		ro(r)
+	}
+
+	return r
+}
+
+// Reconcile a ProviderConfig by validating its storage type.
+func (r *StorageReconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	log := r.log.WithValues("request", req)
+	log.Debug("Reconciling")
+
+	ctx, cancel := context.WithTimeout(ctx, storageTimeout)
+	defer cancel()
+
+	pc := &v1alpha1.ProviderConfig{}
+	err := r.client.Get(ctx, req.NamespacedName, pc)
+	if kerrors.IsNotFound(err) {
+		log.Debug("ProviderConfig was deleted, closing its storage")
+		return reconcile.Result{}, errors.Wrap(r.pool.Evict(req.Name), errEvict)
+	}
+	if err != nil {
+		log.Debug(errGetPC, "error", err)
+		return reconcile.Result{}, errors.Wrap(err, errGetPC)
+	}
+
+	current := pc.GetCondition(v1alpha1.TypeStorageReady)
+	version := pc.Status.SchemaVersion
+
+	var result reconcile.Result
+	t := pc.Spec.Storage.Type
+	if storage.IsRegistered(t) {
+		cond, err := r.ready(ctx, pc)
+		if err != nil {
+			log.Debug(errCapabilities, "error", err)
+			r.record.Event(pc, event.Warning(reasonStorage, err))
+			return reconcile.Result{}, errors.Wrap(err, errCapabilities)
+		}
+		if cond.Status != corev1.ConditionTrue {
+			log.Debug(cond.Message)
+			r.record.Event(pc, event.Warning(reasonStorage, errors.New(cond.Message)))
+		}
+		switch cond.Reason {
+		case v1alpha1.ReasonMigrationFailed, v1alpha1.ReasonUnauthorized:
+			// Neither is fixed by retrying soon, but by someone changing
+			// the graph or the credentials, which does not trigger a
+			// reconcile of the ProviderConfig.
+			result.RequeueAfter = unreadyRetry
+		case v1alpha1.ReasonDegraded:
+			// The ProviderConfig is reconciled once its circuit breaker
+			// closes, but its storage is only tried again by a reconcile
+			// once the breaker's cooldown elapses.
+			result.RequeueAfter = unreadyRetry
+		case v1alpha1.ReasonUnavailable:
+			result.Requeue = true
+		}
+		pc.SetConditions(cond)
+	} else {
+		cond := v1alpha1.UnknownStorageType(t, storage.Registered())
+		log.Debug(cond.Message)
+		r.record.Event(pc, event.Warning(reasonStorage, errors.New(cond.Message)))
+		pc.SetConditions(cond)
+	}
+
+	if pc.GetCondition(v1alpha1.TypeStorageReady).Equal(current) && pc.Status.SchemaVersion == version {
+		return result, nil
+	}
+
+	return result, errors.Wrap(r.client.Status().Update(ctx, pc), errUpdateStatus)
+}
+
+// ready returns the condition of a ProviderConfig whose storage type is
+// registered. The storage is unready if calls to it keep failing, if it cannot
+// be reached, rejects the ProviderConfig's credentials or lacks capabilities
+// it requires. Otherwise its schema, if it has one, is migrated and the version
+// recorded in the ProviderConfig's status.
+func (r *StorageReconciler) ready(ctx context.Context, pc *v1alpha1.ProviderConfig) (xpv1.Condition, error) {
+	repo, err := repository(ctx, r.client, r.pool, pc)
+	if err != nil {
+		return xpv1.Condition{}, err
+	}
+
+	if d, ok := repo.(storage.Degradable); ok {
+		if until, degraded := d.Degraded(); degraded {
+			return v1alpha1.StorageDegraded(until), nil
+		}
+	}
+
+	if c, ok := repo.(storage.CapabilityChecker); ok {
+		missing, err := c.MissingCapabilities(ctx)
+		if cond, ok := unready(err); ok {
+			return cond, nil
+		}
+		if err != nil {
+			return xpv1.Condition{}, err
+		}
+		if len(missing) > 0 {
+			return v1alpha1.MissingCapabilities(missing), nil
+		}
+	}
+
+	if m, ok := repo.(storage.Migrator); ok {
+		version, err := m.Migrate(ctx)
+		if err == nil || version > 0 {
+			// The version is unknown if the migration failed before
+			// the version recorded in the graph could be read.
+			pc.Status.SchemaVersion = version
+		}
+		if cond, ok := unready(err); ok {
+			return cond, nil
+		}
+		if err != nil {
+			return v1alpha1.MigrationFailed(err), nil
+		}
+	}
+
+	return v1alpha1.StorageTypeValid(), nil
+}
+
+// repository returns the pooled storage of a ProviderConfig, resolving its
+// configuration from the ProviderConfig and the credentials it references.
+func repository(ctx context.Context, c client.Client, p *storage.Pool, pc *v1alpha1.ProviderConfig) (service.Repository, error) {
+	cd := pc.Spec.Credentials
+	data, err := resource.CommonCredentialExtractor(ctx, cd.Source, c, cd.CommonCredentialSelectors)
+	if err != nil {
+		return nil, errors.Wrap(err, errGetCreds)
+	}
+
+	cfg, err := storage.ResolveConfig(ctx, c, pc, data)
+	if err != nil {
+		return nil, errors.Wrap(err, errGetCreds)
+	}
+
+	repo, err := p.Get(cfg)
+	return repo, errors.Wrap(err, errGetStorage)
+}
+
+// unready returns the condition of a ProviderConfig whose storage failed with
+// err, if err means the storage cannot be used at all.
+func unready(err error) (xpv1.Condition, bool) {
+	switch {
+	case storetypes.IsAuthError(err):
+		return v1alpha1.StorageUnauthorized(err), true
+	case storetypes.IsRetryable(err):
+		return v1alpha1.StorageUnavailable(err), true
+	}
+
+	return xpv1.Condition{}, false
+}
@@ -17,9 +17,11 @@ import (
 	"github.com/crossplane/crossplane-runtime/pkg/controller"
 	ctrl "sigs.k8s.io/controller-runtime"
 
+	"github.com/VariableExp0rt/powerbroker/internal/controller/account"
 	"github.com/VariableExp0rt/powerbroker/internal/controller/config"
 	"github.com/VariableExp0rt/powerbroker/internal/controller/permissionset"
 	"github.com/VariableExp0rt/powerbroker/internal/controller/persona"
+	"github.com/VariableExp0rt/powerbroker/internal/controller/role"
 	"github.com/VariableExp0rt/powerbroker/internal/controller/team"
 	"github.com/VariableExp0rt/powerbroker/internal/controller/user"
 )
@@ -29,10 +31,14 @@ import (
 func Setup(mgr ctrl.Manager, o controller.Options) error {
 	for _, setup := range []func(ctrl.Manager, controller.Options) error{
 		config.Setup,
+		config.SetupStorage,
+		config.SetupGarbageCollection,
 		user.Setup,
 		persona.Setup,
 		permissionset.Setup,
 		team.Setup,
+		account.Setup,
+		role.Setup,
 	} {
 		if err := setup(mgr, o); err != nil {
 			return err
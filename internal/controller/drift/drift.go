@@ -0,0 +1,59 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package drift compares the parameters of a managed resource with those
+// observed in the storage.
+package drift
+
+import (
+	"sort"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	v1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+)
+
+// options compare lists of references as sets, since the storage neither
+// orders nor repeats them and does not tell an empty list from none. They
+// ignore references to and selectors of other managed resources, which are
+// resolved into those lists and never stored.
+var options = []cmp.Option{
+	cmpopts.AcyclicTransformer("Set", set),
+	cmpopts.IgnoreTypes(&v1.Reference{}, []v1.Reference{}, &v1.Selector{}),
+}
+
+// Diff returns the difference between the desired parameters of a managed
+// resource and those observed in the storage, or an empty string if there
+// is none.
+func Diff(desired, observed interface{}) string {
+	return cmp.Diff(desired, observed, options...)
+}
+
+// set returns the sorted, distinct elements of s.
+func set(s []string) []string {
+	seen := make(map[string]bool, len(s))
+	out := make([]string, 0, len(s))
+	for _, e := range s {
+		if !seen[e] {
+			seen[e] = true
+			out = append(out, e)
+		}
+	}
+	sort.Strings(out)
+
+	return out
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package drift
+
+import (
+	"testing"
+
+	v1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+
+	"github.com/VariableExp0rt/powerbroker/apis/powerbroker/v1alpha1"
+)
+
+func TestDiff(t *testing.T) {
+	cases := map[string]struct {
+		desired  v1alpha1.TeamParameters
+		observed v1alpha1.TeamParameters
+		drifted  bool
+	}{
+		"Equal": {
+			desired:  v1alpha1.TeamParameters{Name: "koopa-troop", Members: []string{"wario"}},
+			observed: v1alpha1.TeamParameters{Name: "koopa-troop", Members: []string{"wario"}},
+		},
+		"OrderAndDuplicatesIgnored": {
+			desired:  v1alpha1.TeamParameters{Members: []string{"wario", "toad", "wario"}},
+			observed: v1alpha1.TeamParameters{Members: []string{"toad", "wario"}},
+		},
+		"NilAndEmptyAreEqual": {
+			desired:  v1alpha1.TeamParameters{Personas: []string{}},
+			observed: v1alpha1.TeamParameters{},
+		},
+		"ReferencesIgnored": {
+			desired: v1alpha1.TeamParameters{
+				ManagedBy:          v1alpha1.ManagedByParameters{User: "bowser", UserRef: &v1.Reference{Name: "bowser"}},
+				PersonaRefs:        []v1.Reference{{Name: "castle-entry"}},
+				PersonaRefSelector: &v1.Selector{MatchLabels: map[string]string{"castle": "true"}},
+			},
+			observed: v1alpha1.TeamParameters{ManagedBy: v1alpha1.ManagedByParameters{User: "bowser"}},
+		},
+		"NameDrifted": {
+			desired:  v1alpha1.TeamParameters{Name: "koopa-troop"},
+			observed: v1alpha1.TeamParameters{Name: "goomba-squad"},
+			drifted:  true,
+		},
+		"PersonasDrifted": {
+			desired:  v1alpha1.TeamParameters{Personas: []string{"castle-entry"}},
+			observed: v1alpha1.TeamParameters{Personas: []string{"castle-entry", "kart-admin"}},
+			drifted:  true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			diff := Diff(tc.desired, tc.observed)
+			if drifted := diff != ""; drifted != tc.drifted {
+				t.Errorf("Diff(...): want drifted %t, got diff:\n%s", tc.drifted, diff)
+			}
+		})
+	}
+}
@@ -21,4 +21,9 @@ const (
 	// External Secret Stores. See the below design for more details.
 	// https://github.com/crossplane/crossplane/blob/390ddd/design/design-doc-external-secret-stores.md
 	EnableAlphaExternalSecretStores feature.Flag = "EnableAlphaExternalSecretStores"
+
+	// EnableAlphaManagementPolicies enables alpha support for the
+	// management policies of Users and Personas, which let a managed
+	// resource observe a node without ever writing to it.
+	EnableAlphaManagementPolicies feature.Flag = "EnableAlphaManagementPolicies"
 )
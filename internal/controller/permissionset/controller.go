@@ -18,10 +18,10 @@ package permissionset
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"time"
 
-	"github.com/google/go-cmp/cmp"
-	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/pkg/errors"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -37,6 +37,7 @@ import (
 
 	"github.com/VariableExp0rt/powerbroker/apis/powerbroker/v1alpha1"
 	apisv1alpha1 "github.com/VariableExp0rt/powerbroker/apis/v1alpha1"
+	"github.com/VariableExp0rt/powerbroker/internal/controller/drift"
 	"github.com/VariableExp0rt/powerbroker/internal/controller/features"
 	svc "github.com/VariableExp0rt/powerbroker/internal/service"
 	permissionsetsvc "github.com/VariableExp0rt/powerbroker/internal/service/permissionset"
@@ -50,11 +51,15 @@ const (
 	errTrackPCUsage     = "cannot track ProviderConfig usage"
 	errGetPC            = "cannot get ProviderConfig"
 	errGetCreds         = "cannot get credentials"
+	errNewService       = "cannot create new service client"
+	errLookup           = "cannot look up permissionset by managed resource UID"
+	errInvalidBinding   = "binding %d is not valid"
+	errGetAccount       = "cannot get account of binding"
 )
 
-var (
-	errNewService = errors.New("cannot create new service client")
-)
+// reasonUpdated is the reason of the event recorded when an update changes
+// the relationships of a PermissionSet.
+const reasonUpdated event.Reason = "UpdatedRelationships"
 
 var _ Connector = &connectorHelper{}
 
@@ -67,6 +72,8 @@ func Setup(mgr ctrl.Manager, o controller.Options) error {
 		cps = append(cps, connection.NewDetailsManager(mgr.GetClient(), apisv1alpha1.StoreConfigGroupVersionKind))
 	}
 
+	recorder := event.NewAPIRecorder(mgr.GetEventRecorderFor(name))
+
 	return ctrl.NewControllerManagedBy(mgr).
 		Named(name).
 		WithOptions(o.ForControllerRuntime()).
@@ -74,14 +81,16 @@ func Setup(mgr ctrl.Manager, o controller.Options) error {
 		Complete(managed.NewReconciler(mgr,
 			resource.ManagedKind(v1alpha1.PermissionSetGroupVersionKind),
 			managed.WithExternalConnecter(&connector{
-				kube:  mgr.GetClient(),
-				usage: resource.NewProviderConfigUsageTracker(mgr.GetClient(), &apisv1alpha1.ProviderConfigUsage{}),
-				util:  &connectorHelper{},
+				kube:   mgr.GetClient(),
+				usage:  resource.NewProviderConfigUsageTracker(mgr.GetClient(), &apisv1alpha1.ProviderConfigUsage{}),
+				record: recorder,
+				util:   &connectorHelper{},
 			}),
 			managed.WithCreationGracePeriod(10*time.Second),
 			managed.WithInitializers(managed.NewDefaultProviderConfig(mgr.GetClient())),
+			managed.WithReferenceResolver(managed.NewAPISimpleReferenceResolver(mgr.GetClient())),
 			managed.WithLogger(o.Logger.WithValues("controller", name)),
-			managed.WithRecorder(event.NewAPIRecorder(mgr.GetEventRecorderFor(name))),
+			managed.WithRecorder(recorder),
 			managed.WithPollInterval(o.PollInterval),
 			managed.WithConnectionPublishers(cps...)))
 }
@@ -102,9 +111,10 @@ func (c *connectorHelper) ExtractCredentials(ctx context.Context, source v1.Cred
 }
 
 type connector struct {
-	kube  client.Client
-	usage resource.Tracker
-	util  Connector
+	kube   client.Client
+	usage  resource.Tracker
+	util   Connector
+	record event.Recorder
 }
 
 func (c *connector) Connect(ctx context.Context, mg resource.Managed) (managed.ExternalClient, error) {
@@ -129,24 +139,27 @@ func (c *connector) Connect(ctx context.Context, mg resource.Managed) (managed.E
 		return nil, errors.Wrap(err, errGetCreds)
 	}
 
-	var service permissionsetsvc.Service
-	switch pc.Spec.Storage.Type {
-	case "neo4j":
-		store, err := storage.NewNeo4jStorage(data)
-		if err != nil {
-			return nil, errors.Wrap(err, "client")
-		}
-		service = permissionsetsvc.NewService(store)
-	default:
-		return nil, errNewService
+	cfg, err := storage.ResolveConfig(ctx, c.kube, pc, data)
+	if err != nil {
+		return nil, errors.Wrap(err, errGetCreds)
 	}
 
-	return &external{service: service, kube: c.kube}, nil
+	store, err := storage.DefaultPool.Get(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, errNewService)
+	}
+
+	return &external{service: c.util.GetService(store), kube: c.kube, record: c.record, timeout: cfg.Timeout}, nil
 }
 
 type external struct {
 	kube    client.Client
 	service permissionsetsvc.Service
+	record  event.Recorder
+
+	// timeout bounds each operation on the storage. Operations are
+	// unbounded when it is zero.
+	timeout time.Duration
 }
 
 func (e *external) Observe(ctx context.Context, mg resource.Managed) (managed.ExternalObservation, error) {
@@ -155,32 +168,54 @@ func (e *external) Observe(ctx context.Context, mg resource.Managed) (managed.Ex
 		return managed.ExternalObservation{}, errors.New(errNotPermissionSet)
 	}
 
-	ext := meta.GetExternalName(cr)
-	if ext == "" {
-		return managed.ExternalObservation{ResourceExists: false}, nil
+	ctx, cancel := storage.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	ctx = storetypes.WithResource(ctx, string(cr.GetUID()))
+
+	// A PermissionSet with an invalid binding can still be deleted.
+	if !meta.WasDeleted(cr) {
+		if err := validate(cr.Spec.ForProvider.Bindings); err != nil {
+			return managed.ExternalObservation{}, err
+		}
 	}
 
-	desiredBinding := cr.Spec.ForProvider.BindTo.DeepCopy()
+	var adopted bool
+	if meta.GetExternalName(cr) == "" {
+		var err error
+		if adopted, err = e.adopt(ctx, cr); err != nil || !adopted {
+			return managed.ExternalObservation{ResourceExists: false}, err
+		}
+	}
+	ext := meta.GetExternalName(cr)
 
 	// TODO: merge the observed binding into this "api's" response
 	// as we're sort of manufacturing a status here
-	resp, err := e.service.GetPermissionSet(ext)
+	resp, err := e.service.GetPermissionSet(ctx, ext)
 	if err != nil {
+		setUnavailable(cr, err)
 		return managed.ExternalObservation{}, errors.Wrap(
 			resource.Ignore(storetypes.IsEntityNotFoundNeo4jErr, err),
 			"cannot get permissionset")
 	}
 
-	cr.Status.AtProvider = generatePermissionSetObservation(resp)
+	if !meta.WasDeleted(cr) {
+		if err := e.validateManaged(ctx, cr.Spec.ForProvider.Bindings, resp.Bindings); err != nil {
+			setUnavailable(cr, err)
+			return managed.ExternalObservation{}, err
+		}
+	}
+
+	bindings, drifted := diffBindings(cr.Spec.ForProvider.Bindings, resp.Bindings)
+	cr.Status.AtProvider = generatePermissionSetObservation(resp, bindings)
+
+	// A permission set is stored under the name of its managed resource.
+	diff := drift.Diff(cr.GetName(), resp.Name) + drifted
 
 	return postObserve(cr, managed.ExternalObservation{
-		ResourceExists: true,
-		ResourceUpToDate: cmp.Equal(*desiredBinding, resp.Binding,
-			cmpopts.IgnoreTypes([]v1.Reference{}, []v1.Selector{}),
-		),
-		Diff: cmp.Diff(*desiredBinding, resp.Binding,
-			cmpopts.IgnoreTypes([]v1.Reference{}, []v1.Selector{}),
-		),
+		ResourceExists:          true,
+		ResourceLateInitialized: adopted,
+		ResourceUpToDate:        diff == "",
+		Diff:                    diff,
 	}, err)
 }
 
@@ -190,10 +225,16 @@ func (e *external) Create(ctx context.Context, mg resource.Managed) (managed.Ext
 		return managed.ExternalCreation{}, errors.New(errNotPermissionSet)
 	}
 
+	ctx, cancel := storage.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	ctx = storetypes.WithResource(ctx, string(cr.GetUID()))
+
 	cr.SetConditions(v1.Creating())
 	uuid, err := e.service.CreatePermissionSet(
+		ctx,
+		string(cr.GetUID()),
 		cr.Name,
-		cr.Spec.ForProvider.BindTo,
+		cr.Spec.ForProvider.Bindings,
 	)
 
 	return postCreate(cr, managed.ExternalCreation{ExternalNameAssigned: true}, uuid, err)
@@ -205,15 +246,25 @@ func (e *external) Update(ctx context.Context, mg resource.Managed) (managed.Ext
 		return managed.ExternalUpdate{}, errors.New(errNotPermissionSet)
 	}
 
-	// TODO(liambaker): fix the fact that it does not update alias, class
-	// just pass the entire BindTo here and handle alias and class too
-	err := e.service.UpdatePermissionSet(
+	ctx, cancel := storage.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	ctx = storetypes.WithResource(ctx, string(cr.GetUID()))
+
+	changes, err := e.service.UpdatePermissionSet(
+		ctx,
 		meta.GetExternalName(cr),
 		cr.GetName(),
-		cr.Spec.ForProvider.BindTo,
+		cr.Spec.ForProvider.Bindings,
 	)
+	if err != nil {
+		return managed.ExternalUpdate{}, errors.Wrap(err, "cannot update permissionset")
+	}
+
+	if !changes.Empty() {
+		e.record.Event(cr, event.Normal(reasonUpdated, changes.String()))
+	}
 
-	return managed.ExternalUpdate{}, errors.Wrap(err, "cannot update permissionset")
+	return managed.ExternalUpdate{}, nil
 }
 
 func (e *external) Delete(ctx context.Context, mg resource.Managed) error {
@@ -222,16 +273,186 @@ func (e *external) Delete(ctx context.Context, mg resource.Managed) error {
 		return errors.New(errNotPermissionSet)
 	}
 
+	ctx, cancel := storage.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	ctx = storetypes.WithResource(ctx, string(cr.GetUID()))
+
 	cr.SetConditions(v1.Deleting())
-	err := e.service.DeletePermissionSet(meta.GetExternalName(cr))
+	err := e.service.DeletePermissionSet(ctx, meta.GetExternalName(cr))
 
 	return errors.Wrap(resource.Ignore(storetypes.IsEntityNotFoundNeo4jErr, err), "cannot delete permissionset")
 }
 
-func generatePermissionSetObservation(r *pwrbrkrtypes.GetPermissionSetResponse) v1alpha1.PermissionSetObservation {
+// adopt sets the external name of a PermissionSet which has none to the uuid of the
+// permissionset created for it, if any. This happens when the provider fails to record
+// the external name after creating the permissionset.
+func (e *external) adopt(ctx context.Context, cr *v1alpha1.PermissionSet) (bool, error) {
+	uuid, err := e.service.LookupPermissionSet(ctx, string(cr.GetUID()))
+	if err != nil {
+		setUnavailable(cr, err)
+		return false, errors.Wrap(resource.Ignore(storetypes.IsEntityNotFoundNeo4jErr, err), errLookup)
+	}
+
+	meta.SetExternalName(cr, uuid)
+	return true, nil
+}
+
+// setUnavailable marks a PermissionSet Unavailable if err means its storage cannot be
+// used. The PermissionSet then reports why it is not ready, as well as that it failed
+// to reconcile.
+func setUnavailable(cr *v1alpha1.PermissionSet, err error) {
+	if storage.Unavailable(err) {
+		cr.SetConditions(v1.Unavailable().WithMessage(err.Error()))
+	}
+}
+
+// diffBindings matches each desired binding with the stored binding of the
+// same account and role. It returns the state of every binding, desired or
+// stored, and the drift of those which are not in sync.
+func diffBindings(desired, stored []v1alpha1.AccountRoleBinding) ([]v1alpha1.BindingObservation, string) {
+	byKey := make(map[string]v1alpha1.AccountRoleBinding, len(stored))
+	for _, b := range stored {
+		byKey[pwrbrkrtypes.BindingKey(b)] = b
+	}
+
+	var diff strings.Builder
+	out := []v1alpha1.BindingObservation{}
+	wanted := map[string]bool{}
+	for _, b := range pwrbrkrtypes.UniqueBindings(desired) {
+		key := pwrbrkrtypes.BindingKey(b)
+		wanted[key] = true
+
+		o := observe(b, v1alpha1.BindingInSync)
+		s, ok := byKey[key]
+		switch d := drift.Diff(canonical(b), canonical(s)); {
+		case !ok:
+			o.State = v1alpha1.BindingMissing
+			fmt.Fprintf(&diff, "binding %s is missing\n", key)
+		case d != "":
+			o.State = v1alpha1.BindingDrifted
+			fmt.Fprintf(&diff, "binding %s has drifted:\n%s", key, d)
+		}
+		out = append(out, o)
+	}
+
+	for _, b := range pwrbrkrtypes.UniqueBindings(stored) {
+		key := pwrbrkrtypes.BindingKey(b)
+		if wanted[key] {
+			continue
+		}
+		out = append(out, observe(b, v1alpha1.BindingUnexpected))
+		fmt.Fprintf(&diff, "binding %s is unexpected\n", key)
+	}
+
+	return out, diff.String()
+}
+
+// observe returns the observation of a binding in the supplied state.
+func observe(b v1alpha1.AccountRoleBinding, state string) v1alpha1.BindingObservation {
+	t := pwrbrkrtypes.TargetOf(b)
+	return v1alpha1.BindingObservation{Cloud: t.Cloud, Account: t.Account, RoleName: t.Role, Scope: t.Scope, State: state}
+}
+
+// canonical returns a binding as it is stored, with its cloud and without
+// the fields of any other cloud.
+func canonical(b v1alpha1.AccountRoleBinding) v1alpha1.AccountRoleBinding {
+	return pwrbrkrtypes.TargetOf(b).Binding(b.Alias, b.AccountClass)
+}
+
+// validate returns an error if a binding does not set exactly the fields of
+// its cloud, since it would not be clear where it delegates access.
+func validate(bindings []v1alpha1.AccountRoleBinding) error {
+	for i, b := range bindings {
+		if err := validateBinding(b); err != nil {
+			return errors.Wrapf(err, errInvalidBinding, i)
+		}
+	}
+
+	return nil
+}
+
+// validateManaged returns an error if a binding sets the alias or class of
+// an account which an Account manages. The account keeps the alias and class
+// of its Account, so the binding would never be in sync. Only bindings whose
+// stored account has no alias or class of their own are checked, since those
+// of a managed account never do.
+func (e *external) validateManaged(ctx context.Context, desired, stored []v1alpha1.AccountRoleBinding) error {
+	byKey := make(map[string]v1alpha1.AccountRoleBinding, len(stored))
+	for _, b := range stored {
+		byKey[pwrbrkrtypes.BindingKey(b)] = b
+	}
+
+	for i, b := range desired {
+		if b.Alias == "" && b.AccountClass == "" {
+			continue
+		}
+		s, ok := byKey[pwrbrkrtypes.BindingKey(b)]
+		if !ok || s.Alias != "" || s.AccountClass != "" {
+			continue
+		}
+
+		t := pwrbrkrtypes.TargetOf(b)
+		_, err := e.service.GetAccount(ctx, t.Cloud, t.Account)
+		if storetypes.IsEntityNotFoundNeo4jErr(err) {
+			continue
+		}
+		if err != nil {
+			return errors.Wrap(err, errGetAccount)
+		}
+
+		return errors.Wrapf(errors.Errorf("%s account %s is managed by an Account, so accountAlias and accountClass must be unset", t.Cloud, t.Account), errInvalidBinding, i)
+	}
+
+	return nil
+}
+
+func validateBinding(b v1alpha1.AccountRoleBinding) error {
+	cloud := pwrbrkrtypes.TargetOf(b).Cloud
+	for _, f := range []struct {
+		cloud string
+		set   bool
+	}{
+		{cloud: v1alpha1.CloudAWS, set: b.Account != "" || b.RoleName != ""},
+		{cloud: v1alpha1.CloudGCP, set: b.GCP != nil},
+		{cloud: v1alpha1.CloudAzure, set: b.Azure != nil},
+		{cloud: v1alpha1.CloudKubernetes, set: b.Kubernetes != nil},
+	} {
+		if f.set && f.cloud != cloud {
+			return errors.Errorf("%s bindings cannot set the fields of %s bindings", cloud, f.cloud)
+		}
+	}
+
+	switch cloud {
+	case v1alpha1.CloudGCP:
+		if b.GCP == nil || b.GCP.Project == "" || b.GCP.Role == "" {
+			return errors.New("gcp bindings must set gcp.project and gcp.role")
+		}
+	case v1alpha1.CloudAzure:
+		if b.Azure == nil || b.Azure.Subscription == "" || b.Azure.RoleDefinition == "" {
+			return errors.New("azure bindings must set azure.subscription and azure.roleDefinition")
+		}
+		sub := "/subscriptions/" + b.Azure.Subscription
+		if s := b.Azure.Scope; s != "" && s != sub && !strings.HasPrefix(s, sub+"/") {
+			return errors.Errorf("azure.scope must be within %s", sub)
+		}
+	case v1alpha1.CloudKubernetes:
+		if b.Kubernetes == nil || b.Kubernetes.Cluster == "" || b.Kubernetes.ClusterRole == "" {
+			return errors.New("kubernetes bindings must set kubernetes.cluster and kubernetes.clusterRole")
+		}
+	default:
+		if b.Account == "" || b.RoleName == "" {
+			return errors.New("aws bindings must set account and roleName")
+		}
+	}
+
+	return nil
+}
+
+func generatePermissionSetObservation(r *pwrbrkrtypes.GetPermissionSetResponse, bindings []v1alpha1.BindingObservation) v1alpha1.PermissionSetObservation {
 	return v1alpha1.PermissionSetObservation{
-		NodeID: r.NodeID,
-		Status: string(r.Status),
+		NodeID:   r.NodeID,
+		Status:   string(r.Status),
+		Bindings: bindings,
 	}
 }
 
@@ -21,10 +21,13 @@ import (
 	"testing"
 
 	"github.com/VariableExp0rt/powerbroker/apis/powerbroker/v1alpha1"
+	"github.com/VariableExp0rt/powerbroker/internal/controller/drift"
 	"github.com/VariableExp0rt/powerbroker/internal/service"
 	"github.com/VariableExp0rt/powerbroker/internal/service/permissionset"
 	"github.com/VariableExp0rt/powerbroker/internal/service/types"
+	"github.com/VariableExp0rt/powerbroker/internal/storage"
 	"github.com/VariableExp0rt/powerbroker/internal/storage/neo4j/transaction"
+	storetypes "github.com/VariableExp0rt/powerbroker/internal/storage/types"
 	"github.com/google/go-cmp/cmp"
 	"github.com/pkg/errors"
 	kclient "sigs.k8s.io/controller-runtime/pkg/client"
@@ -51,12 +54,19 @@ var _ Connector = &connectorHelper{}
 var (
 	externalName = "712081a1-0da3-46cd-bab3-ee1852723c4f"
 	binding      = v1alpha1.AccountRoleBinding{
+		Cloud:        v1alpha1.CloudAWS,
 		Alias:        "my-aws-account-alias",
 		Account:      "111111111111",
 		AccountClass: "production",
 		RoleName:     "my-aws-iam-role",
 	}
-	errInternalServer = &transaction.InternalError{Message: "internal server error"}
+	gcp = v1alpha1.AccountRoleBinding{
+		Cloud: v1alpha1.CloudGCP,
+		GCP:   &v1alpha1.GCPBinding{Project: "my-project", Role: "roles/viewer"},
+	}
+	bindings          = []v1alpha1.AccountRoleBinding{binding}
+	inSync            = []v1alpha1.BindingObservation{{Cloud: v1alpha1.CloudAWS, Account: binding.Account, RoleName: binding.RoleName, State: v1alpha1.BindingInSync}}
+	errInternalServer = &storetypes.InternalError{}
 	errSecretNotFound = errors.New("no resource found for secret name")
 )
 
@@ -237,7 +247,7 @@ func TestConnect(t *testing.T) {
 				},
 			},
 			want: want{
-				err: errNewService,
+				err: errors.Wrap(&storage.UnknownStorageTypeError{}, errNewService),
 			},
 		},
 		"ConnectFailureGetCreds": {
@@ -302,7 +312,7 @@ func TestObserve(t *testing.T) {
 					withConditions(v1.Available(), v1.ReconcileSuccess()),
 					withExternalName("712081a1-0da3-46cd-bab3-ee1852723c4f"),
 					withSpec(v1alpha1.PermissionSetParameters{
-						BindTo: binding,
+						Bindings: bindings,
 					}),
 					withStatus(v1alpha1.PermissionSetObservation{
 						NodeID: externalName,
@@ -310,11 +320,11 @@ func TestObserve(t *testing.T) {
 					}),
 				),
 				service: &service.MockRepository{
-					MockGetPermissionSet: func(uuid string) (*types.GetPermissionSetResponse, error) {
+					MockGetPermissionSet: func(ctx context.Context, uuid string) (*types.GetPermissionSetResponse, error) {
 						return &types.GetPermissionSetResponse{
-							Binding: binding,
-							Status:  "available",
-							NodeID:  "712081a1-0da3-46cd-bab3-ee1852723c4f",
+							Bindings: bindings,
+							Status:   "available",
+							NodeID:   "712081a1-0da3-46cd-bab3-ee1852723c4f",
 						}, nil
 					},
 				},
@@ -323,17 +333,325 @@ func TestObserve(t *testing.T) {
 				cr: permissionSet(
 					withConditions(v1.Available(), v1.ReconcileSuccess()),
 					withExternalName(externalName),
+					withStatus(v1alpha1.PermissionSetObservation{
+						NodeID:   externalName,
+						Status:   transaction.StatusAvailable,
+						Bindings: inSync,
+					}),
+					withSpec(v1alpha1.PermissionSetParameters{Bindings: bindings}),
+				),
+				o: managed.ExternalObservation{
+					ResourceExists:   true,
+					ResourceUpToDate: true,
+				},
+				err: nil,
+			},
+		},
+		"AdoptedByUID": {
+			args: args{
+				cr: permissionSet(
+					withSpec(v1alpha1.PermissionSetParameters{
+						Bindings: bindings,
+					}),
+				),
+				service: &service.MockRepository{
+					MockLookupPermissionSet: func(ctx context.Context, uid string) (string, error) {
+						return externalName, nil
+					},
+					MockGetPermissionSet: func(ctx context.Context, uuid string) (*types.GetPermissionSetResponse, error) {
+						return &types.GetPermissionSetResponse{
+							Bindings: bindings,
+							Status:   "available",
+							NodeID:   externalName,
+						}, nil
+					},
+				},
+			},
+			want: want{
+				cr: permissionSet(
+					withConditions(v1.Available()),
+					withExternalName(externalName),
+					withStatus(v1alpha1.PermissionSetObservation{
+						NodeID:   externalName,
+						Status:   transaction.StatusAvailable,
+						Bindings: inSync,
+					}),
+					withSpec(v1alpha1.PermissionSetParameters{Bindings: bindings}),
+				),
+				o: managed.ExternalObservation{
+					ResourceExists:          true,
+					ResourceLateInitialized: true,
+					ResourceUpToDate:        true,
+				},
+			},
+		},
+		"AliasDrifted": {
+			args: args{
+				cr: permissionSet(
+					withExternalName(externalName),
+					withSpec(v1alpha1.PermissionSetParameters{Bindings: bindings}),
+				),
+				service: &service.MockRepository{
+					MockGetPermissionSet: func(ctx context.Context, uuid string) (*types.GetPermissionSetResponse, error) {
+						drifted := binding
+						drifted.Alias = "renamed-out-of-band"
+						return &types.GetPermissionSetResponse{
+							Bindings: []v1alpha1.AccountRoleBinding{drifted},
+							Status:   "available",
+							NodeID:   externalName,
+						}, nil
+					},
+				},
+			},
+			want: want{
+				cr: permissionSet(
+					withConditions(v1.Available()),
+					withExternalName(externalName),
 					withStatus(v1alpha1.PermissionSetObservation{
 						NodeID: externalName,
 						Status: transaction.StatusAvailable,
+						Bindings: []v1alpha1.BindingObservation{{
+							Cloud:    v1alpha1.CloudAWS,
+							Account:  binding.Account,
+							RoleName: binding.RoleName,
+							State:    v1alpha1.BindingDrifted,
+						}},
 					}),
-					withSpec(v1alpha1.PermissionSetParameters{BindTo: binding}),
+					withSpec(v1alpha1.PermissionSetParameters{Bindings: bindings}),
+				),
+				o: managed.ExternalObservation{
+					ResourceExists:   true,
+					ResourceUpToDate: false,
+					Diff: "binding aws:111111111111/my-aws-iam-role has drifted:\n" + drift.Diff(binding, v1alpha1.AccountRoleBinding{
+						Cloud:        v1alpha1.CloudAWS,
+						Alias:        "renamed-out-of-band",
+						Account:      binding.Account,
+						AccountClass: binding.AccountClass,
+						RoleName:     binding.RoleName,
+					}),
+				},
+			},
+		},
+		"BindingReplacedOutOfBand": {
+			args: args{
+				cr: permissionSet(
+					withExternalName(externalName),
+					withSpec(v1alpha1.PermissionSetParameters{Bindings: bindings}),
+				),
+				service: &service.MockRepository{
+					MockGetPermissionSet: func(ctx context.Context, uuid string) (*types.GetPermissionSetResponse, error) {
+						return &types.GetPermissionSetResponse{
+							Bindings: []v1alpha1.AccountRoleBinding{{Account: "222222222222", RoleName: "admin"}},
+							Status:   "available",
+							NodeID:   externalName,
+						}, nil
+					},
+				},
+			},
+			want: want{
+				cr: permissionSet(
+					withConditions(v1.Available()),
+					withExternalName(externalName),
+					withStatus(v1alpha1.PermissionSetObservation{
+						NodeID: externalName,
+						Status: transaction.StatusAvailable,
+						Bindings: []v1alpha1.BindingObservation{
+							{Cloud: v1alpha1.CloudAWS, Account: binding.Account, RoleName: binding.RoleName, State: v1alpha1.BindingMissing},
+							{Cloud: v1alpha1.CloudAWS, Account: "222222222222", RoleName: "admin", State: v1alpha1.BindingUnexpected},
+						},
+					}),
+					withSpec(v1alpha1.PermissionSetParameters{Bindings: bindings}),
+				),
+				o: managed.ExternalObservation{
+					ResourceExists:   true,
+					ResourceUpToDate: false,
+					Diff:             "binding aws:111111111111/my-aws-iam-role is missing\nbinding aws:222222222222/admin is unexpected\n",
+				},
+			},
+		},
+		"GCPInSync": {
+			args: args{
+				cr: permissionSet(
+					withExternalName(externalName),
+					withSpec(v1alpha1.PermissionSetParameters{Bindings: []v1alpha1.AccountRoleBinding{gcp}}),
+				),
+				service: &service.MockRepository{
+					MockGetPermissionSet: func(ctx context.Context, uuid string) (*types.GetPermissionSetResponse, error) {
+						return &types.GetPermissionSetResponse{
+							Bindings: []v1alpha1.AccountRoleBinding{gcp},
+							Status:   "available",
+							NodeID:   externalName,
+						}, nil
+					},
+				},
+			},
+			want: want{
+				cr: permissionSet(
+					withConditions(v1.Available()),
+					withExternalName(externalName),
+					withStatus(v1alpha1.PermissionSetObservation{
+						NodeID: externalName,
+						Status: transaction.StatusAvailable,
+						Bindings: []v1alpha1.BindingObservation{{
+							Cloud:    v1alpha1.CloudGCP,
+							Account:  "my-project",
+							RoleName: "roles/viewer",
+							State:    v1alpha1.BindingInSync,
+						}},
+					}),
+					withSpec(v1alpha1.PermissionSetParameters{Bindings: []v1alpha1.AccountRoleBinding{gcp}}),
 				),
 				o: managed.ExternalObservation{
 					ResourceExists:   true,
 					ResourceUpToDate: true,
 				},
-				err: nil,
+			},
+		},
+		"FieldsOfAnotherCloud": {
+			args: args{
+				cr: permissionSet(
+					withExternalName(externalName),
+					withSpec(v1alpha1.PermissionSetParameters{Bindings: []v1alpha1.AccountRoleBinding{
+						binding,
+						{Cloud: v1alpha1.CloudGCP, Account: "111111111111", GCP: gcp.GCP},
+					}}),
+				),
+			},
+			want: want{
+				cr: permissionSet(
+					withExternalName(externalName),
+					withSpec(v1alpha1.PermissionSetParameters{Bindings: []v1alpha1.AccountRoleBinding{
+						binding,
+						{Cloud: v1alpha1.CloudGCP, Account: "111111111111", GCP: gcp.GCP},
+					}}),
+				),
+				err: errors.Wrapf(errors.New("gcp bindings cannot set the fields of aws bindings"), errInvalidBinding, 1),
+			},
+		},
+		"AliasOfManagedAccount": {
+			args: args{
+				cr: permissionSet(
+					withExternalName(externalName),
+					withSpec(v1alpha1.PermissionSetParameters{Bindings: bindings}),
+				),
+				service: &service.MockRepository{
+					MockGetPermissionSet: func(ctx context.Context, uuid string) (*types.GetPermissionSetResponse, error) {
+						managed := binding
+						managed.Alias, managed.AccountClass = "", ""
+						return &types.GetPermissionSetResponse{
+							Bindings: []v1alpha1.AccountRoleBinding{managed},
+							Status:   "available",
+							NodeID:   externalName,
+						}, nil
+					},
+					MockGetAccount: func(ctx context.Context, cloud, accountID string) (*types.GetAccountResponse, error) {
+						return &types.GetAccountResponse{NodeID: accountID, Status: storetypes.StatusAvailable}, nil
+					},
+				},
+			},
+			want: want{
+				cr: permissionSet(
+					withExternalName(externalName),
+					withSpec(v1alpha1.PermissionSetParameters{Bindings: bindings}),
+				),
+				err: errors.Wrapf(errors.New("aws account 111111111111 is managed by an Account, so accountAlias and accountClass must be unset"), errInvalidBinding, 0),
+			},
+		},
+		"AliasOfUnmanagedAccountMissing": {
+			args: args{
+				cr: permissionSet(
+					withExternalName(externalName),
+					withSpec(v1alpha1.PermissionSetParameters{Bindings: bindings}),
+				),
+				service: &service.MockRepository{
+					MockGetPermissionSet: func(ctx context.Context, uuid string) (*types.GetPermissionSetResponse, error) {
+						unset := binding
+						unset.Alias, unset.AccountClass = "", ""
+						return &types.GetPermissionSetResponse{
+							Bindings: []v1alpha1.AccountRoleBinding{unset},
+							Status:   "available",
+							NodeID:   externalName,
+						}, nil
+					},
+					MockGetAccount: func(ctx context.Context, cloud, accountID string) (*types.GetAccountResponse, error) {
+						return &types.GetAccountResponse{NodeID: accountID, Status: storetypes.StatusDeleted}, &storetypes.EntityNotFoundError{}
+					},
+				},
+			},
+			want: want{
+				cr: permissionSet(
+					withConditions(v1.Available()),
+					withExternalName(externalName),
+					withStatus(v1alpha1.PermissionSetObservation{
+						NodeID: externalName,
+						Status: transaction.StatusAvailable,
+						Bindings: []v1alpha1.BindingObservation{{
+							Cloud:    v1alpha1.CloudAWS,
+							Account:  binding.Account,
+							RoleName: binding.RoleName,
+							State:    v1alpha1.BindingDrifted,
+						}},
+					}),
+					withSpec(v1alpha1.PermissionSetParameters{Bindings: bindings}),
+				),
+				o: managed.ExternalObservation{
+					ResourceExists:   true,
+					ResourceUpToDate: false,
+					Diff: "binding aws:111111111111/my-aws-iam-role has drifted:\n" + drift.Diff(binding, v1alpha1.AccountRoleBinding{
+						Cloud:    v1alpha1.CloudAWS,
+						Account:  binding.Account,
+						RoleName: binding.RoleName,
+					}),
+				},
+			},
+		},
+		"AzureScopeOutsideSubscription": {
+			args: args{
+				cr: permissionSet(
+					withExternalName(externalName),
+					withSpec(v1alpha1.PermissionSetParameters{Bindings: []v1alpha1.AccountRoleBinding{{
+						Cloud: v1alpha1.CloudAzure,
+						Azure: &v1alpha1.AzureBinding{Subscription: "sub-a", RoleDefinition: "Reader", Scope: "/subscriptions/sub-b"},
+					}}}),
+				),
+			},
+			want: want{
+				cr: permissionSet(
+					withExternalName(externalName),
+					withSpec(v1alpha1.PermissionSetParameters{Bindings: []v1alpha1.AccountRoleBinding{{
+						Cloud: v1alpha1.CloudAzure,
+						Azure: &v1alpha1.AzureBinding{Subscription: "sub-a", RoleDefinition: "Reader", Scope: "/subscriptions/sub-b"},
+					}}}),
+				),
+				err: errors.Wrapf(errors.New("azure.scope must be within /subscriptions/sub-a"), errInvalidBinding, 0),
+			},
+		},
+		"NotFoundByUID": {
+			args: args{
+				cr: permissionSet(),
+				service: &service.MockRepository{
+					MockLookupPermissionSet: func(ctx context.Context, uid string) (string, error) {
+						return "", &storetypes.EntityNotFoundError{}
+					},
+				},
+			},
+			want: want{
+				cr: permissionSet(),
+				o:  managed.ExternalObservation{ResourceExists: false},
+			},
+		},
+		"LookupFailed": {
+			args: args{
+				cr: permissionSet(),
+				service: &service.MockRepository{
+					MockLookupPermissionSet: func(ctx context.Context, uid string) (string, error) {
+						return "", errInternalServer
+					},
+				},
+			},
+			want: want{
+				cr:  permissionSet(),
+				err: errors.Wrap(errInternalServer, errLookup),
 			},
 		},
 		"GetFailedUnavailable": {
@@ -345,7 +663,7 @@ func TestObserve(t *testing.T) {
 					withConditions(v1.Unavailable(), v1.ReconcileSuccess()),
 					withExternalName("712081a1-0da3-46cd-bab3-ee1852723c4f"),
 					withSpec(v1alpha1.PermissionSetParameters{
-						BindTo: binding,
+						Bindings: bindings,
 					}),
 					withStatus(v1alpha1.PermissionSetObservation{
 						NodeID: "712081a1-0da3-46cd-bab3-ee1852723c4f",
@@ -353,7 +671,7 @@ func TestObserve(t *testing.T) {
 					}),
 				),
 				service: &service.MockRepository{
-					MockGetPermissionSet: func(uuid string) (*types.GetPermissionSetResponse, error) {
+					MockGetPermissionSet: func(ctx context.Context, uuid string) (*types.GetPermissionSetResponse, error) {
 						return nil, errInternalServer
 					},
 				},
@@ -363,7 +681,7 @@ func TestObserve(t *testing.T) {
 					withConditions(v1.Unavailable(), v1.ReconcileSuccess()),
 					withExternalName(externalName),
 					withSpec(v1alpha1.PermissionSetParameters{
-						BindTo: binding,
+						Bindings: bindings,
 					}),
 					withStatus(v1alpha1.PermissionSetObservation{
 						NodeID: externalName,
@@ -411,7 +729,7 @@ func TestCreate(t *testing.T) {
 					MockUpdate: test.NewMockClient().Update,
 				},
 				service: &service.MockRepository{
-					MockCreatePermissionSet: func(name string, binding v1alpha1.AccountRoleBinding) (string, error) {
+					MockCreatePermissionSet: func(ctx context.Context, uid string, name string, bindings []v1alpha1.AccountRoleBinding) (string, error) {
 						return "712081a1-0da3-46cd-bab3-ee1852723c4f", nil
 					},
 				},
@@ -434,7 +752,7 @@ func TestCreate(t *testing.T) {
 				},
 				cr: permissionSet(withConditions(v1.Creating())),
 				service: &service.MockRepository{
-					MockCreatePermissionSet: func(name string, binding v1alpha1.AccountRoleBinding) (string, error) {
+					MockCreatePermissionSet: func(ctx context.Context, uid string, name string, bindings []v1alpha1.AccountRoleBinding) (string, error) {
 						return "", errInternalServer
 					},
 				},
@@ -479,33 +797,33 @@ func TestUpdate(t *testing.T) {
 		"SuccessfulUpdate": {
 			args: args{
 				service: &service.MockRepository{
-					MockGetPermissionSet: func(uuid string) (*types.GetPermissionSetResponse, error) {
+					MockGetPermissionSet: func(ctx context.Context, uuid string) (*types.GetPermissionSetResponse, error) {
 						return &types.GetPermissionSetResponse{}, nil
 					},
-					MockUpdatePermissionSet: func(permissionSetUuid, crName string, binding v1alpha1.AccountRoleBinding) error {
-						return nil
+					MockUpdatePermissionSet: func(ctx context.Context, permissionSetUuid, crName string, bindings []v1alpha1.AccountRoleBinding) (types.Changes, error) {
+						return types.Changes{}, nil
 					},
 				},
-				cr: permissionSet(withSpec(v1alpha1.PermissionSetParameters{BindTo: binding})),
+				cr: permissionSet(withSpec(v1alpha1.PermissionSetParameters{Bindings: bindings})),
 			},
 			want: want{
-				cr: permissionSet(withSpec(v1alpha1.PermissionSetParameters{BindTo: binding})),
+				cr: permissionSet(withSpec(v1alpha1.PermissionSetParameters{Bindings: bindings})),
 			},
 		},
 		"UpdateFailed": {
 			args: args{
 				service: &service.MockRepository{
-					MockGetPermissionSet: func(uuid string) (*types.GetPermissionSetResponse, error) {
+					MockGetPermissionSet: func(ctx context.Context, uuid string) (*types.GetPermissionSetResponse, error) {
 						return &types.GetPermissionSetResponse{}, nil
 					},
-					MockUpdatePermissionSet: func(permissionSetUuid, crName string, binding v1alpha1.AccountRoleBinding) error {
-						return errInternalServer
+					MockUpdatePermissionSet: func(ctx context.Context, permissionSetUuid, crName string, bindings []v1alpha1.AccountRoleBinding) (types.Changes, error) {
+						return types.Changes{}, errInternalServer
 					},
 				},
-				cr: permissionSet(withSpec(v1alpha1.PermissionSetParameters{BindTo: binding})),
+				cr: permissionSet(withSpec(v1alpha1.PermissionSetParameters{Bindings: bindings})),
 			},
 			want: want{
-				cr:  permissionSet(withSpec(v1alpha1.PermissionSetParameters{BindTo: binding})),
+				cr:  permissionSet(withSpec(v1alpha1.PermissionSetParameters{Bindings: bindings})),
 				err: errors.Wrap(errInternalServer, "cannot update permissionset"),
 			},
 		},
@@ -543,7 +861,7 @@ func TestDelete(t *testing.T) {
 			args: args{
 				cr: permissionSet(),
 				service: &service.MockRepository{
-					MockDeletePermissionSet: func(permissionSetUuid string) error {
+					MockDeletePermissionSet: func(ctx context.Context, permissionSetUuid string) error {
 						return nil
 					},
 				},
@@ -557,7 +875,7 @@ func TestDelete(t *testing.T) {
 			args: args{
 				cr: permissionSet(),
 				service: &service.MockRepository{
-					MockDeletePermissionSet: func(permissionSetUuid string) error {
+					MockDeletePermissionSet: func(ctx context.Context, permissionSetUuid string) error {
 						return errInternalServer
 					},
 				},
@@ -20,7 +20,6 @@ import (
 	"context"
 	"time"
 
-	"github.com/google/go-cmp/cmp"
 	"github.com/pkg/errors"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -31,13 +30,16 @@ import (
 	"github.com/crossplane/crossplane-runtime/pkg/connection"
 	"github.com/crossplane/crossplane-runtime/pkg/controller"
 	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/feature"
 	"github.com/crossplane/crossplane-runtime/pkg/meta"
 	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
 	"github.com/crossplane/crossplane-runtime/pkg/resource"
 
 	"github.com/VariableExp0rt/powerbroker/apis/powerbroker/v1alpha1"
 	apisv1alpha1 "github.com/VariableExp0rt/powerbroker/apis/v1alpha1"
+	"github.com/VariableExp0rt/powerbroker/internal/controller/drift"
 	"github.com/VariableExp0rt/powerbroker/internal/controller/features"
+	"github.com/VariableExp0rt/powerbroker/internal/controller/policy"
 	service "github.com/VariableExp0rt/powerbroker/internal/service"
 	personasvc "github.com/VariableExp0rt/powerbroker/internal/service/persona"
 	svctypes "github.com/VariableExp0rt/powerbroker/internal/service/types"
@@ -50,11 +52,14 @@ const (
 	errTrackPCUsage = "cannot track ProviderConfig usage"
 	errGetPC        = "cannot get ProviderConfig"
 	errGetCreds     = "cannot get credentials"
+	errNewService   = "cannot create new service client"
+	errLookup       = "cannot look up persona by managed resource UID"
+	errNoExtName    = "cannot observe persona: an ObserveOnly Persona must set the name or uuid of its persona as its external name"
 )
 
-var (
-	errNewService = errors.New("cannot create new service client")
-)
+// reasonUpdated is the reason of the event recorded when an update changes
+// the relationships of a Persona.
+const reasonUpdated event.Reason = "UpdatedRelationships"
 
 // Setup adds a controller that reconciles Persona managed resources.
 func Setup(mgr ctrl.Manager, o controller.Options) error {
@@ -65,6 +70,8 @@ func Setup(mgr ctrl.Manager, o controller.Options) error {
 		cps = append(cps, connection.NewDetailsManager(mgr.GetClient(), apisv1alpha1.StoreConfigGroupVersionKind))
 	}
 
+	recorder := event.NewAPIRecorder(mgr.GetEventRecorderFor(name))
+
 	return ctrl.NewControllerManagedBy(mgr).
 		Named(name).
 		WithOptions(o.ForControllerRuntime()).
@@ -72,18 +79,28 @@ func Setup(mgr ctrl.Manager, o controller.Options) error {
 		Complete(managed.NewReconciler(mgr,
 			resource.ManagedKind(v1alpha1.PersonaGroupVersionKind),
 			managed.WithExternalConnecter(&connector{
-				kube:  mgr.GetClient(),
-				usage: resource.NewProviderConfigUsageTracker(mgr.GetClient(), &apisv1alpha1.ProviderConfigUsage{}),
-				util:  &connectorHelper{},
+				kube:     mgr.GetClient(),
+				usage:    resource.NewProviderConfigUsageTracker(mgr.GetClient(), &apisv1alpha1.ProviderConfigUsage{}),
+				record:   recorder,
+				features: o.Features,
+				util:     &connectorHelper{},
 			}),
 			managed.WithCreationGracePeriod(10*time.Second),
-			managed.WithInitializers(managed.NewDefaultProviderConfig(mgr.GetClient())),
+			managed.WithInitializers(initializers(mgr.GetClient())...),
 			managed.WithReferenceResolver(managed.NewAPISimpleReferenceResolver(mgr.GetClient())),
 			managed.WithLogger(o.Logger.WithValues("controller", name)),
-			managed.WithRecorder(event.NewAPIRecorder(mgr.GetEventRecorderFor(name))),
+			managed.WithRecorder(recorder),
 			managed.WithConnectionPublishers(cps...)))
 }
 
+// initializers initialize a Persona before it is observed. Unlike the
+// default initializers of a managed reconciler, they never set the external
+// name of a Persona to its name, so that a Persona whose external name is
+// lost adopts the persona created for it by its UID.
+func initializers(kube client.Client) []managed.Initializer {
+	return []managed.Initializer{managed.NewDefaultProviderConfig(kube)}
+}
+
 type Connector interface {
 	GetService(repo service.Repository) personasvc.Service
 	ExtractCredentials(context.Context, v1.CredentialsSource, client.Client, v1.CommonCredentialSelectors) ([]byte, error)
@@ -100,9 +117,13 @@ func (c *connectorHelper) ExtractCredentials(ctx context.Context, source v1.Cred
 }
 
 type connector struct {
-	kube  client.Client
-	usage resource.Tracker
-	util  Connector
+	kube   client.Client
+	usage  resource.Tracker
+	util   Connector
+	record event.Recorder
+
+	// features decides whether the management policy of a Persona is honoured.
+	features *feature.Flags
 }
 
 func (c *connector) Connect(ctx context.Context, mg resource.Managed) (managed.ExternalClient, error) {
@@ -127,24 +148,42 @@ func (c *connector) Connect(ctx context.Context, mg resource.Managed) (managed.E
 		return nil, errors.Wrap(err, errGetCreds)
 	}
 
-	var service personasvc.Service
-	switch pc.Spec.Storage.Type {
-	case "neo4j":
-		store, err := storage.NewNeo4jStorage(data)
-		if err != nil {
-			return nil, errors.Wrap(err, "client")
-		}
-		service = personasvc.NewService(store)
-	default:
-		return nil, errNewService
+	cfg, err := storage.ResolveConfig(ctx, c.kube, pc, data)
+	if err != nil {
+		return nil, errors.Wrap(err, errGetCreds)
+	}
+
+	store, err := storage.DefaultPool.Get(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, errNewService)
 	}
 
-	return &external{service: service, kube: c.kube}, nil
+	ext := &external{
+		service:     c.util.GetService(store),
+		kube:        c.kube,
+		record:      c.record,
+		timeout:     cfg.Timeout,
+		observeOnly: policy.ObserveOnly(c.features, cr.Spec.ManagementPolicy),
+	}
+	if ext.observeOnly {
+		return policy.NewObserveOnlyClient(ext), nil
+	}
+
+	return ext, nil
 }
 
 type external struct {
 	kube    client.Client
 	service personasvc.Service
+	record  event.Recorder
+
+	// timeout bounds each operation on the storage. Operations are
+	// unbounded when it is zero.
+	timeout time.Duration
+
+	// observeOnly is true if the Persona may only observe its persona, which it
+	// then also finds by name.
+	observeOnly bool
 }
 
 func (e *external) Observe(ctx context.Context, mg resource.Managed) (managed.ExternalObservation, error) {
@@ -153,14 +192,32 @@ func (e *external) Observe(ctx context.Context, mg resource.Managed) (managed.Ex
 		return managed.ExternalObservation{}, errors.New(errNotPersona)
 	}
 
+	ctx, cancel := storage.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	ctx = storetypes.WithResource(ctx, string(cr.GetUID()))
+
+	var adopted bool
 	if meta.GetExternalName(cr) == "" {
-		return managed.ExternalObservation{ResourceExists: false}, nil
+		// An ObserveOnly Persona never creates its persona, so there is none
+		// to find by its UID, and it waits to be told which persona to observe.
+		if e.observeOnly {
+			return managed.ExternalObservation{}, errors.New(errNoExtName)
+		}
+		var err error
+		if adopted, err = e.adopt(ctx, cr); err != nil || !adopted {
+			return managed.ExternalObservation{ResourceExists: false}, err
+		}
 	}
 
-	currentRefs := cr.Spec.ForProvider.DeepCopy().PermissionSets
+	desired := cr.Spec.ForProvider.DeepCopy()
 
-	resp, err := e.service.GetPersona(meta.GetExternalName(cr))
+	resp, err := e.service.GetPersona(ctx, meta.GetExternalName(cr))
+	if e.observeOnly && storetypes.IsEntityNotFoundNeo4jErr(err) {
+		resp, err = e.adoptByName(ctx, cr)
+		adopted = err == nil
+	}
 	if err != nil {
+		setUnavailable(cr, err)
 		return managed.ExternalObservation{},
 			errors.Wrap(resource.Ignore(storetypes.IsEntityNotFoundNeo4jErr, err), "cannot get persona")
 	}
@@ -173,10 +230,13 @@ func (e *external) Observe(ctx context.Context, mg resource.Managed) (managed.Ex
 		cr.SetConditions(v1.Unavailable())
 	}
 
+	diff := drift.Diff(*desired, v1alpha1.PersonaParameters{Name: resp.Name, PermissionSets: resp.References})
+
 	return managed.ExternalObservation{
-		ResourceExists:   true,
-		ResourceUpToDate: cmp.Equal(currentRefs, resp.References),
-		Diff:             cmp.Diff(currentRefs, resp.References),
+		ResourceExists:          true,
+		ResourceLateInitialized: adopted,
+		ResourceUpToDate:        diff == "",
+		Diff:                    diff,
 	}, nil
 }
 
@@ -186,10 +246,16 @@ func (e *external) Create(ctx context.Context, mg resource.Managed) (managed.Ext
 		return managed.ExternalCreation{}, errors.New(errNotPersona)
 	}
 
+	ctx, cancel := storage.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	ctx = storetypes.WithResource(ctx, string(cr.GetUID()))
+
 	references := cr.Spec.ForProvider.DeepCopy().PermissionSets
 
 	cr.SetConditions(v1.Creating())
 	uuid, err := e.service.CreatePersona(
+		ctx,
+		string(cr.GetUID()),
 		cr.Spec.ForProvider.Name,
 		references,
 	)
@@ -203,15 +269,27 @@ func (e *external) Update(ctx context.Context, mg resource.Managed) (managed.Ext
 		return managed.ExternalUpdate{}, nil
 	}
 
+	ctx, cancel := storage.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	ctx = storetypes.WithResource(ctx, string(cr.GetUID()))
+
 	references := cr.Spec.ForProvider.DeepCopy().PermissionSets
 
-	err := e.service.UpdatePersona(
-		cr.GetName(),
+	changes, err := e.service.UpdatePersona(
+		ctx,
+		cr.Spec.ForProvider.Name,
 		meta.GetExternalName(cr),
 		references,
 	)
+	if err != nil {
+		return managed.ExternalUpdate{}, errors.Wrap(err, "cannot update persona")
+	}
+
+	if !changes.Empty() {
+		e.record.Event(cr, event.Normal(reasonUpdated, changes.String()))
+	}
 
-	return managed.ExternalUpdate{}, errors.Wrap(err, "cannot update persona")
+	return managed.ExternalUpdate{}, nil
 }
 
 func (e *external) Delete(ctx context.Context, mg resource.Managed) error {
@@ -220,16 +298,58 @@ func (e *external) Delete(ctx context.Context, mg resource.Managed) error {
 		return nil
 	}
 
+	ctx, cancel := storage.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	ctx = storetypes.WithResource(ctx, string(cr.GetUID()))
+
 	cr.SetConditions(v1.Deleting())
-	err := e.service.DeletePersona(meta.GetExternalName(cr))
+	err := e.service.DeletePersona(ctx, meta.GetExternalName(cr))
 
 	return errors.Wrap(resource.Ignore(storetypes.IsEntityNotFoundNeo4jErr, err), "cannot delete persona")
 }
 
+// adopt sets the external name of a Persona which has none to the uuid of the
+// persona created for it, if any. This happens when the provider fails to record
+// the external name after creating the persona.
+func (e *external) adopt(ctx context.Context, cr *v1alpha1.Persona) (bool, error) {
+	uuid, err := e.service.LookupPersona(ctx, string(cr.GetUID()))
+	if err != nil {
+		setUnavailable(cr, err)
+		return false, errors.Wrap(resource.Ignore(storetypes.IsEntityNotFoundNeo4jErr, err), errLookup)
+	}
+
+	meta.SetExternalName(cr, uuid)
+	return true, nil
+}
+
+// adoptByName sets the external name of a Persona which only observes a persona to
+// the uuid of the persona it names, and returns that persona. Nodes created by other
+// tooling are usually known by their name rather than their uuid.
+func (e *external) adoptByName(ctx context.Context, cr *v1alpha1.Persona) (*svctypes.GetPersonaResponse, error) {
+	uuid, err := e.service.LookupPersonaByName(ctx, meta.GetExternalName(cr))
+	if err != nil {
+		return nil, err
+	}
+
+	meta.SetExternalName(cr, uuid)
+	return e.service.GetPersona(ctx, uuid)
+}
+
+// setUnavailable marks a Persona Unavailable if err means its storage cannot be
+// used. The Persona then reports why it is not ready, as well as that it failed
+// to reconcile.
+func setUnavailable(cr *v1alpha1.Persona, err error) {
+	if storage.Unavailable(err) {
+		cr.SetConditions(v1.Unavailable().WithMessage(err.Error()))
+	}
+}
+
 func generatePersonaObservation(r *svctypes.GetPersonaResponse) v1alpha1.PersonaObservation {
 	return v1alpha1.PersonaObservation{
-		NodeID: r.NodeID,
-		Status: string(r.Status),
+		NodeID:         r.NodeID,
+		Status:         string(r.Status),
+		Name:           r.Name,
+		PermissionSets: r.References,
 	}
 }
 
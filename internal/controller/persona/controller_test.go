@@ -21,6 +21,7 @@ import (
 	"testing"
 
 	"github.com/VariableExp0rt/powerbroker/apis/powerbroker/v1alpha1"
+	"github.com/VariableExp0rt/powerbroker/internal/controller/drift"
 	"github.com/VariableExp0rt/powerbroker/internal/service"
 	svctypes "github.com/VariableExp0rt/powerbroker/internal/service/types"
 	"github.com/VariableExp0rt/powerbroker/internal/storage/types"
@@ -91,6 +92,9 @@ type args struct {
 	kube       kclient.Client
 	repository service.Repository
 	cr         *v1alpha1.Persona
+
+	// observeOnly is true if the Persona may only observe its node.
+	observeOnly bool
 }
 
 func TestObserve(t *testing.T) {
@@ -110,8 +114,9 @@ func TestObserve(t *testing.T) {
 					MockUpdate: test.NewMockClient().Update,
 				},
 				repository: &service.MockRepository{
-					MockGetPersona: func(uuid string) (*svctypes.GetPersonaResponse, error) {
+					MockGetPersona: func(ctx context.Context, uuid string) (*svctypes.GetPersonaResponse, error) {
 						return &svctypes.GetPersonaResponse{
+							Name:       personaName,
 							References: permissionSetRefs,
 							NodeID:     uuid,
 							Status:     "available",
@@ -134,8 +139,10 @@ func TestObserve(t *testing.T) {
 						PermissionSets: permissionSetRefs,
 					}),
 					withStatus(v1alpha1.PersonaObservation{
-						NodeID: externalName,
-						Status: string(types.StatusAvailable),
+						NodeID:         externalName,
+						Status:         string(types.StatusAvailable),
+						Name:           personaName,
+						PermissionSets: permissionSetRefs,
 					}),
 				),
 				o: managed.ExternalObservation{
@@ -150,8 +157,9 @@ func TestObserve(t *testing.T) {
 					MockUpdate: test.NewMockClient().Update,
 				},
 				repository: &service.MockRepository{
-					MockGetPersona: func(uuid string) (*svctypes.GetPersonaResponse, error) {
+					MockGetPersona: func(ctx context.Context, uuid string) (*svctypes.GetPersonaResponse, error) {
 						return &svctypes.GetPersonaResponse{
+							Name:       personaName,
 							References: []string{"super-admin-customer1-001"},
 							NodeID:     uuid,
 							Status:     "available",
@@ -174,21 +182,252 @@ func TestObserve(t *testing.T) {
 					}),
 					withConditions(v1.Available()),
 					withStatus(v1alpha1.PersonaObservation{
-						NodeID: externalName,
-						Status: string(types.StatusAvailable),
+						NodeID:         externalName,
+						Status:         string(types.StatusAvailable),
+						Name:           personaName,
+						PermissionSets: []string{"super-admin-customer1-001"},
 					}),
 				),
 				o: managed.ExternalObservation{
 					ResourceExists:   true,
 					ResourceUpToDate: false,
-					Diff: cmp.Diff(
-						permissionSetRefs,
-						[]string{"super-admin-customer1-001"},
+					Diff: drift.Diff(
+						v1alpha1.PersonaParameters{Name: personaName, PermissionSets: permissionSetRefs},
+						v1alpha1.PersonaParameters{Name: personaName, PermissionSets: []string{"super-admin-customer1-001"}},
 					),
 				},
 				err: nil,
 			},
 		},
+		"NameDrifted": {
+			args: args{
+				repository: &service.MockRepository{
+					MockGetPersona: func(ctx context.Context, uuid string) (*svctypes.GetPersonaResponse, error) {
+						return &svctypes.GetPersonaResponse{
+							Name:       "renamed-out-of-band",
+							References: permissionSetRefs,
+							NodeID:     uuid,
+							Status:     "available",
+						}, nil
+					},
+				},
+				cr: persona(
+					withExternalName(externalName),
+					withSpec(v1alpha1.PersonaParameters{
+						Name:           personaName,
+						PermissionSets: permissionSetRefs,
+					})),
+			},
+			want: want{
+				cr: persona(
+					withConditions(v1.Available()),
+					withExternalName(externalName),
+					withSpec(v1alpha1.PersonaParameters{
+						Name:           personaName,
+						PermissionSets: permissionSetRefs,
+					}),
+					withStatus(v1alpha1.PersonaObservation{
+						NodeID:         externalName,
+						Status:         string(types.StatusAvailable),
+						Name:           "renamed-out-of-band",
+						PermissionSets: permissionSetRefs,
+					}),
+				),
+				o: managed.ExternalObservation{
+					ResourceExists:   true,
+					ResourceUpToDate: false,
+					Diff: drift.Diff(
+						v1alpha1.PersonaParameters{Name: personaName, PermissionSets: permissionSetRefs},
+						v1alpha1.PersonaParameters{Name: "renamed-out-of-band", PermissionSets: permissionSetRefs},
+					),
+				},
+			},
+		},
+		"OrderIgnored": {
+			args: args{
+				repository: &service.MockRepository{
+					MockGetPersona: func(ctx context.Context, uuid string) (*svctypes.GetPersonaResponse, error) {
+						return &svctypes.GetPersonaResponse{
+							Name:       personaName,
+							References: []string{permissionSetRefs[1], permissionSetRefs[0]},
+							NodeID:     uuid,
+							Status:     "available",
+						}, nil
+					},
+				},
+				cr: persona(
+					withExternalName(externalName),
+					withSpec(v1alpha1.PersonaParameters{
+						Name:           personaName,
+						PermissionSets: permissionSetRefs,
+					})),
+			},
+			want: want{
+				cr: persona(
+					withConditions(v1.Available()),
+					withExternalName(externalName),
+					withSpec(v1alpha1.PersonaParameters{
+						Name:           personaName,
+						PermissionSets: permissionSetRefs,
+					}),
+					withStatus(v1alpha1.PersonaObservation{
+						NodeID:         externalName,
+						Status:         string(types.StatusAvailable),
+						Name:           personaName,
+						PermissionSets: []string{permissionSetRefs[1], permissionSetRefs[0]},
+					}),
+				),
+				o: managed.ExternalObservation{
+					ResourceExists:   true,
+					ResourceUpToDate: true,
+				},
+			},
+		},
+		"AdoptedByName": {
+			args: args{
+				observeOnly: true,
+				repository: &service.MockRepository{
+					MockLookupPersonaByName: func(ctx context.Context, name string) (string, error) {
+						if name != personaName {
+							return "", &types.EntityNotFoundError{}
+						}
+						return externalName, nil
+					},
+					MockGetPersona: func(ctx context.Context, uuid string) (*svctypes.GetPersonaResponse, error) {
+						if uuid != externalName {
+							return nil, &types.EntityNotFoundError{}
+						}
+						return &svctypes.GetPersonaResponse{
+							Name:       personaName,
+							NodeID:     externalName,
+							References: permissionSetRefs,
+							Status:     "available",
+						}, nil
+					},
+				},
+				cr: persona(
+					withExternalName(personaName),
+					withSpec(v1alpha1.PersonaParameters{
+						Name: personaName,
+					}),
+				),
+			},
+			want: want{
+				cr: persona(
+					withConditions(v1.Available()),
+					withExternalName(externalName),
+					withSpec(v1alpha1.PersonaParameters{
+						Name: personaName,
+					}),
+					withStatus(v1alpha1.PersonaObservation{
+						NodeID:         externalName,
+						Status:         string(types.StatusAvailable),
+						Name:           personaName,
+						PermissionSets: permissionSetRefs,
+					}),
+				),
+				o: managed.ExternalObservation{
+					ResourceExists:          true,
+					ResourceLateInitialized: true,
+					ResourceUpToDate:        false,
+					Diff: drift.Diff(
+						v1alpha1.PersonaParameters{Name: personaName},
+						v1alpha1.PersonaParameters{Name: personaName, PermissionSets: permissionSetRefs},
+					),
+				},
+			},
+		},
+		"ObserveOnlyWithoutExternalName": {
+			args: args{
+				observeOnly: true,
+				repository: &service.MockRepository{
+					MockLookupPersona: func(ctx context.Context, uid string) (string, error) {
+						t.Errorf("LookupPersona(...): an ObserveOnly Persona should not adopt a persona by UID")
+						return externalName, nil
+					},
+					MockLookupPersonaByName: func(ctx context.Context, name string) (string, error) {
+						t.Errorf("LookupPersonaByName(...): an ObserveOnly Persona should not adopt a persona by its own name")
+						return externalName, nil
+					},
+				},
+				cr: persona(withSpec(v1alpha1.PersonaParameters{Name: personaName})),
+			},
+			want: want{
+				cr:  persona(withSpec(v1alpha1.PersonaParameters{Name: personaName})),
+				err: errors.New(errNoExtName),
+			},
+		},
+		"AdoptedByUID": {
+			args: args{
+				repository: &service.MockRepository{
+					MockLookupPersona: func(ctx context.Context, uid string) (string, error) {
+						return externalName, nil
+					},
+					MockGetPersona: func(ctx context.Context, uuid string) (*svctypes.GetPersonaResponse, error) {
+						return &svctypes.GetPersonaResponse{
+							Name:       personaName,
+							References: permissionSetRefs,
+							NodeID:     uuid,
+							Status:     "available",
+						}, nil
+					},
+				},
+				cr: persona(
+					withSpec(v1alpha1.PersonaParameters{
+						Name:           personaName,
+						PermissionSets: permissionSetRefs,
+					})),
+			},
+			want: want{
+				cr: persona(
+					withConditions(v1.Available()),
+					withExternalName(externalName),
+					withSpec(v1alpha1.PersonaParameters{
+						Name:           personaName,
+						PermissionSets: permissionSetRefs,
+					}),
+					withStatus(v1alpha1.PersonaObservation{
+						NodeID:         externalName,
+						Status:         string(types.StatusAvailable),
+						Name:           personaName,
+						PermissionSets: permissionSetRefs,
+					}),
+				),
+				o: managed.ExternalObservation{
+					ResourceExists:          true,
+					ResourceLateInitialized: true,
+					ResourceUpToDate:        true,
+				},
+			},
+		},
+		"NotFoundByUID": {
+			args: args{
+				repository: &service.MockRepository{
+					MockLookupPersona: func(ctx context.Context, uid string) (string, error) {
+						return "", &types.EntityNotFoundError{}
+					},
+				},
+				cr: persona(),
+			},
+			want: want{
+				cr: persona(),
+				o:  managed.ExternalObservation{ResourceExists: false},
+			},
+		},
+		"LookupFailed": {
+			args: args{
+				repository: &service.MockRepository{
+					MockLookupPersona: func(ctx context.Context, uid string) (string, error) {
+						return "", errInternalServer
+					},
+				},
+				cr: persona(),
+			},
+			want: want{
+				cr:  persona(),
+				err: errors.Wrap(errInternalServer, errLookup),
+			},
+		},
 		"GetFailedInternalError": {
 			args: args{
 				cr: persona(
@@ -203,7 +442,7 @@ func TestObserve(t *testing.T) {
 					MockUpdate: test.NewMockClient().Update,
 				},
 				repository: &service.MockRepository{
-					MockGetPersona: func(uuid string) (*svctypes.GetPersonaResponse, error) {
+					MockGetPersona: func(ctx context.Context, uuid string) (*svctypes.GetPersonaResponse, error) {
 						return nil, errInternalServer
 					},
 				},
@@ -224,7 +463,7 @@ func TestObserve(t *testing.T) {
 
 	for name, tc := range cases {
 		t.Run(name, func(t *testing.T) {
-			e := external{service: tc.args.repository}
+			e := external{service: tc.args.repository, observeOnly: tc.args.observeOnly}
 			o, err := e.Observe(context.Background(), tc.args.cr)
 
 			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
@@ -260,7 +499,7 @@ func TestCreate(t *testing.T) {
 					}),
 				),
 				repository: &service.MockRepository{
-					MockCreatePersona: func(personaName string, permissionSetRefs []string) (string, error) {
+					MockCreatePersona: func(ctx context.Context, uid string, personaName string, permissionSetRefs []string) (string, error) {
 						return externalName, nil
 					},
 				},
@@ -287,7 +526,7 @@ func TestCreate(t *testing.T) {
 					}),
 				),
 				repository: &service.MockRepository{
-					MockCreatePersona: func(personaName string, permissionSetRefs []string) (string, error) {
+					MockCreatePersona: func(ctx context.Context, uid string, personaName string, permissionSetRefs []string) (string, error) {
 						return "", errInternalServer
 					},
 				},
@@ -339,15 +578,16 @@ func TestUpdate(t *testing.T) {
 					MockUpdate: test.NewMockClient().Update,
 				},
 				repository: &service.MockRepository{
-					MockGetPersona: func(uuid string) (*svctypes.GetPersonaResponse, error) {
+					MockGetPersona: func(ctx context.Context, uuid string) (*svctypes.GetPersonaResponse, error) {
 						return &svctypes.GetPersonaResponse{
+							Name:       personaName,
 							References: append(permissionSetRefs, "some-new-persona"),
 							NodeID:     uuid,
 							Status:     "available",
 						}, nil
 					},
-					MockUpdatePersona: func(personaName, personaUuid string, permissionSetUuids []string) error {
-						return nil
+					MockUpdatePersona: func(ctx context.Context, personaName, personaUuid string, permissionSetUuids []string) (svctypes.Changes, error) {
+						return svctypes.Changes{}, nil
 					},
 				},
 				cr: persona(
@@ -373,15 +613,16 @@ func TestUpdate(t *testing.T) {
 					MockUpdate: test.NewMockClient().Update,
 				},
 				repository: &service.MockRepository{
-					MockGetPersona: func(uuid string) (*svctypes.GetPersonaResponse, error) {
+					MockGetPersona: func(ctx context.Context, uuid string) (*svctypes.GetPersonaResponse, error) {
 						return &svctypes.GetPersonaResponse{
+							Name:       personaName,
 							References: permissionSetRefs,
 							NodeID:     uuid,
 							Status:     "unavailable",
 						}, nil
 					},
-					MockUpdatePersona: func(personaName, personaUuid string, permissionSetUuids []string) error {
-						return errInternalServer
+					MockUpdatePersona: func(ctx context.Context, personaName, personaUuid string, permissionSetUuids []string) (svctypes.Changes, error) {
+						return svctypes.Changes{}, errInternalServer
 					},
 				},
 				cr: persona(
@@ -432,7 +673,7 @@ func TestDelete(t *testing.T) {
 			args: args{
 				cr: persona(),
 				repository: &service.MockRepository{
-					MockDeletePersona: func(personaUuid string) error {
+					MockDeletePersona: func(ctx context.Context, personaUuid string) error {
 						return nil
 					},
 				},
@@ -446,7 +687,7 @@ func TestDelete(t *testing.T) {
 			args: args{
 				cr: persona(),
 				repository: &service.MockRepository{
-					MockDeletePersona: func(personaUuid string) error {
+					MockDeletePersona: func(ctx context.Context, personaUuid string) error {
 						return errInternalServer
 					},
 				},
@@ -0,0 +1,89 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package policy applies the management policy of a managed resource to the
+// client of its node.
+package policy
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/crossplane/crossplane-runtime/pkg/feature"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+
+	"github.com/VariableExp0rt/powerbroker/apis/powerbroker/v1alpha1"
+	"github.com/VariableExp0rt/powerbroker/internal/controller/features"
+)
+
+const (
+	errNotFound = "cannot observe node: it does not exist, and the ObserveOnly management policy forbids creating it"
+	errCreate   = "cannot create node: the ObserveOnly management policy forbids it"
+)
+
+// ObserveOnly returns true if management policies are enabled and p only
+// lets the provider observe a node.
+func ObserveOnly(f *feature.Flags, p v1alpha1.ManagementPolicy) bool {
+	return f.Enabled(features.EnableAlphaManagementPolicies) && p == v1alpha1.ManagementObserveOnly
+}
+
+// NewObserveOnlyClient returns an ExternalClient which observes a node
+// through c, but never writes to it.
+func NewObserveOnlyClient(c managed.ExternalClient) managed.ExternalClient {
+	return &observeOnly{client: c}
+}
+
+type observeOnly struct {
+	client managed.ExternalClient
+}
+
+// Observe reports a node which does not exist as an error rather than
+// asking for it to be created, and one which does as up to date so that it
+// is never updated. A managed resource which is being deleted no longer
+// observes its node, which is left as it is.
+func (o *observeOnly) Observe(ctx context.Context, mg resource.Managed) (managed.ExternalObservation, error) {
+	if meta.WasDeleted(mg) {
+		return managed.ExternalObservation{ResourceExists: false}, nil
+	}
+
+	obs, err := o.client.Observe(ctx, mg)
+	if err != nil {
+		return managed.ExternalObservation{}, err
+	}
+	if !obs.ResourceExists {
+		return managed.ExternalObservation{}, errors.New(errNotFound)
+	}
+
+	obs.ResourceUpToDate = true
+	obs.Diff = ""
+
+	return obs, nil
+}
+
+func (o *observeOnly) Create(context.Context, resource.Managed) (managed.ExternalCreation, error) {
+	return managed.ExternalCreation{}, errors.New(errCreate)
+}
+
+func (o *observeOnly) Update(context.Context, resource.Managed) (managed.ExternalUpdate, error) {
+	return managed.ExternalUpdate{}, nil
+}
+
+func (o *observeOnly) Delete(context.Context, resource.Managed) error {
+	return nil
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/crossplane/crossplane-runtime/pkg/feature"
+	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/VariableExp0rt/powerbroker/apis/powerbroker/v1alpha1"
+	"github.com/VariableExp0rt/powerbroker/internal/controller/features"
+)
+
+var errBoom = errors.New("boom")
+
+func TestObserveOnly(t *testing.T) {
+	enabled := &feature.Flags{}
+	enabled.Enable(features.EnableAlphaManagementPolicies)
+
+	cases := map[string]struct {
+		flags  *feature.Flags
+		policy v1alpha1.ManagementPolicy
+		want   bool
+	}{
+		"Disabled":    {flags: &feature.Flags{}, policy: v1alpha1.ManagementObserveOnly},
+		"FullControl": {flags: enabled, policy: v1alpha1.ManagementFullControl},
+		"Default":     {flags: enabled},
+		"ObserveOnly": {flags: enabled, policy: v1alpha1.ManagementObserveOnly, want: true},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := ObserveOnly(tc.flags, tc.policy); got != tc.want {
+				t.Errorf("ObserveOnly(...): want %t, got %t", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestObserveOnlyClientObserve(t *testing.T) {
+	deleted := metav1.Now()
+
+	type want struct {
+		o   managed.ExternalObservation
+		err error
+	}
+
+	cases := map[string]struct {
+		mg      resource.Managed
+		observe func(context.Context, resource.Managed) (managed.ExternalObservation, error)
+		want    want
+	}{
+		"UpToDateDespiteDiff": {
+			mg: &v1alpha1.User{},
+			observe: func(context.Context, resource.Managed) (managed.ExternalObservation, error) {
+				return managed.ExternalObservation{ResourceExists: true, ResourceLateInitialized: true, Diff: "drifted"}, nil
+			},
+			want: want{
+				o: managed.ExternalObservation{ResourceExists: true, ResourceLateInitialized: true, ResourceUpToDate: true},
+			},
+		},
+		"NotFound": {
+			mg: &v1alpha1.User{},
+			observe: func(context.Context, resource.Managed) (managed.ExternalObservation, error) {
+				return managed.ExternalObservation{ResourceExists: false}, nil
+			},
+			want: want{err: errors.New(errNotFound)},
+		},
+		"ObserveFailed": {
+			mg: &v1alpha1.User{},
+			observe: func(context.Context, resource.Managed) (managed.ExternalObservation, error) {
+				return managed.ExternalObservation{}, errBoom
+			},
+			want: want{err: errBoom},
+		},
+		"Deleting": {
+			mg: &v1alpha1.User{ObjectMeta: metav1.ObjectMeta{DeletionTimestamp: &deleted}},
+			observe: func(context.Context, resource.Managed) (managed.ExternalObservation, error) {
+				t.Errorf("Observe(...): a managed resource being deleted should not observe its node")
+				return managed.ExternalObservation{ResourceExists: true}, nil
+			},
+			want: want{o: managed.ExternalObservation{ResourceExists: false}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			c := NewObserveOnlyClient(&managed.ExternalClientFns{ObserveFn: tc.observe})
+			o, err := c.Observe(context.Background(), tc.mg)
+
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("Observe(...): -want error, +got error:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.o, o); diff != "" {
+				t.Errorf("Observe(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestObserveOnlyClientNeverWrites(t *testing.T) {
+	write := func() { t.Errorf("a client which only observes should never write") }
+	c := NewObserveOnlyClient(&managed.ExternalClientFns{
+		CreateFn: func(context.Context, resource.Managed) (managed.ExternalCreation, error) {
+			write()
+			return managed.ExternalCreation{}, nil
+		},
+		UpdateFn: func(context.Context, resource.Managed) (managed.ExternalUpdate, error) {
+			write()
+			return managed.ExternalUpdate{}, nil
+		},
+		DeleteFn: func(context.Context, resource.Managed) error {
+			write()
+			return nil
+		},
+	})
+	ctx := context.Background()
+
+	if _, err := c.Create(ctx, &v1alpha1.User{}); err == nil {
+		t.Errorf("Create(...): want an error")
+	}
+	if _, err := c.Update(ctx, &v1alpha1.User{}); err != nil {
+		t.Errorf("Update(...): %v", err)
+	}
+	if err := c.Delete(ctx, &v1alpha1.User{}); err != nil {
+		t.Errorf("Delete(...): %v", err)
+	}
+}
@@ -0,0 +1,483 @@
+/*
+Copyright 2022 The Crossplane Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package role
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	v1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/VariableExp0rt/powerbroker/apis/powerbroker/v1alpha1"
+	"github.com/VariableExp0rt/powerbroker/internal/controller/drift"
+	"github.com/VariableExp0rt/powerbroker/internal/service"
+	"github.com/VariableExp0rt/powerbroker/internal/service/types"
+	"github.com/VariableExp0rt/powerbroker/internal/storage/neo4j/transaction"
+	storetypes "github.com/VariableExp0rt/powerbroker/internal/storage/types"
+)
+
+var _ managed.ExternalClient = &external{}
+var _ managed.ExternalConnecter = &connector{}
+
+var (
+	externalName = "ReadOnlyAccess"
+	params       = v1alpha1.RoleParameters{
+		Cloud:              v1alpha1.CloudAWS,
+		Name:               externalName,
+		Description:        "Read only access to every service",
+		Tier:               v1alpha1.RoleTierModerate,
+		PolicyDocument:     `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["s3:Get*"],"Resource":"*"}]}`,
+		MaxSessionDuration: &metav1.Duration{Duration: time.Hour},
+	}
+	observation = v1alpha1.RoleObservation{
+		NodeID:             externalName,
+		Status:             transaction.StatusAvailable,
+		Description:        params.Description,
+		Tier:               params.Tier,
+		PolicyDocument:     params.PolicyDocument,
+		MaxSessionDuration: params.MaxSessionDuration,
+	}
+	errInternalServer = &storetypes.InternalError{}
+)
+
+type roleModifier = func(*v1alpha1.Role)
+
+func withConditions(cnds ...v1.Condition) roleModifier {
+	return func(r *v1alpha1.Role) {
+		r.SetConditions(cnds...)
+	}
+}
+
+func withSpec(p v1alpha1.RoleParameters) roleModifier {
+	return func(r *v1alpha1.Role) {
+		r.Spec.ForProvider = p
+	}
+}
+
+func withStatus(o v1alpha1.RoleObservation) roleModifier {
+	return func(r *v1alpha1.Role) {
+		r.Status.AtProvider = o
+	}
+}
+
+func withExternalName(name string) roleModifier {
+	return func(r *v1alpha1.Role) {
+		meta.SetExternalName(r, name)
+	}
+}
+
+func role(opts ...roleModifier) *v1alpha1.Role {
+	r := &v1alpha1.Role{}
+	for _, o := range opts {
+		o(r)
+	}
+
+	return r
+}
+
+type args struct {
+	cr      *v1alpha1.Role
+	service service.Repository
+}
+
+func TestObserve(t *testing.T) {
+	drifted := params
+	drifted.Tier = v1alpha1.RoleTierCritical
+
+	type want struct {
+		cr  *v1alpha1.Role
+		o   managed.ExternalObservation
+		err error
+	}
+
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"SuccessfulAvailable": {
+			args: args{
+				cr: role(withExternalName(externalName), withSpec(params)),
+				service: &service.MockRepository{
+					MockGetRole: func(ctx context.Context, cloud, name string) (*types.GetRoleResponse, error) {
+						return &types.GetRoleResponse{Role: params, Status: "available", NodeID: name}, nil
+					},
+				},
+			},
+			want: want{
+				cr: role(
+					withConditions(v1.Available()),
+					withExternalName(externalName),
+					withSpec(params),
+					withStatus(observation),
+				),
+				o: managed.ExternalObservation{
+					ResourceExists:   true,
+					ResourceUpToDate: true,
+				},
+			},
+		},
+		"DefaultsCloud": {
+			args: args{
+				cr: role(withExternalName(externalName), withSpec(func() v1alpha1.RoleParameters {
+					p := params
+					p.Cloud = ""
+					return p
+				}())),
+				service: &service.MockRepository{
+					MockGetRole: func(ctx context.Context, cloud, name string) (*types.GetRoleResponse, error) {
+						if cloud != v1alpha1.CloudAWS {
+							return &types.GetRoleResponse{Status: "deleted", NodeID: name}, &storetypes.EntityNotFoundError{}
+						}
+						return &types.GetRoleResponse{Role: params, Status: "available", NodeID: name}, nil
+					},
+				},
+			},
+			want: want{
+				cr: role(
+					withConditions(v1.Available()),
+					withExternalName(externalName),
+					withSpec(func() v1alpha1.RoleParameters {
+						p := params
+						p.Cloud = ""
+						return p
+					}()),
+					withStatus(observation),
+				),
+				o: managed.ExternalObservation{
+					ResourceExists:   true,
+					ResourceUpToDate: true,
+				},
+			},
+		},
+		"AdoptedByUID": {
+			args: args{
+				cr: role(withSpec(params)),
+				service: &service.MockRepository{
+					MockLookupRole: func(ctx context.Context, uid string) (string, error) {
+						return externalName, nil
+					},
+					MockGetRole: func(ctx context.Context, cloud, name string) (*types.GetRoleResponse, error) {
+						return &types.GetRoleResponse{Role: params, Status: "available", NodeID: name}, nil
+					},
+				},
+			},
+			want: want{
+				cr: role(
+					withConditions(v1.Available()),
+					withExternalName(externalName),
+					withSpec(params),
+					withStatus(observation),
+				),
+				o: managed.ExternalObservation{
+					ResourceExists:          true,
+					ResourceLateInitialized: true,
+					ResourceUpToDate:        true,
+				},
+			},
+		},
+		"TierDrifted": {
+			args: args{
+				cr: role(withExternalName(externalName), withSpec(params)),
+				service: &service.MockRepository{
+					MockGetRole: func(ctx context.Context, cloud, name string) (*types.GetRoleResponse, error) {
+						return &types.GetRoleResponse{Role: drifted, Status: "available", NodeID: name}, nil
+					},
+				},
+			},
+			want: want{
+				cr: role(
+					withConditions(v1.Available()),
+					withExternalName(externalName),
+					withSpec(params),
+					withStatus(func() v1alpha1.RoleObservation {
+						o := observation
+						o.Tier = v1alpha1.RoleTierCritical
+						return o
+					}()),
+				),
+				o: managed.ExternalObservation{
+					ResourceExists:   true,
+					ResourceUpToDate: false,
+					Diff:             drift.Diff(params, drifted),
+				},
+			},
+		},
+		"NotFoundByUID": {
+			args: args{
+				cr: role(),
+				service: &service.MockRepository{
+					MockLookupRole: func(ctx context.Context, uid string) (string, error) {
+						return "", &storetypes.EntityNotFoundError{}
+					},
+				},
+			},
+			want: want{
+				cr: role(),
+				o:  managed.ExternalObservation{ResourceExists: false},
+			},
+		},
+		"NotFound": {
+			args: args{
+				cr: role(withExternalName(externalName), withSpec(params)),
+				service: &service.MockRepository{
+					MockGetRole: func(ctx context.Context, cloud, name string) (*types.GetRoleResponse, error) {
+						return &types.GetRoleResponse{Status: "deleted", NodeID: name}, &storetypes.EntityNotFoundError{}
+					},
+				},
+			},
+			want: want{
+				cr: role(withExternalName(externalName), withSpec(params)),
+				o:  managed.ExternalObservation{ResourceExists: false},
+			},
+		},
+		"GetFailed": {
+			args: args{
+				cr: role(withExternalName(externalName), withSpec(params)),
+				service: &service.MockRepository{
+					MockGetRole: func(ctx context.Context, cloud, name string) (*types.GetRoleResponse, error) {
+						return nil, errInternalServer
+					},
+				},
+			},
+			want: want{
+				cr:  role(withExternalName(externalName), withSpec(params)),
+				err: errors.Wrap(errInternalServer, "cannot get role"),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			e := &external{service: tc.args.service}
+			o, err := e.Observe(context.Background(), tc.args.cr)
+
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.cr, tc.args.cr, test.EquateConditions()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.o, o); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCreate(t *testing.T) {
+	errManaged := &storetypes.ConflictError{Err: errors.New("role ReadOnlyAccess is managed by another Role")}
+
+	type want struct {
+		cr  *v1alpha1.Role
+		o   managed.ExternalCreation
+		err error
+	}
+
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"SuccessfulCreate": {
+			args: args{
+				cr: role(withSpec(params)),
+				service: &service.MockRepository{
+					MockCreateRole: func(ctx context.Context, uid string, role *v1alpha1.RoleParameters) (string, error) {
+						return role.Name, nil
+					},
+				},
+			},
+			want: want{
+				cr: role(
+					withConditions(v1.Creating()),
+					withExternalName(externalName),
+					withSpec(params),
+				),
+				o: managed.ExternalCreation{ExternalNameAssigned: true},
+			},
+		},
+		"InvalidPolicyDocument": {
+			args: args{
+				cr: role(withSpec(func() v1alpha1.RoleParameters {
+					p := params
+					p.PolicyDocument = "Allow *"
+					return p
+				}())),
+				service: &service.MockRepository{},
+			},
+			want: want{
+				cr: role(withSpec(func() v1alpha1.RoleParameters {
+					p := params
+					p.PolicyDocument = "Allow *"
+					return p
+				}())),
+				err: errors.New(errPolicy),
+			},
+		},
+		"ManagedByAnother": {
+			args: args{
+				cr: role(withSpec(params)),
+				service: &service.MockRepository{
+					MockCreateRole: func(ctx context.Context, uid string, role *v1alpha1.RoleParameters) (string, error) {
+						return "", errManaged
+					},
+				},
+			},
+			want: want{
+				cr:  role(withConditions(v1.Creating()), withSpec(params)),
+				err: errors.Wrap(errManaged, "cannot create role"),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			e := &external{service: tc.args.service}
+			o, err := e.Create(context.Background(), tc.args.cr)
+
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.cr, tc.args.cr, test.EquateConditions()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.o, o); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	renamed := params
+	renamed.Name = "PowerUserAccess"
+
+	invalid := params
+	invalid.PolicyDocument = `{"Version":`
+
+	cases := map[string]struct {
+		args args
+		want error
+	}{
+		"SuccessfulUpdate": {
+			args: args{
+				cr: role(withExternalName(externalName), withSpec(params)),
+				service: &service.MockRepository{
+					MockUpdateRole: func(ctx context.Context, cloud, name string, role *v1alpha1.RoleParameters) error {
+						if cloud != v1alpha1.CloudAWS || name != externalName {
+							t.Errorf("UpdateRole(...): want role %q in %q, got %q in %q", externalName, v1alpha1.CloudAWS, name, cloud)
+						}
+						return nil
+					},
+				},
+			},
+		},
+		"NameChanged": {
+			args: args{
+				cr:      role(withExternalName(externalName), withSpec(renamed)),
+				service: &service.MockRepository{},
+			},
+			want: errors.New(errImmutable),
+		},
+		"InvalidPolicyDocument": {
+			args: args{
+				cr:      role(withExternalName(externalName), withSpec(invalid)),
+				service: &service.MockRepository{},
+			},
+			want: errors.New(errPolicy),
+		},
+		"UpdateFailed": {
+			args: args{
+				cr: role(withExternalName(externalName), withSpec(params)),
+				service: &service.MockRepository{
+					MockUpdateRole: func(ctx context.Context, cloud, name string, role *v1alpha1.RoleParameters) error {
+						return errInternalServer
+					},
+				},
+			},
+			want: errors.Wrap(errInternalServer, "cannot update role"),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			e := &external{service: tc.args.service}
+			_, err := e.Update(context.Background(), tc.args.cr)
+
+			if diff := cmp.Diff(tc.want, err, test.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	cases := map[string]struct {
+		args args
+		want error
+	}{
+		"SuccessfulDelete": {
+			args: args{
+				cr: role(withExternalName(externalName)),
+				service: &service.MockRepository{
+					MockDeleteRole: func(ctx context.Context, cloud, name string) error {
+						return nil
+					},
+				},
+			},
+		},
+		"AlreadyDeleted": {
+			args: args{
+				cr: role(withExternalName(externalName)),
+				service: &service.MockRepository{
+					MockDeleteRole: func(ctx context.Context, cloud, name string) error {
+						return &storetypes.EntityNotFoundError{}
+					},
+				},
+			},
+		},
+		"DeleteFailed": {
+			args: args{
+				cr: role(withExternalName(externalName)),
+				service: &service.MockRepository{
+					MockDeleteRole: func(ctx context.Context, cloud, name string) error {
+						return errInternalServer
+					},
+				},
+			},
+			want: errors.Wrap(errInternalServer, "cannot delete role"),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			e := &external{service: tc.args.service}
+			err := e.Delete(context.Background(), tc.args.cr)
+
+			if diff := cmp.Diff(tc.want, err, test.EquateErrors()); diff != "" {
+				t.Errorf("r: -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -20,7 +20,6 @@ import (
 	"context"
 	"time"
 
-	"github.com/google/go-cmp/cmp"
 	"github.com/pkg/errors"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -37,6 +36,7 @@ import (
 	"github.com/VariableExp0rt/powerbroker/apis/powerbroker/v1alpha1"
 	apisv1alpha1 "github.com/VariableExp0rt/powerbroker/apis/v1alpha1"
 
+	"github.com/VariableExp0rt/powerbroker/internal/controller/drift"
 	"github.com/VariableExp0rt/powerbroker/internal/controller/features"
 	"github.com/VariableExp0rt/powerbroker/internal/service"
 	teamsvc "github.com/VariableExp0rt/powerbroker/internal/service/team"
@@ -50,11 +50,13 @@ const (
 	errTrackPCUsage = "cannot track ProviderConfig usage"
 	errGetPC        = "cannot get ProviderConfig"
 	errGetCreds     = "cannot get credentials"
+	errNewService   = "cannot create new service client"
+	errLookup       = "cannot look up team by managed resource UID"
 )
 
-var (
-	errNewService = errors.New("cannot create new service client")
-)
+// reasonUpdated is the reason of the event recorded when an update changes
+// the relationships of a Team.
+const reasonUpdated event.Reason = "UpdatedRelationships"
 
 // Setup adds a controller that reconciles Team managed resources.
 func Setup(mgr ctrl.Manager, o controller.Options) error {
@@ -65,6 +67,8 @@ func Setup(mgr ctrl.Manager, o controller.Options) error {
 		cps = append(cps, connection.NewDetailsManager(mgr.GetClient(), apisv1alpha1.StoreConfigGroupVersionKind))
 	}
 
+	recorder := event.NewAPIRecorder(mgr.GetEventRecorderFor(name))
+
 	return ctrl.NewControllerManagedBy(mgr).
 		Named(name).
 		WithOptions(o.ForControllerRuntime()).
@@ -72,14 +76,15 @@ func Setup(mgr ctrl.Manager, o controller.Options) error {
 		Complete(managed.NewReconciler(mgr,
 			resource.ManagedKind(v1alpha1.TeamGroupVersionKind),
 			managed.WithExternalConnecter(&connector{
-				kube:  mgr.GetClient(),
-				usage: resource.NewProviderConfigUsageTracker(mgr.GetClient(), &apisv1alpha1.ProviderConfigUsage{}),
-				util:  &connectorHelper{}}),
+				kube:   mgr.GetClient(),
+				usage:  resource.NewProviderConfigUsageTracker(mgr.GetClient(), &apisv1alpha1.ProviderConfigUsage{}),
+				record: recorder,
+				util:   &connectorHelper{}}),
 			managed.WithCreationGracePeriod(10*time.Second),
 			managed.WithInitializers(managed.NewDefaultProviderConfig(mgr.GetClient())),
 			managed.WithReferenceResolver(managed.NewAPISimpleReferenceResolver(mgr.GetClient())),
 			managed.WithLogger(o.Logger.WithValues("controller", name)),
-			managed.WithRecorder(event.NewAPIRecorder(mgr.GetEventRecorderFor(name))),
+			managed.WithRecorder(recorder),
 			managed.WithConnectionPublishers(cps...)))
 }
 
@@ -102,9 +107,10 @@ func (c *connectorHelper) ExtractCredentials(ctx context.Context, source v1.Cred
 // A connector is expected to produce an ExternalClient when its Connect method
 // is called.
 type connector struct {
-	kube  client.Client
-	usage resource.Tracker
-	util  Connector
+	kube   client.Client
+	usage  resource.Tracker
+	util   Connector
+	record event.Recorder
 }
 
 // Connect typically produces an ExternalClient by:
@@ -134,19 +140,17 @@ func (c *connector) Connect(ctx context.Context, mg resource.Managed) (managed.E
 		return nil, errors.Wrap(err, errGetCreds)
 	}
 
-	var service teamsvc.Service
-	switch pc.Spec.Storage.Type {
-	case "neo4j":
-		store, err := storage.NewNeo4jStorage(data)
-		if err != nil {
-			return nil, errors.Wrap(err, "client")
-		}
-		service = teamsvc.NewService(store)
-	default:
-		return nil, errNewService
+	cfg, err := storage.ResolveConfig(ctx, c.kube, pc, data)
+	if err != nil {
+		return nil, errors.Wrap(err, errGetCreds)
 	}
 
-	return &external{service: service, kube: c.kube}, nil
+	store, err := storage.DefaultPool.Get(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, errNewService)
+	}
+
+	return &external{service: c.util.GetService(store), kube: c.kube, record: c.record, timeout: cfg.Timeout}, nil
 }
 
 // An ExternalClient observes, then either creates, updates, or deletes an
@@ -154,6 +158,11 @@ func (c *connector) Connect(ctx context.Context, mg resource.Managed) (managed.E
 type external struct {
 	kube    client.Client
 	service teamsvc.Service
+	record  event.Recorder
+
+	// timeout bounds each operation on the storage. Operations are
+	// unbounded when it is zero.
+	timeout time.Duration
 }
 
 func (e *external) Observe(ctx context.Context, mg resource.Managed) (managed.ExternalObservation, error) {
@@ -162,14 +171,25 @@ func (e *external) Observe(ctx context.Context, mg resource.Managed) (managed.Ex
 		return managed.ExternalObservation{}, errors.New(errNotTeam)
 	}
 
+	ctx, cancel := storage.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	ctx = storetypes.WithResource(ctx, string(cr.GetUID()))
+
+	var adopted bool
 	if meta.GetExternalName(cr) == "" {
-		return managed.ExternalObservation{ResourceExists: false}, nil
+		var err error
+		if adopted, err = e.adopt(ctx, cr); err != nil || !adopted {
+			return managed.ExternalObservation{ResourceExists: false}, err
+		}
 	}
 
-	currentParams := cr.Spec.ForProvider.DeepCopy()
+	// The storage never makes the manager of a team one of its members.
+	desired := cr.Spec.ForProvider.DeepCopy()
+	desired.Members = without(desired.Members, desired.ManagedBy.User)
 
-	resp, err := e.service.GetTeam(meta.GetExternalName(cr))
+	resp, err := e.service.GetTeam(ctx, meta.GetExternalName(cr))
 	if err != nil {
+		setUnavailable(cr, err)
 		return managed.ExternalObservation{},
 			errors.Wrap(resource.Ignore(storetypes.IsEntityNotFoundNeo4jErr, err), "cannot get team")
 	}
@@ -183,10 +203,18 @@ func (e *external) Observe(ctx context.Context, mg resource.Managed) (managed.Ex
 		cr.SetConditions(v1.Unavailable())
 	}
 
+	diff := drift.Diff(*desired, v1alpha1.TeamParameters{
+		Name:      resp.Name,
+		ManagedBy: v1alpha1.ManagedByParameters{User: resp.ManagedBy},
+		Members:   resp.Members,
+		Personas:  resp.Personas,
+	})
+
 	return managed.ExternalObservation{
-		ResourceExists:   true,
-		ResourceUpToDate: cmp.Equal(currentParams.Members, resp.Members) && cmp.Equal(currentParams.ManagedBy.User, resp.ManagedBy),
-		Diff:             cmp.Diff(currentParams.Members, resp.Members) + cmp.Diff(currentParams.ManagedBy.User, resp.ManagedBy),
+		ResourceExists:          true,
+		ResourceLateInitialized: adopted,
+		ResourceUpToDate:        diff == "",
+		Diff:                    diff,
 	}, err
 }
 
@@ -196,10 +224,14 @@ func (e *external) Create(ctx context.Context, mg resource.Managed) (managed.Ext
 		return managed.ExternalCreation{}, errors.New(errNotTeam)
 	}
 
+	ctx, cancel := storage.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	ctx = storetypes.WithResource(ctx, string(cr.GetUID()))
+
 	params := cr.Spec.ForProvider.DeepCopy()
 
 	cr.SetConditions(v1.Creating())
-	uuid, err := e.service.CreateTeam(params)
+	uuid, err := e.service.CreateTeam(ctx, string(cr.GetUID()), params)
 
 	return postCreate(cr, managed.ExternalCreation{ExternalNameAssigned: true}, uuid, err)
 }
@@ -210,11 +242,22 @@ func (e *external) Update(ctx context.Context, mg resource.Managed) (managed.Ext
 		return managed.ExternalUpdate{}, errors.New(errNotTeam)
 	}
 
+	ctx, cancel := storage.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	ctx = storetypes.WithResource(ctx, string(cr.GetUID()))
+
 	params := cr.Spec.ForProvider.DeepCopy()
 
-	err := e.service.UpdateTeam(meta.GetExternalName(cr), params)
+	changes, err := e.service.UpdateTeam(ctx, meta.GetExternalName(cr), params)
+	if err != nil {
+		return managed.ExternalUpdate{}, errors.Wrap(err, "cannot update team")
+	}
 
-	return managed.ExternalUpdate{}, errors.Wrap(err, "cannot update team")
+	if !changes.Empty() {
+		e.record.Event(cr, event.Normal(reasonUpdated, changes.String()))
+	}
+
+	return managed.ExternalUpdate{}, nil
 }
 
 func (e *external) Delete(ctx context.Context, mg resource.Managed) error {
@@ -223,12 +266,39 @@ func (e *external) Delete(ctx context.Context, mg resource.Managed) error {
 		return errors.New(errNotTeam)
 	}
 
+	ctx, cancel := storage.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	ctx = storetypes.WithResource(ctx, string(cr.GetUID()))
+
 	cr.SetConditions(v1.Deleting())
-	err := e.service.DeleteTeam(meta.GetExternalName(cr))
+	err := e.service.DeleteTeam(ctx, meta.GetExternalName(cr))
 
 	return errors.Wrap(resource.Ignore(storetypes.IsEntityNotFoundNeo4jErr, err), "cannot delete team")
 }
 
+// adopt sets the external name of a Team which has none to the uuid of the
+// team created for it, if any. This happens when the provider fails to record
+// the external name after creating the team.
+func (e *external) adopt(ctx context.Context, cr *v1alpha1.Team) (bool, error) {
+	uuid, err := e.service.LookupTeam(ctx, string(cr.GetUID()))
+	if err != nil {
+		setUnavailable(cr, err)
+		return false, errors.Wrap(resource.Ignore(storetypes.IsEntityNotFoundNeo4jErr, err), errLookup)
+	}
+
+	meta.SetExternalName(cr, uuid)
+	return true, nil
+}
+
+// setUnavailable marks a Team Unavailable if err means its storage cannot be
+// used. The Team then reports why it is not ready, as well as that it failed
+// to reconcile.
+func setUnavailable(cr *v1alpha1.Team, err error) {
+	if storage.Unavailable(err) {
+		cr.SetConditions(v1.Unavailable().WithMessage(err.Error()))
+	}
+}
+
 func generateTeamObservation(r *svctypes.GetTeamResponse) v1alpha1.TeamObservation {
 	return v1alpha1.TeamObservation{
 		NodeID: r.NodeID,
@@ -244,3 +314,15 @@ func postCreate(cr *v1alpha1.Team, ec managed.ExternalCreation, uuid string, err
 	meta.SetExternalName(cr, uuid)
 	return ec, nil
 }
+
+// without returns the elements of s other than e.
+func without(s []string, e string) []string {
+	out := make([]string, 0, len(s))
+	for _, x := range s {
+		if x != e {
+			out = append(out, x)
+		}
+	}
+
+	return out
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/VariableExp0rt/powerbroker/apis/powerbroker/v1alpha1"
+	"github.com/VariableExp0rt/powerbroker/internal/controller/drift"
 	"github.com/VariableExp0rt/powerbroker/internal/service"
 	svctypes "github.com/VariableExp0rt/powerbroker/internal/service/types"
 	storetypes "github.com/VariableExp0rt/powerbroker/internal/storage/types"
@@ -113,8 +114,9 @@ func TestObserve(t *testing.T) {
 					MockUpdate: test.NewMockClient().MockUpdate,
 				},
 				repository: &service.MockRepository{
-					MockGetTeam: func(s string) (*svctypes.GetTeamResponse, error) {
+					MockGetTeam: func(ctx context.Context, s string) (*svctypes.GetTeamResponse, error) {
 						return &svctypes.GetTeamResponse{
+							Name:      "super-mario-team",
 							ManagedBy: "bowser",
 							Members:   []string{"wario", "toad", "princess"},
 							Personas:  []string{"entry-to-bowser-castle-role"},
@@ -159,14 +161,95 @@ func TestObserve(t *testing.T) {
 				err: nil,
 			},
 		},
+		"AdoptedByUID": {
+			args: args{
+				repository: &service.MockRepository{
+					MockLookupTeam: func(ctx context.Context, uid string) (string, error) {
+						return teamUuid, nil
+					},
+					MockGetTeam: func(ctx context.Context, s string) (*svctypes.GetTeamResponse, error) {
+						return &svctypes.GetTeamResponse{
+							Name:      "super-mario-team",
+							ManagedBy: manager,
+							Members:   members,
+							Personas:  personas,
+							NodeID:    teamUuid,
+							Status:    "available",
+						}, nil
+					},
+				},
+				cr: team(
+					withSpec(v1alpha1.TeamParameters{
+						Name: "super-mario-team",
+						ManagedBy: v1alpha1.ManagedByParameters{
+							User: manager,
+						},
+						Members:  members,
+						Personas: personas,
+					}),
+				),
+			},
+			want: want{
+				cr: team(
+					withExternalName(teamUuid),
+					withSpec(v1alpha1.TeamParameters{
+						Name: "super-mario-team",
+						ManagedBy: v1alpha1.ManagedByParameters{
+							User: manager,
+						},
+						Members:  members,
+						Personas: personas,
+					}),
+					withConditions(v1.Available()),
+					withStatus(v1alpha1.TeamObservation{
+						NodeID: teamUuid,
+						Status: string(storetypes.StatusAvailable),
+					}),
+				),
+				o: managed.ExternalObservation{
+					ResourceExists:          true,
+					ResourceLateInitialized: true,
+					ResourceUpToDate:        true,
+				},
+			},
+		},
+		"NotFoundByUID": {
+			args: args{
+				repository: &service.MockRepository{
+					MockLookupTeam: func(ctx context.Context, uid string) (string, error) {
+						return "", &storetypes.EntityNotFoundError{}
+					},
+				},
+				cr: team(),
+			},
+			want: want{
+				cr: team(),
+				o:  managed.ExternalObservation{ResourceExists: false},
+			},
+		},
+		"LookupFailed": {
+			args: args{
+				repository: &service.MockRepository{
+					MockLookupTeam: func(ctx context.Context, uid string) (string, error) {
+						return "", errInternalServer
+					},
+				},
+				cr: team(),
+			},
+			want: want{
+				cr:  team(),
+				err: errors.Wrap(errInternalServer, errLookup),
+			},
+		},
 		"FailedWithDiff": {
 			args: args{
 				kube: &test.MockClient{
 					MockUpdate: test.NewMockClient().Update,
 				},
 				repository: &service.MockRepository{
-					MockGetTeam: func(s string) (*svctypes.GetTeamResponse, error) {
+					MockGetTeam: func(ctx context.Context, s string) (*svctypes.GetTeamResponse, error) {
 						return &svctypes.GetTeamResponse{
+							Name:      "super-mario-team",
 							ManagedBy: "luigi",
 							Members:   []string{"wario", "toad", "princess"},
 							Personas:  []string{"entry-to-bowser-castle-role"},
@@ -207,20 +290,134 @@ func TestObserve(t *testing.T) {
 				o: managed.ExternalObservation{
 					ResourceExists:   true,
 					ResourceUpToDate: false,
-					Diff: cmp.Diff(
-						manager,
-						"luigi",
+					Diff: drift.Diff(
+						v1alpha1.TeamParameters{
+							Name:      "super-mario-team",
+							ManagedBy: v1alpha1.ManagedByParameters{User: manager},
+							Members:   members,
+							Personas:  personas,
+						},
+						v1alpha1.TeamParameters{
+							Name:      "super-mario-team",
+							ManagedBy: v1alpha1.ManagedByParameters{User: "luigi"},
+							Members:   members,
+							Personas:  personas,
+						},
+					),
+				},
+			},
+		},
+		"PersonasDrifted": {
+			args: args{
+				repository: &service.MockRepository{
+					MockGetTeam: func(ctx context.Context, s string) (*svctypes.GetTeamResponse, error) {
+						return &svctypes.GetTeamResponse{
+							Name:      "super-mario-team",
+							ManagedBy: manager,
+							Members:   members,
+							Personas:  []string{"entry-to-peach-castle-role"},
+							NodeID:    teamUuid,
+							Status:    "available",
+						}, nil
+					},
+				},
+				cr: team(
+					withExternalName(teamUuid),
+					withSpec(v1alpha1.TeamParameters{
+						Name:      "super-mario-team",
+						ManagedBy: v1alpha1.ManagedByParameters{User: manager},
+						Members:   members,
+						Personas:  personas,
+					}),
+				),
+			},
+			want: want{
+				cr: team(
+					withExternalName(teamUuid),
+					withSpec(v1alpha1.TeamParameters{
+						Name:      "super-mario-team",
+						ManagedBy: v1alpha1.ManagedByParameters{User: manager},
+						Members:   members,
+						Personas:  personas,
+					}),
+					withConditions(v1.Available()),
+					withStatus(v1alpha1.TeamObservation{
+						NodeID: teamUuid,
+						Status: string(storetypes.StatusAvailable),
+					}),
+				),
+				o: managed.ExternalObservation{
+					ResourceExists:   true,
+					ResourceUpToDate: false,
+					Diff: drift.Diff(
+						v1alpha1.TeamParameters{
+							Name:      "super-mario-team",
+							ManagedBy: v1alpha1.ManagedByParameters{User: manager},
+							Members:   members,
+							Personas:  personas,
+						},
+						v1alpha1.TeamParameters{
+							Name:      "super-mario-team",
+							ManagedBy: v1alpha1.ManagedByParameters{User: manager},
+							Members:   members,
+							Personas:  []string{"entry-to-peach-castle-role"},
+						},
 					),
 				},
 			},
 		},
+		"ManagerIsNeverAMember": {
+			args: args{
+				repository: &service.MockRepository{
+					MockGetTeam: func(ctx context.Context, s string) (*svctypes.GetTeamResponse, error) {
+						return &svctypes.GetTeamResponse{
+							Name:      "super-mario-team",
+							ManagedBy: manager,
+							Members:   []string{"toad", "wario", "princess"},
+							Personas:  personas,
+							NodeID:    teamUuid,
+							Status:    "available",
+						}, nil
+					},
+				},
+				cr: team(
+					withExternalName(teamUuid),
+					withSpec(v1alpha1.TeamParameters{
+						Name:      "super-mario-team",
+						ManagedBy: v1alpha1.ManagedByParameters{User: manager},
+						Members:   append([]string{manager}, members...),
+						Personas:  personas,
+					}),
+				),
+			},
+			want: want{
+				cr: team(
+					withExternalName(teamUuid),
+					withSpec(v1alpha1.TeamParameters{
+						Name:      "super-mario-team",
+						ManagedBy: v1alpha1.ManagedByParameters{User: manager},
+						Members:   append([]string{manager}, members...),
+						Personas:  personas,
+					}),
+					withConditions(v1.Available()),
+					withStatus(v1alpha1.TeamObservation{
+						NodeID: teamUuid,
+						Status: string(storetypes.StatusAvailable),
+					}),
+				),
+				o: managed.ExternalObservation{
+					ResourceExists:   true,
+					ResourceUpToDate: true,
+				},
+			},
+		},
 		"FailedWithError": {
 			args: args{
 				kube: &test.MockClient{
 					MockUpdate: test.NewMockClient().Update,
 				},
 				repository: &service.MockRepository{
-					MockGetTeam: func(s string) (*svctypes.GetTeamResponse, error) {
+					MockGetTeam: func(ctx context.Context, s string) (*svctypes.GetTeamResponse, error) {
 						return &svctypes.GetTeamResponse{}, errInternalServer
 					},
 				},
@@ -273,7 +470,7 @@ func TestCreate(t *testing.T) {
 					MockUpdate: test.NewMockClient().Update,
 				},
 				repository: &service.MockRepository{
-					MockCreateTeam: func(tp *v1alpha1.TeamParameters) (string, error) {
+					MockCreateTeam: func(ctx context.Context, uid string, tp *v1alpha1.TeamParameters) (string, error) {
 						return teamUuid, nil
 					},
 				},
@@ -319,7 +516,7 @@ func TestCreate(t *testing.T) {
 					MockUpdate: test.NewMockClient().Update,
 				},
 				repository: &service.MockRepository{
-					MockCreateTeam: func(tp *v1alpha1.TeamParameters) (string, error) {
+					MockCreateTeam: func(ctx context.Context, uid string, tp *v1alpha1.TeamParameters) (string, error) {
 						return "", errInternalServer
 					},
 				},
@@ -383,7 +580,7 @@ func TestUpdate(t *testing.T) {
 		"SuccessfulUpdate": {
 			args: args{
 				repository: &service.MockRepository{
-					MockGetTeam: func(s string) (*svctypes.GetTeamResponse, error) {
+					MockGetTeam: func(ctx context.Context, s string) (*svctypes.GetTeamResponse, error) {
 						return &svctypes.GetTeamResponse{
 							ManagedBy: manager,
 							Members:   members,
@@ -392,8 +589,8 @@ func TestUpdate(t *testing.T) {
 							Status:    storetypes.StatusAvailable,
 						}, nil
 					},
-					MockUpdateTeam: func(s string, tp *v1alpha1.TeamParameters) error {
-						return nil
+					MockUpdateTeam: func(ctx context.Context, s string, tp *v1alpha1.TeamParameters) (svctypes.Changes, error) {
+						return svctypes.Changes{}, nil
 					},
 				},
 				cr: team(
@@ -424,7 +621,7 @@ func TestUpdate(t *testing.T) {
 		"UpdateFailed": {
 			args: args{
 				repository: &service.MockRepository{
-					MockGetTeam: func(s string) (*svctypes.GetTeamResponse, error) {
+					MockGetTeam: func(ctx context.Context, s string) (*svctypes.GetTeamResponse, error) {
 						return &svctypes.GetTeamResponse{
 							ManagedBy: manager,
 							Members:   members,
@@ -433,8 +630,8 @@ func TestUpdate(t *testing.T) {
 							Status:    storetypes.StatusAvailable,
 						}, nil
 					},
-					MockUpdateTeam: func(s string, tp *v1alpha1.TeamParameters) error {
-						return errInternalServer
+					MockUpdateTeam: func(ctx context.Context, s string, tp *v1alpha1.TeamParameters) (svctypes.Changes, error) {
+						return svctypes.Changes{}, errInternalServer
 					},
 				},
 				cr: team(
@@ -495,7 +692,7 @@ func TestDelete(t *testing.T) {
 		"SuccessfulDelete": {
 			args: args{
 				repository: &service.MockRepository{
-					MockDeleteTeam: func(s string) error {
+					MockDeleteTeam: func(ctx context.Context, s string) error {
 						return nil
 					},
 				},
@@ -530,7 +727,7 @@ func TestDelete(t *testing.T) {
 		"DeleteFailed": {
 			args: args{
 				repository: &service.MockRepository{
-					MockDeleteTeam: func(s string) error {
+					MockDeleteTeam: func(ctx context.Context, s string) error {
 						return errInternalServer
 					},
 				},
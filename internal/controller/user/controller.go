@@ -18,8 +18,13 @@ package user
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
 
-	"github.com/google/go-cmp/cmp"
 	"github.com/pkg/errors"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -29,13 +34,16 @@ import (
 	"github.com/crossplane/crossplane-runtime/pkg/connection"
 	"github.com/crossplane/crossplane-runtime/pkg/controller"
 	"github.com/crossplane/crossplane-runtime/pkg/event"
+	"github.com/crossplane/crossplane-runtime/pkg/feature"
 	"github.com/crossplane/crossplane-runtime/pkg/meta"
 	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
 	"github.com/crossplane/crossplane-runtime/pkg/resource"
 
 	"github.com/VariableExp0rt/powerbroker/apis/powerbroker/v1alpha1"
 	apisv1alpha1 "github.com/VariableExp0rt/powerbroker/apis/v1alpha1"
+	"github.com/VariableExp0rt/powerbroker/internal/controller/drift"
 	"github.com/VariableExp0rt/powerbroker/internal/controller/features"
+	"github.com/VariableExp0rt/powerbroker/internal/controller/policy"
 	svc "github.com/VariableExp0rt/powerbroker/internal/service"
 	usersvc "github.com/VariableExp0rt/powerbroker/internal/service/user"
 	storage "github.com/VariableExp0rt/powerbroker/internal/storage"
@@ -50,11 +58,15 @@ const (
 	errTrackPCUsage = "cannot track ProviderConfig usage"
 	errGetPC        = "cannot get ProviderConfig"
 	errGetCreds     = "cannot get credentials"
+	errNewService   = "cannot create new service client"
+	errLookup       = "cannot look up user by managed resource UID"
+	errGetAccess    = "cannot get effective access of user"
+	errNoExtName    = "cannot observe user: an ObserveOnly User must set the name or uuid of its user as its external name"
 )
 
-var (
-	errNewService = errors.New("cannot create new service client")
-)
+// reasonUpdated is the reason of the event recorded when an update changes
+// the relationships of a User.
+const reasonUpdated event.Reason = "UpdatedRelationships"
 
 type Connector interface {
 	GetService(repo svc.Repository) usersvc.Service
@@ -80,6 +92,8 @@ func Setup(mgr ctrl.Manager, o controller.Options) error {
 		cps = append(cps, connection.NewDetailsManager(mgr.GetClient(), apisv1alpha1.StoreConfigGroupVersionKind))
 	}
 
+	recorder := event.NewAPIRecorder(mgr.GetEventRecorderFor(name))
+
 	return ctrl.NewControllerManagedBy(mgr).
 		Named(name).
 		WithOptions(o.ForControllerRuntime()).
@@ -87,19 +101,35 @@ func Setup(mgr ctrl.Manager, o controller.Options) error {
 		Complete(managed.NewReconciler(mgr,
 			resource.ManagedKind(v1alpha1.UserGroupVersionKind),
 			managed.WithExternalConnecter(&connector{
-				kube:  mgr.GetClient(),
-				usage: resource.NewProviderConfigUsageTracker(mgr.GetClient(), &apisv1alpha1.ProviderConfigUsage{}),
-				util:  &connectorHelper{}}),
+				kube:     mgr.GetClient(),
+				usage:    resource.NewProviderConfigUsageTracker(mgr.GetClient(), &apisv1alpha1.ProviderConfigUsage{}),
+				record:   recorder,
+				features: o.Features,
+				util:     &connectorHelper{}}),
+			managed.WithCreationGracePeriod(10*time.Second),
+			managed.WithInitializers(initializers(mgr.GetClient())...),
 			managed.WithReferenceResolver(managed.NewAPISimpleReferenceResolver(mgr.GetClient())),
 			managed.WithLogger(o.Logger.WithValues("controller", name)),
-			managed.WithRecorder(event.NewAPIRecorder(mgr.GetEventRecorderFor(name))),
+			managed.WithRecorder(recorder),
 			managed.WithConnectionPublishers(cps...)))
 }
 
+// initializers initialize a User before it is observed. Unlike the default
+// initializers of a managed reconciler, they never set the external name of
+// a User to its name, so that a User whose external name is lost adopts the
+// user created for it by its UID.
+func initializers(kube client.Client) []managed.Initializer {
+	return []managed.Initializer{managed.NewDefaultProviderConfig(kube)}
+}
+
 type connector struct {
-	kube  client.Client
-	usage resource.Tracker
-	util  Connector
+	kube   client.Client
+	usage  resource.Tracker
+	util   Connector
+	record event.Recorder
+
+	// features decides whether the management policy of a User is honoured.
+	features *feature.Flags
 }
 
 func (c *connector) Connect(ctx context.Context, mg resource.Managed) (managed.ExternalClient, error) {
@@ -123,24 +153,42 @@ func (c *connector) Connect(ctx context.Context, mg resource.Managed) (managed.E
 		return nil, errors.Wrap(err, errGetCreds)
 	}
 
-	var service usersvc.Service
-	switch pc.Spec.Storage.Type {
-	case "neo4j":
-		store, err := storage.NewNeo4jStorage(data)
-		if err != nil {
-			return nil, errors.Wrap(err, "client")
-		}
-		service = usersvc.NewService(store)
-	default:
-		return nil, errNewService
+	cfg, err := storage.ResolveConfig(ctx, c.kube, pc, data)
+	if err != nil {
+		return nil, errors.Wrap(err, errGetCreds)
 	}
 
-	return &external{service: service}, nil
+	store, err := storage.DefaultPool.Get(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, errNewService)
+	}
+
+	ext := &external{
+		service:     c.util.GetService(store),
+		kube:        c.kube,
+		record:      c.record,
+		timeout:     cfg.Timeout,
+		observeOnly: policy.ObserveOnly(c.features, cr.Spec.ManagementPolicy),
+	}
+	if ext.observeOnly {
+		return policy.NewObserveOnlyClient(ext), nil
+	}
+
+	return ext, nil
 }
 
 type external struct {
 	kube    client.Client
 	service usersvc.Service
+	record  event.Recorder
+
+	// timeout bounds each operation on the storage. Operations are
+	// unbounded when it is zero.
+	timeout time.Duration
+
+	// observeOnly is true if the User may only observe its user, which it
+	// then also finds by name.
+	observeOnly bool
 }
 
 func (e *external) Observe(ctx context.Context, mg resource.Managed) (managed.ExternalObservation, error) {
@@ -149,21 +197,46 @@ func (e *external) Observe(ctx context.Context, mg resource.Managed) (managed.Ex
 		return managed.ExternalObservation{}, errors.New(errNotUser)
 	}
 
-	ext := meta.GetExternalName(cr)
-	if ext == "" {
-		return managed.ExternalObservation{ResourceExists: false}, nil
+	ctx, cancel := storage.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	ctx = storetypes.WithResource(ctx, string(cr.GetUID()))
+
+	var adopted bool
+	if meta.GetExternalName(cr) == "" {
+		// An ObserveOnly User never creates its user, so there is none to
+		// find by its UID, and it waits to be told which user to observe.
+		if e.observeOnly {
+			return managed.ExternalObservation{}, errors.New(errNoExtName)
+		}
+		var err error
+		if adopted, err = e.adopt(ctx, cr); err != nil || !adopted {
+			return managed.ExternalObservation{ResourceExists: false}, err
+		}
 	}
+	ext := meta.GetExternalName(cr)
 
-	refs := cr.Spec.ForProvider.DeepCopy().Personas
+	desired := cr.Spec.ForProvider.DeepCopy()
 	resp, err := e.service.GetUser(
+		ctx,
 		ext,
 	)
+	if e.observeOnly && storetypes.IsEntityNotFoundNeo4jErr(err) {
+		resp, err = e.adoptByName(ctx, cr)
+		adopted = err == nil
+	}
 	if err != nil {
+		setUnavailable(cr, err)
 		return managed.ExternalObservation{},
 			errors.Wrap(resource.Ignore(storetypes.IsEntityNotFoundNeo4jErr, err), "cannot get user")
 	}
 
-	cr.Status.AtProvider = generateUserObservation(resp)
+	access, err := e.service.GetEffectiveAccess(ctx, resp.NodeID)
+	if err != nil {
+		setUnavailable(cr, err)
+		return managed.ExternalObservation{}, errors.Wrap(err, errGetAccess)
+	}
+
+	cr.Status.AtProvider = generateUserObservation(resp, access)
 	switch cr.Status.AtProvider.Status {
 	case transaction.StatusAvailable:
 		cr.SetConditions(v1.Available())
@@ -171,10 +244,13 @@ func (e *external) Observe(ctx context.Context, mg resource.Managed) (managed.Ex
 		cr.SetConditions(v1.Unavailable())
 	}
 
+	diff := drift.Diff(*desired, v1alpha1.UserParameters{Name: resp.Name, Personas: resp.References})
+
 	return managed.ExternalObservation{
-		ResourceExists:   true,
-		ResourceUpToDate: cmp.Equal(refs, resp.References),
-		Diff:             cmp.Diff(refs, resp.References),
+		ResourceExists:          true,
+		ResourceLateInitialized: adopted,
+		ResourceUpToDate:        diff == "",
+		Diff:                    diff,
 	}, err
 }
 
@@ -184,10 +260,16 @@ func (e *external) Create(ctx context.Context, mg resource.Managed) (managed.Ext
 		return managed.ExternalCreation{}, errors.New(errNotUser)
 	}
 
+	ctx, cancel := storage.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	ctx = storetypes.WithResource(ctx, string(cr.GetUID()))
+
 	refs := cr.Spec.ForProvider.DeepCopy().Personas
 
 	cr.SetConditions(v1.Creating())
 	uuid, err := e.service.CreateUser(
+		ctx,
+		string(cr.GetUID()),
 		cr.Spec.ForProvider.Name,
 		refs,
 	)
@@ -201,15 +283,27 @@ func (e *external) Update(ctx context.Context, mg resource.Managed) (managed.Ext
 		return managed.ExternalUpdate{}, errors.New(errNotUser)
 	}
 
+	ctx, cancel := storage.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	ctx = storetypes.WithResource(ctx, string(cr.GetUID()))
+
 	references := cr.Spec.ForProvider.DeepCopy().Personas
 
-	err := e.service.UpdateUser(
-		cr.GetName(),
+	changes, err := e.service.UpdateUser(
+		ctx,
+		cr.Spec.ForProvider.Name,
 		meta.GetExternalName(cr),
 		references,
 	)
+	if err != nil {
+		return managed.ExternalUpdate{}, errors.Wrap(err, "cannot update user")
+	}
+
+	if !changes.Empty() {
+		e.record.Event(cr, event.Normal(reasonUpdated, changes.String()))
+	}
 
-	return managed.ExternalUpdate{}, errors.Wrap(err, "cannot update user")
+	return managed.ExternalUpdate{}, nil
 }
 
 func (e *external) Delete(ctx context.Context, mg resource.Managed) error {
@@ -218,19 +312,107 @@ func (e *external) Delete(ctx context.Context, mg resource.Managed) error {
 		return errors.New(errNotUser)
 	}
 
+	ctx, cancel := storage.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	ctx = storetypes.WithResource(ctx, string(cr.GetUID()))
+
 	cr.SetConditions(v1.Deleting())
-	err := e.service.DeleteUser(meta.GetExternalName(cr))
+	err := e.service.DeleteUser(ctx, meta.GetExternalName(cr))
 
 	return errors.Wrap(resource.Ignore(storetypes.IsEntityNotFoundNeo4jErr, err), "cannot delete user")
 }
 
-func generateUserObservation(r *svctypes.GetUserResponse) v1alpha1.UserObservation {
+// adopt sets the external name of a User which has none to the uuid of the
+// user created for it, if any. This happens when the provider fails to record
+// the external name after creating the user.
+func (e *external) adopt(ctx context.Context, cr *v1alpha1.User) (bool, error) {
+	uuid, err := e.service.LookupUser(ctx, string(cr.GetUID()))
+	if err != nil {
+		setUnavailable(cr, err)
+		return false, errors.Wrap(resource.Ignore(storetypes.IsEntityNotFoundNeo4jErr, err), errLookup)
+	}
+
+	meta.SetExternalName(cr, uuid)
+	return true, nil
+}
+
+// adoptByName sets the external name of a User which only observes a user to
+// the uuid of the user its external name names, and returns that user. Nodes
+// created by other tooling are usually known by their name rather than their
+// uuid. The name of the User itself is never used.
+func (e *external) adoptByName(ctx context.Context, cr *v1alpha1.User) (*svctypes.GetUserResponse, error) {
+	uuid, err := e.service.LookupUserByName(ctx, meta.GetExternalName(cr))
+	if err != nil {
+		return nil, err
+	}
+
+	meta.SetExternalName(cr, uuid)
+	return e.service.GetUser(ctx, uuid)
+}
+
+// setUnavailable marks a User Unavailable if err means its storage cannot be
+// used. The User then reports why it is not ready, as well as that it failed
+// to reconcile.
+func setUnavailable(cr *v1alpha1.User, err error) {
+	if storage.Unavailable(err) {
+		cr.SetConditions(v1.Unavailable().WithMessage(err.Error()))
+	}
+}
+
+func generateUserObservation(r *svctypes.GetUserResponse, access []svctypes.Access) v1alpha1.UserObservation {
+	effective := effectiveAccess(access)
 	return v1alpha1.UserObservation{
-		NodeID: r.NodeID,
-		Status: string(r.Status),
+		NodeID:          r.NodeID,
+		Status:          string(r.Status),
+		Name:            r.Name,
+		Personas:        r.References,
+		EffectiveAccess: effective,
+		AccessHash:      accessHash(effective),
 	}
 }
 
+// effectiveAccess observes each account and role a user can access once for
+// each of its sources, however many personas and permission sets lead to it.
+// The observations are sorted so that the status of a User only changes when
+// its access does.
+func effectiveAccess(access []svctypes.Access) []v1alpha1.AccessObservation {
+	seen := map[v1alpha1.AccessObservation]bool{}
+	var out []v1alpha1.AccessObservation
+	for _, a := range access {
+		o := v1alpha1.AccessObservation{
+			Cloud:    a.Cloud,
+			Account:  a.Account,
+			RoleName: a.Role,
+			Scope:    a.Scope,
+			Source:   v1alpha1.AccessSourceDirect,
+		}
+		if !a.Direct() {
+			o.Source, o.Team = v1alpha1.AccessSourceTeam, a.Team
+		}
+		if seen[o] {
+			continue
+		}
+		seen[o] = true
+		out = append(out, o)
+	}
+
+	key := func(o v1alpha1.AccessObservation) string {
+		return strings.Join([]string{o.Cloud, o.Account, o.RoleName, o.Scope, o.Source, o.Team}, "\x00")
+	}
+	sort.Slice(out, func(i, j int) bool { return key(out[i]) < key(out[j]) })
+
+	return out
+}
+
+// accessHash returns a hash of the supplied, sorted, effective access.
+func accessHash(access []v1alpha1.AccessObservation) string {
+	// The observations are plain data, so cannot fail to marshal.
+	b, _ := json.Marshal(access)
+	sum := sha256.Sum256(b)
+
+	return hex.EncodeToString(sum[:])
+}
+
 func postCreate(cr *v1alpha1.User, ec managed.ExternalCreation, uuid string, err error) (managed.ExternalCreation, error) {
 	if err != nil {
 		return managed.ExternalCreation{}, errors.Wrap(err, "cannot create user")
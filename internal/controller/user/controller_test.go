@@ -19,17 +19,22 @@ package user
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/VariableExp0rt/powerbroker/apis/powerbroker/v1alpha1"
+	"github.com/VariableExp0rt/powerbroker/internal/controller/drift"
 	"github.com/VariableExp0rt/powerbroker/internal/service"
 	svctypes "github.com/VariableExp0rt/powerbroker/internal/service/types"
 	storetypes "github.com/VariableExp0rt/powerbroker/internal/storage/types"
 	"github.com/google/go-cmp/cmp"
 	"github.com/pkg/errors"
 
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	kclient "sigs.k8s.io/controller-runtime/pkg/client"
 
 	v1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/event"
 	"github.com/crossplane/crossplane-runtime/pkg/meta"
 	"github.com/crossplane/crossplane-runtime/pkg/reconciler/managed"
 	"github.com/crossplane/crossplane-runtime/pkg/test"
@@ -51,8 +56,26 @@ var (
 	userName          = "my-least-privileged-user"
 	personaRefs       = []string{"super-admin-smash-bros", "production-access-for-everyone"}
 	errInternalServer = &storetypes.InternalError{}
+	errUnavailable    = &storetypes.UnavailableError{Err: errors.New("connection refused")}
+	errUnauthorized   = &storetypes.AuthError{Err: errors.New("bad password")}
+
+	noAccess     = func(context.Context, string) ([]svctypes.Access, error) { return nil, nil }
+	noAccessHash = accessHash(nil)
 )
 
+// recorder keeps the events it is asked to record.
+type recorder struct {
+	events []event.Event
+}
+
+func (r *recorder) Event(_ runtime.Object, e event.Event) {
+	r.events = append(r.events, e)
+}
+
+func (r *recorder) WithAnnotations(...string) event.Recorder {
+	return r
+}
+
 type userModifier func(*v1alpha1.User)
 
 func withConditions(c ...v1.Condition) userModifier {
@@ -92,9 +115,19 @@ type args struct {
 	kube       kclient.Client
 	repository service.Repository
 	cr         *v1alpha1.User
+
+	// observeOnly is true if the User may only observe its node.
+	observeOnly bool
 }
 
 func TestObserve(t *testing.T) {
+	admin := svctypes.Target{Cloud: v1alpha1.CloudAWS, Account: "123456789012", Role: "Admin"}
+	readonly := svctypes.Target{Cloud: v1alpha1.CloudAWS, Account: "123456789012", Role: "ReadOnly"}
+	access := []v1alpha1.AccessObservation{
+		{Cloud: v1alpha1.CloudAWS, Account: "123456789012", RoleName: "Admin", Source: v1alpha1.AccessSourceTeam, Team: "plumbers"},
+		{Cloud: v1alpha1.CloudAWS, Account: "123456789012", RoleName: "ReadOnly", Source: v1alpha1.AccessSourceDirect},
+	}
+
 	type want struct {
 		cr  *v1alpha1.User
 		o   managed.ExternalObservation
@@ -111,13 +144,15 @@ func TestObserve(t *testing.T) {
 					MockUpdate: test.NewMockClient().Update,
 				},
 				repository: &service.MockRepository{
-					MockGetUser: func(userUuid string) (*svctypes.GetUserResponse, error) {
+					MockGetUser: func(ctx context.Context, userUuid string) (*svctypes.GetUserResponse, error) {
 						return &svctypes.GetUserResponse{
+							Name:       userName,
 							NodeID:     externalName,
 							References: personaRefs,
 							Status:     "available",
 						}, nil
 					},
+					MockGetEffectiveAccess: noAccess,
 				},
 				cr: user(
 					withExternalName(externalName),
@@ -136,8 +171,11 @@ func TestObserve(t *testing.T) {
 						Personas: personaRefs,
 					}),
 					withStatus(v1alpha1.UserObservation{
-						NodeID: externalName,
-						Status: string(storetypes.StatusAvailable),
+						NodeID:     externalName,
+						Status:     string(storetypes.StatusAvailable),
+						Name:       userName,
+						Personas:   personaRefs,
+						AccessHash: noAccessHash,
 					}),
 				),
 				o: managed.ExternalObservation{
@@ -152,13 +190,15 @@ func TestObserve(t *testing.T) {
 					MockUpdate: test.NewMockClient().Update,
 				},
 				repository: &service.MockRepository{
-					MockGetUser: func(userUuid string) (*svctypes.GetUserResponse, error) {
+					MockGetUser: func(ctx context.Context, userUuid string) (*svctypes.GetUserResponse, error) {
 						return &svctypes.GetUserResponse{
+							Name:       userName,
 							NodeID:     externalName,
 							References: personaRefs,
 							Status:     "available",
 						}, nil
 					},
+					MockGetEffectiveAccess: noAccess,
 				},
 				cr: user(
 					withExternalName(externalName),
@@ -177,30 +217,324 @@ func TestObserve(t *testing.T) {
 						Personas: append(personaRefs, "the-scoped-readonly-persona"),
 					}),
 					withStatus(v1alpha1.UserObservation{
-						NodeID: externalName,
-						Status: string(storetypes.StatusAvailable),
+						NodeID:     externalName,
+						Status:     string(storetypes.StatusAvailable),
+						Name:       userName,
+						Personas:   personaRefs,
+						AccessHash: noAccessHash,
+					}),
+				),
+				o: managed.ExternalObservation{
+					ResourceExists:   true,
+					ResourceUpToDate: false,
+					Diff: drift.Diff(
+						v1alpha1.UserParameters{Name: userName, Personas: append(personaRefs, "the-scoped-readonly-persona")},
+						v1alpha1.UserParameters{Name: userName, Personas: personaRefs},
+					),
+				},
+			},
+		},
+		"NameDrifted": {
+			args: args{
+				repository: &service.MockRepository{
+					MockGetUser: func(ctx context.Context, userUuid string) (*svctypes.GetUserResponse, error) {
+						return &svctypes.GetUserResponse{
+							Name:       "renamed-out-of-band",
+							NodeID:     externalName,
+							References: personaRefs,
+							Status:     "available",
+						}, nil
+					},
+					MockGetEffectiveAccess: noAccess,
+				},
+				cr: user(
+					withExternalName(externalName),
+					withSpec(v1alpha1.UserParameters{
+						Name:     userName,
+						Personas: personaRefs,
+					}),
+				),
+			},
+			want: want{
+				cr: user(
+					withConditions(v1.Available()),
+					withExternalName(externalName),
+					withSpec(v1alpha1.UserParameters{
+						Name:     userName,
+						Personas: personaRefs,
+					}),
+					withStatus(v1alpha1.UserObservation{
+						NodeID:     externalName,
+						Status:     string(storetypes.StatusAvailable),
+						Name:       "renamed-out-of-band",
+						Personas:   personaRefs,
+						AccessHash: noAccessHash,
 					}),
 				),
 				o: managed.ExternalObservation{
-					ResourceExists: true,
-					ResourceUpToDate: cmp.Equal(
-						append(personaRefs, "the-scoped-readonly-persona"),
-						personaRefs,
+					ResourceExists:   true,
+					ResourceUpToDate: false,
+					Diff: drift.Diff(
+						v1alpha1.UserParameters{Name: userName, Personas: personaRefs},
+						v1alpha1.UserParameters{Name: "renamed-out-of-band", Personas: personaRefs},
 					),
-					Diff: cmp.Diff(
-						append(personaRefs, "the-scoped-readonly-persona"),
-						personaRefs,
+				},
+			},
+		},
+		"EffectiveAccess": {
+			args: args{
+				repository: &service.MockRepository{
+					MockGetUser: func(ctx context.Context, userUuid string) (*svctypes.GetUserResponse, error) {
+						return &svctypes.GetUserResponse{
+							Name:       userName,
+							NodeID:     externalName,
+							References: personaRefs,
+							Status:     "available",
+						}, nil
+					},
+					MockGetEffectiveAccess: func(ctx context.Context, userUuid string) ([]svctypes.Access, error) {
+						if userUuid != externalName {
+							return nil, &storetypes.EntityNotFoundError{}
+						}
+						return []svctypes.Access{
+							{Target: admin, PermissionSet: "admin", Persona: "operator", Team: "plumbers"},
+							{Target: readonly, PermissionSet: "readonly", Persona: "auditor"},
+							// The same access through another persona is observed once.
+							{Target: readonly, PermissionSet: "readonly", Persona: "reviewer"},
+						}, nil
+					},
+				},
+				cr: user(
+					withExternalName(externalName),
+					withSpec(v1alpha1.UserParameters{
+						Name:     userName,
+						Personas: personaRefs,
+					}),
+				),
+			},
+			want: want{
+				cr: user(
+					withConditions(v1.Available()),
+					withExternalName(externalName),
+					withSpec(v1alpha1.UserParameters{
+						Name:     userName,
+						Personas: personaRefs,
+					}),
+					withStatus(v1alpha1.UserObservation{
+						NodeID:          externalName,
+						Status:          string(storetypes.StatusAvailable),
+						Name:            userName,
+						Personas:        personaRefs,
+						EffectiveAccess: access,
+						AccessHash:      accessHash(access),
+					}),
+				),
+				o: managed.ExternalObservation{
+					ResourceExists:   true,
+					ResourceUpToDate: true,
+				},
+			},
+		},
+		"GetAccessFailed": {
+			args: args{
+				repository: &service.MockRepository{
+					MockGetUser: func(ctx context.Context, userUuid string) (*svctypes.GetUserResponse, error) {
+						return &svctypes.GetUserResponse{
+							Name:       userName,
+							NodeID:     externalName,
+							References: personaRefs,
+							Status:     "available",
+						}, nil
+					},
+					MockGetEffectiveAccess: func(ctx context.Context, userUuid string) ([]svctypes.Access, error) {
+						return nil, errUnavailable
+					},
+				},
+				cr: user(withExternalName(externalName)),
+			},
+			want: want{
+				cr: user(
+					withExternalName(externalName),
+					withConditions(v1.Unavailable().WithMessage(errUnavailable.Error())),
+				),
+				err: errors.Wrap(errUnavailable, errGetAccess),
+			},
+		},
+		"AdoptedByName": {
+			args: args{
+				observeOnly: true,
+				repository: &service.MockRepository{
+					MockLookupUserByName: func(ctx context.Context, name string) (string, error) {
+						if name != userName {
+							return "", &storetypes.EntityNotFoundError{}
+						}
+						return externalName, nil
+					},
+					MockGetUser: func(ctx context.Context, uuid string) (*svctypes.GetUserResponse, error) {
+						if uuid != externalName {
+							return nil, &storetypes.EntityNotFoundError{}
+						}
+						return &svctypes.GetUserResponse{
+							Name:       userName,
+							NodeID:     externalName,
+							References: personaRefs,
+							Status:     "available",
+						}, nil
+					},
+					MockGetEffectiveAccess: noAccess,
+				},
+				cr: user(
+					withExternalName(userName),
+					withSpec(v1alpha1.UserParameters{
+						Name: userName,
+					}),
+				),
+			},
+			want: want{
+				cr: user(
+					withConditions(v1.Available()),
+					withExternalName(externalName),
+					withSpec(v1alpha1.UserParameters{
+						Name: userName,
+					}),
+					withStatus(v1alpha1.UserObservation{
+						NodeID:     externalName,
+						Status:     string(storetypes.StatusAvailable),
+						Name:       userName,
+						Personas:   personaRefs,
+						AccessHash: noAccessHash,
+					}),
+				),
+				o: managed.ExternalObservation{
+					ResourceExists:          true,
+					ResourceLateInitialized: true,
+					ResourceUpToDate:        false,
+					Diff: drift.Diff(
+						v1alpha1.UserParameters{Name: userName},
+						v1alpha1.UserParameters{Name: userName, Personas: personaRefs},
 					),
 				},
 			},
 		},
+		"NotAdoptedByNameUnlessObserveOnly": {
+			args: args{
+				repository: &service.MockRepository{
+					MockGetUser: func(ctx context.Context, uuid string) (*svctypes.GetUserResponse, error) {
+						return nil, &storetypes.EntityNotFoundError{}
+					},
+					MockLookupUserByName: func(ctx context.Context, name string) (string, error) {
+						t.Errorf("LookupUserByName(...): a User with full control should not adopt a user by name")
+						return externalName, nil
+					},
+				},
+				cr: user(withExternalName(userName)),
+			},
+			want: want{
+				cr: user(withExternalName(userName)),
+				o:  managed.ExternalObservation{ResourceExists: false},
+			},
+		},
+		"ObserveOnlyWithoutExternalName": {
+			args: args{
+				observeOnly: true,
+				repository: &service.MockRepository{
+					MockLookupUser: func(ctx context.Context, uid string) (string, error) {
+						t.Errorf("LookupUser(...): an ObserveOnly User should not adopt a user by UID")
+						return externalName, nil
+					},
+					MockLookupUserByName: func(ctx context.Context, name string) (string, error) {
+						t.Errorf("LookupUserByName(...): an ObserveOnly User should not adopt a user by its own name")
+						return externalName, nil
+					},
+				},
+				cr: user(withSpec(v1alpha1.UserParameters{Name: userName})),
+			},
+			want: want{
+				cr:  user(withSpec(v1alpha1.UserParameters{Name: userName})),
+				err: errors.New(errNoExtName),
+			},
+		},
+		"AdoptedByUID": {
+			args: args{
+				repository: &service.MockRepository{
+					MockLookupUser: func(ctx context.Context, uid string) (string, error) {
+						return externalName, nil
+					},
+					MockGetUser: func(ctx context.Context, userUuid string) (*svctypes.GetUserResponse, error) {
+						return &svctypes.GetUserResponse{
+							Name:       userName,
+							NodeID:     externalName,
+							References: personaRefs,
+							Status:     "available",
+						}, nil
+					},
+					MockGetEffectiveAccess: noAccess,
+				},
+				cr: user(
+					withSpec(v1alpha1.UserParameters{
+						Name:     userName,
+						Personas: personaRefs,
+					}),
+				),
+			},
+			want: want{
+				cr: user(
+					withConditions(v1.Available()),
+					withExternalName(externalName),
+					withSpec(v1alpha1.UserParameters{
+						Name:     userName,
+						Personas: personaRefs,
+					}),
+					withStatus(v1alpha1.UserObservation{
+						NodeID:     externalName,
+						Status:     string(storetypes.StatusAvailable),
+						Name:       userName,
+						Personas:   personaRefs,
+						AccessHash: noAccessHash,
+					}),
+				),
+				o: managed.ExternalObservation{
+					ResourceExists:          true,
+					ResourceLateInitialized: true,
+					ResourceUpToDate:        true,
+				},
+			},
+		},
+		"NotFoundByUID": {
+			args: args{
+				repository: &service.MockRepository{
+					MockLookupUser: func(ctx context.Context, uid string) (string, error) {
+						return "", &storetypes.EntityNotFoundError{}
+					},
+				},
+				cr: user(),
+			},
+			want: want{
+				cr: user(),
+				o:  managed.ExternalObservation{ResourceExists: false},
+			},
+		},
+		"LookupFailed": {
+			args: args{
+				repository: &service.MockRepository{
+					MockLookupUser: func(ctx context.Context, uid string) (string, error) {
+						return "", errInternalServer
+					},
+				},
+				cr: user(),
+			},
+			want: want{
+				cr:  user(),
+				err: errors.Wrap(errInternalServer, errLookup),
+			},
+		},
 		"GetFailedInternalError": {
 			args: args{
 				kube: &test.MockClient{
 					MockUpdate: test.NewMockClient().Update,
 				},
 				repository: &service.MockRepository{
-					MockGetUser: func(userUuid string) (*svctypes.GetUserResponse, error) {
+					MockGetUser: func(ctx context.Context, userUuid string) (*svctypes.GetUserResponse, error) {
 						return nil, errInternalServer
 					},
 				},
@@ -223,11 +557,42 @@ func TestObserve(t *testing.T) {
 				err: errors.Wrap(errInternalServer, "cannot get user"),
 			},
 		},
+		"GetFailedUnavailable": {
+			args: args{
+				repository: &service.MockRepository{
+					MockGetUser: func(ctx context.Context, userUuid string) (*svctypes.GetUserResponse, error) {
+						return nil, errUnavailable
+					},
+				},
+				cr: user(withExternalName(externalName)),
+			},
+			want: want{
+				cr: user(
+					withExternalName(externalName),
+					withConditions(v1.Unavailable().WithMessage(errUnavailable.Error())),
+				),
+				err: errors.Wrap(errUnavailable, "cannot get user"),
+			},
+		},
+		"LookupFailedUnauthorized": {
+			args: args{
+				repository: &service.MockRepository{
+					MockLookupUser: func(ctx context.Context, uid string) (string, error) {
+						return "", errUnauthorized
+					},
+				},
+				cr: user(),
+			},
+			want: want{
+				cr:  user(withConditions(v1.Unavailable().WithMessage(errUnauthorized.Error()))),
+				err: errors.Wrap(errUnauthorized, errLookup),
+			},
+		},
 	}
 
 	for name, tc := range cases {
 		t.Run(name, func(t *testing.T) {
-			e := external{service: tc.args.repository}
+			e := external{service: tc.args.repository, observeOnly: tc.args.observeOnly}
 			o, err := e.Observe(context.Background(), tc.args.cr)
 
 			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
@@ -257,7 +622,7 @@ func TestCreate(t *testing.T) {
 		"SuccessfulCreate": {
 			args: args{
 				repository: &service.MockRepository{
-					MockCreateUser: func(userName string, personaRefs []string) (string, error) {
+					MockCreateUser: func(ctx context.Context, uid string, userName string, personaRefs []string) (string, error) {
 						return externalName, nil
 					},
 				},
@@ -285,7 +650,7 @@ func TestCreate(t *testing.T) {
 		"CreateFailed": {
 			args: args{
 				repository: &service.MockRepository{
-					MockCreateUser: func(userName string, personaRefs []string) (string, error) {
+					MockCreateUser: func(ctx context.Context, uid string, userName string, personaRefs []string) (string, error) {
 						return "", errInternalServer
 					},
 				},
@@ -329,9 +694,10 @@ func TestCreate(t *testing.T) {
 
 func TestUpdate(t *testing.T) {
 	type want struct {
-		cr  *v1alpha1.User
-		o   managed.ExternalUpdate
-		err error
+		cr     *v1alpha1.User
+		o      managed.ExternalUpdate
+		events []event.Event
+		err    error
 	}
 
 	cases := map[string]struct {
@@ -351,15 +717,15 @@ func TestUpdate(t *testing.T) {
 					MockUpdate: test.NewMockClient().Update,
 				},
 				repository: &service.MockRepository{
-					MockGetUser: func(userUuid string) (*svctypes.GetUserResponse, error) {
+					MockGetUser: func(ctx context.Context, userUuid string) (*svctypes.GetUserResponse, error) {
 						return &svctypes.GetUserResponse{
 							NodeID:     externalName,
 							Status:     "available",
 							References: append(personaRefs, "evil-persona-delete-storage-bkts"),
 						}, nil
 					},
-					MockUpdateUser: func(userName, userUuid string, personaRefs []string) error {
-						return nil
+					MockUpdateUser: func(ctx context.Context, userName, userUuid string, personaRefs []string) (svctypes.Changes, error) {
+						return svctypes.Changes{}, nil
 					},
 				},
 			},
@@ -374,6 +740,36 @@ func TestUpdate(t *testing.T) {
 				err: nil,
 			},
 		},
+		"UpdateChangedRelationships": {
+			args: args{
+				cr: user(
+					withSpec(v1alpha1.UserParameters{
+						Name:     userName,
+						Personas: personaRefs,
+					}),
+					withExternalName(externalName),
+				),
+				repository: &service.MockRepository{
+					MockUpdateUser: func(ctx context.Context, userName, userUuid string, personaRefs []string) (svctypes.Changes, error) {
+						return svctypes.Changes{
+							Added:   []svctypes.Relationship{{Type: svctypes.RelationGranted, Node: personaRefs[1]}},
+							Removed: []svctypes.Relationship{{Type: svctypes.RelationGranted, Node: "evil-persona-delete-storage-bkts"}},
+						}, nil
+					},
+				},
+			},
+			want: want{
+				cr: user(
+					withSpec(v1alpha1.UserParameters{
+						Name:     userName,
+						Personas: personaRefs,
+					}),
+					withExternalName(externalName),
+				),
+				events: []event.Event{event.Normal(reasonUpdated,
+					"added GRANTED production-access-for-everyone; removed GRANTED evil-persona-delete-storage-bkts")},
+			},
+		},
 		"UpdateFailed": {
 			args: args{
 				cr: user(
@@ -387,15 +783,15 @@ func TestUpdate(t *testing.T) {
 					MockUpdate: test.NewMockClient().Update,
 				},
 				repository: &service.MockRepository{
-					MockGetUser: func(userUuid string) (*svctypes.GetUserResponse, error) {
+					MockGetUser: func(ctx context.Context, userUuid string) (*svctypes.GetUserResponse, error) {
 						return &svctypes.GetUserResponse{
 							NodeID:     externalName,
 							Status:     "available",
 							References: personaRefs,
 						}, nil
 					},
-					MockUpdateUser: func(userName, userUuid string, personaRefs []string) error {
-						return errInternalServer
+					MockUpdateUser: func(ctx context.Context, userName, userUuid string, personaRefs []string) (svctypes.Changes, error) {
+						return svctypes.Changes{}, errInternalServer
 					},
 				},
 			},
@@ -414,7 +810,8 @@ func TestUpdate(t *testing.T) {
 
 	for name, tc := range cases {
 		t.Run(name, func(t *testing.T) {
-			e := external{service: tc.args.repository}
+			rec := &recorder{}
+			e := external{service: tc.args.repository, record: rec}
 			o, err := e.Update(context.Background(), tc.args.cr)
 
 			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
@@ -426,6 +823,9 @@ func TestUpdate(t *testing.T) {
 			if diff := cmp.Diff(tc.want.o, o); diff != "" {
 				t.Errorf("r: -want, +got:\n%s", diff)
 			}
+			if diff := cmp.Diff(tc.want.events, rec.events); diff != "" {
+				t.Errorf("r: -want events, +got events:\n%s", diff)
+			}
 		})
 	}
 }
@@ -444,7 +844,7 @@ func TestDelete(t *testing.T) {
 			args: args{
 				cr: user(),
 				repository: &service.MockRepository{
-					MockDeleteUser: func(userUuid string) error {
+					MockDeleteUser: func(ctx context.Context, userUuid string) error {
 						return nil
 					},
 				},
@@ -458,7 +858,7 @@ func TestDelete(t *testing.T) {
 			args: args{
 				cr: user(),
 				repository: &service.MockRepository{
-					MockDeleteUser: func(userUuid string) error {
+					MockDeleteUser: func(ctx context.Context, userUuid string) error {
 						return errInternalServer
 					},
 				},
@@ -484,3 +884,79 @@ func TestDelete(t *testing.T) {
 		})
 	}
 }
+
+func TestTimeout(t *testing.T) {
+	cases := map[string]struct {
+		timeout     time.Duration
+		wantBounded bool
+	}{
+		"Bounded":   {timeout: time.Minute, wantBounded: true},
+		"Unbounded": {},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var bounded bool
+			e := external{
+				timeout: tc.timeout,
+				service: &service.MockRepository{
+					MockDeleteUser: func(ctx context.Context, userUuid string) error {
+						_, bounded = ctx.Deadline()
+						return nil
+					},
+				},
+			}
+
+			if err := e.Delete(context.Background(), user(withExternalName(externalName))); err != nil {
+				t.Fatalf("Delete(...): %v", err)
+			}
+			if bounded != tc.wantBounded {
+				t.Errorf("Delete(...): want storage call bounded %t, got %t", tc.wantBounded, bounded)
+			}
+		})
+	}
+}
+
+func TestRecoverLostExternalName(t *testing.T) {
+	uid := types.UID("0c5a4b3e-7d0f-4d3a-9b5e-2f1c8a6e4d21")
+	cr := user(withSpec(v1alpha1.UserParameters{Name: userName, Personas: personaRefs}))
+	cr.SetName(userName)
+	cr.SetUID(uid)
+	cr.SetProviderConfigReference(&v1.Reference{Name: "default"})
+
+	kube := &test.MockClient{MockUpdate: test.NewMockUpdateFn(nil)}
+	for _, i := range initializers(kube) {
+		if err := i.Initialize(context.Background(), cr); err != nil {
+			t.Fatalf("Initialize(...): %v", err)
+		}
+	}
+	if ext := meta.GetExternalName(cr); ext != "" {
+		t.Fatalf("Initialize(...): want no external name for a User which lost it, got %q", ext)
+	}
+
+	e := external{service: &service.MockRepository{
+		MockLookupUser: func(ctx context.Context, got string) (string, error) {
+			if got != string(uid) {
+				return "", &storetypes.EntityNotFoundError{}
+			}
+			return externalName, nil
+		},
+		MockGetUser: func(ctx context.Context, userUuid string) (*svctypes.GetUserResponse, error) {
+			if userUuid != externalName {
+				return nil, &storetypes.EntityNotFoundError{}
+			}
+			return &svctypes.GetUserResponse{Name: userName, NodeID: externalName, References: personaRefs, Status: "available"}, nil
+		},
+		MockGetEffectiveAccess: noAccess,
+	}}
+	o, err := e.Observe(context.Background(), cr)
+	if err != nil {
+		t.Fatalf("Observe(...): %v", err)
+	}
+	if !o.ResourceExists || !o.ResourceLateInitialized {
+		t.Errorf("Observe(...): want the user created for the User adopted, got %+v", o)
+	}
+	if ext := meta.GetExternalName(cr); ext != externalName {
+		t.Errorf("Observe(...): want external name %q, got %q", externalName, ext)
+	}
+}
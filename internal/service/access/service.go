@@ -0,0 +1,151 @@
+package access
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pkg/errors"
+	kmeta "k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+
+	"github.com/VariableExp0rt/powerbroker/apis/powerbroker/v1alpha1"
+	powerbroker "github.com/VariableExp0rt/powerbroker/internal/service"
+	"github.com/VariableExp0rt/powerbroker/internal/service/types"
+	"github.com/VariableExp0rt/powerbroker/internal/storage"
+)
+
+// lists returns an empty list of the managed resources of each label a hop
+// may have.
+var lists = map[string]func() client.ObjectList{
+	v1alpha1.UserKind:          func() client.ObjectList { return &v1alpha1.UserList{} },
+	v1alpha1.TeamKind:          func() client.ObjectList { return &v1alpha1.TeamList{} },
+	v1alpha1.PersonaKind:       func() client.ObjectList { return &v1alpha1.PersonaList{} },
+	v1alpha1.PermissionSetKind: func() client.ObjectList { return &v1alpha1.PermissionSetList{} },
+	v1alpha1.AccountKind:       func() client.ObjectList { return &v1alpha1.AccountList{} },
+	v1alpha1.RoleKind:          func() client.ObjectList { return &v1alpha1.RoleList{} },
+}
+
+type Service interface {
+	// ExplainAccess returns every distinct path which grants the user with
+	// the supplied uuid access to the account with the role in the cloud,
+	// with the name of the managed resource behind each hop. It returns
+	// storage.ErrCannotExplain if the storage is not an Explainer.
+	ExplainAccess(ctx context.Context, userUuid, cloud, accountID, roleName string) ([]types.Path, error)
+}
+
+type service struct {
+	repository powerbroker.Repository
+	kube       client.Reader
+}
+
+// NewService returns a Service which explains access with the supplied
+// Repository, and reads managed resources with the supplied client.
+func NewService(repo powerbroker.Repository, kube client.Reader) Service {
+	return &service{repository: repo, kube: kube}
+}
+
+func (s *service) ExplainAccess(ctx context.Context, userUuid, cloud, accountID, roleName string) ([]types.Path, error) {
+	e, ok := s.repository.(storage.Explainer)
+	if !ok {
+		return nil, storage.ErrCannotExplain
+	}
+
+	paths, err := e.ExplainAccess(ctx, userUuid, cloud, accountID, roleName)
+	if err != nil {
+		return nil, err
+	}
+
+	names := map[string]*resources{}
+	for _, p := range paths {
+		for _, h := range p.Hops {
+			if _, ok := names[h.Label]; ok {
+				continue
+			}
+			r, err := s.resources(ctx, h.Label)
+			if err != nil {
+				return nil, err
+			}
+			names[h.Label] = r
+		}
+	}
+
+	for _, p := range paths {
+		for i := range p.Hops {
+			p.Hops[i].Resource = names[p.Hops[i].Label].name(p.Hops[i], p.Cloud)
+		}
+	}
+	sort.Slice(paths, func(i, j int) bool { return paths[i].String() < paths[j].String() })
+
+	return paths, nil
+}
+
+// resources are the names of the managed resources of a kind, by their UID
+// and by their external name. The external names of Accounts and Roles are
+// prefixed with their cloud, since the same id or name may be used in each.
+type resources struct {
+	byUID          map[string]string
+	byExternalName map[string]string
+}
+
+// name returns the name of the managed resource behind the hop of a path to
+// the supplied cloud: the one which manages its node, or else the one which
+// observes it. It returns an empty string if there is neither.
+func (r *resources) name(h types.Hop, cloud string) string {
+	if n, ok := r.byUID[h.UID]; ok && h.UID != "" {
+		return n
+	}
+
+	if h.Label == v1alpha1.AccountKind || h.Label == v1alpha1.RoleKind {
+		return r.byExternalName[cloud+"/"+h.ID]
+	}
+
+	return r.byExternalName[h.ID]
+}
+
+// externalKey returns the key of a managed resource with the supplied
+// external name in resources.byExternalName.
+func externalKey(o client.Object, ext string) string {
+	switch cr := o.(type) {
+	case *v1alpha1.Account:
+		return types.DefaultCloud(cr.Spec.ForProvider.Cloud) + "/" + ext
+	case *v1alpha1.Role:
+		return types.DefaultCloud(cr.Spec.ForProvider.Cloud) + "/" + ext
+	}
+
+	return ext
+}
+
+// resources returns the names of the managed resources of the kind hops with
+// the supplied label have.
+func (s *service) resources(ctx context.Context, label string) (*resources, error) {
+	r := &resources{byUID: map[string]string{}, byExternalName: map[string]string{}}
+
+	newList, ok := lists[label]
+	if !ok {
+		return r, nil
+	}
+
+	l := newList()
+	if err := s.kube.List(ctx, l); err != nil {
+		return nil, errors.Wrapf(err, "cannot list %s resources", label)
+	}
+	items, err := kmeta.ExtractList(l)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot list %s resources", label)
+	}
+
+	for _, i := range items {
+		o, ok := i.(client.Object)
+		if !ok {
+			continue
+		}
+		r.byUID[string(o.GetUID())] = o.GetName()
+		if ext := meta.GetExternalName(o); ext != "" {
+			r.byExternalName[externalKey(o, ext)] = o.GetName()
+		}
+	}
+
+	return r, nil
+}
@@ -0,0 +1,129 @@
+package access
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ktypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/crossplane/crossplane-runtime/pkg/meta"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/VariableExp0rt/powerbroker/apis/powerbroker/v1alpha1"
+	powerbroker "github.com/VariableExp0rt/powerbroker/internal/service"
+	"github.com/VariableExp0rt/powerbroker/internal/service/types"
+	"github.com/VariableExp0rt/powerbroker/internal/storage"
+)
+
+var errBoom = errors.New("boom")
+
+type explainer struct {
+	powerbroker.MockRepository
+
+	paths []types.Path
+	err   error
+}
+
+func (e *explainer) ExplainAccess(context.Context, string, string, string, string) ([]types.Path, error) {
+	return e.paths, e.err
+}
+
+func object(name, uid, externalName string) metav1.ObjectMeta {
+	o := metav1.ObjectMeta{Name: name, UID: ktypes.UID(uid)}
+	if externalName != "" {
+		meta.SetExternalName(&o, externalName)
+	}
+
+	return o
+}
+
+func TestExplainAccess(t *testing.T) {
+	target := types.Target{Cloud: v1alpha1.CloudAWS, Account: "123456789012", Role: "ReadOnly"}
+	path := func() types.Path {
+		return types.Path{Target: target, Hops: []types.Hop{
+			{Label: v1alpha1.UserKind, ID: "u-1", Name: "mario", UID: "uid-mario"},
+			{Label: v1alpha1.PersonaKind, ID: "pe-1", Name: "auditor", UID: "uid-auditor"},
+			{Label: v1alpha1.PermissionSetKind, ID: "ps-1", Name: "readonly"},
+			{Label: v1alpha1.AccountKind, ID: "123456789012", Name: "123456789012"},
+			{Label: v1alpha1.RoleKind, ID: "ReadOnly", Name: "ReadOnly", UID: "uid-role"},
+		}}
+	}
+
+	kube := &test.MockClient{
+		MockList: func(_ context.Context, list client.ObjectList, _ ...client.ListOption) error {
+			switch l := list.(type) {
+			case *v1alpha1.UserList:
+				l.Items = []v1alpha1.User{{ObjectMeta: object("mario-cr", "uid-mario", "u-1")}}
+			case *v1alpha1.PersonaList:
+				l.Items = []v1alpha1.Persona{{ObjectMeta: object("auditor-cr", "uid-auditor", "pe-1")}}
+			case *v1alpha1.PermissionSetList:
+				// Observes the permission set its node was not created for.
+				l.Items = []v1alpha1.PermissionSet{{ObjectMeta: object("readonly-cr", "uid-other", "ps-1")}}
+			case *v1alpha1.AccountList:
+				// Observes the project with the same id as the account.
+				l.Items = []v1alpha1.Account{{
+					ObjectMeta: object("analytics-cr", "uid-analytics", "123456789012"),
+					Spec:       v1alpha1.AccountSpec{ForProvider: v1alpha1.AccountParameters{Cloud: v1alpha1.CloudGCP, ID: "123456789012"}},
+				}}
+			case *v1alpha1.RoleList:
+				l.Items = []v1alpha1.Role{{ObjectMeta: object("readonly-role", "uid-role", "ReadOnly")}}
+			}
+			return nil
+		},
+	}
+
+	type want struct {
+		paths []string
+		err   error
+	}
+
+	cases := map[string]struct {
+		repo powerbroker.Repository
+		kube client.Reader
+		want want
+	}{
+		"NamesResources": {
+			repo: &explainer{paths: []types.Path{path()}},
+			kube: kube,
+			want: want{paths: []string{
+				"User mario (mario-cr) → Persona auditor (auditor-cr) → PermissionSet readonly (readonly-cr) → Account 123456789012/Role ReadOnly (readonly-role)",
+			}},
+		},
+		"CannotExplain": {
+			repo: &powerbroker.MockRepository{},
+			kube: kube,
+			want: want{err: storage.ErrCannotExplain},
+		},
+		"ExplainFailed": {
+			repo: &explainer{err: errBoom},
+			kube: kube,
+			want: want{err: errBoom},
+		},
+		"ListFailed": {
+			repo: &explainer{paths: []types.Path{path()}},
+			kube: &test.MockClient{MockList: test.NewMockListFn(errBoom)},
+			want: want{err: errors.Wrapf(errBoom, "cannot list %s resources", v1alpha1.UserKind)},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			paths, err := NewService(tc.repo, tc.kube).ExplainAccess(context.Background(), "u-1", v1alpha1.CloudAWS, "123456789012", "ReadOnly")
+
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("ExplainAccess(...): -want error, +got error:\n%s", diff)
+			}
+			var got []string
+			for _, p := range paths {
+				got = append(got, p.String())
+			}
+			if diff := cmp.Diff(tc.want.paths, got); diff != "" {
+				t.Errorf("ExplainAccess(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package account
+
+import (
+	"context"
+
+	"github.com/VariableExp0rt/powerbroker/apis/powerbroker/v1alpha1"
+	powerbroker "github.com/VariableExp0rt/powerbroker/internal/service"
+	"github.com/VariableExp0rt/powerbroker/internal/service/types"
+)
+
+type Service interface {
+	CreateAccount(ctx context.Context, uid string, account *v1alpha1.AccountParameters) (string, error)
+	LookupAccount(ctx context.Context, uid string) (string, error)
+	GetAccount(ctx context.Context, cloud, id string) (*types.GetAccountResponse, error)
+	UpdateAccount(ctx context.Context, cloud, id string, account *v1alpha1.AccountParameters) error
+	DeleteAccount(ctx context.Context, cloud, id string) error
+
+	// WhoCanAccess returns each user who can access an account with a
+	// role in a cloud, with the shortest path which grants it.
+	WhoCanAccess(ctx context.Context, cloud, id, roleName string) ([]types.Grant, error)
+}
+
+type service struct {
+	repository powerbroker.Repository
+}
+
+func NewService(repo powerbroker.Repository) Service {
+	return &service{repository: repo}
+}
+
+func (s *service) CreateAccount(ctx context.Context, uid string, account *v1alpha1.AccountParameters) (string, error) {
+	return s.repository.CreateAccount(ctx, uid, account)
+}
+
+func (s *service) LookupAccount(ctx context.Context, uid string) (string, error) {
+	return s.repository.LookupAccount(ctx, uid)
+}
+
+func (s *service) GetAccount(ctx context.Context, cloud, id string) (*types.GetAccountResponse, error) {
+	return s.repository.GetAccount(ctx, cloud, id)
+}
+
+func (s *service) UpdateAccount(ctx context.Context, cloud, id string, account *v1alpha1.AccountParameters) error {
+	return s.repository.UpdateAccount(ctx, cloud, id, account)
+}
+
+func (s *service) DeleteAccount(ctx context.Context, cloud, id string) error {
+	return s.repository.DeleteAccount(ctx, cloud, id)
+}
+
+func (s *service) WhoCanAccess(ctx context.Context, cloud, id, roleName string) ([]types.Grant, error) {
+	grants, err := s.repository.WhoCanAccess(ctx, cloud, id, roleName)
+	if err != nil {
+		return nil, err
+	}
+
+	return types.Shortest(grants), nil
+}
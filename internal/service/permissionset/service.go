@@ -1,16 +1,23 @@
 package permissionset
 
 import (
+	"context"
+
 	"github.com/VariableExp0rt/powerbroker/apis/powerbroker/v1alpha1"
 	powerbroker "github.com/VariableExp0rt/powerbroker/internal/service"
 	"github.com/VariableExp0rt/powerbroker/internal/service/types"
 )
 
 type Service interface {
-	CreatePermissionSet(name string, binding v1alpha1.AccountRoleBinding) (string, error)
-	GetPermissionSet(name string) (*types.GetPermissionSetResponse, error)
-	UpdatePermissionSet(uuid, name string, binding v1alpha1.AccountRoleBinding) error
-	DeletePermissionSet(name string) error
+	CreatePermissionSet(ctx context.Context, uid, name string, bindings []v1alpha1.AccountRoleBinding) (string, error)
+	LookupPermissionSet(ctx context.Context, uid string) (string, error)
+	GetPermissionSet(ctx context.Context, name string) (*types.GetPermissionSetResponse, error)
+	UpdatePermissionSet(ctx context.Context, uuid, name string, bindings []v1alpha1.AccountRoleBinding) (types.Changes, error)
+	DeletePermissionSet(ctx context.Context, name string) error
+
+	// GetAccount returns the account with the supplied id in the cloud, or
+	// an EntityNotFoundError if no Account manages it.
+	GetAccount(ctx context.Context, cloud, accountID string) (*types.GetAccountResponse, error)
 }
 
 type service struct {
@@ -21,16 +28,24 @@ func NewService(repo powerbroker.Repository) Service {
 	return &service{repository: repo}
 }
 
-func (s *service) CreatePermissionSet(name string, binding v1alpha1.AccountRoleBinding) (string, error) {
-	return s.repository.CreatePermissionSet(name, binding)
+func (s *service) CreatePermissionSet(ctx context.Context, uid, name string, bindings []v1alpha1.AccountRoleBinding) (string, error) {
+	return s.repository.CreatePermissionSet(ctx, uid, name, bindings)
+}
+
+func (s *service) LookupPermissionSet(ctx context.Context, uid string) (string, error) {
+	return s.repository.LookupPermissionSet(ctx, uid)
 }
 
-func (s *service) GetPermissionSet(name string) (*types.GetPermissionSetResponse, error) {
-	return s.repository.GetPermissionSet(name)
+func (s *service) GetPermissionSet(ctx context.Context, name string) (*types.GetPermissionSetResponse, error) {
+	return s.repository.GetPermissionSet(ctx, name)
+}
+func (s *service) UpdatePermissionSet(ctx context.Context, uuid, name string, bindings []v1alpha1.AccountRoleBinding) (types.Changes, error) {
+	return s.repository.UpdatePermissionSet(ctx, uuid, name, bindings)
 }
-func (s *service) UpdatePermissionSet(uuid, name string, binding v1alpha1.AccountRoleBinding) error {
-	return s.repository.UpdatePermissionSet(uuid, name, binding)
+func (s *service) DeletePermissionSet(ctx context.Context, name string) error {
+	return s.repository.DeletePermissionSet(ctx, name)
 }
-func (s *service) DeletePermissionSet(name string) error {
-	return s.repository.DeletePermissionSet(name)
+
+func (s *service) GetAccount(ctx context.Context, cloud, accountID string) (*types.GetAccountResponse, error) {
+	return s.repository.GetAccount(ctx, cloud, accountID)
 }
@@ -1,15 +1,19 @@
 package persona
 
 import (
+	"context"
+
 	powerbroker "github.com/VariableExp0rt/powerbroker/internal/service"
 	"github.com/VariableExp0rt/powerbroker/internal/service/types"
 )
 
 type Service interface {
-	CreatePersona(personaname string, permsetReferences []string) (string, error)
-	GetPersona(personaname string) (*types.GetPersonaResponse, error)
-	UpdatePersona(personaname, personaUuid string, permsetReferences []string) error
-	DeletePersona(personaname string) error
+	CreatePersona(ctx context.Context, uid, personaname string, permsetReferences []string) (string, error)
+	LookupPersona(ctx context.Context, uid string) (string, error)
+	LookupPersonaByName(ctx context.Context, name string) (string, error)
+	GetPersona(ctx context.Context, personaname string) (*types.GetPersonaResponse, error)
+	UpdatePersona(ctx context.Context, personaname, personaUuid string, permsetReferences []string) (types.Changes, error)
+	DeletePersona(ctx context.Context, personaname string) error
 }
 
 type service struct {
@@ -20,18 +24,26 @@ func NewService(repo powerbroker.Repository) Service {
 	return &service{repository: repo}
 }
 
-func (s *service) CreatePersona(personaname string, personaReferences []string) (string, error) {
-	return s.repository.CreatePersona(personaname, personaReferences)
+func (s *service) CreatePersona(ctx context.Context, uid, personaname string, personaReferences []string) (string, error) {
+	return s.repository.CreatePersona(ctx, uid, personaname, personaReferences)
+}
+
+func (s *service) LookupPersona(ctx context.Context, uid string) (string, error) {
+	return s.repository.LookupPersona(ctx, uid)
+}
+
+func (s *service) LookupPersonaByName(ctx context.Context, name string) (string, error) {
+	return s.repository.LookupPersonaByName(ctx, name)
 }
 
-func (s *service) GetPersona(name string) (*types.GetPersonaResponse, error) {
-	return s.repository.GetPersona(name)
+func (s *service) GetPersona(ctx context.Context, name string) (*types.GetPersonaResponse, error) {
+	return s.repository.GetPersona(ctx, name)
 }
 
-func (s *service) UpdatePersona(name, uuid string, references []string) error {
-	return s.repository.UpdatePersona(name, uuid, references)
+func (s *service) UpdatePersona(ctx context.Context, name, uuid string, references []string) (types.Changes, error) {
+	return s.repository.UpdatePersona(ctx, name, uuid, references)
 }
 
-func (s *service) DeletePersona(name string) error {
-	return s.repository.DeletePersona(name)
+func (s *service) DeletePersona(ctx context.Context, name string) error {
+	return s.repository.DeletePersona(ctx, name)
 }
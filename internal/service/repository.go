@@ -1,28 +1,81 @@
 package service
 
 import (
+	"context"
+
 	"github.com/VariableExp0rt/powerbroker/apis/powerbroker/v1alpha1"
 	"github.com/VariableExp0rt/powerbroker/internal/service/types"
 )
 
 // Repository is an interface which must be satisfied by storage
-// objects that implement the interface. Currently only neo4j - planned
-// extension for SpiceDB.
+// objects that implement the interface. It is implemented by the neo4j,
+// SpiceDB and memory backends in internal/storage, which the conformance
+// suite holds to the contract below.
+//
+// Every Create method takes the UID of the managed resource the entity
+// belongs to. Creating an entity with a UID that is already stored returns
+// the existing entity rather than a duplicate, and the Lookup methods find
+// an entity by UID should the external name of its managed resource be lost.
+// The LookupByName methods find an entity which was not created by the
+// provider by its name instead, which must be unique.
+//
+// Every method takes a context. An implementation must return once the
+// context is done, rather than block the reconcile which called it.
+//
+// The Update methods only add and remove the relationships which differ
+// from those stored, and return the changes they made.
+//
+// Accounts and roles are identified by their cloud and their id or name at
+// it rather than a uuid, since permission sets bind to them by those, so
+// the same id or name may be used in several clouds. The external name of
+// an Account or Role is its id or name, and its cloud is that of its
+// parameters. A permission set binds each account and role at most once,
+// and returns its bindings in the order of types.BindingKey.
+//
+// GetAccount and UpdateAccount only find an account an Account manages.
+// DeleteAccount releases an account a permission set still binds, removing
+// only its Account and the properties it set, so that the binding is kept
+// and the account is collected once it is orphaned. The Role methods do the
+// same for roles.
+//
+// GetEffectiveAccess returns every binding a user can use, once for each
+// path to it: through a persona granted to the user, or one inherited by a
+// team the user is a member of. Bindings without a role grant no access.
+// WhoCanAccess walks the same paths back from an account and role, and
+// returns a grant for each user at the end of each of them.
 type Repository interface {
-	CreateUser(string, []string) (string, error)
-	GetUser(string) (*types.GetUserResponse, error)
-	UpdateUser(userName string, userUuid string, personaRefs []string) error
-	DeleteUser(string) error
-	CreatePersona(personaName string, permissionSetRefs []string) (string, error)
-	GetPersona(string) (*types.GetPersonaResponse, error)
-	UpdatePersona(personaName string, personaUuid string, permissionSetUuids []string) error
-	DeletePersona(string) error
-	CreatePermissionSet(string, v1alpha1.AccountRoleBinding) (string, error)
-	GetPermissionSet(string) (*types.GetPermissionSetResponse, error)
-	UpdatePermissionSet(permissionSetUuid, crName string, binding v1alpha1.AccountRoleBinding) error
-	DeletePermissionSet(string) error
-	CreateTeam(*v1alpha1.TeamParameters) (string, error)
-	GetTeam(string) (*types.GetTeamResponse, error)
-	UpdateTeam(string, *v1alpha1.TeamParameters) error
-	DeleteTeam(string) error
+	CreateUser(ctx context.Context, uid, userName string, personaRefs []string) (string, error)
+	LookupUser(ctx context.Context, uid string) (string, error)
+	LookupUserByName(ctx context.Context, name string) (string, error)
+	GetUser(context.Context, string) (*types.GetUserResponse, error)
+	UpdateUser(ctx context.Context, userName string, userUuid string, personaRefs []string) (types.Changes, error)
+	DeleteUser(context.Context, string) error
+	GetEffectiveAccess(ctx context.Context, userUuid string) ([]types.Access, error)
+	CreatePersona(ctx context.Context, uid, personaName string, permissionSetRefs []string) (string, error)
+	LookupPersona(ctx context.Context, uid string) (string, error)
+	LookupPersonaByName(ctx context.Context, name string) (string, error)
+	GetPersona(context.Context, string) (*types.GetPersonaResponse, error)
+	UpdatePersona(ctx context.Context, personaName string, personaUuid string, permissionSetUuids []string) (types.Changes, error)
+	DeletePersona(context.Context, string) error
+	CreatePermissionSet(ctx context.Context, uid, name string, bindings []v1alpha1.AccountRoleBinding) (string, error)
+	LookupPermissionSet(ctx context.Context, uid string) (string, error)
+	GetPermissionSet(context.Context, string) (*types.GetPermissionSetResponse, error)
+	UpdatePermissionSet(ctx context.Context, permissionSetUuid, crName string, bindings []v1alpha1.AccountRoleBinding) (types.Changes, error)
+	DeletePermissionSet(context.Context, string) error
+	CreateTeam(ctx context.Context, uid string, teamparams *v1alpha1.TeamParameters) (string, error)
+	LookupTeam(ctx context.Context, uid string) (string, error)
+	GetTeam(context.Context, string) (*types.GetTeamResponse, error)
+	UpdateTeam(context.Context, string, *v1alpha1.TeamParameters) (types.Changes, error)
+	DeleteTeam(context.Context, string) error
+	CreateAccount(ctx context.Context, uid string, account *v1alpha1.AccountParameters) (string, error)
+	LookupAccount(ctx context.Context, uid string) (string, error)
+	GetAccount(ctx context.Context, cloud, accountID string) (*types.GetAccountResponse, error)
+	UpdateAccount(ctx context.Context, cloud, accountID string, account *v1alpha1.AccountParameters) error
+	DeleteAccount(ctx context.Context, cloud, accountID string) error
+	WhoCanAccess(ctx context.Context, cloud, accountID, roleName string) ([]types.Grant, error)
+	CreateRole(ctx context.Context, uid string, role *v1alpha1.RoleParameters) (string, error)
+	LookupRole(ctx context.Context, uid string) (string, error)
+	GetRole(ctx context.Context, cloud, roleName string) (*types.GetRoleResponse, error)
+	UpdateRole(ctx context.Context, cloud, roleName string, role *v1alpha1.RoleParameters) error
+	DeleteRole(ctx context.Context, cloud, roleName string) error
 }
@@ -1,88 +1,180 @@
 package service
 
 import (
+	"context"
+
 	"github.com/VariableExp0rt/powerbroker/apis/powerbroker/v1alpha1"
 	"github.com/VariableExp0rt/powerbroker/internal/service/types"
 )
 
 type MockRepository struct {
-	MockCreateUser          func(string, []string) (string, error)
-	MockGetUser             func(string) (*types.GetUserResponse, error)
-	MockUpdateUser          func(userName string, userUuid string, personaRefs []string) error
-	MockDeleteUser          func(string) error
-	MockCreatePersona       func(personaName string, permissionSetRefs []string) (string, error)
-	MockGetPersona          func(string) (*types.GetPersonaResponse, error)
-	MockUpdatePersona       func(personaName string, personaUuid string, permissionSetUuids []string) error
-	MockDeletePersona       func(string) error
-	MockCreatePermissionSet func(string, v1alpha1.AccountRoleBinding) (string, error)
-	MockGetPermissionSet    func(string) (*types.GetPermissionSetResponse, error)
-	MockUpdatePermissionSet func(permissionSetUuid, crName string, binding v1alpha1.AccountRoleBinding) error
-	MockDeletePermissionSet func(string) error
-	MockCreateTeam          func(*v1alpha1.TeamParameters) (string, error)
-	MockGetTeam             func(string) (*types.GetTeamResponse, error)
-	MockUpdateTeam          func(string, *v1alpha1.TeamParameters) error
-	MockDeleteTeam          func(string) error
+	MockCreateUser          func(ctx context.Context, uid, userName string, personaRefs []string) (string, error)
+	MockLookupUser          func(ctx context.Context, uid string) (string, error)
+	MockLookupUserByName    func(ctx context.Context, name string) (string, error)
+	MockGetUser             func(context.Context, string) (*types.GetUserResponse, error)
+	MockUpdateUser          func(ctx context.Context, userName string, userUuid string, personaRefs []string) (types.Changes, error)
+	MockDeleteUser          func(context.Context, string) error
+	MockGetEffectiveAccess  func(ctx context.Context, userUuid string) ([]types.Access, error)
+	MockCreatePersona       func(ctx context.Context, uid, personaName string, permissionSetRefs []string) (string, error)
+	MockLookupPersona       func(ctx context.Context, uid string) (string, error)
+	MockLookupPersonaByName func(ctx context.Context, name string) (string, error)
+	MockGetPersona          func(context.Context, string) (*types.GetPersonaResponse, error)
+	MockUpdatePersona       func(ctx context.Context, personaName string, personaUuid string, permissionSetUuids []string) (types.Changes, error)
+	MockDeletePersona       func(context.Context, string) error
+	MockCreatePermissionSet func(ctx context.Context, uid, name string, bindings []v1alpha1.AccountRoleBinding) (string, error)
+	MockLookupPermissionSet func(ctx context.Context, uid string) (string, error)
+	MockGetPermissionSet    func(context.Context, string) (*types.GetPermissionSetResponse, error)
+	MockUpdatePermissionSet func(ctx context.Context, permissionSetUuid, crName string, bindings []v1alpha1.AccountRoleBinding) (types.Changes, error)
+	MockDeletePermissionSet func(context.Context, string) error
+	MockCreateTeam          func(ctx context.Context, uid string, teamparams *v1alpha1.TeamParameters) (string, error)
+	MockLookupTeam          func(ctx context.Context, uid string) (string, error)
+	MockGetTeam             func(context.Context, string) (*types.GetTeamResponse, error)
+	MockUpdateTeam          func(context.Context, string, *v1alpha1.TeamParameters) (types.Changes, error)
+	MockDeleteTeam          func(context.Context, string) error
+	MockCreateAccount       func(ctx context.Context, uid string, account *v1alpha1.AccountParameters) (string, error)
+	MockLookupAccount       func(ctx context.Context, uid string) (string, error)
+	MockGetAccount          func(ctx context.Context, cloud, accountID string) (*types.GetAccountResponse, error)
+	MockUpdateAccount       func(ctx context.Context, cloud, accountID string, account *v1alpha1.AccountParameters) error
+	MockDeleteAccount       func(ctx context.Context, cloud, accountID string) error
+	MockWhoCanAccess        func(ctx context.Context, cloud, accountID, roleName string) ([]types.Grant, error)
+	MockCreateRole          func(ctx context.Context, uid string, role *v1alpha1.RoleParameters) (string, error)
+	MockLookupRole          func(ctx context.Context, uid string) (string, error)
+	MockGetRole             func(ctx context.Context, cloud, roleName string) (*types.GetRoleResponse, error)
+	MockUpdateRole          func(ctx context.Context, cloud, roleName string, role *v1alpha1.RoleParameters) error
+	MockDeleteRole          func(ctx context.Context, cloud, roleName string) error
+}
+
+func (_m MockRepository) CreateUser(ctx context.Context, uid, name string, personaReferences []string) (string, error) {
+	return _m.MockCreateUser(ctx, uid, name, personaReferences)
+}
+
+func (_m MockRepository) LookupUser(ctx context.Context, uid string) (string, error) {
+	return _m.MockLookupUser(ctx, uid)
 }
 
-func (_m MockRepository) CreateUser(name string, personaReferences []string) (string, error) {
-	return _m.MockCreateUser(name, personaReferences)
+func (_m MockRepository) LookupUserByName(ctx context.Context, name string) (string, error) {
+	return _m.MockLookupUserByName(ctx, name)
 }
 
-func (_m MockRepository) GetUser(uuid string) (*types.GetUserResponse, error) {
-	return _m.MockGetUser(uuid)
+func (_m MockRepository) GetUser(ctx context.Context, uuid string) (*types.GetUserResponse, error) {
+	return _m.MockGetUser(ctx, uuid)
 }
 
-func (_m MockRepository) UpdateUser(userName string, userUuid string, personaRefs []string) error {
-	return _m.MockUpdateUser(userName, userUuid, personaRefs)
+func (_m MockRepository) UpdateUser(ctx context.Context, userName string, userUuid string, personaRefs []string) (types.Changes, error) {
+	return _m.MockUpdateUser(ctx, userName, userUuid, personaRefs)
 }
 
-func (_m MockRepository) DeleteUser(uuid string) error {
-	return _m.MockDeleteUser(uuid)
+func (_m MockRepository) DeleteUser(ctx context.Context, uuid string) error {
+	return _m.MockDeleteUser(ctx, uuid)
 }
 
-func (_m MockRepository) CreatePersona(personaName string, permissionSetRefs []string) (string, error) {
-	return _m.MockCreatePersona(personaName, permissionSetRefs)
+func (_m MockRepository) GetEffectiveAccess(ctx context.Context, userUuid string) ([]types.Access, error) {
+	return _m.MockGetEffectiveAccess(ctx, userUuid)
 }
 
-func (_m MockRepository) GetPersona(uuid string) (*types.GetPersonaResponse, error) {
-	return _m.MockGetPersona(uuid)
+func (_m MockRepository) CreatePersona(ctx context.Context, uid, personaName string, permissionSetRefs []string) (string, error) {
+	return _m.MockCreatePersona(ctx, uid, personaName, permissionSetRefs)
 }
 
-func (_m MockRepository) UpdatePersona(personaName string, personaUuid string, permissionSetUuids []string) error {
-	return _m.MockUpdatePersona(personaName, personaUuid, permissionSetUuids)
+func (_m MockRepository) LookupPersona(ctx context.Context, uid string) (string, error) {
+	return _m.MockLookupPersona(ctx, uid)
 }
 
-func (_m MockRepository) DeletePersona(uuid string) error {
-	return _m.MockDeletePersona(uuid)
+func (_m MockRepository) LookupPersonaByName(ctx context.Context, name string) (string, error) {
+	return _m.MockLookupPersonaByName(ctx, name)
 }
 
-func (_m MockRepository) CreatePermissionSet(name string, binding v1alpha1.AccountRoleBinding) (string, error) {
-	return _m.MockCreatePermissionSet(name, binding)
+func (_m MockRepository) GetPersona(ctx context.Context, uuid string) (*types.GetPersonaResponse, error) {
+	return _m.MockGetPersona(ctx, uuid)
 }
 
-func (_m MockRepository) GetPermissionSet(uuid string) (*types.GetPermissionSetResponse, error) {
-	return _m.MockGetPermissionSet(uuid)
+func (_m MockRepository) UpdatePersona(ctx context.Context, personaName string, personaUuid string, permissionSetUuids []string) (types.Changes, error) {
+	return _m.MockUpdatePersona(ctx, personaName, personaUuid, permissionSetUuids)
 }
 
-func (_m MockRepository) UpdatePermissionSet(permissionSetUuid, crName string, binding v1alpha1.AccountRoleBinding) error {
-	return _m.MockUpdatePermissionSet(permissionSetUuid, crName, binding)
+func (_m MockRepository) DeletePersona(ctx context.Context, uuid string) error {
+	return _m.MockDeletePersona(ctx, uuid)
 }
 
-func (_m MockRepository) DeletePermissionSet(uuid string) error {
-	return _m.MockDeletePermissionSet(uuid)
+func (_m MockRepository) CreatePermissionSet(ctx context.Context, uid, name string, bindings []v1alpha1.AccountRoleBinding) (string, error) {
+	return _m.MockCreatePermissionSet(ctx, uid, name, bindings)
 }
 
-func (_m MockRepository) CreateTeam(tp *v1alpha1.TeamParameters) (string, error) {
-	return _m.MockCreateTeam(tp)
+func (_m MockRepository) LookupPermissionSet(ctx context.Context, uid string) (string, error) {
+	return _m.MockLookupPermissionSet(ctx, uid)
 }
 
-func (_m MockRepository) GetTeam(uuid string) (*types.GetTeamResponse, error) {
-	return _m.MockGetTeam(uuid)
+func (_m MockRepository) GetPermissionSet(ctx context.Context, uuid string) (*types.GetPermissionSetResponse, error) {
+	return _m.MockGetPermissionSet(ctx, uuid)
 }
 
-func (_m MockRepository) UpdateTeam(uuid string, tp *v1alpha1.TeamParameters) error {
-	return _m.MockUpdateTeam(uuid, tp)
+func (_m MockRepository) UpdatePermissionSet(ctx context.Context, permissionSetUuid, crName string, bindings []v1alpha1.AccountRoleBinding) (types.Changes, error) {
+	return _m.MockUpdatePermissionSet(ctx, permissionSetUuid, crName, bindings)
 }
-func (_m MockRepository) DeleteTeam(uuid string) error {
-	return _m.MockDeleteTeam(uuid)
+
+func (_m MockRepository) DeletePermissionSet(ctx context.Context, uuid string) error {
+	return _m.MockDeletePermissionSet(ctx, uuid)
+}
+
+func (_m MockRepository) CreateTeam(ctx context.Context, uid string, tp *v1alpha1.TeamParameters) (string, error) {
+	return _m.MockCreateTeam(ctx, uid, tp)
+}
+
+func (_m MockRepository) LookupTeam(ctx context.Context, uid string) (string, error) {
+	return _m.MockLookupTeam(ctx, uid)
+}
+
+func (_m MockRepository) GetTeam(ctx context.Context, uuid string) (*types.GetTeamResponse, error) {
+	return _m.MockGetTeam(ctx, uuid)
+}
+
+func (_m MockRepository) UpdateTeam(ctx context.Context, uuid string, tp *v1alpha1.TeamParameters) (types.Changes, error) {
+	return _m.MockUpdateTeam(ctx, uuid, tp)
+}
+func (_m MockRepository) DeleteTeam(ctx context.Context, uuid string) error {
+	return _m.MockDeleteTeam(ctx, uuid)
+}
+
+func (_m MockRepository) CreateAccount(ctx context.Context, uid string, account *v1alpha1.AccountParameters) (string, error) {
+	return _m.MockCreateAccount(ctx, uid, account)
+}
+
+func (_m MockRepository) LookupAccount(ctx context.Context, uid string) (string, error) {
+	return _m.MockLookupAccount(ctx, uid)
+}
+
+func (_m MockRepository) GetAccount(ctx context.Context, cloud, id string) (*types.GetAccountResponse, error) {
+	return _m.MockGetAccount(ctx, cloud, id)
+}
+
+func (_m MockRepository) UpdateAccount(ctx context.Context, cloud, accountID string, account *v1alpha1.AccountParameters) error {
+	return _m.MockUpdateAccount(ctx, cloud, accountID, account)
+}
+
+func (_m MockRepository) DeleteAccount(ctx context.Context, cloud, id string) error {
+	return _m.MockDeleteAccount(ctx, cloud, id)
+}
+
+func (_m MockRepository) WhoCanAccess(ctx context.Context, cloud, accountID, roleName string) ([]types.Grant, error) {
+	return _m.MockWhoCanAccess(ctx, cloud, accountID, roleName)
+}
+
+func (_m MockRepository) CreateRole(ctx context.Context, uid string, role *v1alpha1.RoleParameters) (string, error) {
+	return _m.MockCreateRole(ctx, uid, role)
+}
+
+func (_m MockRepository) LookupRole(ctx context.Context, uid string) (string, error) {
+	return _m.MockLookupRole(ctx, uid)
+}
+
+func (_m MockRepository) GetRole(ctx context.Context, cloud, id string) (*types.GetRoleResponse, error) {
+	return _m.MockGetRole(ctx, cloud, id)
+}
+
+func (_m MockRepository) UpdateRole(ctx context.Context, cloud, roleName string, role *v1alpha1.RoleParameters) error {
+	return _m.MockUpdateRole(ctx, cloud, roleName, role)
+}
+
+func (_m MockRepository) DeleteRole(ctx context.Context, cloud, id string) error {
+	return _m.MockDeleteRole(ctx, cloud, id)
 }
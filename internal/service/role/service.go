@@ -0,0 +1,45 @@
+package role
+
+import (
+	"context"
+
+	"github.com/VariableExp0rt/powerbroker/apis/powerbroker/v1alpha1"
+	powerbroker "github.com/VariableExp0rt/powerbroker/internal/service"
+	"github.com/VariableExp0rt/powerbroker/internal/service/types"
+)
+
+type Service interface {
+	CreateRole(ctx context.Context, uid string, role *v1alpha1.RoleParameters) (string, error)
+	LookupRole(ctx context.Context, uid string) (string, error)
+	GetRole(ctx context.Context, cloud, name string) (*types.GetRoleResponse, error)
+	UpdateRole(ctx context.Context, cloud, name string, role *v1alpha1.RoleParameters) error
+	DeleteRole(ctx context.Context, cloud, name string) error
+}
+
+type service struct {
+	repository powerbroker.Repository
+}
+
+func NewService(repo powerbroker.Repository) Service {
+	return &service{repository: repo}
+}
+
+func (s *service) CreateRole(ctx context.Context, uid string, role *v1alpha1.RoleParameters) (string, error) {
+	return s.repository.CreateRole(ctx, uid, role)
+}
+
+func (s *service) LookupRole(ctx context.Context, uid string) (string, error) {
+	return s.repository.LookupRole(ctx, uid)
+}
+
+func (s *service) GetRole(ctx context.Context, cloud, name string) (*types.GetRoleResponse, error) {
+	return s.repository.GetRole(ctx, cloud, name)
+}
+
+func (s *service) UpdateRole(ctx context.Context, cloud, name string, role *v1alpha1.RoleParameters) error {
+	return s.repository.UpdateRole(ctx, cloud, name, role)
+}
+
+func (s *service) DeleteRole(ctx context.Context, cloud, name string) error {
+	return s.repository.DeleteRole(ctx, cloud, name)
+}
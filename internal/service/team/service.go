@@ -1,16 +1,19 @@
 package team
 
 import (
+	"context"
+
 	"github.com/VariableExp0rt/powerbroker/apis/powerbroker/v1alpha1"
 	powerbroker "github.com/VariableExp0rt/powerbroker/internal/service"
 	"github.com/VariableExp0rt/powerbroker/internal/service/types"
 )
 
 type Service interface {
-	CreateTeam(*v1alpha1.TeamParameters) (string, error)
-	GetTeam(teamname string) (*types.GetTeamResponse, error)
-	UpdateTeam(teamname string, teamparams *v1alpha1.TeamParameters) error
-	DeleteTeam(teamname string) error
+	CreateTeam(ctx context.Context, uid string, teamparams *v1alpha1.TeamParameters) (string, error)
+	LookupTeam(ctx context.Context, uid string) (string, error)
+	GetTeam(ctx context.Context, teamname string) (*types.GetTeamResponse, error)
+	UpdateTeam(ctx context.Context, teamname string, teamparams *v1alpha1.TeamParameters) (types.Changes, error)
+	DeleteTeam(ctx context.Context, teamname string) error
 }
 
 type service struct {
@@ -21,18 +24,22 @@ func NewService(repo powerbroker.Repository) Service {
 	return &service{repository: repo}
 }
 
-func (s *service) CreateTeam(params *v1alpha1.TeamParameters) (string, error) {
-	return s.repository.CreateTeam(params)
+func (s *service) CreateTeam(ctx context.Context, uid string, params *v1alpha1.TeamParameters) (string, error) {
+	return s.repository.CreateTeam(ctx, uid, params)
+}
+
+func (s *service) LookupTeam(ctx context.Context, uid string) (string, error) {
+	return s.repository.LookupTeam(ctx, uid)
 }
 
-func (s *service) GetTeam(name string) (*types.GetTeamResponse, error) {
-	return s.repository.GetTeam(name)
+func (s *service) GetTeam(ctx context.Context, name string) (*types.GetTeamResponse, error) {
+	return s.repository.GetTeam(ctx, name)
 }
 
-func (s *service) UpdateTeam(name string, params *v1alpha1.TeamParameters) error {
-	return s.repository.UpdateTeam(name, params)
+func (s *service) UpdateTeam(ctx context.Context, name string, params *v1alpha1.TeamParameters) (types.Changes, error) {
+	return s.repository.UpdateTeam(ctx, name, params)
 }
 
-func (s *service) DeleteTeam(name string) error {
-	return s.repository.DeleteTeam(name)
+func (s *service) DeleteTeam(ctx context.Context, name string) error {
+	return s.repository.DeleteTeam(ctx, name)
 }
@@ -0,0 +1,126 @@
+package types
+
+import (
+	"sort"
+	"strings"
+)
+
+// An Access is the target of a binding a user can use, and the path which
+// grants it: the permission set with the binding, the persona it is
+// attached to, and the team the user inherits the persona from, if it is not
+// granted to the user directly. Permission sets, personas and teams are
+// named as they are stored.
+type Access struct {
+	Target
+
+	PermissionSet string
+	Persona       string
+	Team          string
+}
+
+// Direct returns true if the access is through a persona granted to the
+// user rather than one of its teams.
+func (a Access) Direct() bool {
+	return a.Team == ""
+}
+
+// A Grant is access a user has to an account with a role, and the path which
+// grants it. Users are identified by their uuid and named as they are stored.
+type Grant struct {
+	Access
+
+	User     string
+	UserName string
+}
+
+// Hops returns the number of relationships on the path of the grant, from
+// the user to the account and role.
+func (g Grant) Hops() int {
+	if g.Direct() {
+		return 3
+	}
+
+	return 4
+}
+
+// Shortest returns a grant for each user in the supplied grants, sorted by
+// the names of the users: the grant with the shortest path, or the first of
+// those in the order of their permission sets, personas and teams.
+func Shortest(grants []Grant) []Grant {
+	shortest := map[string]Grant{}
+	for _, g := range grants {
+		s, ok := shortest[g.User]
+		if !ok || g.Hops() < s.Hops() || (g.Hops() == s.Hops() && pathKey(g) < pathKey(s)) {
+			shortest[g.User] = g
+		}
+	}
+
+	out := make([]Grant, 0, len(shortest))
+	for _, g := range shortest {
+		out = append(out, g)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].UserName != out[j].UserName {
+			return out[i].UserName < out[j].UserName
+		}
+		return out[i].User < out[j].User
+	})
+
+	return out
+}
+
+func pathKey(g Grant) string {
+	return strings.Join([]string{g.PermissionSet, g.Persona, g.Team, g.Scope}, "\x00")
+}
+
+// A Hop is a node on a path which grants access. It is identified by its
+// label, which is the kind of the managed resource which manages it, and the
+// uuid, or account id or role name, it is stored with. UID is that of the
+// managed resource which manages the node, if any, and Resource its name.
+type Hop struct {
+	Label    string
+	ID       string
+	Name     string
+	UID      string
+	Resource string
+}
+
+func (h Hop) String() string {
+	name := h.Name
+	if name == "" {
+		name = h.ID
+	}
+	if h.Resource == "" {
+		return h.Label + " " + name
+	}
+
+	return h.Label + " " + name + " (" + h.Resource + ")"
+}
+
+// A Path is a distinct path which grants a user access to a target. Its hops
+// run from the user, through its team if the access is inherited, to the
+// persona, permission set, account and role.
+type Path struct {
+	Target
+
+	Hops []Hop
+}
+
+// String renders the path as a chain of its hops, such as User → Team →
+// Persona → PermissionSet → Account/Role.
+func (p Path) String() string {
+	hops := make([]string, 0, len(p.Hops))
+	for _, h := range p.Hops {
+		hops = append(hops, h.String())
+	}
+
+	// The account and role are the two ends of a single binding.
+	if n := len(hops); n >= 2 {
+		hops = append(hops[:n-2], hops[n-2]+"/"+hops[n-1])
+	}
+	if p.Scope != "" && len(hops) > 0 {
+		hops[len(hops)-1] += " at " + p.Scope
+	}
+
+	return strings.Join(hops, " → ")
+}
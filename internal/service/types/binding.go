@@ -0,0 +1,139 @@
+package types
+
+import (
+	"sort"
+
+	"github.com/VariableExp0rt/powerbroker/apis/powerbroker/v1alpha1"
+)
+
+// A Target is where a binding delegates access, whatever its cloud: the
+// account, project, subscription or cluster it delegates access to, the
+// role it delegates access with, and the scope within the account it is
+// limited to, if any.
+type Target struct {
+	Cloud   string
+	Account string
+	Role    string
+	Scope   string
+}
+
+// TargetOf returns the target of a binding, from the fields of its cloud.
+// A binding without a cloud is an aws binding.
+func TargetOf(b v1alpha1.AccountRoleBinding) Target {
+	switch b.Cloud {
+	case v1alpha1.CloudGCP:
+		t := Target{Cloud: b.Cloud}
+		if b.GCP != nil {
+			t.Account, t.Role = b.GCP.Project, b.GCP.Role
+		}
+		return t
+	case v1alpha1.CloudAzure:
+		t := Target{Cloud: b.Cloud}
+		if b.Azure != nil {
+			t.Account, t.Role, t.Scope = b.Azure.Subscription, b.Azure.RoleDefinition, b.Azure.Scope
+		}
+		return t
+	case v1alpha1.CloudKubernetes:
+		t := Target{Cloud: b.Cloud}
+		if b.Kubernetes != nil {
+			t.Account, t.Role = b.Kubernetes.Cluster, b.Kubernetes.ClusterRole
+		}
+		return t
+	default:
+		return Target{Cloud: v1alpha1.CloudAWS, Account: b.Account, Role: b.RoleName}
+	}
+}
+
+// Binding returns the binding to the target which describes its account
+// with the supplied alias and class. It is the inverse of TargetOf.
+func (t Target) Binding(alias, class string) v1alpha1.AccountRoleBinding {
+	b := v1alpha1.AccountRoleBinding{Cloud: t.Cloud, Alias: alias, AccountClass: class}
+	switch t.Cloud {
+	case v1alpha1.CloudGCP:
+		b.GCP = &v1alpha1.GCPBinding{Project: t.Account, Role: t.Role}
+	case v1alpha1.CloudAzure:
+		b.Azure = &v1alpha1.AzureBinding{Subscription: t.Account, RoleDefinition: t.Role, Scope: t.Scope}
+	case v1alpha1.CloudKubernetes:
+		b.Kubernetes = &v1alpha1.KubernetesBinding{Cluster: t.Account, ClusterRole: t.Role}
+	default:
+		b.Cloud, b.Account, b.RoleName = v1alpha1.CloudAWS, t.Account, t.Role
+	}
+
+	return b
+}
+
+// DefaultCloud returns cloud, or aws if it is empty. A binding, Account or
+// Role without a cloud is in aws.
+func DefaultCloud(cloud string) string {
+	if cloud == "" {
+		return v1alpha1.CloudAWS
+	}
+
+	return cloud
+}
+
+// BindingKey identifies a binding of a permission set by its cloud, account,
+// role and scope. Each binding is stored as a pair of delegations to its
+// account and role which carry its key.
+func BindingKey(b v1alpha1.AccountRoleBinding) string {
+	t := TargetOf(b)
+	if t.Scope != "" {
+		return t.Cloud + ":" + t.Account + "/" + t.Role + "@" + t.Scope
+	}
+
+	return t.Cloud + ":" + t.Account + "/" + t.Role
+}
+
+// UniqueBindings returns the bindings in the order of their keys, without
+// those which bind the same account and role in the same cloud as another.
+// The first of those is kept.
+func UniqueBindings(bindings []v1alpha1.AccountRoleBinding) []v1alpha1.AccountRoleBinding {
+	seen := make(map[string]bool, len(bindings))
+	out := make([]v1alpha1.AccountRoleBinding, 0, len(bindings))
+	for _, b := range bindings {
+		if k := BindingKey(b); !seen[k] {
+			seen[k] = true
+			out = append(out, b)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return BindingKey(out[i]) < BindingKey(out[j]) })
+
+	return out
+}
+
+// DiffBindings returns the changes which delegate access through the
+// desired bindings rather than the current ones. A binding is added or
+// removed with both of its delegations.
+func DiffBindings(current, desired []v1alpha1.AccountRoleBinding) Changes {
+	keys := func(bindings []v1alpha1.AccountRoleBinding) []string {
+		out := make([]string, 0, len(bindings))
+		for _, b := range bindings {
+			out = append(out, BindingKey(b))
+		}
+		return out
+	}
+	byKey := map[string]v1alpha1.AccountRoleBinding{}
+	for _, b := range append(append([]v1alpha1.AccountRoleBinding{}, current...), desired...) {
+		byKey[BindingKey(b)] = b
+	}
+
+	c := Changes{}
+	d := Diff("", keys(current), keys(desired))
+	for _, r := range d.Added {
+		c.Added = append(c.Added, delegations(byKey[r.Node])...)
+	}
+	for _, r := range d.Removed {
+		c.Removed = append(c.Removed, delegations(byKey[r.Node])...)
+	}
+
+	return c
+}
+
+// delegations returns the pair of relationships a binding is stored as.
+func delegations(b v1alpha1.AccountRoleBinding) []Relationship {
+	t := TargetOf(b)
+	return []Relationship{
+		{Type: RelationDelegatesAccessTo, Node: t.Account},
+		{Type: RelationDelegatesAccessWith, Node: t.Role},
+	}
+}
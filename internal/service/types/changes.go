@@ -0,0 +1,99 @@
+package types
+
+import "strings"
+
+// The types of relationship an update can add to or remove from an entity.
+// They are named after the relationships of the neo4j store, whichever
+// store the entity is kept in.
+const (
+	RelationGranted             = "GRANTED"
+	RelationMemberOf            = "MEMBER_OF"
+	RelationManagedBy           = "MANAGED_BY"
+	RelationInherits            = "INHERITS"
+	RelationAttachedTo          = "ATTACHED_TO"
+	RelationDelegatesAccessTo   = "DELEGATES_ACCESS_TO"
+	RelationDelegatesAccessWith = "DELEGATES_ACCESS_WITH"
+)
+
+// A Relationship of an entity, identified by its type and the id of the
+// node at its other end: a uuid, an account id or a role name.
+type Relationship struct {
+	Type string
+	Node string
+}
+
+func (r Relationship) String() string {
+	return r.Type + " " + r.Node
+}
+
+// Changes are the relationships an update added to and removed from an
+// entity. Relationships the entity already had are in neither.
+type Changes struct {
+	Added   []Relationship
+	Removed []Relationship
+}
+
+// Diff returns the changes which relate an entity to the desired nodes
+// through rel, rather than to the current ones.
+func Diff(rel string, current, desired []string) Changes {
+	have := make(map[string]bool, len(current))
+	for _, c := range current {
+		have[c] = true
+	}
+
+	c := Changes{}
+	want := make(map[string]bool, len(desired))
+	for _, d := range desired {
+		if !have[d] && !want[d] {
+			c.Added = append(c.Added, Relationship{Type: rel, Node: d})
+		}
+		want[d] = true
+	}
+
+	for _, n := range current {
+		if !want[n] {
+			c.Removed = append(c.Removed, Relationship{Type: rel, Node: n})
+		}
+	}
+
+	return c
+}
+
+// Merge returns the changes of both c and o.
+func (c Changes) Merge(o Changes) Changes {
+	return Changes{
+		Added:   append(append([]Relationship{}, c.Added...), o.Added...),
+		Removed: append(append([]Relationship{}, c.Removed...), o.Removed...),
+	}
+}
+
+// Empty returns true if nothing was added or removed.
+func (c Changes) Empty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0
+}
+
+// String describes the changes, e.g. "added GRANTED 1234; removed GRANTED
+// 5678".
+func (c Changes) String() string {
+	parts := make([]string, 0, 2)
+	if len(c.Added) > 0 {
+		parts = append(parts, "added "+join(c.Added))
+	}
+	if len(c.Removed) > 0 {
+		parts = append(parts, "removed "+join(c.Removed))
+	}
+	if len(parts) == 0 {
+		return "no relationships changed"
+	}
+
+	return strings.Join(parts, "; ")
+}
+
+func join(rs []Relationship) string {
+	s := make([]string, 0, len(rs))
+	for _, r := range rs {
+		s = append(s, r.String())
+	}
+
+	return strings.Join(s, ", ")
+}
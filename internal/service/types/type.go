@@ -9,30 +9,56 @@ type Neo4jCredentialObject struct {
 	DatabaseURI      string `yaml:"databaseUri"`
 	DatabaseUsername string `yaml:"databaseUsername"`
 	DatabasePassword string `yaml:"databasePassword"`
+	Token            string `yaml:"token"`
+	KerberosTicket   string `yaml:"kerberosTicket"`
 }
 
+type SpiceDBCredentialObject struct {
+	Endpoint string `yaml:"endpoint"`
+	Token    string `yaml:"token"`
+}
+
+// The Get*Response types hold the state of an entity as stored, so that it
+// can be compared with every parameter of its managed resource.
+
 type GetUserResponse struct {
+	Name       string
 	References []string
 	Status     types.Status
 	NodeID     string
 }
 
 type GetPersonaResponse struct {
+	Name       string
 	References []string
 	Status     types.Status
 	NodeID     string
 }
 
 type GetPermissionSetResponse struct {
-	Binding v1alpha1.AccountRoleBinding
-	Status  types.Status
-	NodeID  string
+	Name     string
+	Bindings []v1alpha1.AccountRoleBinding
+	Status   types.Status
+	NodeID   string
 }
 
 type GetTeamResponse struct {
+	Name      string
 	ManagedBy string
 	Members   []string
 	Personas  []string
 	NodeID    string
 	Status    types.Status
 }
+
+type GetAccountResponse struct {
+	Account v1alpha1.AccountParameters
+	Status  types.Status
+	NodeID  string
+}
+
+type GetRoleResponse struct {
+	Role   v1alpha1.RoleParameters
+	Status types.Status
+	NodeID string
+}
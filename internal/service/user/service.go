@@ -1,15 +1,20 @@
 package user
 
 import (
+	"context"
+
 	powerbroker "github.com/VariableExp0rt/powerbroker/internal/service"
 	"github.com/VariableExp0rt/powerbroker/internal/service/types"
 )
 
 type Service interface {
-	CreateUser(username string, personaReferences []string) (string, error)
-	GetUser(username string) (*types.GetUserResponse, error)
-	UpdateUser(username, userUuid string, personaReferences []string) error
-	DeleteUser(username string) error
+	CreateUser(ctx context.Context, uid, username string, personaReferences []string) (string, error)
+	LookupUser(ctx context.Context, uid string) (string, error)
+	LookupUserByName(ctx context.Context, name string) (string, error)
+	GetUser(ctx context.Context, username string) (*types.GetUserResponse, error)
+	UpdateUser(ctx context.Context, username, userUuid string, personaReferences []string) (types.Changes, error)
+	DeleteUser(ctx context.Context, username string) error
+	GetEffectiveAccess(ctx context.Context, userUuid string) ([]types.Access, error)
 }
 
 type service struct {
@@ -20,16 +25,27 @@ func NewService(repo powerbroker.Repository) Service {
 	return &service{repository: repo}
 }
 
-func (s *service) CreateUser(username string, personaReferences []string) (string, error) {
-	return s.repository.CreateUser(username, personaReferences)
+func (s *service) CreateUser(ctx context.Context, uid, username string, personaReferences []string) (string, error) {
+	return s.repository.CreateUser(ctx, uid, username, personaReferences)
+}
+
+func (s *service) LookupUser(ctx context.Context, uid string) (string, error) {
+	return s.repository.LookupUser(ctx, uid)
 }
 
-func (s *service) GetUser(name string) (*types.GetUserResponse, error) {
-	return s.repository.GetUser(name)
+func (s *service) LookupUserByName(ctx context.Context, name string) (string, error) {
+	return s.repository.LookupUserByName(ctx, name)
+}
+
+func (s *service) GetUser(ctx context.Context, name string) (*types.GetUserResponse, error) {
+	return s.repository.GetUser(ctx, name)
+}
+func (s *service) UpdateUser(ctx context.Context, name, uuid string, references []string) (types.Changes, error) {
+	return s.repository.UpdateUser(ctx, name, uuid, references)
 }
-func (s *service) UpdateUser(name, uuid string, references []string) error {
-	return s.repository.UpdateUser(name, uuid, references)
+func (s *service) DeleteUser(ctx context.Context, name string) error {
+	return s.repository.DeleteUser(ctx, name)
 }
-func (s *service) DeleteUser(name string) error {
-	return s.repository.DeleteUser(name)
+func (s *service) GetEffectiveAccess(ctx context.Context, uuid string) ([]types.Access, error) {
+	return s.repository.GetEffectiveAccess(ctx, uuid)
 }
@@ -0,0 +1,1464 @@
+/*
+Package conformance is a test suite shared by every storage backend. A
+backend passes it by calling Run from its own tests with a function which
+returns a Repository backed by an empty, or at least isolated, store.
+
+The suite describes the behaviour the controllers rely on:
+
+  - Get of an entity that exists returns StatusAvailable and its references,
+    which may be empty.
+  - Get of an entity that does not exist returns StatusDeleted and an
+    EntityNotFoundError. Update of such an entity returns an
+    EntityNotFoundError, whereas Delete succeeds.
+  - References to entities that do not exist are ignored, and references
+    to an entity are removed when it is deleted.
+  - A user which manages a team is never also a member of it.
+  - Update returns the relationships it added and removed, and leaves every
+    other relationship, including those of other entities, as it was.
+  - Repeating a create reference, update or delete has no further effect.
+  - Repeating a create with the same UID returns the entity created first,
+    which Lookup finds by that UID until it is deleted.
+  - LookupByName finds the only entity with a name, and returns a
+    ConflictError if several share it.
+  - An account is identified by its cloud and id. Creating one takes over an
+    account permission sets already delegate access to, unless another UID
+    manages it, and a binding never changes the alias or class of a managed
+    one. Get only finds an account an Account manages, and deleting one a
+    permission set still binds only releases it, keeping the binding.
+  - A permission set binds each account and role at most once, and a
+    binding is added or removed with both of its delegations.
+  - A binding keeps its cloud, and the fields of its cloud, and a binding
+    without a cloud is an aws binding.
+  - A role is identified by its cloud and name in the same way, and keeps
+    policy documents of any length, and is found and released like an
+    account. An account or role with the same id or name in another cloud
+    is another account or role.
+  - The effective access of a user has an entry for each binding of each
+    persona granted to it or inherited by one of its teams, and for each
+    path to the binding. WhoCanAccess returns the same paths from the users
+    at their end, and ExplainAccess, if the backend can explain access,
+    returns each of them from a user with every hop along the way.
+
+The order of returned references is not part of the contract.
+*/
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/google/uuid"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/VariableExp0rt/powerbroker/apis/powerbroker/v1alpha1"
+	"github.com/VariableExp0rt/powerbroker/internal/service"
+	"github.com/VariableExp0rt/powerbroker/internal/service/types"
+	storetypes "github.com/VariableExp0rt/powerbroker/internal/storage/types"
+)
+
+// missing is a uuid which no backend will ever generate.
+const missing = "00000000-0000-0000-0000-000000000000"
+
+// A Factory returns a Repository to run a single test against.
+type Factory func(t *testing.T) service.Repository
+
+var opts = []cmp.Option{
+	cmpopts.SortSlices(func(a, b string) bool { return a < b }),
+	cmpopts.SortSlices(func(a, b types.Relationship) bool { return a.String() < b.String() }),
+	cmpopts.EquateEmpty(),
+}
+
+// Run runs the conformance suite against the Repositories built by newRepo.
+func Run(t *testing.T, newRepo Factory) {
+	t.Helper()
+
+	tests := map[string]func(*testing.T, service.Repository){
+		"User":               testUser,
+		"Persona":            testPersona,
+		"PermissionSet":      testPermissionSet,
+		"Account":            testAccount,
+		"Role":               testRole,
+		"Clouds":             testClouds,
+		"CloudKeys":          testCloudKeys,
+		"Team":               testTeam,
+		"EffectiveAccess":    testEffectiveAccess,
+		"WhoCanAccess":       testWhoCanAccess,
+		"ExplainAccess":      testExplainAccess,
+		"NotFound":           testNotFound,
+		"ReferenceIntegrity": testReferenceIntegrity,
+		"Idempotency":        testIdempotency,
+		"Adoption":           testAdoption,
+		"LookupByName":       testLookupByName,
+		"UpdateScope":        testUpdateScope,
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			test(t, newRepo(t))
+		})
+	}
+}
+
+func testUser(t *testing.T, repo service.Repository) {
+	ctx := context.Background()
+
+	p1 := createPersona(t, repo, "readonly")
+	p2 := createPersona(t, repo, "admin")
+
+	id, err := repo.CreateUser(ctx, uid(), "mario", []string{p1})
+	if err != nil {
+		t.Fatalf("CreateUser(...): %v", err)
+	}
+
+	want := &types.GetUserResponse{Name: "mario", NodeID: id, Status: storetypes.StatusAvailable, References: []string{p1}}
+	if diff := cmp.Diff(want, getUser(t, repo, id), opts...); diff != "" {
+		t.Errorf("GetUser(...): -want, +got:\n%s", diff)
+	}
+
+	changes, err := repo.UpdateUser(ctx, "mario", id, []string{p2})
+	if err != nil {
+		t.Fatalf("UpdateUser(...): %v", err)
+	}
+	wantChanges := types.Changes{
+		Added:   []types.Relationship{{Type: types.RelationGranted, Node: p2}},
+		Removed: []types.Relationship{{Type: types.RelationGranted, Node: p1}},
+	}
+	if diff := cmp.Diff(wantChanges, changes, opts...); diff != "" {
+		t.Errorf("UpdateUser(...): -want, +got:\n%s", diff)
+	}
+	want.References = []string{p2}
+	if diff := cmp.Diff(want, getUser(t, repo, id), opts...); diff != "" {
+		t.Errorf("GetUser(...): -want, +got:\n%s", diff)
+	}
+
+	if _, err := repo.UpdateUser(ctx, "luigi", id, nil); err != nil {
+		t.Fatalf("UpdateUser(...): %v", err)
+	}
+	want.Name = "luigi"
+	want.References = nil
+	if diff := cmp.Diff(want, getUser(t, repo, id), opts...); diff != "" {
+		t.Errorf("GetUser(...): -want, +got:\n%s", diff)
+	}
+
+	if err := repo.DeleteUser(ctx, id); err != nil {
+		t.Fatalf("DeleteUser(...): %v", err)
+	}
+	if _, err := repo.GetUser(ctx, id); !storetypes.IsEntityNotFoundNeo4jErr(err) {
+		t.Errorf("GetUser(...): want EntityNotFoundError, got %v", err)
+	}
+}
+
+func testPersona(t *testing.T, repo service.Repository) {
+	ctx := context.Background()
+
+	ps1 := createPermissionSet(t, repo, "readonly")
+	ps2 := createPermissionSet(t, repo, "readonly-too")
+
+	id, err := repo.CreatePersona(ctx, uid(), "auditor", []string{ps1})
+	if err != nil {
+		t.Fatalf("CreatePersona(...): %v", err)
+	}
+
+	want := &types.GetPersonaResponse{Name: "auditor", NodeID: id, Status: storetypes.StatusAvailable, References: []string{ps1}}
+	if diff := cmp.Diff(want, getPersona(t, repo, id), opts...); diff != "" {
+		t.Errorf("GetPersona(...): -want, +got:\n%s", diff)
+	}
+
+	changes, err := repo.UpdatePersona(ctx, "reviewer", id, []string{ps1, ps2})
+	if err != nil {
+		t.Fatalf("UpdatePersona(...): %v", err)
+	}
+	wantChanges := types.Changes{Added: []types.Relationship{{Type: types.RelationAttachedTo, Node: ps2}}}
+	if diff := cmp.Diff(wantChanges, changes, opts...); diff != "" {
+		t.Errorf("UpdatePersona(...): -want, +got:\n%s", diff)
+	}
+	want.Name = "reviewer"
+	want.References = []string{ps1, ps2}
+	if diff := cmp.Diff(want, getPersona(t, repo, id), opts...); diff != "" {
+		t.Errorf("GetPersona(...): -want, +got:\n%s", diff)
+	}
+
+	if err := repo.DeletePersona(ctx, id); err != nil {
+		t.Fatalf("DeletePersona(...): %v", err)
+	}
+	if _, err := repo.GetPersona(ctx, id); !storetypes.IsEntityNotFoundNeo4jErr(err) {
+		t.Errorf("GetPersona(...): want EntityNotFoundError, got %v", err)
+	}
+}
+
+func testPermissionSet(t *testing.T, repo service.Repository) {
+	ctx := context.Background()
+
+	admin := v1alpha1.AccountRoleBinding{
+		Cloud:        v1alpha1.CloudAWS,
+		Account:      "123456789012",
+		Alias:        "production",
+		AccountClass: "aws:prod",
+		RoleName:     "Administrator",
+	}
+	readonly := admin
+	readonly.RoleName = "ReadOnly"
+
+	// A binding which is repeated is stored once.
+	id, err := repo.CreatePermissionSet(ctx, uid(), "admin", []v1alpha1.AccountRoleBinding{readonly, admin, readonly})
+	if err != nil {
+		t.Fatalf("CreatePermissionSet(...): %v", err)
+	}
+
+	want := &types.GetPermissionSetResponse{Name: "admin", NodeID: id, Status: storetypes.StatusAvailable, Bindings: []v1alpha1.AccountRoleBinding{admin, readonly}}
+	if diff := cmp.Diff(want, getPermissionSet(t, repo, id), opts...); diff != "" {
+		t.Errorf("GetPermissionSet(...): -want, +got:\n%s", diff)
+	}
+
+	staging := v1alpha1.AccountRoleBinding{
+		Cloud:        v1alpha1.CloudAWS,
+		Account:      "210987654321",
+		Alias:        "staging",
+		AccountClass: "aws:nonprod",
+		RoleName:     "ReadOnly",
+	}
+	changes, err := repo.UpdatePermissionSet(ctx, id, "admin", []v1alpha1.AccountRoleBinding{staging, admin})
+	if err != nil {
+		t.Fatalf("UpdatePermissionSet(...): %v", err)
+	}
+	wantChanges := types.Changes{
+		Added: []types.Relationship{
+			{Type: types.RelationDelegatesAccessTo, Node: staging.Account},
+			{Type: types.RelationDelegatesAccessWith, Node: staging.RoleName},
+		},
+		Removed: []types.Relationship{
+			{Type: types.RelationDelegatesAccessTo, Node: readonly.Account},
+			{Type: types.RelationDelegatesAccessWith, Node: readonly.RoleName},
+		},
+	}
+	if diff := cmp.Diff(wantChanges, changes, opts...); diff != "" {
+		t.Errorf("UpdatePermissionSet(...): -want, +got:\n%s", diff)
+	}
+	want.Bindings = []v1alpha1.AccountRoleBinding{admin, staging}
+	if diff := cmp.Diff(want, getPermissionSet(t, repo, id), opts...); diff != "" {
+		t.Errorf("GetPermissionSet(...): -want, +got:\n%s", diff)
+	}
+
+	changes, err = repo.UpdatePermissionSet(ctx, id, "admin", []v1alpha1.AccountRoleBinding{admin})
+	if err != nil {
+		t.Fatalf("UpdatePermissionSet(...): %v", err)
+	}
+	wantChanges = types.Changes{
+		Removed: []types.Relationship{
+			{Type: types.RelationDelegatesAccessTo, Node: staging.Account},
+			{Type: types.RelationDelegatesAccessWith, Node: staging.RoleName},
+		},
+	}
+	if diff := cmp.Diff(wantChanges, changes, opts...); diff != "" {
+		t.Errorf("UpdatePermissionSet(...): -want, +got:\n%s", diff)
+	}
+	want.Bindings = []v1alpha1.AccountRoleBinding{admin}
+	if diff := cmp.Diff(want, getPermissionSet(t, repo, id), opts...); diff != "" {
+		t.Errorf("GetPermissionSet(...): -want, +got:\n%s", diff)
+	}
+
+	if err := repo.DeletePermissionSet(ctx, id); err != nil {
+		t.Fatalf("DeletePermissionSet(...): %v", err)
+	}
+	if _, err := repo.GetPermissionSet(ctx, id); !storetypes.IsEntityNotFoundNeo4jErr(err) {
+		t.Errorf("GetPermissionSet(...): want EntityNotFoundError, got %v", err)
+	}
+}
+
+func testAccount(t *testing.T, repo service.Repository) {
+	ctx := context.Background()
+
+	binding := v1alpha1.AccountRoleBinding{
+		Cloud:        v1alpha1.CloudAWS,
+		Account:      "123456789012",
+		Alias:        "legacy",
+		AccountClass: "aws:legacy",
+		RoleName:     "Administrator",
+	}
+	ps, err := repo.CreatePermissionSet(ctx, uid(), "admin", []v1alpha1.AccountRoleBinding{binding})
+	if err != nil {
+		t.Fatalf("CreatePermissionSet(...): %v", err)
+	}
+
+	params := &v1alpha1.AccountParameters{
+		Cloud:       v1alpha1.CloudAWS,
+		ID:          "123456789012",
+		Alias:       "production",
+		Class:       "aws:prod",
+		Owner:       "platform",
+		Environment: "production",
+		Lifecycle:   v1alpha1.AccountActive,
+	}
+	u := uid()
+	id, err := repo.CreateAccount(ctx, u, params)
+	if err != nil {
+		t.Fatalf("CreateAccount(...): %v", err)
+	}
+	if id != params.ID {
+		t.Errorf("CreateAccount(...): want id %q, got %q", params.ID, id)
+	}
+	if _, err := repo.CreateAccount(ctx, uid(), params); !storetypes.IsConflictError(err) {
+		t.Errorf("CreateAccount(...): want ConflictError for an account managed by another UID, got %v", err)
+	}
+
+	want := &types.GetAccountResponse{Account: *params, NodeID: id, Status: storetypes.StatusAvailable}
+	if diff := cmp.Diff(want, getAccount(t, repo, v1alpha1.CloudAWS, id), opts...); diff != "" {
+		t.Errorf("GetAccount(...): -want, +got:\n%s", diff)
+	}
+
+	// A managed account keeps its own alias and class, which are no longer
+	// those of the bindings to it.
+	binding.Alias, binding.AccountClass = "", ""
+	if diff := cmp.Diff([]v1alpha1.AccountRoleBinding{binding}, getPermissionSet(t, repo, ps).Bindings, opts...); diff != "" {
+		t.Errorf("GetPermissionSet(...): -want bindings, +got:\n%s", diff)
+	}
+	if _, err := repo.UpdatePermissionSet(ctx, ps, "admin", []v1alpha1.AccountRoleBinding{{Account: id, Alias: "legacy", AccountClass: "aws:legacy", RoleName: "Administrator"}}); err != nil {
+		t.Fatalf("UpdatePermissionSet(...): %v", err)
+	}
+	if diff := cmp.Diff(want, getAccount(t, repo, v1alpha1.CloudAWS, id), opts...); diff != "" {
+		t.Errorf("GetAccount(...): a binding should not change a managed account: -want, +got:\n%s", diff)
+	}
+
+	params.Lifecycle = v1alpha1.AccountSuspended
+	params.Owner = ""
+	if err := repo.UpdateAccount(ctx, v1alpha1.CloudAWS, id, params); err != nil {
+		t.Fatalf("UpdateAccount(...): %v", err)
+	}
+	want.Account = *params
+	if diff := cmp.Diff(want, getAccount(t, repo, v1alpha1.CloudAWS, id), opts...); diff != "" {
+		t.Errorf("GetAccount(...): -want, +got:\n%s", diff)
+	}
+	if got, err := repo.LookupAccount(ctx, u); err != nil || got != id {
+		t.Errorf("LookupAccount(...): want %q, got %q and %v", id, got, err)
+	}
+
+	// The same id in another cloud is another account, which no Account
+	// manages.
+	if err := repo.UpdateAccount(ctx, v1alpha1.CloudGCP, id, &v1alpha1.AccountParameters{Cloud: v1alpha1.CloudGCP, ID: id}); !storetypes.IsEntityNotFoundNeo4jErr(err) {
+		t.Errorf("UpdateAccount(...): want EntityNotFoundError for an account in another cloud, got %v", err)
+	}
+	if diff := cmp.Diff(want, getAccount(t, repo, v1alpha1.CloudAWS, id), opts...); diff != "" {
+		t.Errorf("GetAccount(...): updating an account in another cloud should not change it: -want, +got:\n%s", diff)
+	}
+
+	if err := repo.DeleteAccount(ctx, v1alpha1.CloudAWS, id); err != nil {
+		t.Fatalf("DeleteAccount(...): %v", err)
+	}
+	resp, err := repo.GetAccount(ctx, v1alpha1.CloudAWS, id)
+	if !storetypes.IsEntityNotFoundNeo4jErr(err) {
+		t.Errorf("GetAccount(...): want EntityNotFoundError, got %v", err)
+	}
+	if resp.Status != storetypes.StatusDeleted {
+		t.Errorf("GetAccount(...): want status %q, got %q", storetypes.StatusDeleted, resp.Status)
+	}
+	if _, err := repo.LookupAccount(ctx, u); !storetypes.IsEntityNotFoundNeo4jErr(err) {
+		t.Errorf("LookupAccount(...): want EntityNotFoundError once the account is deleted, got %v", err)
+	}
+	if err := repo.UpdateAccount(ctx, v1alpha1.CloudAWS, id, params); !storetypes.IsEntityNotFoundNeo4jErr(err) {
+		t.Errorf("UpdateAccount(...): want EntityNotFoundError, got %v", err)
+	}
+	if err := repo.DeleteAccount(ctx, v1alpha1.CloudAWS, id); err != nil {
+		t.Errorf("DeleteAccount(...): deleting a deleted account: %v", err)
+	}
+
+	// The permission set still binds the account, so deleting the Account
+	// only released it: the binding is kept, and another Account may manage
+	// the account.
+	if diff := cmp.Diff([]v1alpha1.AccountRoleBinding{binding}, getPermissionSet(t, repo, ps).Bindings, opts...); diff != "" {
+		t.Errorf("GetPermissionSet(...): want the binding to a released account: -want, +got:\n%s", diff)
+	}
+	u = uid()
+	if _, err := repo.CreateAccount(ctx, u, params); err != nil {
+		t.Fatalf("CreateAccount(...): managing a released account: %v", err)
+	}
+	if diff := cmp.Diff(want, getAccount(t, repo, v1alpha1.CloudAWS, id), opts...); diff != "" {
+		t.Errorf("GetAccount(...): -want, +got:\n%s", diff)
+	}
+
+	// Once nothing binds the account, deleting its Account deletes it.
+	if err := repo.DeletePermissionSet(ctx, ps); err != nil {
+		t.Fatalf("DeletePermissionSet(...): %v", err)
+	}
+	if err := repo.DeleteAccount(ctx, v1alpha1.CloudAWS, id); err != nil {
+		t.Fatalf("DeleteAccount(...): %v", err)
+	}
+	if _, err := repo.GetAccount(ctx, v1alpha1.CloudAWS, id); !storetypes.IsEntityNotFoundNeo4jErr(err) {
+		t.Errorf("GetAccount(...): want EntityNotFoundError, got %v", err)
+	}
+	ps, err = repo.CreatePermissionSet(ctx, uid(), "admin", []v1alpha1.AccountRoleBinding{binding})
+	if err != nil {
+		t.Fatalf("CreatePermissionSet(...): %v", err)
+	}
+	if _, err := repo.GetAccount(ctx, v1alpha1.CloudAWS, id); !storetypes.IsEntityNotFoundNeo4jErr(err) {
+		t.Errorf("GetAccount(...): want EntityNotFoundError for an account no Account manages, got %v", err)
+	}
+	if err := repo.DeletePermissionSet(ctx, ps); err != nil {
+		t.Fatalf("DeletePermissionSet(...): %v", err)
+	}
+}
+
+func testRole(t *testing.T, repo service.Repository) {
+	ctx := context.Background()
+
+	binding := v1alpha1.AccountRoleBinding{Cloud: v1alpha1.CloudAWS, Account: "123456789012", RoleName: "ReadOnlyAccess"}
+	ps, err := repo.CreatePermissionSet(ctx, uid(), "readers", []v1alpha1.AccountRoleBinding{binding})
+	if err != nil {
+		t.Fatalf("CreatePermissionSet(...): %v", err)
+	}
+
+	// A policy document may well be longer than the ids of some backends.
+	actions := make([]string, 200)
+	for i := range actions {
+		actions[i] = fmt.Sprintf("%q", fmt.Sprintf("service%d:Get*", i))
+	}
+	params := &v1alpha1.RoleParameters{
+		Cloud:              v1alpha1.CloudAWS,
+		Name:               "ReadOnlyAccess",
+		Description:        "Read only access to every service",
+		Tier:               v1alpha1.RoleTierModerate,
+		PolicyDocument:     `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":[` + strings.Join(actions, ",") + `],"Resource":"*"}]}`,
+		MaxSessionDuration: &metav1.Duration{Duration: time.Hour},
+	}
+	u := uid()
+	name, err := repo.CreateRole(ctx, u, params)
+	if err != nil {
+		t.Fatalf("CreateRole(...): %v", err)
+	}
+	if name != params.Name {
+		t.Errorf("CreateRole(...): want name %q, got %q", params.Name, name)
+	}
+	if _, err := repo.CreateRole(ctx, uid(), params); !storetypes.IsConflictError(err) {
+		t.Errorf("CreateRole(...): want ConflictError for a role managed by another UID, got %v", err)
+	}
+
+	want := &types.GetRoleResponse{Role: *params, NodeID: name, Status: storetypes.StatusAvailable}
+	if diff := cmp.Diff(want, getRole(t, repo, v1alpha1.CloudAWS, name), opts...); diff != "" {
+		t.Errorf("GetRole(...): -want, +got:\n%s", diff)
+	}
+	if diff := cmp.Diff([]v1alpha1.AccountRoleBinding{binding}, getPermissionSet(t, repo, ps).Bindings, opts...); diff != "" {
+		t.Errorf("GetPermissionSet(...): -want bindings, +got:\n%s", diff)
+	}
+
+	params.Tier = v1alpha1.RoleTierHigh
+	params.Description = ""
+	params.MaxSessionDuration = nil
+	if err := repo.UpdateRole(ctx, v1alpha1.CloudAWS, name, params); err != nil {
+		t.Fatalf("UpdateRole(...): %v", err)
+	}
+	want.Role = *params
+	if diff := cmp.Diff(want, getRole(t, repo, v1alpha1.CloudAWS, name), opts...); diff != "" {
+		t.Errorf("GetRole(...): -want, +got:\n%s", diff)
+	}
+	if got, err := repo.LookupRole(ctx, u); err != nil || got != name {
+		t.Errorf("LookupRole(...): want %q, got %q and %v", name, got, err)
+	}
+
+	// The same name in another cloud is another role, which no Role manages.
+	if err := repo.UpdateRole(ctx, v1alpha1.CloudGCP, name, &v1alpha1.RoleParameters{Cloud: v1alpha1.CloudGCP, Name: name}); !storetypes.IsEntityNotFoundNeo4jErr(err) {
+		t.Errorf("UpdateRole(...): want EntityNotFoundError for a role in another cloud, got %v", err)
+	}
+	if diff := cmp.Diff(want, getRole(t, repo, v1alpha1.CloudAWS, name), opts...); diff != "" {
+		t.Errorf("GetRole(...): updating a role in another cloud should not change it: -want, +got:\n%s", diff)
+	}
+
+	if err := repo.DeleteRole(ctx, v1alpha1.CloudAWS, name); err != nil {
+		t.Fatalf("DeleteRole(...): %v", err)
+	}
+	resp, err := repo.GetRole(ctx, v1alpha1.CloudAWS, name)
+	if !storetypes.IsEntityNotFoundNeo4jErr(err) {
+		t.Errorf("GetRole(...): want EntityNotFoundError, got %v", err)
+	}
+	if resp.Status != storetypes.StatusDeleted {
+		t.Errorf("GetRole(...): want status %q, got %q", storetypes.StatusDeleted, resp.Status)
+	}
+	if err := repo.UpdateRole(ctx, v1alpha1.CloudAWS, name, params); !storetypes.IsEntityNotFoundNeo4jErr(err) {
+		t.Errorf("UpdateRole(...): want EntityNotFoundError, got %v", err)
+	}
+	if err := repo.DeleteRole(ctx, v1alpha1.CloudAWS, name); err != nil {
+		t.Errorf("DeleteRole(...): deleting a deleted role: %v", err)
+	}
+	if _, err := repo.LookupRole(ctx, u); !storetypes.IsEntityNotFoundNeo4jErr(err) {
+		t.Errorf("LookupRole(...): want EntityNotFoundError once the role is deleted, got %v", err)
+	}
+
+	// The permission set still binds the role, so deleting the Role only
+	// released it: the binding is kept, and another Role may manage the
+	// role.
+	if diff := cmp.Diff([]v1alpha1.AccountRoleBinding{binding}, getPermissionSet(t, repo, ps).Bindings, opts...); diff != "" {
+		t.Errorf("GetPermissionSet(...): want the binding with a released role: -want, +got:\n%s", diff)
+	}
+	if _, err := repo.CreateRole(ctx, uid(), params); err != nil {
+		t.Fatalf("CreateRole(...): managing a released role: %v", err)
+	}
+	if diff := cmp.Diff(want, getRole(t, repo, v1alpha1.CloudAWS, name), opts...); diff != "" {
+		t.Errorf("GetRole(...): -want, +got:\n%s", diff)
+	}
+
+	// Once nothing binds the role, deleting its Role deletes it.
+	if err := repo.DeletePermissionSet(ctx, ps); err != nil {
+		t.Fatalf("DeletePermissionSet(...): %v", err)
+	}
+	if err := repo.DeleteRole(ctx, v1alpha1.CloudAWS, name); err != nil {
+		t.Fatalf("DeleteRole(...): %v", err)
+	}
+	if _, err := repo.GetRole(ctx, v1alpha1.CloudAWS, name); !storetypes.IsEntityNotFoundNeo4jErr(err) {
+		t.Errorf("GetRole(...): want EntityNotFoundError, got %v", err)
+	}
+	ps, err = repo.CreatePermissionSet(ctx, uid(), "readers", []v1alpha1.AccountRoleBinding{binding})
+	if err != nil {
+		t.Fatalf("CreatePermissionSet(...): %v", err)
+	}
+	if _, err := repo.GetRole(ctx, v1alpha1.CloudAWS, name); !storetypes.IsEntityNotFoundNeo4jErr(err) {
+		t.Errorf("GetRole(...): want EntityNotFoundError for a role no Role manages, got %v", err)
+	}
+	if err := repo.DeletePermissionSet(ctx, ps); err != nil {
+		t.Fatalf("DeletePermissionSet(...): %v", err)
+	}
+}
+
+func testClouds(t *testing.T, repo service.Repository) {
+	ctx := context.Background()
+
+	gcp := v1alpha1.AccountRoleBinding{
+		Cloud: v1alpha1.CloudGCP,
+		Alias: "analytics",
+		GCP:   &v1alpha1.GCPBinding{Project: "analytics-prod", Role: "roles/bigquery.dataViewer"},
+	}
+	azure := v1alpha1.AccountRoleBinding{
+		Cloud: v1alpha1.CloudAzure,
+		Azure: &v1alpha1.AzureBinding{Subscription: "0b1f6471", RoleDefinition: "Reader", Scope: "/subscriptions/0b1f6471/resourceGroups/web"},
+	}
+	kubernetes := v1alpha1.AccountRoleBinding{
+		Cloud:      v1alpha1.CloudKubernetes,
+		Kubernetes: &v1alpha1.KubernetesBinding{Cluster: "prod-eu", ClusterRole: "view"},
+	}
+	aws := v1alpha1.AccountRoleBinding{Account: "123456789012", RoleName: "ReadOnly"}
+
+	id, err := repo.CreatePermissionSet(ctx, uid(), "readers", []v1alpha1.AccountRoleBinding{gcp, azure, kubernetes, aws})
+	if err != nil {
+		t.Fatalf("CreatePermissionSet(...): %v", err)
+	}
+
+	withCloud := aws
+	withCloud.Cloud = v1alpha1.CloudAWS
+	want := &types.GetPermissionSetResponse{Name: "readers", NodeID: id, Status: storetypes.StatusAvailable, Bindings: types.UniqueBindings([]v1alpha1.AccountRoleBinding{gcp, azure, kubernetes, withCloud})}
+	if diff := cmp.Diff(want, getPermissionSet(t, repo, id), opts...); diff != "" {
+		t.Errorf("GetPermissionSet(...): -want, +got:\n%s", diff)
+	}
+
+	// A binding at another scope is another binding.
+	subscription := azure
+	subscription.Azure = &v1alpha1.AzureBinding{Subscription: "0b1f6471", RoleDefinition: "Reader"}
+	changes, err := repo.UpdatePermissionSet(ctx, id, "readers", []v1alpha1.AccountRoleBinding{gcp, subscription, kubernetes, aws})
+	if err != nil {
+		t.Fatalf("UpdatePermissionSet(...): %v", err)
+	}
+	wantChanges := types.Changes{
+		Added: []types.Relationship{
+			{Type: types.RelationDelegatesAccessTo, Node: "0b1f6471"},
+			{Type: types.RelationDelegatesAccessWith, Node: "Reader"},
+		},
+		Removed: []types.Relationship{
+			{Type: types.RelationDelegatesAccessTo, Node: "0b1f6471"},
+			{Type: types.RelationDelegatesAccessWith, Node: "Reader"},
+		},
+	}
+	if diff := cmp.Diff(wantChanges, changes, opts...); diff != "" {
+		t.Errorf("UpdatePermissionSet(...): -want, +got:\n%s", diff)
+	}
+	want.Bindings = types.UniqueBindings([]v1alpha1.AccountRoleBinding{gcp, subscription, kubernetes, withCloud})
+	if diff := cmp.Diff(want, getPermissionSet(t, repo, id), opts...); diff != "" {
+		t.Errorf("GetPermissionSet(...): -want, +got:\n%s", diff)
+	}
+}
+
+// testCloudKeys checks that an account or role with the same id or name in
+// two clouds is two accounts or roles.
+func testCloudKeys(t *testing.T, repo service.Repository) {
+	ctx := context.Background()
+
+	aws := v1alpha1.AccountRoleBinding{Cloud: v1alpha1.CloudAWS, Account: "shared", Alias: "legacy", RoleName: "viewer"}
+	gcp := v1alpha1.AccountRoleBinding{Cloud: v1alpha1.CloudGCP, Alias: "analytics", GCP: &v1alpha1.GCPBinding{Project: "shared", Role: "viewer"}}
+	ps, err := repo.CreatePermissionSet(ctx, uid(), "viewers", []v1alpha1.AccountRoleBinding{aws, gcp})
+	if err != nil {
+		t.Fatalf("CreatePermissionSet(...): %v", err)
+	}
+	if diff := cmp.Diff(types.UniqueBindings([]v1alpha1.AccountRoleBinding{aws, gcp}), getPermissionSet(t, repo, ps).Bindings, opts...); diff != "" {
+		t.Errorf("GetPermissionSet(...): -want bindings, +got:\n%s", diff)
+	}
+
+	accounts := []*v1alpha1.AccountParameters{
+		{Cloud: v1alpha1.CloudAWS, ID: "shared", Alias: "production", Owner: "platform"},
+		{Cloud: v1alpha1.CloudGCP, ID: "shared", Alias: "warehouse", Owner: "data"},
+	}
+	roles := []*v1alpha1.RoleParameters{
+		{Cloud: v1alpha1.CloudAWS, Name: "viewer", Description: "An IAM role"},
+		{Cloud: v1alpha1.CloudGCP, Name: "viewer", Description: "A GCP role"},
+	}
+	for _, a := range accounts {
+		if _, err := repo.CreateAccount(ctx, uid(), a); err != nil {
+			t.Errorf("CreateAccount(...): want no ConflictError for the account in %s, got %v", a.Cloud, err)
+		}
+	}
+	for _, r := range roles {
+		if _, err := repo.CreateRole(ctx, uid(), r); err != nil {
+			t.Errorf("CreateRole(...): want no ConflictError for the role in %s, got %v", r.Cloud, err)
+		}
+	}
+	for _, a := range accounts {
+		want := &types.GetAccountResponse{Account: *a, NodeID: a.ID, Status: storetypes.StatusAvailable}
+		if diff := cmp.Diff(want, getAccount(t, repo, a.Cloud, a.ID), opts...); diff != "" {
+			t.Errorf("GetAccount(...): -want, +got:\n%s", diff)
+		}
+	}
+	for _, r := range roles {
+		want := &types.GetRoleResponse{Role: *r, NodeID: r.Name, Status: storetypes.StatusAvailable}
+		if diff := cmp.Diff(want, getRole(t, repo, r.Cloud, r.Name), opts...); diff != "" {
+			t.Errorf("GetRole(...): -want, +got:\n%s", diff)
+		}
+	}
+
+	persona, err := repo.CreatePersona(ctx, uid(), "viewer", []string{ps})
+	if err != nil {
+		t.Fatalf("CreatePersona(...): %v", err)
+	}
+	mario, err := repo.CreateUser(ctx, uid(), "mario", []string{persona})
+	if err != nil {
+		t.Fatalf("CreateUser(...): %v", err)
+	}
+	for _, b := range []v1alpha1.AccountRoleBinding{aws, gcp} {
+		target := types.TargetOf(b)
+		want := []types.Grant{{Access: types.Access{Target: target, PermissionSet: "viewers", Persona: "viewer"}, User: mario, UserName: "mario"}}
+		got, err := repo.WhoCanAccess(ctx, target.Cloud, target.Account, target.Role)
+		if err != nil {
+			t.Fatalf("WhoCanAccess(...): %v", err)
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("WhoCanAccess(...): -want, +got:\n%s", diff)
+		}
+	}
+}
+
+func testTeam(t *testing.T, repo service.Repository) {
+	ctx := context.Background()
+
+	bowser := createUser(t, repo, "bowser")
+	wario := createUser(t, repo, "wario")
+	toad := createUser(t, repo, "toad")
+	persona := createPersona(t, repo, "castle-entry")
+
+	params := &v1alpha1.TeamParameters{
+		Name:      "koopa-troop",
+		ManagedBy: v1alpha1.ManagedByParameters{User: bowser},
+		Members:   []string{wario, toad},
+		Personas:  []string{persona},
+	}
+
+	id, err := repo.CreateTeam(ctx, uid(), params)
+	if err != nil {
+		t.Fatalf("CreateTeam(...): %v", err)
+	}
+
+	want := &types.GetTeamResponse{
+		Name:      "koopa-troop",
+		NodeID:    id,
+		Status:    storetypes.StatusAvailable,
+		ManagedBy: bowser,
+		Members:   []string{wario, toad},
+		Personas:  []string{persona},
+	}
+	if diff := cmp.Diff(want, getTeam(t, repo, id), opts...); diff != "" {
+		t.Errorf("GetTeam(...): -want, +got:\n%s", diff)
+	}
+
+	params.ManagedBy.User = wario
+	params.Members = []string{toad}
+	params.Personas = nil
+	changes, err := repo.UpdateTeam(ctx, id, params)
+	if err != nil {
+		t.Fatalf("UpdateTeam(...): %v", err)
+	}
+	wantChanges := types.Changes{
+		Added: []types.Relationship{{Type: types.RelationManagedBy, Node: wario}},
+		Removed: []types.Relationship{
+			{Type: types.RelationManagedBy, Node: bowser},
+			{Type: types.RelationMemberOf, Node: wario},
+			{Type: types.RelationInherits, Node: persona},
+		},
+	}
+	if diff := cmp.Diff(wantChanges, changes, opts...); diff != "" {
+		t.Errorf("UpdateTeam(...): -want, +got:\n%s", diff)
+	}
+	want.ManagedBy = wario
+	want.Members = []string{toad}
+	want.Personas = nil
+	if diff := cmp.Diff(want, getTeam(t, repo, id), opts...); diff != "" {
+		t.Errorf("GetTeam(...): -want, +got:\n%s", diff)
+	}
+
+	// A team without any relationships still exists.
+	params.ManagedBy.User = ""
+	params.Members = nil
+	if _, err := repo.UpdateTeam(ctx, id, params); err != nil {
+		t.Fatalf("UpdateTeam(...): %v", err)
+	}
+	want.ManagedBy = ""
+	want.Members = nil
+	if diff := cmp.Diff(want, getTeam(t, repo, id), opts...); diff != "" {
+		t.Errorf("GetTeam(...): -want, +got:\n%s", diff)
+	}
+
+	if err := repo.DeleteTeam(ctx, id); err != nil {
+		t.Fatalf("DeleteTeam(...): %v", err)
+	}
+	if _, err := repo.GetTeam(ctx, id); !storetypes.IsEntityNotFoundNeo4jErr(err) {
+		t.Errorf("GetTeam(...): want EntityNotFoundError, got %v", err)
+	}
+}
+
+func testEffectiveAccess(t *testing.T, repo service.Repository) {
+	ctx := context.Background()
+
+	gcp := v1alpha1.AccountRoleBinding{
+		Cloud: v1alpha1.CloudGCP,
+		GCP:   &v1alpha1.GCPBinding{Project: "analytics-prod", Role: "roles/viewer"},
+	}
+	readonly, err := repo.CreatePermissionSet(ctx, uid(), "readonly", []v1alpha1.AccountRoleBinding{{Account: "123456789012", RoleName: "ReadOnly"}, gcp})
+	if err != nil {
+		t.Fatalf("CreatePermissionSet(...): %v", err)
+	}
+	admin, err := repo.CreatePermissionSet(ctx, uid(), "admin", []v1alpha1.AccountRoleBinding{{Account: "123456789012", RoleName: "Admin"}})
+	if err != nil {
+		t.Fatalf("CreatePermissionSet(...): %v", err)
+	}
+
+	auditor, err := repo.CreatePersona(ctx, uid(), "auditor", []string{readonly})
+	if err != nil {
+		t.Fatalf("CreatePersona(...): %v", err)
+	}
+	operator, err := repo.CreatePersona(ctx, uid(), "operator", []string{admin})
+	if err != nil {
+		t.Fatalf("CreatePersona(...): %v", err)
+	}
+
+	mario, err := repo.CreateUser(ctx, uid(), "mario", []string{auditor})
+	if err != nil {
+		t.Fatalf("CreateUser(...): %v", err)
+	}
+	peach := createUser(t, repo, "peach")
+
+	// A manager does not inherit the personas of its team.
+	params := &v1alpha1.TeamParameters{
+		Name:      "plumbers",
+		ManagedBy: v1alpha1.ManagedByParameters{User: peach},
+		Members:   []string{mario},
+		Personas:  []string{auditor, operator},
+	}
+	if _, err := repo.CreateTeam(ctx, uid(), params); err != nil {
+		t.Fatalf("CreateTeam(...): %v", err)
+	}
+
+	aws := func(role string) types.Target {
+		return types.Target{Cloud: v1alpha1.CloudAWS, Account: "123456789012", Role: role}
+	}
+	viewer := types.Target{Cloud: v1alpha1.CloudGCP, Account: "analytics-prod", Role: "roles/viewer"}
+	want := []types.Access{
+		{Target: aws("ReadOnly"), PermissionSet: "readonly", Persona: "auditor"},
+		{Target: viewer, PermissionSet: "readonly", Persona: "auditor"},
+		{Target: aws("ReadOnly"), PermissionSet: "readonly", Persona: "auditor", Team: "plumbers"},
+		{Target: viewer, PermissionSet: "readonly", Persona: "auditor", Team: "plumbers"},
+		{Target: aws("Admin"), PermissionSet: "admin", Persona: "operator", Team: "plumbers"},
+	}
+	byPath := cmpopts.SortSlices(func(a, b types.Access) bool { return fmt.Sprint(a) < fmt.Sprint(b) })
+
+	got, err := repo.GetEffectiveAccess(ctx, mario)
+	if err != nil {
+		t.Fatalf("GetEffectiveAccess(...): %v", err)
+	}
+	if diff := cmp.Diff(want, got, byPath); diff != "" {
+		t.Errorf("GetEffectiveAccess(...): -want, +got:\n%s", diff)
+	}
+
+	got, err = repo.GetEffectiveAccess(ctx, peach)
+	if err != nil {
+		t.Fatalf("GetEffectiveAccess(...): %v", err)
+	}
+	if diff := cmp.Diff([]types.Access{}, got, cmpopts.EquateEmpty()); diff != "" {
+		t.Errorf("GetEffectiveAccess(...): -want, +got:\n%s", diff)
+	}
+}
+
+func testWhoCanAccess(t *testing.T, repo service.Repository) {
+	ctx := context.Background()
+
+	readonly, err := repo.CreatePermissionSet(ctx, uid(), "readonly", []v1alpha1.AccountRoleBinding{{Account: "123456789012", RoleName: "ReadOnly"}})
+	if err != nil {
+		t.Fatalf("CreatePermissionSet(...): %v", err)
+	}
+	admin, err := repo.CreatePermissionSet(ctx, uid(), "admin", []v1alpha1.AccountRoleBinding{{Account: "123456789012", RoleName: "Admin"}})
+	if err != nil {
+		t.Fatalf("CreatePermissionSet(...): %v", err)
+	}
+
+	auditor, err := repo.CreatePersona(ctx, uid(), "auditor", []string{readonly})
+	if err != nil {
+		t.Fatalf("CreatePersona(...): %v", err)
+	}
+	operator, err := repo.CreatePersona(ctx, uid(), "operator", []string{admin})
+	if err != nil {
+		t.Fatalf("CreatePersona(...): %v", err)
+	}
+
+	mario, err := repo.CreateUser(ctx, uid(), "mario", []string{auditor})
+	if err != nil {
+		t.Fatalf("CreateUser(...): %v", err)
+	}
+	if _, err := repo.CreateUser(ctx, uid(), "toad", []string{operator}); err != nil {
+		t.Fatalf("CreateUser(...): %v", err)
+	}
+	luigi := createUser(t, repo, "luigi")
+	peach := createUser(t, repo, "peach")
+
+	params := &v1alpha1.TeamParameters{
+		Name:      "plumbers",
+		ManagedBy: v1alpha1.ManagedByParameters{User: peach},
+		Members:   []string{mario, luigi},
+		Personas:  []string{auditor},
+	}
+	if _, err := repo.CreateTeam(ctx, uid(), params); err != nil {
+		t.Fatalf("CreateTeam(...): %v", err)
+	}
+
+	access := types.Access{
+		Target:        types.Target{Cloud: v1alpha1.CloudAWS, Account: "123456789012", Role: "ReadOnly"},
+		PermissionSet: "readonly",
+		Persona:       "auditor",
+	}
+	inherited := access
+	inherited.Team = "plumbers"
+	want := []types.Grant{
+		{Access: access, User: mario, UserName: "mario"},
+		{Access: inherited, User: mario, UserName: "mario"},
+		{Access: inherited, User: luigi, UserName: "luigi"},
+	}
+	byPath := cmpopts.SortSlices(func(a, b types.Grant) bool { return fmt.Sprint(a) < fmt.Sprint(b) })
+
+	got, err := repo.WhoCanAccess(ctx, v1alpha1.CloudAWS, "123456789012", "ReadOnly")
+	if err != nil {
+		t.Fatalf("WhoCanAccess(...): %v", err)
+	}
+	if diff := cmp.Diff(want, got, byPath); diff != "" {
+		t.Errorf("WhoCanAccess(...): -want, +got:\n%s", diff)
+	}
+
+	got, err = repo.WhoCanAccess(ctx, v1alpha1.CloudAWS, "210987654321", "ReadOnly")
+	if err != nil {
+		t.Fatalf("WhoCanAccess(...): %v", err)
+	}
+	if diff := cmp.Diff([]types.Grant{}, got, cmpopts.EquateEmpty()); diff != "" {
+		t.Errorf("WhoCanAccess(...): -want, +got:\n%s", diff)
+	}
+}
+
+// An explainer is a Repository which can explain why a user has access to an
+// account with a role, as storage.Explainer.
+type explainer interface {
+	ExplainAccess(ctx context.Context, userUuid, cloud, accountID, roleName string) ([]types.Path, error)
+}
+
+func testExplainAccess(t *testing.T, repo service.Repository) {
+	e, ok := repo.(explainer)
+	if !ok {
+		t.Skip("the backend cannot explain access")
+	}
+	ctx := context.Background()
+
+	psUID, personaUID, marioUID, teamUID := uid(), uid(), uid(), uid()
+	readonly, err := repo.CreatePermissionSet(ctx, psUID, "readonly", []v1alpha1.AccountRoleBinding{{Account: "123456789012", RoleName: "ReadOnly"}})
+	if err != nil {
+		t.Fatalf("CreatePermissionSet(...): %v", err)
+	}
+	auditor, err := repo.CreatePersona(ctx, personaUID, "auditor", []string{readonly})
+	if err != nil {
+		t.Fatalf("CreatePersona(...): %v", err)
+	}
+	mario, err := repo.CreateUser(ctx, marioUID, "mario", []string{auditor})
+	if err != nil {
+		t.Fatalf("CreateUser(...): %v", err)
+	}
+	peach := createUser(t, repo, "peach")
+	team, err := repo.CreateTeam(ctx, teamUID, &v1alpha1.TeamParameters{
+		Name:      "plumbers",
+		ManagedBy: v1alpha1.ManagedByParameters{User: peach},
+		Members:   []string{mario},
+		Personas:  []string{auditor},
+	})
+	if err != nil {
+		t.Fatalf("CreateTeam(...): %v", err)
+	}
+
+	target := types.Target{Cloud: v1alpha1.CloudAWS, Account: "123456789012", Role: "ReadOnly"}
+	user := types.Hop{Label: v1alpha1.UserKind, ID: mario, Name: "mario", UID: marioUID}
+	binding := []types.Hop{
+		{Label: v1alpha1.PersonaKind, ID: auditor, Name: "auditor", UID: personaUID},
+		{Label: v1alpha1.PermissionSetKind, ID: readonly, Name: "readonly", UID: psUID},
+		{Label: v1alpha1.AccountKind, ID: "123456789012", Name: "123456789012"},
+		{Label: v1alpha1.RoleKind, ID: "ReadOnly", Name: "ReadOnly"},
+	}
+	want := []types.Path{
+		{Target: target, Hops: append([]types.Hop{user}, binding...)},
+		{Target: target, Hops: append([]types.Hop{user, {Label: v1alpha1.TeamKind, ID: team, Name: "plumbers", UID: teamUID}}, binding...)},
+	}
+	byPath := cmpopts.SortSlices(func(a, b types.Path) bool { return a.String() < b.String() })
+
+	got, err := e.ExplainAccess(ctx, mario, v1alpha1.CloudAWS, "123456789012", "ReadOnly")
+	if err != nil {
+		t.Fatalf("ExplainAccess(...): %v", err)
+	}
+	if diff := cmp.Diff(want, got, byPath); diff != "" {
+		t.Errorf("ExplainAccess(...): -want, +got:\n%s", diff)
+	}
+
+	got, err = e.ExplainAccess(ctx, mario, v1alpha1.CloudGCP, "123456789012", "ReadOnly")
+	if err != nil {
+		t.Fatalf("ExplainAccess(...): %v", err)
+	}
+	if diff := cmp.Diff([]types.Path{}, got, cmpopts.EquateEmpty()); diff != "" {
+		t.Errorf("ExplainAccess(...): want no paths to an account in another cloud: -want, +got:\n%s", diff)
+	}
+}
+
+func testNotFound(t *testing.T, repo service.Repository) {
+	ctx := context.Background()
+
+	u, err := repo.GetUser(ctx, missing)
+	if !storetypes.IsEntityNotFoundNeo4jErr(err) {
+		t.Errorf("GetUser(...): want EntityNotFoundError, got %v", err)
+	}
+	if diff := cmp.Diff(&types.GetUserResponse{NodeID: missing, Status: storetypes.StatusDeleted}, u, opts...); diff != "" {
+		t.Errorf("GetUser(...): -want, +got:\n%s", diff)
+	}
+
+	p, err := repo.GetPersona(ctx, missing)
+	if !storetypes.IsEntityNotFoundNeo4jErr(err) {
+		t.Errorf("GetPersona(...): want EntityNotFoundError, got %v", err)
+	}
+	if diff := cmp.Diff(&types.GetPersonaResponse{NodeID: missing, Status: storetypes.StatusDeleted}, p, opts...); diff != "" {
+		t.Errorf("GetPersona(...): -want, +got:\n%s", diff)
+	}
+
+	ps, err := repo.GetPermissionSet(ctx, missing)
+	if !storetypes.IsEntityNotFoundNeo4jErr(err) {
+		t.Errorf("GetPermissionSet(...): want EntityNotFoundError, got %v", err)
+	}
+	if diff := cmp.Diff(&types.GetPermissionSetResponse{NodeID: missing, Status: storetypes.StatusDeleted}, ps, opts...); diff != "" {
+		t.Errorf("GetPermissionSet(...): -want, +got:\n%s", diff)
+	}
+
+	tm, err := repo.GetTeam(ctx, missing)
+	if !storetypes.IsEntityNotFoundNeo4jErr(err) {
+		t.Errorf("GetTeam(...): want EntityNotFoundError, got %v", err)
+	}
+	if diff := cmp.Diff(&types.GetTeamResponse{NodeID: missing, Status: storetypes.StatusDeleted}, tm, opts...); diff != "" {
+		t.Errorf("GetTeam(...): -want, +got:\n%s", diff)
+	}
+
+	if _, err := repo.UpdateUser(ctx, "mario", missing, nil); !storetypes.IsEntityNotFoundNeo4jErr(err) {
+		t.Errorf("UpdateUser(...): want EntityNotFoundError, got %v", err)
+	}
+	if _, err := repo.UpdatePersona(ctx, "auditor", missing, nil); !storetypes.IsEntityNotFoundNeo4jErr(err) {
+		t.Errorf("UpdatePersona(...): want EntityNotFoundError, got %v", err)
+	}
+	bindings := []v1alpha1.AccountRoleBinding{{Account: "123456789012", RoleName: "ReadOnly"}}
+	if _, err := repo.UpdatePermissionSet(ctx, missing, "readonly", bindings); !storetypes.IsEntityNotFoundNeo4jErr(err) {
+		t.Errorf("UpdatePermissionSet(...): want EntityNotFoundError, got %v", err)
+	}
+	if _, err := repo.UpdateTeam(ctx, missing, &v1alpha1.TeamParameters{Name: "koopa-troop"}); !storetypes.IsEntityNotFoundNeo4jErr(err) {
+		t.Errorf("UpdateTeam(...): want EntityNotFoundError, got %v", err)
+	}
+
+	if err := repo.DeleteUser(ctx, missing); err != nil {
+		t.Errorf("DeleteUser(...): %v", err)
+	}
+	if err := repo.DeletePersona(ctx, missing); err != nil {
+		t.Errorf("DeletePersona(...): %v", err)
+	}
+	if err := repo.DeletePermissionSet(ctx, missing); err != nil {
+		t.Errorf("DeletePermissionSet(...): %v", err)
+	}
+	if err := repo.DeleteTeam(ctx, missing); err != nil {
+		t.Errorf("DeleteTeam(...): %v", err)
+	}
+}
+
+func testReferenceIntegrity(t *testing.T, repo service.Repository) {
+	ctx := context.Background()
+
+	ps := createPermissionSet(t, repo, "readonly")
+	persona, err := repo.CreatePersona(ctx, uid(), "auditor", []string{ps, missing})
+	if err != nil {
+		t.Fatalf("CreatePersona(...): %v", err)
+	}
+	if diff := cmp.Diff([]string{ps}, getPersona(t, repo, persona).References, opts...); diff != "" {
+		t.Errorf("GetPersona(...): unknown permission sets should be ignored: -want, +got:\n%s", diff)
+	}
+
+	manager := createUser(t, repo, "bowser")
+	member, err := repo.CreateUser(ctx, uid(), "wario", []string{persona, missing})
+	if err != nil {
+		t.Fatalf("CreateUser(...): %v", err)
+	}
+	if diff := cmp.Diff([]string{persona}, getUser(t, repo, member).References, opts...); diff != "" {
+		t.Errorf("GetUser(...): unknown personas should be ignored: -want, +got:\n%s", diff)
+	}
+
+	team, err := repo.CreateTeam(ctx, uid(), &v1alpha1.TeamParameters{
+		Name:      "koopa-troop",
+		ManagedBy: v1alpha1.ManagedByParameters{User: manager},
+		Members:   []string{manager, member, missing},
+		Personas:  []string{persona, missing},
+	})
+	if err != nil {
+		t.Fatalf("CreateTeam(...): %v", err)
+	}
+	want := &types.GetTeamResponse{
+		Name:      "koopa-troop",
+		NodeID:    team,
+		Status:    storetypes.StatusAvailable,
+		ManagedBy: manager,
+		Members:   []string{member},
+		Personas:  []string{persona},
+	}
+	if diff := cmp.Diff(want, getTeam(t, repo, team), opts...); diff != "" {
+		t.Errorf("GetTeam(...): a manager is never a member: -want, +got:\n%s", diff)
+	}
+
+	if err := repo.DeletePermissionSet(ctx, ps); err != nil {
+		t.Fatalf("DeletePermissionSet(...): %v", err)
+	}
+	if got := getPersona(t, repo, persona).References; len(got) != 0 {
+		t.Errorf("GetPersona(...): want no permission sets after deleting them, got %v", got)
+	}
+
+	if err := repo.DeletePersona(ctx, persona); err != nil {
+		t.Fatalf("DeletePersona(...): %v", err)
+	}
+	if got := getUser(t, repo, member).References; len(got) != 0 {
+		t.Errorf("GetUser(...): want no personas after deleting them, got %v", got)
+	}
+	want.Personas = nil
+	if diff := cmp.Diff(want, getTeam(t, repo, team), opts...); diff != "" {
+		t.Errorf("GetTeam(...): -want, +got:\n%s", diff)
+	}
+
+	if err := repo.DeleteUser(ctx, manager); err != nil {
+		t.Fatalf("DeleteUser(...): %v", err)
+	}
+	if err := repo.DeleteUser(ctx, member); err != nil {
+		t.Fatalf("DeleteUser(...): %v", err)
+	}
+	want.ManagedBy = ""
+	want.Members = nil
+	if diff := cmp.Diff(want, getTeam(t, repo, team), opts...); diff != "" {
+		t.Errorf("GetTeam(...): -want, +got:\n%s", diff)
+	}
+}
+
+func testIdempotency(t *testing.T, repo service.Repository) {
+	ctx := context.Background()
+
+	persona := createPersona(t, repo, "readonly")
+
+	user, err := repo.CreateUser(ctx, uid(), "mario", []string{persona, persona})
+	if err != nil {
+		t.Fatalf("CreateUser(...): %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		changes, err := repo.UpdateUser(ctx, "mario", user, []string{persona})
+		if err != nil {
+			t.Fatalf("UpdateUser(...): %v", err)
+		}
+		if !changes.Empty() {
+			t.Errorf("UpdateUser(...): want no changes, got %s", changes)
+		}
+	}
+	if diff := cmp.Diff([]string{persona}, getUser(t, repo, user).References, opts...); diff != "" {
+		t.Errorf("GetUser(...): -want, +got:\n%s", diff)
+	}
+
+	bindings := []v1alpha1.AccountRoleBinding{{Cloud: v1alpha1.CloudAWS, Account: "123456789012", Alias: "production", RoleName: "ReadOnly"}}
+	ps, err := repo.CreatePermissionSet(ctx, uid(), "readonly", bindings)
+	if err != nil {
+		t.Fatalf("CreatePermissionSet(...): %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		changes, err := repo.UpdatePermissionSet(ctx, ps, "readonly", bindings)
+		if err != nil {
+			t.Fatalf("UpdatePermissionSet(...): %v", err)
+		}
+		if !changes.Empty() {
+			t.Errorf("UpdatePermissionSet(...): want no changes, got %s", changes)
+		}
+		changes, err = repo.UpdatePersona(ctx, "readonly", persona, []string{ps, ps})
+		if err != nil {
+			t.Fatalf("UpdatePersona(...): %v", err)
+		}
+		if i > 0 && !changes.Empty() {
+			t.Errorf("UpdatePersona(...): want no changes when repeated, got %s", changes)
+		}
+	}
+	if diff := cmp.Diff(bindings, getPermissionSet(t, repo, ps).Bindings, opts...); diff != "" {
+		t.Errorf("GetPermissionSet(...): -want, +got:\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{ps}, getPersona(t, repo, persona).References, opts...); diff != "" {
+		t.Errorf("GetPersona(...): -want, +got:\n%s", diff)
+	}
+
+	params := &v1alpha1.TeamParameters{Name: "mushroom-kingdom", Members: []string{user, user}, Personas: []string{persona}}
+	team, err := repo.CreateTeam(ctx, uid(), params)
+	if err != nil {
+		t.Fatalf("CreateTeam(...): %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		changes, err := repo.UpdateTeam(ctx, team, params)
+		if err != nil {
+			t.Fatalf("UpdateTeam(...): %v", err)
+		}
+		if !changes.Empty() {
+			t.Errorf("UpdateTeam(...): want no changes, got %s", changes)
+		}
+	}
+	want := &types.GetTeamResponse{
+		Name:     "mushroom-kingdom",
+		NodeID:   team,
+		Status:   storetypes.StatusAvailable,
+		Members:  []string{user},
+		Personas: []string{persona},
+	}
+	if diff := cmp.Diff(want, getTeam(t, repo, team), opts...); diff != "" {
+		t.Errorf("GetTeam(...): -want, +got:\n%s", diff)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := repo.DeleteTeam(ctx, team); err != nil {
+			t.Errorf("DeleteTeam(...): %v", err)
+		}
+		if err := repo.DeleteUser(ctx, user); err != nil {
+			t.Errorf("DeleteUser(...): %v", err)
+		}
+		if err := repo.DeletePersona(ctx, persona); err != nil {
+			t.Errorf("DeletePersona(...): %v", err)
+		}
+		if err := repo.DeletePermissionSet(ctx, ps); err != nil {
+			t.Errorf("DeletePermissionSet(...): %v", err)
+		}
+	}
+}
+
+func testAdoption(t *testing.T, repo service.Repository) {
+	ctx := context.Background()
+
+	bindings := []v1alpha1.AccountRoleBinding{{Account: "123456789012", RoleName: "ReadOnly"}}
+
+	kinds := map[string]struct {
+		create func(uid string) (string, error)
+		lookup func(ctx context.Context, uid string) (string, error)
+		remove func(ctx context.Context, id string) error
+	}{
+		"User": {
+			create: func(uid string) (string, error) { return repo.CreateUser(ctx, uid, "mario", nil) },
+			lookup: repo.LookupUser,
+			remove: repo.DeleteUser,
+		},
+		"Persona": {
+			create: func(uid string) (string, error) { return repo.CreatePersona(ctx, uid, "auditor", nil) },
+			lookup: repo.LookupPersona,
+			remove: repo.DeletePersona,
+		},
+		"PermissionSet": {
+			create: func(uid string) (string, error) { return repo.CreatePermissionSet(ctx, uid, "readonly", bindings) },
+			lookup: repo.LookupPermissionSet,
+			remove: repo.DeletePermissionSet,
+		},
+		"Team": {
+			create: func(uid string) (string, error) {
+				return repo.CreateTeam(ctx, uid, &v1alpha1.TeamParameters{Name: "koopa-troop"})
+			},
+			lookup: repo.LookupTeam,
+			remove: repo.DeleteTeam,
+		},
+		"Account": {
+			create: func(uid string) (string, error) {
+				return repo.CreateAccount(ctx, uid, &v1alpha1.AccountParameters{ID: "345678901234"})
+			},
+			lookup: repo.LookupAccount,
+			remove: func(ctx context.Context, id string) error { return repo.DeleteAccount(ctx, v1alpha1.CloudAWS, id) },
+		},
+		"Role": {
+			create: func(uid string) (string, error) {
+				return repo.CreateRole(ctx, uid, &v1alpha1.RoleParameters{Name: "PowerUserAccess"})
+			},
+			lookup: repo.LookupRole,
+			remove: func(ctx context.Context, name string) error { return repo.DeleteRole(ctx, v1alpha1.CloudAWS, name) },
+		},
+	}
+
+	for name, k := range kinds {
+		u := uid()
+
+		if _, err := k.lookup(ctx, u); !storetypes.IsEntityNotFoundNeo4jErr(err) {
+			t.Errorf("Lookup%s(...): want EntityNotFoundError before create, got %v", name, err)
+		}
+
+		id, err := k.create(u)
+		if err != nil {
+			t.Fatalf("Create%s(...): %v", name, err)
+		}
+		again, err := k.create(u)
+		if err != nil {
+			t.Fatalf("Create%s(...): %v", name, err)
+		}
+		if again != id {
+			t.Errorf("Create%s(...): want %q from a repeated create, got %q", name, id, again)
+		}
+
+		got, err := k.lookup(ctx, u)
+		if err != nil {
+			t.Fatalf("Lookup%s(...): %v", name, err)
+		}
+		if got != id {
+			t.Errorf("Lookup%s(...): want %q, got %q", name, id, got)
+		}
+
+		if err := k.remove(ctx, id); err != nil {
+			t.Fatalf("Delete%s(...): %v", name, err)
+		}
+		if _, err := k.lookup(ctx, u); !storetypes.IsEntityNotFoundNeo4jErr(err) {
+			t.Errorf("Lookup%s(...): want EntityNotFoundError after delete, got %v", name, err)
+		}
+	}
+}
+
+// testUpdateScope checks that an update leaves the relationships of the
+// entities it references alone.
+func testUpdateScope(t *testing.T, repo service.Repository) {
+	ctx := context.Background()
+
+	ps := createPermissionSet(t, repo, "readonly")
+	granted, err := repo.CreatePersona(ctx, uid(), "auditor", []string{ps})
+	if err != nil {
+		t.Fatalf("CreatePersona(...): %v", err)
+	}
+	inherited := createPersona(t, repo, "castle-entry")
+
+	user, err := repo.CreateUser(ctx, uid(), "mario", []string{granted})
+	if err != nil {
+		t.Fatalf("CreateUser(...): %v", err)
+	}
+	manager := createUser(t, repo, "peach")
+
+	params := &v1alpha1.TeamParameters{
+		Name:      "mushroom-kingdom",
+		ManagedBy: v1alpha1.ManagedByParameters{User: manager},
+		Members:   []string{user},
+		Personas:  []string{inherited},
+	}
+	team, err := repo.CreateTeam(ctx, uid(), params)
+	if err != nil {
+		t.Fatalf("CreateTeam(...): %v", err)
+	}
+	other, err := repo.CreateTeam(ctx, uid(), params)
+	if err != nil {
+		t.Fatalf("CreateTeam(...): %v", err)
+	}
+
+	params.Members = nil
+	if _, err := repo.UpdateTeam(ctx, team, params); err != nil {
+		t.Fatalf("UpdateTeam(...): %v", err)
+	}
+	if _, err := repo.UpdateUser(ctx, "mario", user, []string{granted}); err != nil {
+		t.Fatalf("UpdateUser(...): %v", err)
+	}
+
+	if diff := cmp.Diff([]string{granted}, getUser(t, repo, user).References, opts...); diff != "" {
+		t.Errorf("GetUser(...): updating a team should not change its members' personas: -want, +got:\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{ps}, getPersona(t, repo, granted).References, opts...); diff != "" {
+		t.Errorf("GetPersona(...): updating a user should not change its personas: -want, +got:\n%s", diff)
+	}
+	want := &types.GetTeamResponse{
+		Name:      "mushroom-kingdom",
+		NodeID:    other,
+		Status:    storetypes.StatusAvailable,
+		ManagedBy: manager,
+		Members:   []string{user},
+		Personas:  []string{inherited},
+	}
+	if diff := cmp.Diff(want, getTeam(t, repo, other), opts...); diff != "" {
+		t.Errorf("GetTeam(...): updating a team should not change other teams: -want, +got:\n%s", diff)
+	}
+}
+
+// uid returns a new managed resource UID.
+func uid() string {
+	return uuid.NewString()
+}
+
+func testLookupByName(t *testing.T, repo service.Repository) {
+	ctx := context.Background()
+
+	kinds := map[string]struct {
+		create func(t *testing.T, repo service.Repository, name string) string
+		lookup func(ctx context.Context, name string) (string, error)
+		rename func(ctx context.Context, name, id string) error
+	}{
+		"User": {
+			create: createUser,
+			lookup: repo.LookupUserByName,
+			rename: func(ctx context.Context, name, id string) error {
+				_, err := repo.UpdateUser(ctx, name, id, nil)
+				return err
+			},
+		},
+		"Persona": {
+			create: createPersona,
+			lookup: repo.LookupPersonaByName,
+			rename: func(ctx context.Context, name, id string) error {
+				_, err := repo.UpdatePersona(ctx, name, id, nil)
+				return err
+			},
+		},
+	}
+
+	for name, k := range kinds {
+		if _, err := k.lookup(ctx, "peach"); !storetypes.IsEntityNotFoundNeo4jErr(err) {
+			t.Errorf("Lookup%sByName(...): want EntityNotFoundError before create, got %v", name, err)
+		}
+
+		id := k.create(t, repo, "peach")
+		_ = k.create(t, repo, "daisy")
+
+		got, err := k.lookup(ctx, "peach")
+		if err != nil {
+			t.Fatalf("Lookup%sByName(...): %v", name, err)
+		}
+		if got != id {
+			t.Errorf("Lookup%sByName(...): want %q, got %q", name, id, got)
+		}
+
+		if err := k.rename(ctx, "daisy", id); err != nil {
+			t.Fatalf("Update%s(...): %v", name, err)
+		}
+		if _, err := k.lookup(ctx, "peach"); !storetypes.IsEntityNotFoundNeo4jErr(err) {
+			t.Errorf("Lookup%sByName(...): want EntityNotFoundError after rename, got %v", name, err)
+		}
+		if _, err := k.lookup(ctx, "daisy"); !storetypes.IsConflictError(err) {
+			t.Errorf("Lookup%sByName(...): want ConflictError for a shared name, got %v", name, err)
+		}
+	}
+}
+
+func createUser(t *testing.T, repo service.Repository, name string) string {
+	t.Helper()
+
+	id, err := repo.CreateUser(context.Background(), uid(), name, nil)
+	if err != nil {
+		t.Fatalf("CreateUser(...): %v", err)
+	}
+
+	return id
+}
+
+func createPersona(t *testing.T, repo service.Repository, name string) string {
+	t.Helper()
+
+	id, err := repo.CreatePersona(context.Background(), uid(), name, nil)
+	if err != nil {
+		t.Fatalf("CreatePersona(...): %v", err)
+	}
+
+	return id
+}
+
+func createPermissionSet(t *testing.T, repo service.Repository, name string) string {
+	t.Helper()
+
+	id, err := repo.CreatePermissionSet(context.Background(), uid(), name, []v1alpha1.AccountRoleBinding{{Account: "123456789012", RoleName: name}})
+	if err != nil {
+		t.Fatalf("CreatePermissionSet(...): %v", err)
+	}
+
+	return id
+}
+
+func getUser(t *testing.T, repo service.Repository, id string) *types.GetUserResponse {
+	t.Helper()
+
+	resp, err := repo.GetUser(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetUser(...): %v", err)
+	}
+
+	return resp
+}
+
+func getPersona(t *testing.T, repo service.Repository, id string) *types.GetPersonaResponse {
+	t.Helper()
+
+	resp, err := repo.GetPersona(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetPersona(...): %v", err)
+	}
+
+	return resp
+}
+
+func getPermissionSet(t *testing.T, repo service.Repository, id string) *types.GetPermissionSetResponse {
+	t.Helper()
+
+	resp, err := repo.GetPermissionSet(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetPermissionSet(...): %v", err)
+	}
+
+	return resp
+}
+
+func getAccount(t *testing.T, repo service.Repository, cloud, id string) *types.GetAccountResponse {
+	t.Helper()
+
+	resp, err := repo.GetAccount(context.Background(), cloud, id)
+	if err != nil {
+		t.Fatalf("GetAccount(...): %v", err)
+	}
+
+	return resp
+}
+
+func getRole(t *testing.T, repo service.Repository, cloud, name string) *types.GetRoleResponse {
+	t.Helper()
+
+	resp, err := repo.GetRole(context.Background(), cloud, name)
+	if err != nil {
+		t.Fatalf("GetRole(...): %v", err)
+	}
+
+	return resp
+}
+
+func getTeam(t *testing.T, repo service.Repository, id string) *types.GetTeamResponse {
+	t.Helper()
+
+	resp, err := repo.GetTeam(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetTeam(...): %v", err)
+	}
+
+	return resp
+}
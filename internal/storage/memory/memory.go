@@ -0,0 +1,1011 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/VariableExp0rt/powerbroker/apis/powerbroker/v1alpha1"
+	"github.com/VariableExp0rt/powerbroker/internal/service/types"
+	storetypes "github.com/VariableExp0rt/powerbroker/internal/storage/types"
+)
+
+/*
+
+Memory is a service.Repository which keeps the graph in process. It is meant
+for local development and for running the controllers in CI without a
+database, so nothing is persisted and every ProviderConfig gets its own
+graph for the lifetime of the provider.
+
+Nodes and edges use the same labels and relationship types as the neo4j
+store so that the two can be reasoned about interchangeably:
+
+	(:User)-[:GRANTED]->(:Persona)
+	(:User)-[:MEMBER_OF]->(:Team)
+	(:Team)-[:MANAGED_BY]->(:User)
+	(:Team)-[:INHERITS]->(:Persona)
+	(:PermissionSet)-[:ATTACHED_TO]->(:Persona)
+	(:PermissionSet)-[:DELEGATES_ACCESS_TO]->(:Account)
+	(:PermissionSet)-[:DELEGATES_ACCESS_WITH]->(:Role)
+
+Each binding of a permission set is a pair of delegations to its account and
+role which carry the same binding key. An account has the cloud of the
+bindings to it.
+*/
+
+type Label string
+
+const (
+	LabelUser          Label = "User"
+	LabelTeam          Label = "Team"
+	LabelPersona       Label = "Persona"
+	LabelPermissionSet Label = "PermissionSet"
+	LabelAccount       Label = "Account"
+	LabelRole          Label = "Role"
+)
+
+type Relation string
+
+const (
+	RelationGranted             Relation = types.RelationGranted
+	RelationMemberOf            Relation = types.RelationMemberOf
+	RelationManagedBy           Relation = types.RelationManagedBy
+	RelationInherits            Relation = types.RelationInherits
+	RelationAttachedTo          Relation = types.RelationAttachedTo
+	RelationDelegatesAccessTo   Relation = types.RelationDelegatesAccessTo
+	RelationDelegatesAccessWith Relation = types.RelationDelegatesAccessWith
+)
+
+// A NodeKey identifies a node by its label and identity property: uuid for
+// entities owned by a managed resource, id for accounts and name for roles.
+// Accounts and roles are also identified by their cloud, so the same id or
+// name may be used in each cloud.
+type NodeKey struct {
+	Label Label
+	Cloud string
+	ID    string
+}
+
+// An Edge is a typed, directed relationship between two nodes. The
+// delegations of a permission set carry the key of the binding they belong
+// to, so that a permission set may delegate access to the same account or
+// with the same role through several bindings. A delegation to an account
+// also carries the scope within it the binding is limited to, if any.
+type Edge struct {
+	From     NodeKey
+	Relation Relation
+	To       NodeKey
+	Binding  string
+	Scope    string
+}
+
+type Memory struct {
+	mu    sync.RWMutex
+	nodes map[NodeKey]map[string]string
+	edges map[Edge]struct{}
+}
+
+func New() *Memory {
+	return &Memory{
+		nodes: map[NodeKey]map[string]string{},
+		edges: map[Edge]struct{}{},
+	}
+}
+
+var (
+	graphsMu sync.Mutex
+	graphs   = map[string]*Memory{}
+)
+
+// ForProviderConfig returns the graph of the named ProviderConfig, creating
+// it on first use.
+func ForProviderConfig(name string) *Memory {
+	graphsMu.Lock()
+	defer graphsMu.Unlock()
+
+	m, ok := graphs[name]
+	if !ok {
+		m = New()
+		graphs[name] = m
+	}
+
+	return m
+}
+
+// Nodes returns the keys of every node in the graph.
+func (m *Memory) Nodes() []NodeKey {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	out := make([]NodeKey, 0, len(m.nodes))
+	for k := range m.nodes {
+		out = append(out, k)
+	}
+
+	return out
+}
+
+// Edges returns every edge in the graph.
+func (m *Memory) Edges() []Edge {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	out := make([]Edge, 0, len(m.edges))
+	for e := range m.edges {
+		out = append(out, e)
+	}
+
+	return out
+}
+
+func (m *Memory) CreateUser(ctx context.Context, uid, userName string, personaRefs []string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u := m.merge(LabelUser, uid, userName)
+	m.mergeAll(u, RelationGranted, LabelPersona, personaRefs, false)
+
+	return u.ID, nil
+}
+
+func (m *Memory) LookupUser(ctx context.Context, uid string) (string, error) {
+	return m.lookup(LabelUser, uid)
+}
+
+func (m *Memory) LookupUserByName(ctx context.Context, name string) (string, error) {
+	return m.lookupByName(LabelUser, name)
+}
+
+func (m *Memory) GetUser(ctx context.Context, userUuid string) (*types.GetUserResponse, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	u := NodeKey{Label: LabelUser, ID: userUuid}
+	props, ok := m.nodes[u]
+	if !ok {
+		return &types.GetUserResponse{
+			NodeID: userUuid,
+			Status: storetypes.StatusDeleted,
+		}, &storetypes.EntityNotFoundError{}
+	}
+
+	return &types.GetUserResponse{
+		Name:       props["name"],
+		NodeID:     userUuid,
+		Status:     storetypes.StatusAvailable,
+		References: m.targets(u, RelationGranted),
+	}, nil
+}
+
+func (m *Memory) UpdateUser(ctx context.Context, userName string, userUuid string, personaRefs []string) (types.Changes, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u := NodeKey{Label: LabelUser, ID: userUuid}
+	props, ok := m.nodes[u]
+	if !ok {
+		return types.Changes{}, &storetypes.EntityNotFoundError{}
+	}
+
+	props["name"] = userName
+
+	return m.relate(u, RelationGranted, LabelPersona, personaRefs, false), nil
+}
+
+func (m *Memory) DeleteUser(ctx context.Context, userUuid string) error {
+	return m.detachDelete(NodeKey{Label: LabelUser, ID: userUuid})
+}
+
+func (m *Memory) GetEffectiveAccess(ctx context.Context, userUuid string) ([]types.Access, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	out := []types.Access{}
+	through := func(persona NodeKey, team string) {
+		for _, id := range m.sources(persona, RelationAttachedTo, LabelPermissionSet) {
+			ps := NodeKey{Label: LabelPermissionSet, ID: id}
+			for _, b := range m.bindings(ps) {
+				t := types.TargetOf(b)
+				if t.Role == "" {
+					continue
+				}
+				out = append(out, types.Access{Target: t, PermissionSet: m.nodes[ps]["name"], Persona: m.nodes[persona]["name"], Team: team})
+			}
+		}
+	}
+
+	u := NodeKey{Label: LabelUser, ID: userUuid}
+	for _, p := range m.targets(u, RelationGranted) {
+		through(NodeKey{Label: LabelPersona, ID: p}, "")
+	}
+	for _, id := range m.targets(u, RelationMemberOf) {
+		t := NodeKey{Label: LabelTeam, ID: id}
+		for _, p := range m.targets(t, RelationInherits) {
+			through(NodeKey{Label: LabelPersona, ID: p}, m.nodes[t]["name"])
+		}
+	}
+
+	return out, nil
+}
+
+func (m *Memory) CreatePersona(ctx context.Context, uid, personaName string, permissionSetRefs []string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p := m.merge(LabelPersona, uid, personaName)
+	m.mergeAll(p, RelationAttachedTo, LabelPermissionSet, permissionSetRefs, true)
+
+	return p.ID, nil
+}
+
+func (m *Memory) LookupPersona(ctx context.Context, uid string) (string, error) {
+	return m.lookup(LabelPersona, uid)
+}
+
+func (m *Memory) LookupPersonaByName(ctx context.Context, name string) (string, error) {
+	return m.lookupByName(LabelPersona, name)
+}
+
+func (m *Memory) GetPersona(ctx context.Context, personaUuid string) (*types.GetPersonaResponse, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	p := NodeKey{Label: LabelPersona, ID: personaUuid}
+	props, ok := m.nodes[p]
+	if !ok {
+		return &types.GetPersonaResponse{
+			NodeID: personaUuid,
+			Status: storetypes.StatusDeleted,
+		}, &storetypes.EntityNotFoundError{}
+	}
+
+	return &types.GetPersonaResponse{
+		Name:       props["name"],
+		NodeID:     personaUuid,
+		Status:     storetypes.StatusAvailable,
+		References: m.sources(p, RelationAttachedTo, LabelPermissionSet),
+	}, nil
+}
+
+func (m *Memory) UpdatePersona(ctx context.Context, personaName string, personaUuid string, permissionSetUuids []string) (types.Changes, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p := NodeKey{Label: LabelPersona, ID: personaUuid}
+	props, ok := m.nodes[p]
+	if !ok {
+		return types.Changes{}, &storetypes.EntityNotFoundError{}
+	}
+
+	props["name"] = personaName
+
+	return m.relate(p, RelationAttachedTo, LabelPermissionSet, permissionSetUuids, true), nil
+}
+
+func (m *Memory) DeletePersona(ctx context.Context, personaUuid string) error {
+	return m.detachDelete(NodeKey{Label: LabelPersona, ID: personaUuid})
+}
+
+func (m *Memory) CreatePermissionSet(ctx context.Context, uid, name string, bindings []v1alpha1.AccountRoleBinding) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ps := m.merge(LabelPermissionSet, uid, name)
+	m.bind(ps, bindings)
+
+	return ps.ID, nil
+}
+
+func (m *Memory) LookupPermissionSet(ctx context.Context, uid string) (string, error) {
+	return m.lookup(LabelPermissionSet, uid)
+}
+
+func (m *Memory) GetPermissionSet(ctx context.Context, permissionSetUuid string) (*types.GetPermissionSetResponse, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ps := NodeKey{Label: LabelPermissionSet, ID: permissionSetUuid}
+	props, ok := m.nodes[ps]
+	if !ok {
+		return &types.GetPermissionSetResponse{
+			NodeID: permissionSetUuid,
+			Status: storetypes.StatusDeleted,
+		}, &storetypes.EntityNotFoundError{}
+	}
+
+	return &types.GetPermissionSetResponse{
+		Name:     props["name"],
+		Bindings: m.bindings(ps),
+		NodeID:   permissionSetUuid,
+		Status:   storetypes.StatusAvailable,
+	}, nil
+}
+
+func (m *Memory) UpdatePermissionSet(ctx context.Context, permissionSetUuid, crName string, bindings []v1alpha1.AccountRoleBinding) (types.Changes, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ps := NodeKey{Label: LabelPermissionSet, ID: permissionSetUuid}
+	props, ok := m.nodes[ps]
+	if !ok {
+		return types.Changes{}, &storetypes.EntityNotFoundError{}
+	}
+
+	props["name"] = crName
+
+	return m.bind(ps, bindings), nil
+}
+
+func (m *Memory) DeletePermissionSet(ctx context.Context, permissionSetUuid string) error {
+	return m.detachDelete(NodeKey{Label: LabelPermissionSet, ID: permissionSetUuid})
+}
+
+func (m *Memory) CreateTeam(ctx context.Context, uid string, teamparams *v1alpha1.TeamParameters) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t := m.merge(LabelTeam, uid, teamparams.Name)
+	m.team(t, teamparams)
+
+	return t.ID, nil
+}
+
+func (m *Memory) LookupTeam(ctx context.Context, uid string) (string, error) {
+	return m.lookup(LabelTeam, uid)
+}
+
+func (m *Memory) GetTeam(ctx context.Context, teamUuid string) (*types.GetTeamResponse, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	t := NodeKey{Label: LabelTeam, ID: teamUuid}
+	props, ok := m.nodes[t]
+	if !ok {
+		return &types.GetTeamResponse{
+			NodeID: teamUuid,
+			Status: storetypes.StatusDeleted,
+		}, &storetypes.EntityNotFoundError{}
+	}
+
+	var manager string
+	if managers := m.targets(t, RelationManagedBy); len(managers) > 0 {
+		manager = managers[0]
+	}
+
+	return &types.GetTeamResponse{
+		Name:      props["name"],
+		NodeID:    teamUuid,
+		Status:    storetypes.StatusAvailable,
+		ManagedBy: manager,
+		Members:   m.sources(t, RelationMemberOf, LabelUser),
+		Personas:  m.targets(t, RelationInherits),
+	}, nil
+}
+
+func (m *Memory) UpdateTeam(ctx context.Context, teamUuid string, teamparams *v1alpha1.TeamParameters) (types.Changes, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t := NodeKey{Label: LabelTeam, ID: teamUuid}
+	if _, ok := m.nodes[t]; !ok {
+		return types.Changes{}, &storetypes.EntityNotFoundError{}
+	}
+
+	m.nodes[t]["name"] = teamparams.Name
+
+	return m.team(t, teamparams), nil
+}
+
+func (m *Memory) DeleteTeam(ctx context.Context, teamUuid string) error {
+	return m.detachDelete(NodeKey{Label: LabelTeam, ID: teamUuid})
+}
+
+func (m *Memory) CreateAccount(ctx context.Context, uid string, account *v1alpha1.AccountParameters) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if n, ok := m.find(LabelAccount, uid); ok {
+		return n.ID, nil
+	}
+
+	a := accountKey(types.DefaultCloud(account.Cloud), account.ID)
+	if props, ok := m.nodes[a]; ok && props["uid"] != "" {
+		return "", &storetypes.ConflictError{Err: errors.Errorf("account %s is managed by %s", account.ID, props["uid"])}
+	}
+	m.nodes[a] = accountProps(uid, account)
+
+	return a.ID, nil
+}
+
+func (m *Memory) LookupAccount(ctx context.Context, uid string) (string, error) {
+	return m.lookup(LabelAccount, uid)
+}
+
+func (m *Memory) GetAccount(ctx context.Context, cloud, accountID string) (*types.GetAccountResponse, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	props, ok := m.nodes[accountKey(cloud, accountID)]
+	if !ok || props["uid"] == "" {
+		return &types.GetAccountResponse{
+			NodeID: accountID,
+			Status: storetypes.StatusDeleted,
+		}, &storetypes.EntityNotFoundError{}
+	}
+
+	return &types.GetAccountResponse{
+		Account: v1alpha1.AccountParameters{
+			Cloud:       cloud,
+			ID:          accountID,
+			Alias:       props["alias"],
+			Class:       props["class"],
+			Owner:       props["owner"],
+			Environment: props["environment"],
+			Lifecycle:   props["lifecycle"],
+		},
+		NodeID: accountID,
+		Status: storetypes.StatusAvailable,
+	}, nil
+}
+
+func (m *Memory) UpdateAccount(ctx context.Context, cloud, accountID string, account *v1alpha1.AccountParameters) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	a := accountKey(cloud, accountID)
+	props, ok := m.nodes[a]
+	if !ok || props["uid"] == "" {
+		return &storetypes.EntityNotFoundError{}
+	}
+	m.nodes[a] = accountProps(props["uid"], account)
+
+	return nil
+}
+
+func (m *Memory) DeleteAccount(ctx context.Context, cloud, accountID string) error {
+	return m.release(accountKey(cloud, accountID))
+}
+
+func (m *Memory) WhoCanAccess(ctx context.Context, cloud, accountID, roleName string) ([]types.Grant, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	out := []types.Grant{}
+	granted := func(persona NodeKey, access types.Access) {
+		for _, u := range m.sources(persona, RelationGranted, LabelUser) {
+			out = append(out, types.Grant{Access: access, User: u, UserName: m.nodes[NodeKey{Label: LabelUser, ID: u}]["name"]})
+		}
+		for _, id := range m.sources(persona, RelationInherits, LabelTeam) {
+			t := NodeKey{Label: LabelTeam, ID: id}
+			inherited := access
+			inherited.Team = m.nodes[t]["name"]
+			for _, u := range m.sources(t, RelationMemberOf, LabelUser) {
+				out = append(out, types.Grant{Access: inherited, User: u, UserName: m.nodes[NodeKey{Label: LabelUser, ID: u}]["name"]})
+			}
+		}
+	}
+
+	for _, id := range m.sources(accountKey(cloud, accountID), RelationDelegatesAccessTo, LabelPermissionSet) {
+		ps := NodeKey{Label: LabelPermissionSet, ID: id}
+		for _, b := range m.bindings(ps) {
+			t := types.TargetOf(b)
+			if t.Role == "" || t.Cloud != cloud || t.Account != accountID || t.Role != roleName {
+				continue
+			}
+			for _, p := range m.targets(ps, RelationAttachedTo) {
+				persona := NodeKey{Label: LabelPersona, ID: p}
+				granted(persona, types.Access{Target: t, PermissionSet: m.nodes[ps]["name"], Persona: m.nodes[persona]["name"]})
+			}
+		}
+	}
+
+	return out, nil
+}
+
+// ExplainAccess walks forward from the user with the supplied uuid, through
+// the personas granted to it directly or inherited by its teams, to the
+// permission sets which bind the account and role in the cloud, and returns
+// each path it finds.
+func (m *Memory) ExplainAccess(ctx context.Context, userUuid, cloud, accountID, roleName string) ([]types.Path, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	hop := func(n NodeKey) types.Hop {
+		return types.Hop{Label: string(n.Label), ID: n.ID, Name: m.nodes[n]["name"], UID: m.nodes[n]["uid"]}
+	}
+
+	out := []types.Path{}
+	explain := func(via []types.Hop, persona NodeKey) {
+		for _, id := range m.sources(persona, RelationAttachedTo, LabelPermissionSet) {
+			ps := NodeKey{Label: LabelPermissionSet, ID: id}
+			for _, b := range m.bindings(ps) {
+				t := types.TargetOf(b)
+				if t.Role == "" || t.Cloud != cloud || t.Account != accountID || t.Role != roleName {
+					continue
+				}
+				hops := append(append([]types.Hop{}, via...),
+					hop(persona),
+					hop(ps),
+					types.Hop{Label: string(LabelAccount), ID: t.Account, Name: t.Account, UID: m.nodes[accountKey(cloud, accountID)]["uid"]},
+					types.Hop{Label: string(LabelRole), ID: t.Role, Name: t.Role, UID: m.nodes[roleKey(cloud, roleName)]["uid"]},
+				)
+				out = append(out, types.Path{Target: t, Hops: hops})
+			}
+		}
+	}
+
+	u := NodeKey{Label: LabelUser, ID: userUuid}
+	if _, ok := m.nodes[u]; !ok {
+		return out, nil
+	}
+	for _, p := range m.targets(u, RelationGranted) {
+		explain([]types.Hop{hop(u)}, NodeKey{Label: LabelPersona, ID: p})
+	}
+	for _, id := range m.targets(u, RelationMemberOf) {
+		t := NodeKey{Label: LabelTeam, ID: id}
+		for _, p := range m.targets(t, RelationInherits) {
+			explain([]types.Hop{hop(u), hop(t)}, NodeKey{Label: LabelPersona, ID: p})
+		}
+	}
+
+	return out, nil
+}
+
+func (m *Memory) CreateRole(ctx context.Context, uid string, role *v1alpha1.RoleParameters) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if n, ok := m.find(LabelRole, uid); ok {
+		return n.ID, nil
+	}
+
+	r := roleKey(types.DefaultCloud(role.Cloud), role.Name)
+	if props, ok := m.nodes[r]; ok && props["uid"] != "" {
+		return "", &storetypes.ConflictError{Err: errors.Errorf("role %s is managed by %s", role.Name, props["uid"])}
+	}
+	m.nodes[r] = roleProps(uid, role)
+
+	return r.ID, nil
+}
+
+func (m *Memory) LookupRole(ctx context.Context, uid string) (string, error) {
+	return m.lookup(LabelRole, uid)
+}
+
+func (m *Memory) GetRole(ctx context.Context, cloud, roleName string) (*types.GetRoleResponse, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	props, ok := m.nodes[roleKey(cloud, roleName)]
+	if !ok || props["uid"] == "" {
+		return &types.GetRoleResponse{
+			NodeID: roleName,
+			Status: storetypes.StatusDeleted,
+		}, &storetypes.EntityNotFoundError{}
+	}
+
+	return &types.GetRoleResponse{
+		Role: v1alpha1.RoleParameters{
+			Cloud:              cloud,
+			Name:               roleName,
+			Description:        props["description"],
+			Tier:               props["tier"],
+			PolicyDocument:     props["policyDocument"],
+			MaxSessionDuration: storetypes.ParseDuration(props["maxSessionDuration"]),
+		},
+		NodeID: roleName,
+		Status: storetypes.StatusAvailable,
+	}, nil
+}
+
+func (m *Memory) UpdateRole(ctx context.Context, cloud, roleName string, role *v1alpha1.RoleParameters) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r := roleKey(cloud, roleName)
+	props, ok := m.nodes[r]
+	if !ok || props["uid"] == "" {
+		return &storetypes.EntityNotFoundError{}
+	}
+	m.nodes[r] = roleProps(props["uid"], role)
+
+	return nil
+}
+
+func (m *Memory) DeleteRole(ctx context.Context, cloud, roleName string) error {
+	return m.release(roleKey(cloud, roleName))
+}
+
+// MarkOrphans marks every account and role which no permission set
+// delegates access to as orphaned since now, unless it already is. Accounts
+// and roles which are referenced again are no longer orphaned.
+func (m *Memory) MarkOrphans(ctx context.Context, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	referenced := m.referencedNodes()
+	for n, props := range m.nodes {
+		if !orphanable(n, props) {
+			continue
+		}
+		if referenced[n] {
+			delete(props, "orphanedAt")
+			continue
+		}
+		if _, ok := props["orphanedAt"]; !ok {
+			props["orphanedAt"] = strconv.FormatInt(now.Unix(), 10)
+		}
+	}
+
+	return nil
+}
+
+// Orphans returns every account and role which no permission set delegates
+// access to, orphaned since they were marked, or since now if they are not
+// marked yet.
+func (m *Memory) Orphans(ctx context.Context, now time.Time) ([]storetypes.Orphan, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	referenced := m.referencedNodes()
+	out := []storetypes.Orphan{}
+	for n, props := range m.nodes {
+		if !orphanable(n, props) || referenced[n] {
+			continue
+		}
+		since := now
+		if at, ok := props["orphanedAt"]; ok {
+			seconds, _ := strconv.ParseInt(at, 10, 64)
+			since = time.Unix(seconds, 0)
+		}
+		out = append(out, storetypes.Orphan{Label: string(n.Label), Cloud: n.Cloud, ID: n.ID, Since: since})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].String() < out[j].String() })
+
+	return out, nil
+}
+
+// orphanable returns true if the node may be orphaned: an account or role
+// which no Account or Role manages.
+func orphanable(n NodeKey, props map[string]string) bool {
+	return (n.Label == LabelAccount || n.Label == LabelRole) && props["uid"] == ""
+}
+
+// referencedNodes returns every node an edge points to.
+func (m *Memory) referencedNodes() map[NodeKey]bool {
+	referenced := map[NodeKey]bool{}
+	for e := range m.edges {
+		referenced[e.To] = true
+	}
+
+	return referenced
+}
+
+// Collect deletes the supplied orphans which nothing references.
+func (m *Memory) Collect(ctx context.Context, orphans []storetypes.Orphan) ([]storetypes.Orphan, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := []storetypes.Orphan{}
+	for _, o := range orphans {
+		n := NodeKey{Label: Label(o.Label), Cloud: o.Cloud, ID: o.ID}
+		if props, ok := m.nodes[n]; !ok || props["uid"] != "" || m.referenced(n) {
+			continue
+		}
+		delete(m.nodes, n)
+		out = append(out, o)
+	}
+
+	return out, nil
+}
+
+// merge returns the node of label owned by uid, creating it with a new uuid
+// and the supplied name if there is none. Callers must hold the write lock.
+func (m *Memory) merge(label Label, uid, name string) NodeKey {
+	if n, ok := m.find(label, uid); ok {
+		return n
+	}
+
+	n := NodeKey{Label: label, ID: uuid.NewString()}
+	m.nodes[n] = map[string]string{"uid": uid, "name": name}
+
+	return n
+}
+
+// lookup returns the uuid of the node of label owned by uid.
+func (m *Memory) lookup(label Label, uid string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	n, ok := m.find(label, uid)
+	if !ok {
+		return "", &storetypes.EntityNotFoundError{}
+	}
+
+	return n.ID, nil
+}
+
+// lookupByName returns the id of the only node of label with the supplied
+// name.
+func (m *Memory) lookupByName(label Label, name string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var ids []string
+	for n, props := range m.nodes {
+		if n.Label == label && props["name"] == name {
+			ids = append(ids, n.ID)
+		}
+	}
+	sort.Strings(ids)
+
+	return storetypes.Unique(ids)
+}
+
+// find returns the node of label owned by uid. Callers must hold the read
+// lock.
+func (m *Memory) find(label Label, uid string) (NodeKey, bool) {
+	for n, props := range m.nodes {
+		if n.Label == label && props["uid"] == uid {
+			return n, true
+		}
+	}
+
+	return NodeKey{}, false
+}
+
+// bind merges the account and role of each binding and delegates access to
+// them, through a pair of edges carrying the key of the binding, from the
+// permission set. Pairs of bindings the permission set no longer has are
+// removed. The account and role of a binding are those in its cloud, and the
+// alias and class of an account managed by an Account are left alone.
+// Callers must hold the write lock.
+func (m *Memory) bind(ps NodeKey, bindings []v1alpha1.AccountRoleBinding) types.Changes {
+	bindings = types.UniqueBindings(bindings)
+	c := types.DiffBindings(m.bindings(ps), bindings)
+
+	m.deleteEdges(func(e Edge) bool {
+		return e.From == ps && (e.Relation == RelationDelegatesAccessTo || e.Relation == RelationDelegatesAccessWith)
+	})
+	for _, b := range bindings {
+		t := types.TargetOf(b)
+		account := accountKey(t.Cloud, t.Account)
+		role := roleKey(t.Cloud, t.Role)
+
+		props, ok := m.nodes[account]
+		if !ok {
+			props = map[string]string{}
+			m.nodes[account] = props
+		}
+		if props["uid"] == "" {
+			props["alias"], props["class"] = b.Alias, b.AccountClass
+		}
+		if _, ok := m.nodes[role]; !ok {
+			m.nodes[role] = map[string]string{}
+		}
+
+		key := types.BindingKey(b)
+		m.edges[Edge{From: ps, Relation: RelationDelegatesAccessTo, To: account, Binding: key, Scope: t.Scope}] = struct{}{}
+		m.edges[Edge{From: ps, Relation: RelationDelegatesAccessWith, To: role, Binding: key}] = struct{}{}
+	}
+
+	return c
+}
+
+// bindings returns the bindings of a permission set, which are the accounts
+// it delegates access to paired with the role it delegates access with
+// under the same key. The alias and class of an account managed by an
+// Account are its own, so they are not returned. Callers must hold the read
+// lock.
+func (m *Memory) bindings(ps NodeKey) []v1alpha1.AccountRoleBinding {
+	roles := map[string]string{}
+	for e := range m.edges {
+		if e.From == ps && e.Relation == RelationDelegatesAccessWith {
+			roles[e.Binding] = e.To.ID
+		}
+	}
+
+	out := []v1alpha1.AccountRoleBinding{}
+	for e := range m.edges {
+		if e.From != ps || e.Relation != RelationDelegatesAccessTo {
+			continue
+		}
+		account := m.nodes[e.To]
+		t := types.Target{Cloud: e.To.Cloud, Account: e.To.ID, Role: roles[e.Binding], Scope: e.Scope}
+		if account["uid"] != "" {
+			out = append(out, t.Binding("", ""))
+			continue
+		}
+		out = append(out, t.Binding(account["alias"], account["class"]))
+	}
+
+	return types.UniqueBindings(out)
+}
+
+// team relates a team to its manager, members and personas. A manager of a
+// team is never also a member of it. Callers must hold the write lock.
+func (m *Memory) team(t NodeKey, teamparams *v1alpha1.TeamParameters) types.Changes {
+	members := make([]string, 0, len(teamparams.Members))
+	for _, u := range teamparams.Members {
+		if u != teamparams.ManagedBy.User {
+			members = append(members, u)
+		}
+	}
+
+	return m.relate(t, RelationManagedBy, LabelUser, []string{teamparams.ManagedBy.User}, false).
+		Merge(m.relate(t, RelationMemberOf, LabelUser, members, true)).
+		Merge(m.relate(t, RelationInherits, LabelPersona, teamparams.Personas, false))
+}
+
+// relate relates n through rel to the existing nodes of label with one of
+// the supplied ids, and no others, and returns the edges it added and
+// removed. Edges point from n unless inbound is true. Callers must hold the
+// write lock.
+func (m *Memory) relate(n NodeKey, rel Relation, label Label, ids []string, inbound bool) types.Changes {
+	desired := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := m.nodes[NodeKey{Label: label, ID: id}]; ok {
+			desired = append(desired, id)
+		}
+	}
+
+	current := m.targets(n, rel)
+	if inbound {
+		current = m.sources(n, rel, label)
+	}
+
+	c := types.Diff(string(rel), current, desired)
+	for _, r := range c.Removed {
+		other := NodeKey{Label: label, ID: r.Node}
+		if inbound {
+			delete(m.edges, Edge{From: other, Relation: rel, To: n})
+			continue
+		}
+		delete(m.edges, Edge{From: n, Relation: rel, To: other})
+	}
+	m.mergeAll(n, rel, label, desired, inbound)
+
+	return c
+}
+
+// mergeAll creates an edge between n and every existing node of label with
+// one of the supplied ids. Edges point from n unless inbound is true. Ids of
+// nodes that do not exist are skipped, as a MATCH would in Cypher. Callers
+// must hold the write lock.
+func (m *Memory) mergeAll(n NodeKey, rel Relation, label Label, ids []string, inbound bool) {
+	for _, id := range ids {
+		other := NodeKey{Label: label, ID: id}
+		if _, ok := m.nodes[other]; !ok {
+			continue
+		}
+
+		if inbound {
+			m.edges[Edge{From: other, Relation: rel, To: n}] = struct{}{}
+			continue
+		}
+		m.edges[Edge{From: n, Relation: rel, To: other}] = struct{}{}
+	}
+}
+
+// targets returns the sorted ids of nodes n points to through rel. Callers
+// must hold the read lock.
+func (m *Memory) targets(n NodeKey, rel Relation) []string {
+	out := []string{}
+	for e := range m.edges {
+		if e.From == n && e.Relation == rel {
+			out = append(out, e.To.ID)
+		}
+	}
+	sort.Strings(out)
+
+	return out
+}
+
+// sources returns the sorted ids of nodes of label which point to n through
+// rel. Callers must hold the read lock.
+func (m *Memory) sources(n NodeKey, rel Relation, label Label) []string {
+	out := []string{}
+	for e := range m.edges {
+		if e.To == n && e.Relation == rel && e.From.Label == label {
+			out = append(out, e.From.ID)
+		}
+	}
+	sort.Strings(out)
+
+	return out
+}
+
+// accountKey returns the key of the account with the supplied id in the
+// cloud.
+func accountKey(cloud, id string) NodeKey {
+	return NodeKey{Label: LabelAccount, Cloud: cloud, ID: id}
+}
+
+// roleKey returns the key of the role with the supplied name in the cloud.
+func roleKey(cloud, name string) NodeKey {
+	return NodeKey{Label: LabelRole, Cloud: cloud, ID: name}
+}
+
+// accountProps returns the properties of an account managed by the Account
+// with the supplied uid.
+func accountProps(uid string, account *v1alpha1.AccountParameters) map[string]string {
+	return map[string]string{
+		"uid":         uid,
+		"alias":       account.Alias,
+		"class":       account.Class,
+		"owner":       account.Owner,
+		"environment": account.Environment,
+		"lifecycle":   account.Lifecycle,
+	}
+}
+
+// roleProps returns the properties of a role managed by the Role with the
+// supplied uid.
+func roleProps(uid string, role *v1alpha1.RoleParameters) map[string]string {
+	return map[string]string{
+		"uid":                uid,
+		"description":        role.Description,
+		"tier":               role.Tier,
+		"policyDocument":     role.PolicyDocument,
+		"maxSessionDuration": storetypes.FormatDuration(role.MaxSessionDuration),
+	}
+}
+
+// referenced returns true if an edge points to n. Callers must hold the read
+// lock.
+func (m *Memory) referenced(n NodeKey) bool {
+	for e := range m.edges {
+		if e.To == n {
+			return true
+		}
+	}
+
+	return false
+}
+
+// deleteEdges removes every edge for which match returns true. Callers must
+// hold the write lock.
+func (m *Memory) deleteEdges(match func(Edge) bool) {
+	for e := range m.edges {
+		if match(e) {
+			delete(m.edges, e)
+		}
+	}
+}
+
+// release removes the account or role n, unless a permission set still
+// delegates access to or with it. In that case only its managed resource and
+// the properties it set are removed, leaving n to be collected once it is
+// orphaned. Releasing a node which does not exist is not an error.
+func (m *Memory) release(n NodeKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.nodes[n]; !ok {
+		return nil
+	}
+	if m.referenced(n) {
+		m.nodes[n] = map[string]string{}
+		return nil
+	}
+	delete(m.nodes, n)
+
+	return nil
+}
+
+// detachDelete removes a node and every edge attached to it. Deleting a node
+// which does not exist is not an error, as with DETACH DELETE.
+func (m *Memory) detachDelete(n NodeKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.deleteEdges(func(e Edge) bool { return e.From == n || e.To == n })
+	delete(m.nodes, n)
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package memory_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/VariableExp0rt/powerbroker/apis/powerbroker/v1alpha1"
+	"github.com/VariableExp0rt/powerbroker/internal/service"
+	"github.com/VariableExp0rt/powerbroker/internal/storage"
+	"github.com/VariableExp0rt/powerbroker/internal/storage/conformance"
+	"github.com/VariableExp0rt/powerbroker/internal/storage/memory"
+	storetypes "github.com/VariableExp0rt/powerbroker/internal/storage/types"
+)
+
+var (
+	_ service.Repository = &memory.Memory{}
+	_ storage.Collector  = &memory.Memory{}
+)
+
+func TestForProviderConfig(t *testing.T) {
+	a := memory.ForProviderConfig("a")
+
+	if got := memory.ForProviderConfig("a"); got != a {
+		t.Errorf("ForProviderConfig(...): want the same graph for the same ProviderConfig")
+	}
+	if got := memory.ForProviderConfig("b"); got == a {
+		t.Errorf("ForProviderConfig(...): want a different graph for a different ProviderConfig")
+	}
+}
+
+func TestConformance(t *testing.T) {
+	conformance.Run(t, func(t *testing.T) service.Repository {
+		return memory.New()
+	})
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	store := memory.New()
+	persona, _ := store.CreatePersona(context.Background(), "persona-uid", "readonly", nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			id, err := store.CreateUser(context.Background(), fmt.Sprintf("user-uid-%d", i), fmt.Sprintf("user-%d", i), []string{persona})
+			if err != nil {
+				t.Errorf("CreateUser(...): %v", err)
+				return
+			}
+			if _, err := store.GetUser(context.Background(), id); err != nil {
+				t.Errorf("GetUser(...): %v", err)
+			}
+			if _, err := store.UpdateUser(context.Background(), "renamed", id, nil); err != nil {
+				t.Errorf("UpdateUser(...): %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if got := len(store.Nodes()); got != 51 {
+		t.Errorf("Nodes(): want 51 nodes, got %d", got)
+	}
+	if got := len(store.Edges()); got != 0 {
+		t.Errorf("Edges(): want 0 edges, got %d", got)
+	}
+}
+
+func TestCollect(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	first, second := time.Unix(1000, 0), time.Unix(2000, 0)
+
+	a, _ := store.CreatePermissionSet(ctx, "a-uid", "a", []v1alpha1.AccountRoleBinding{{Account: "111111111111", RoleName: "ReadOnly"}})
+	b, _ := store.CreatePermissionSet(ctx, "b-uid", "b", []v1alpha1.AccountRoleBinding{{Account: "222222222222", RoleName: "ReadOnly"}})
+	_ = store.DeletePermissionSet(ctx, a)
+
+	// An orphan which is not marked yet is orphaned since now, and finding
+	// it does not mark it.
+	orphans, _ := store.Orphans(ctx, first)
+	want := []storetypes.Orphan{{Label: storetypes.OrphanAccount, Cloud: v1alpha1.CloudAWS, ID: "111111111111", Since: first}}
+	if diff := cmp.Diff(want, orphans); diff != "" {
+		t.Errorf("Orphans(...): -want, +got:\n%s", diff)
+	}
+	orphans, _ = store.Orphans(ctx, second)
+	want[0].Since = second
+	if diff := cmp.Diff(want, orphans); diff != "" {
+		t.Errorf("Orphans(...): want an unmarked orphan orphaned since now: -want, +got:\n%s", diff)
+	}
+
+	// Referencing an orphan again adopts it, and an orphan stays orphaned
+	// since it was first marked.
+	_ = store.MarkOrphans(ctx, first)
+	_, _ = store.UpdatePermissionSet(ctx, b, "b", []v1alpha1.AccountRoleBinding{{Account: "111111111111", RoleName: "ReadOnly"}})
+	_ = store.MarkOrphans(ctx, first)
+	stale, _ := store.Orphans(ctx, first)
+	_ = store.MarkOrphans(ctx, second)
+	orphans, _ = store.Orphans(ctx, second)
+	want = []storetypes.Orphan{{Label: storetypes.OrphanAccount, Cloud: v1alpha1.CloudAWS, ID: "222222222222", Since: first}}
+	if diff := cmp.Diff(want, orphans); diff != "" {
+		t.Errorf("Orphans(...): -want, +got:\n%s", diff)
+	}
+
+	// Orphans which were adopted since they were found are not collected.
+	collected, _ := store.Collect(ctx, append(stale, storetypes.Orphan{Label: storetypes.OrphanAccount, Cloud: v1alpha1.CloudAWS, ID: "111111111111", Since: first}))
+	if diff := cmp.Diff(want, collected); diff != "" {
+		t.Errorf("Collect(...): -want, +got:\n%s", diff)
+	}
+	if orphans, _ := store.Orphans(ctx, second); len(orphans) != 0 {
+		t.Errorf("Orphans(...): want none once collected, got %v", orphans)
+	}
+
+	// Accounts and roles managed by an Account or Role are never orphaned.
+	_, _ = store.CreateAccount(ctx, "c-uid", &v1alpha1.AccountParameters{ID: "333333333333"})
+	_, _ = store.CreateRole(ctx, "d-uid", &v1alpha1.RoleParameters{Name: "Administrator"})
+	if orphans, _ := store.Orphans(ctx, second); len(orphans) != 0 {
+		t.Errorf("Orphans(...): want none for a managed account or role, got %v", orphans)
+	}
+}
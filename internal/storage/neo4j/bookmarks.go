@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"sync"
+	"time"
+)
+
+// bookmarkTTL is how long the bookmark of a managed resource is kept. It
+// only needs to outlive the time the slowest member of a cluster takes to
+// catch up with a write.
+const bookmarkTTL = 10 * time.Minute
+
+type bookmark struct {
+	value string
+	at    time.Time
+}
+
+// bookmarks remembers the bookmark of the last transaction of each managed
+// resource. A session which starts from it waits until the server it reads
+// from has caught up with that transaction, so that a managed resource
+// observes what it created or updated even when reading from a follower.
+type bookmarks struct {
+	mu   sync.Mutex
+	last map[string]bookmark
+}
+
+// get returns the bookmarks a session of the managed resource with the
+// supplied UID starts from.
+func (b *bookmarks) get(uid string) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	bm, ok := b.last[uid]
+	if !ok || time.Since(bm.at) > bookmarkTTL {
+		return nil
+	}
+
+	return []string{bm.value}
+}
+
+// set records the bookmark of the last transaction of the managed resource
+// with the supplied UID, and forgets the bookmarks which have expired.
+func (b *bookmarks) set(uid, value string) {
+	if value == "" {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.last == nil {
+		b.last = map[string]bookmark{}
+	}
+
+	now := time.Now()
+	for k, bm := range b.last {
+		if now.Sub(bm.at) > bookmarkTTL {
+			delete(b.last, k)
+		}
+	}
+	b.last[uid] = bookmark{value: value, at: now}
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"errors"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j/db"
+
+	storetypes "github.com/VariableExp0rt/powerbroker/internal/storage/types"
+)
+
+// noRecords is the message of the usage error Single returns when a query
+// matched nothing, which the driver does not otherwise distinguish.
+const noRecords = "Result contains no more records"
+
+// classify translates an error returned by the driver into one of the error
+// types of the storage package. Errors it cannot classify are returned as is.
+func classify(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var (
+		nerr *db.Neo4jError
+		uerr *neo4j.UsageError
+		cerr *neo4j.ConnectivityError
+		lerr *neo4j.TransactionExecutionLimit
+		terr *neo4j.TokenExpiredError
+		perr *db.ProtocolError
+	)
+	switch {
+	case errors.As(err, &nerr):
+		return classifyCode(nerr)
+	case errors.As(err, &uerr) && uerr.Message == noRecords:
+		return &storetypes.EntityNotFoundError{}
+	case errors.As(err, &terr):
+		return &storetypes.AuthError{Err: err}
+	case errors.As(err, &cerr), errors.As(err, &lerr), errors.As(err, &perr):
+		return &storetypes.UnavailableError{Err: err}
+	}
+
+	return err
+}
+
+// classifyCode classifies an error returned by the server by its status
+// code, e.g. Neo.ClientError.Schema.ConstraintViolation. See
+// https://neo4j.com/docs/status-codes/current/
+func classifyCode(err *db.Neo4jError) error {
+	switch {
+	case err.Code == "Neo.ClientError.Schema.ConstraintViolation":
+		return &storetypes.ConflictError{Err: err}
+	case err.IsRetriableTransient(), err.IsRetriableCluster():
+		return &storetypes.TransientError{Err: err}
+	case err.Classification() == "TransientError", err.Classification() == "DatabaseError":
+		return &storetypes.UnavailableError{Err: err}
+	case err.Category() == "Security":
+		return &storetypes.AuthError{Err: err}
+	case err.Category() == "Schema", err.Code == "Neo.ClientError.Procedure.ProcedureNotFound":
+		return &storetypes.SchemaError{Err: err}
+	}
+
+	return err
+}
@@ -66,3 +66,69 @@ func (_m *MockDriver) Target() url.URL {
 func (_m *MockDriver) Close() error {
 	return _m.MockClose()
 }
+
+type MockTransaction struct {
+	MockRun      func(cypher string, params map[string]interface{}) (neo4j.Result, error)
+	MockCommit   func() error
+	MockRollback func() error
+	MockClose    func() error
+}
+
+func (_m *MockTransaction) Run(cypher string, params map[string]interface{}) (neo4j.Result, error) {
+	return _m.MockRun(cypher, params)
+}
+
+func (_m *MockTransaction) Commit() error {
+	return _m.MockCommit()
+}
+
+func (_m *MockTransaction) Rollback() error {
+	return _m.MockRollback()
+}
+
+func (_m *MockTransaction) Close() error {
+	return _m.MockClose()
+}
+
+type MockResult struct {
+	MockKeys       func() ([]string, error)
+	MockNext       func() bool
+	MockNextRecord func(record **neo4j.Record) bool
+	MockErr        func() error
+	MockRecord     func() *neo4j.Record
+	MockCollect    func() ([]*neo4j.Record, error)
+	MockSingle     func() (*neo4j.Record, error)
+	MockConsume    func() (neo4j.ResultSummary, error)
+}
+
+func (_m *MockResult) Keys() ([]string, error) {
+	return _m.MockKeys()
+}
+
+func (_m *MockResult) Next() bool {
+	return _m.MockNext()
+}
+
+func (_m *MockResult) NextRecord(record **neo4j.Record) bool {
+	return _m.MockNextRecord(record)
+}
+
+func (_m *MockResult) Err() error {
+	return _m.MockErr()
+}
+
+func (_m *MockResult) Record() *neo4j.Record {
+	return _m.MockRecord()
+}
+
+func (_m *MockResult) Collect() ([]*neo4j.Record, error) {
+	return _m.MockCollect()
+}
+
+func (_m *MockResult) Single() (*neo4j.Record, error) {
+	return _m.MockSingle()
+}
+
+func (_m *MockResult) Consume() (neo4j.ResultSummary, error) {
+	return _m.MockConsume()
+}
@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"github.com/pkg/errors"
+
+	"github.com/VariableExp0rt/powerbroker/internal/storage/neo4j/transaction"
+)
+
+// A Migration changes the schema of the graph from the version before it to
+// its own. Its statements must be safe to run again, since a migration which
+// fails part way is retried from its first statement.
+type Migration struct {
+	Version     int
+	Description string
+	Statements  []string
+}
+
+// Migrations are applied in order to bring the graph up to the schema the
+// provider expects. A new migration is appended with the next version; an
+// applied one is never changed.
+var Migrations = []Migration{
+	{
+		Version:     1,
+		Description: "uniqueness constraints on the identity of every node",
+		Statements: []string{
+			"CREATE CONSTRAINT user_uuid IF NOT EXISTS FOR (n:User) REQUIRE n.uuid IS UNIQUE",
+			"CREATE CONSTRAINT persona_uuid IF NOT EXISTS FOR (n:Persona) REQUIRE n.uuid IS UNIQUE",
+			"CREATE CONSTRAINT permissionset_uuid IF NOT EXISTS FOR (n:PermissionSet) REQUIRE n.uuid IS UNIQUE",
+			"CREATE CONSTRAINT team_uuid IF NOT EXISTS FOR (n:Team) REQUIRE n.uuid IS UNIQUE",
+			"CREATE CONSTRAINT account_id IF NOT EXISTS FOR (n:Account) REQUIRE n.id IS UNIQUE",
+			"CREATE CONSTRAINT role_name IF NOT EXISTS FOR (n:Role) REQUIRE n.name IS UNIQUE",
+		},
+	},
+	{
+		Version:     2,
+		Description: "uniqueness constraints on the managed resource UID of every node",
+		Statements: []string{
+			"CREATE CONSTRAINT user_uid IF NOT EXISTS FOR (n:User) REQUIRE n.uid IS UNIQUE",
+			"CREATE CONSTRAINT persona_uid IF NOT EXISTS FOR (n:Persona) REQUIRE n.uid IS UNIQUE",
+			"CREATE CONSTRAINT permissionset_uid IF NOT EXISTS FOR (n:PermissionSet) REQUIRE n.uid IS UNIQUE",
+			"CREATE CONSTRAINT team_uid IF NOT EXISTS FOR (n:Team) REQUIRE n.uid IS UNIQUE",
+		},
+	},
+	{
+		Version:     3,
+		Description: "uniqueness constraint on the managed resource UID of accounts",
+		Statements: []string{
+			"CREATE CONSTRAINT account_uid IF NOT EXISTS FOR (n:Account) REQUIRE n.uid IS UNIQUE",
+		},
+	},
+	{
+		Version:     4,
+		Description: "uniqueness constraint on the managed resource UID of roles",
+		Statements: []string{
+			"CREATE CONSTRAINT role_uid IF NOT EXISTS FOR (n:Role) REQUIRE n.uid IS UNIQUE",
+		},
+	},
+	{
+		Version:     5,
+		Description: "key the delegations of permission sets by their binding",
+		Statements: []string{
+			// A permission set had a single binding before, so its only
+			// delegations are paired.
+			`MATCH (p:PermissionSet)-[to:DELEGATES_ACCESS_TO]->(ac:Account), (p)-[w:DELEGATES_ACCESS_WITH]->(r:Role)
+			WHERE to.binding IS NULL AND w.binding IS NULL
+			SET to.binding = ac.id + '/' + r.name, w.binding = ac.id + '/' + r.name`,
+		},
+	},
+	{
+		Version:     6,
+		Description: "label accounts with their cloud",
+		Statements: []string{
+			// Every binding was to an aws account before.
+			"MATCH (ac:Account) WHERE ac.cloud IS NULL SET ac.cloud = 'aws'",
+			"CREATE INDEX account_cloud IF NOT EXISTS FOR (n:Account) ON (n.cloud)",
+		},
+	},
+	{
+		Version:     7,
+		Description: "key accounts and roles on their cloud",
+		Statements: []string{
+			"DROP CONSTRAINT account_id IF EXISTS",
+			"DROP CONSTRAINT role_name IF EXISTS",
+			// Every role was bound in aws before, but a role was shared by
+			// the bindings of every cloud, so those in another cloud are
+			// moved to a role of their own.
+			"MATCH (r:Role) WHERE r.cloud IS NULL SET r.cloud = 'aws'",
+			`MATCH (p:PermissionSet)-[to:DELEGATES_ACCESS_TO]->(ac:Account), (p)-[w:DELEGATES_ACCESS_WITH {binding: to.binding}]->(r:Role)
+			WHERE ac.cloud <> r.cloud
+			MERGE (c:Role {cloud: ac.cloud, name: r.name})
+			MERGE (p)-[:DELEGATES_ACCESS_WITH {binding: w.binding}]->(c)
+			DELETE w`,
+			// Binding keys are prefixed with the cloud of their account.
+			`MATCH (p:PermissionSet)-[to:DELEGATES_ACCESS_TO]->(ac:Account)
+			WHERE NOT to.binding STARTS WITH ac.cloud + ':'
+			WITH p, to, ac, to.binding AS old
+			OPTIONAL MATCH (p)-[w:DELEGATES_ACCESS_WITH {binding: old}]->(:Role)
+			SET to.binding = ac.cloud + ':' + old
+			FOREACH (x IN CASE WHEN w IS NULL THEN [] ELSE [w] END | SET x.binding = ac.cloud + ':' + old)`,
+			`MATCH (ac:Account)
+			FOREACH (_ IN CASE WHEN ac.cloud = 'aws' THEN [1] ELSE [] END | SET ac:AWS)
+			FOREACH (_ IN CASE WHEN ac.cloud = 'gcp' THEN [1] ELSE [] END | SET ac:GCP)
+			FOREACH (_ IN CASE WHEN ac.cloud = 'azure' THEN [1] ELSE [] END | SET ac:Azure)
+			FOREACH (_ IN CASE WHEN ac.cloud = 'kubernetes' THEN [1] ELSE [] END | SET ac:Kubernetes)`,
+			// Community edition only constrains a single property to be
+			// unique, so accounts and roles are keyed on their cloud and id
+			// or name together.
+			"MATCH (ac:Account) SET ac.key = ac.cloud + ':' + ac.id",
+			"MATCH (r:Role) SET r.key = r.cloud + ':' + r.name",
+			"CREATE CONSTRAINT account_key IF NOT EXISTS FOR (n:Account) REQUIRE n.key IS UNIQUE",
+			"CREATE CONSTRAINT role_key IF NOT EXISTS FOR (n:Role) REQUIRE n.key IS UNIQUE",
+		},
+	},
+}
+
+// SchemaVersion returns the version of the latest migration.
+func SchemaVersion() int {
+	return Migrations[len(Migrations)-1].Version
+}
+
+// Migrate applies every migration newer than the schema version recorded in
+// the graph, recording the version of each once it is applied. It returns
+// the schema version of the graph, which is that of the last migration
+// applied if one fails.
+func (db *Neo4jDB) Migrate(ctx context.Context) (int, error) {
+	out, err := db.read(ctx, transaction.SchemaVersionTxFunc())
+	if err != nil {
+		return 0, errors.Wrap(err, "cannot get schema version")
+	}
+	v, _ := out.(*neo4j.Record).Get("version")
+	version, _ := v.(int64)
+
+	for _, m := range Migrations {
+		if int64(m.Version) <= version {
+			continue
+		}
+
+		for _, s := range m.Statements {
+			if _, err := db.write(ctx, transaction.SchemaTxFunc(s)); err != nil {
+				return int(version), errors.Wrapf(err, "cannot apply schema version %d: %s", m.Version, m.Description)
+			}
+		}
+
+		if _, err := db.write(ctx, transaction.SetSchemaVersionTxFunc(m.Version)); err != nil {
+			return int(version), errors.Wrapf(err, "cannot record schema version %d", m.Version)
+		}
+		version = int64(m.Version)
+	}
+
+	return int(version), nil
+}
@@ -1,8 +1,9 @@
 package storage
 
 import (
+	"context"
 	"fmt"
-	"strings"
+	"time"
 
 	"github.com/VariableExp0rt/powerbroker/apis/powerbroker/v1alpha1"
 	"github.com/VariableExp0rt/powerbroker/internal/service/types"
@@ -14,41 +15,39 @@ import (
 
 type Neo4jDB struct {
 	Driver neo4j.Driver
-}
 
-func (db *Neo4jDB) CreateUser(userName string, personaRefs []string) (string, error) {
-	session := db.Driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
-	defer session.Close()
+	// IDs generates the uuids of new nodes. Uuids are generated in Go
+	// when it is nil.
+	IDs transaction.IDStrategy
 
-	var out interface{}
-	var uuid string
-	var err error
+	// Database is the name of the database the graph is stored in. The
+	// default database of the server is used when it is empty.
+	Database string
 
-	if out, err = session.WriteTransaction(transaction.AddUserTxFunc(userName)); err != nil {
-		return "", err
-	}
-
-	record := out.(*neo4j.Record)
+	bookmarks bookmarks
+}
 
-	uuid, ok := record.Values[0].(string)
-	if !ok {
-		return "", errors.New("no user was created")
-	}
+// Close closes the underlying driver and every connection it pooled.
+func (db *Neo4jDB) Close() error {
+	return db.Driver.Close()
+}
 
-	if _, err = session.WriteTransaction(transaction.AddUserPersonaRelationTxFunc(uuid, personaRefs)); err != nil {
-		return "", err
-	}
+func (db *Neo4jDB) CreateUser(ctx context.Context, uid, userName string, personaRefs []string) (string, error) {
+	return db.create(ctx, transaction.CreateUser(db.ids(), uid, userName, personaRefs))
+}
 
-	return uuid, nil
+func (db *Neo4jDB) LookupUser(ctx context.Context, uid string) (string, error) {
+	return db.lookup(ctx, "User", uid)
 }
 
-func (db *Neo4jDB) GetUser(userUuid string) (*types.GetUserResponse, error) {
-	session := db.Driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
-	defer session.Close()
+func (db *Neo4jDB) LookupUserByName(ctx context.Context, name string) (string, error) {
+	return db.lookupByName(ctx, "User", name)
+}
 
-	out, err := session.ReadTransaction(transaction.GetUserTxFunc(userUuid))
+func (db *Neo4jDB) GetUser(ctx context.Context, userUuid string) (*types.GetUserResponse, error) {
+	out, err := db.read(ctx, transaction.GetUserTxFunc(userUuid))
 	if err != nil {
-		if strings.Contains(err.Error(), "Result contains no more records") {
+		if storetypes.IsEntityNotFoundNeo4jErr(err) {
 			return &types.GetUserResponse{
 				NodeID:     userUuid,
 				Status:     storetypes.StatusDeleted,
@@ -74,23 +73,11 @@ func (db *Neo4jDB) GetUser(userUuid string) (*types.GetUserResponse, error) {
 			&storetypes.EntityNotFoundError{}
 	case *neo4j.Record:
 		record := out.(*neo4j.Record)
-		references := record.Values[0].([]interface{})
-
-		personaSlc := make([]string, len(references))
-		for i, v := range references {
-			personaSlc[i] = fmt.Sprint(v)
-		}
-
-		if len(personaSlc) == 0 {
-			return &types.GetUserResponse{
-					NodeID:     userUuid,
-					Status:     storetypes.StatusDeleted,
-					References: nil,
-				},
-				&storetypes.EntityNotFoundError{}
-		}
+		references, _ := record.Get("personaRefs")
+		personaSlc := toStrings(references)
 
 		return &types.GetUserResponse{
+				Name:       toString(record, "name"),
 				NodeID:     userUuid,
 				Status:     storetypes.StatusAvailable,
 				References: personaSlc,
@@ -102,64 +89,51 @@ func (db *Neo4jDB) GetUser(userUuid string) (*types.GetUserResponse, error) {
 		References: nil,
 		NodeID:     userUuid,
 		Status:     storetypes.StatusAvailable,
-	}, &transaction.InternalError{Message: "internal server error"}
+	}, &storetypes.InternalError{}
 }
 
-func (db *Neo4jDB) UpdateUser(userName string, userUuid string, personaRefs []string) error {
-	session := db.Driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
-	defer session.Close()
-
-	if _, err := session.WriteTransaction(transaction.UpdateUserTxFunc(userName, personaRefs)); err != nil {
-		return err
-	}
-
-	return nil
+func (db *Neo4jDB) UpdateUser(ctx context.Context, userName string, userUuid string, personaRefs []string) (types.Changes, error) {
+	return db.update(ctx, transaction.UpdateUserTxFunc(userUuid, userName, personaRefs))
 }
 
-func (db *Neo4jDB) DeleteUser(userUuid string) error {
-	session := db.Driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
-	defer session.Close()
-
-	if _, err := session.WriteTransaction(transaction.DeleteUserTxFunc(userUuid)); err != nil {
+func (db *Neo4jDB) DeleteUser(ctx context.Context, userUuid string) error {
+	if _, err := db.write(ctx, transaction.DeleteUserTxFunc(userUuid)); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (db *Neo4jDB) CreatePersona(personaName string, permissionSetRefs []string) (string, error) {
-	session := db.Driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
-	defer session.Close()
-
-	var out interface{}
-	var uuid string
-	var err error
-
-	if out, err = session.WriteTransaction(transaction.AddPersonaTxFunc(personaName)); err != nil {
-		return "", err
+func (db *Neo4jDB) GetEffectiveAccess(ctx context.Context, userUuid string) ([]types.Access, error) {
+	records, err := db.read(ctx, transaction.GetEffectiveAccessTxFunc(userUuid))
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get effective access")
 	}
 
-	record := out.(*neo4j.Record)
-
-	uuid, ok := record.Values[0].(string)
-	if !ok {
-		return "", errors.New("no persona was created")
+	out := []types.Access{}
+	for _, r := range records.([]*neo4j.Record) {
+		out = append(out, toAccess(r))
 	}
 
-	if _, err := session.WriteTransaction(transaction.AddPersonaPermissionSetRelationTxFunc(uuid, permissionSetRefs)); err != nil {
-		return "", err
-	}
+	return out, nil
+}
 
-	return uuid, nil
+func (db *Neo4jDB) CreatePersona(ctx context.Context, uid, personaName string, permissionSetRefs []string) (string, error) {
+	return db.create(ctx, transaction.CreatePersona(db.ids(), uid, personaName, permissionSetRefs))
 }
 
-func (db *Neo4jDB) GetPersona(uuid string) (*types.GetPersonaResponse, error) {
-	session := db.Driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
-	defer session.Close()
+func (db *Neo4jDB) LookupPersona(ctx context.Context, uid string) (string, error) {
+	return db.lookup(ctx, "Persona", uid)
+}
 
-	out, err := session.ReadTransaction(transaction.GetPersonaTxFunc(uuid))
+func (db *Neo4jDB) LookupPersonaByName(ctx context.Context, name string) (string, error) {
+	return db.lookupByName(ctx, "Persona", name)
+}
+
+func (db *Neo4jDB) GetPersona(ctx context.Context, uuid string) (*types.GetPersonaResponse, error) {
+	out, err := db.read(ctx, transaction.GetPersonaTxFunc(uuid))
 	if err != nil {
-		if strings.Contains(err.Error(), "Result contains no more records") {
+		if storetypes.IsEntityNotFoundNeo4jErr(err) {
 			return &types.GetPersonaResponse{
 				NodeID:     uuid,
 				Status:     storetypes.StatusDeleted,
@@ -180,10 +154,9 @@ func (db *Neo4jDB) GetPersona(uuid string) (*types.GetPersonaResponse, error) {
 	// for this...
 	// https://stackoverflow.com/questions/44027826/convert-interface-to-string-in-golang
 
-	// When collect() is used, it will not error on collect(elements_length_zero) as x
-	// so 'x' is returned as an empty slice, this means we need to check the returned
-	// []iface{} once converted into []string, and if len == 0, deletion request was
-	// successful
+	// The persona is matched before its permission sets are optionally
+	// collected, so a persona without any is returned as an empty slice,
+	// whereas a missing persona returns no record at all.
 	switch out.(type) {
 	case nil:
 		return &types.GetPersonaResponse{
@@ -194,23 +167,11 @@ func (db *Neo4jDB) GetPersona(uuid string) (*types.GetPersonaResponse, error) {
 			&storetypes.EntityNotFoundError{}
 	case *neo4j.Record:
 		record := out.(*neo4j.Record)
-		permissionSets, _ := record.Values[0].([]interface{})
-
-		permissionSetSlc := make([]string, len(permissionSets))
-		for i, v := range permissionSets {
-			permissionSetSlc[i] = fmt.Sprint(v)
-		}
-
-		if len(permissionSetSlc) == 0 {
-			return &types.GetPersonaResponse{
-					NodeID:     uuid,
-					Status:     storetypes.StatusDeleted,
-					References: nil,
-				},
-				&storetypes.EntityNotFoundError{}
-		}
+		permissionSets, _ := record.Get("permissionSetRefs")
+		permissionSetSlc := toStrings(permissionSets)
 
 		return &types.GetPersonaResponse{
+			Name:       toString(record, "name"),
 			NodeID:     uuid,
 			Status:     storetypes.StatusAvailable,
 			References: permissionSetSlc,
@@ -222,79 +183,40 @@ func (db *Neo4jDB) GetPersona(uuid string) (*types.GetPersonaResponse, error) {
 			Status:     storetypes.StatusUnavailable,
 			References: nil,
 		},
-		&transaction.InternalError{Message: "internal server error"}
+		&storetypes.InternalError{}
 
 }
 
-func (db *Neo4jDB) UpdatePersona(personaName string, personaUuid string, permissionSetUuids []string) error {
-	session := db.Driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
-	defer session.Close()
-
-	_, err := session.WriteTransaction(transaction.UpdatePersonaTxFunc(personaName, permissionSetUuids))
-
-	return err
+func (db *Neo4jDB) UpdatePersona(ctx context.Context, personaName string, personaUuid string, permissionSetUuids []string) (types.Changes, error) {
+	return db.update(ctx, transaction.UpdatePersonaTxFunc(personaUuid, personaName, permissionSetUuids))
 }
 
-func (db *Neo4jDB) DeletePersona(personaUuid string) error {
-	session := db.Driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
-	defer session.Close()
-
-	_, err := session.WriteTransaction(transaction.DeletePersonaTxFunc(personaUuid))
+func (db *Neo4jDB) DeletePersona(ctx context.Context, personaUuid string) error {
+	_, err := db.write(ctx, transaction.DeletePersonaTxFunc(personaUuid))
 
 	return err
 }
 
-func (db *Neo4jDB) CreatePermissionSet(name string, binding v1alpha1.AccountRoleBinding) (string, error) {
-	session := db.Driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
-	defer session.Close()
-
-	var out interface{}
-	var uuid string
-	var err error
-
-	if _, err = session.WriteTransaction(transaction.AddAccountTxFunc(binding.Account, binding.Alias, binding.AccountClass)); err != nil {
-		return "", err
-	}
-
-	if _, err = session.WriteTransaction(transaction.AddRoleTxFunc(binding.RoleName)); err != nil {
-		return "", err
-	}
-
-	if out, err = session.WriteTransaction(transaction.AddPermissionSetTxFunc(name)); err != nil {
-		return "", err
-	}
-
-	record := out.(*neo4j.Record)
-
-	uuid, ok := record.Values[0].(string)
-	if !ok {
-		return "", errors.New("no permissionset was created")
-	}
-
-	if _, err := session.WriteTransaction(transaction.AddPermissionSetAccountRoleRelationTxFunc(uuid, binding.Account, binding.RoleName)); err != nil {
-		return "", err
-	}
-
-	return uuid, nil
+func (db *Neo4jDB) CreatePermissionSet(ctx context.Context, uid, name string, bindings []v1alpha1.AccountRoleBinding) (string, error) {
+	return db.create(ctx, transaction.CreatePermissionSet(db.ids(), uid, name, bindingParams(bindings)))
 }
 
-func (db *Neo4jDB) GetPermissionSet(uuid string) (*types.GetPermissionSetResponse, error) {
-	session := db.Driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
-	defer session.Close()
+func (db *Neo4jDB) LookupPermissionSet(ctx context.Context, uid string) (string, error) {
+	return db.lookup(ctx, "PermissionSet", uid)
+}
 
-	out, err := session.ReadTransaction(transaction.GetPermissionSetTxFunc(uuid))
+func (db *Neo4jDB) GetPermissionSet(ctx context.Context, uuid string) (*types.GetPermissionSetResponse, error) {
+	out, err := db.read(ctx, transaction.GetPermissionSetTxFunc(uuid))
 	if err != nil {
-		if strings.Contains(err.Error(), "Result contains no more records") {
+		if storetypes.IsEntityNotFoundNeo4jErr(err) {
 			return &types.GetPermissionSetResponse{
-				Binding: v1alpha1.AccountRoleBinding{},
-				Status:  storetypes.StatusDeleted,
-				NodeID:  uuid,
+				Status: storetypes.StatusDeleted,
+				NodeID: uuid,
 			}, &storetypes.EntityNotFoundError{}
 		}
 		return &types.GetPermissionSetResponse{
-			Binding: v1alpha1.AccountRoleBinding{},
-			Status:  storetypes.StatusUnavailable,
-			NodeID:  uuid,
+			Status: storetypes.StatusUnavailable,
+			NodeID: uuid,
 		}, err
 	}
 
@@ -310,56 +232,35 @@ func (db *Neo4jDB) GetPermissionSet(uuid string) (*types.GetPermissionSetRespons
 	switch out.(type) {
 	case nil:
 		return &types.GetPermissionSetResponse{
-				Binding: v1alpha1.AccountRoleBinding{},
-				Status:  storetypes.StatusDeleted,
-				NodeID:  uuid,
+				Status: storetypes.StatusDeleted,
+				NodeID: uuid,
 			},
 			&storetypes.EntityNotFoundError{}
 	case *neo4j.Record:
 		record := out.(*neo4j.Record)
+		bindings, _ := record.Get("bindings")
 		return &types.GetPermissionSetResponse{
-				Binding: v1alpha1.AccountRoleBinding{
-					Account:      record.Values[0].(string),
-					Alias:        record.Values[1].(string),
-					AccountClass: record.Values[2].(string),
-					RoleName:     record.Values[3].(string),
-				},
-				Status: storetypes.StatusAvailable,
-				NodeID: uuid,
+				Name:     toString(record, "name"),
+				Bindings: toBindings(bindings),
+				Status:   storetypes.StatusAvailable,
+				NodeID:   uuid,
 			},
 			nil
 	}
 
 	return &types.GetPermissionSetResponse{
-			Binding: v1alpha1.AccountRoleBinding{},
-			Status:  storetypes.StatusUnavailable,
-			NodeID:  uuid,
+			Status: storetypes.StatusUnavailable,
+			NodeID: uuid,
 		},
-		&transaction.InternalError{Message: "internal server error"}
+		&storetypes.InternalError{}
 }
 
-func (db *Neo4jDB) UpdatePermissionSet(permissionSetUuid, crName string, binding v1alpha1.AccountRoleBinding) error {
-	session := db.Driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
-	defer session.Close()
-
-	_, err := session.WriteTransaction(transaction.UpdatePermissionSetTxFunc(permissionSetUuid,
-		crName,
-		binding.Account,
-		binding.Alias,
-		binding.AccountClass,
-		binding.RoleName))
-	if err != nil {
-		return err
-	}
-
-	return nil
+func (db *Neo4jDB) UpdatePermissionSet(ctx context.Context, permissionSetUuid, crName string, bindings []v1alpha1.AccountRoleBinding) (types.Changes, error) {
+	return db.update(ctx, transaction.UpdatePermissionSetTxFunc(permissionSetUuid, crName, bindingParams(bindings)))
 }
 
-func (db *Neo4jDB) DeletePermissionSet(permissionSetUuid string) error {
-	session := db.Driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
-	defer session.Close()
-
-	_, err := session.WriteTransaction(transaction.DeletePermissionSetTxFunc(permissionSetUuid))
+func (db *Neo4jDB) DeletePermissionSet(ctx context.Context, permissionSetUuid string) error {
+	_, err := db.write(ctx, transaction.DeletePermissionSetTxFunc(permissionSetUuid))
 	if err != nil {
 		return err
 	}
@@ -367,47 +268,21 @@ func (db *Neo4jDB) DeletePermissionSet(permissionSetUuid string) error {
 	return nil
 }
 
-func (db *Neo4jDB) CreateTeam(teamparams *v1alpha1.TeamParameters) (string, error) {
-	session := db.Driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
-	defer session.Close()
-
-	var out interface{}
-	var uuid string
-	var err error
-
-	if out, err = session.WriteTransaction(transaction.AddTeamTxFunc(teamparams.Name)); err != nil {
-		return "", err
-	}
-
-	record := out.(*neo4j.Record)
-
-	uuid, ok := record.Values[0].(string)
-	if !ok {
-		return "", errors.New("no team was created")
-	}
-
-	if _, err = session.WriteTransaction(transaction.AddTeamManagedByRelationTxFunc(uuid, teamparams.ManagedBy.User)); err != nil {
-		return "", err
-	}
-
-	if _, err = session.WriteTransaction(transaction.AddTeamMemberRelationTxFunc(uuid, teamparams.Members)); err != nil {
-		return "", err
-	}
-
-	if _, err = session.WriteTransaction(transaction.AddTeamPersonaRelationTxFunc(uuid, teamparams.Personas)); err != nil {
-		return "", err
-	}
-
-	return uuid, nil
+func (db *Neo4jDB) CreateTeam(ctx context.Context, uid string, teamparams *v1alpha1.TeamParameters) (string, error) {
+	return db.create(ctx, transaction.CreateTeam(db.ids(), uid, teamparams.Name,
+		teamparams.ManagedBy.User,
+		teamparams.Members,
+		teamparams.Personas))
 }
 
-func (db *Neo4jDB) GetTeam(uuid string) (*types.GetTeamResponse, error) {
-	session := db.Driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
-	defer session.Close()
+func (db *Neo4jDB) LookupTeam(ctx context.Context, uid string) (string, error) {
+	return db.lookup(ctx, "Team", uid)
+}
 
-	out, err := session.ReadTransaction(transaction.GetPersonaTxFunc(uuid))
+func (db *Neo4jDB) GetTeam(ctx context.Context, uuid string) (*types.GetTeamResponse, error) {
+	out, err := db.read(ctx, transaction.GetTeamTxFunc(uuid))
 	if err != nil {
-		if strings.Contains(err.Error(), "Result contains no more records") {
+		if storetypes.IsEntityNotFoundNeo4jErr(err) {
 			return &types.GetTeamResponse{
 				NodeID:    uuid,
 				Status:    storetypes.StatusDeleted,
@@ -432,10 +307,9 @@ func (db *Neo4jDB) GetTeam(uuid string) (*types.GetTeamResponse, error) {
 	// for this...
 	// https://stackoverflow.com/questions/44027826/convert-interface-to-string-in-golang
 
-	// When collect() is used, it will not error on collect(elements_length_zero) as x
-	// so 'x' is returned as an empty slice, this means we need to check the returned
-	// []iface{} once converted into []string, and if len == 0, deletion request was
-	// successful
+	// Every relationship of a team is optional, so a team without any
+	// is returned with empty slices and no manager, whereas a missing
+	// team returns no record at all.
 	switch out.(type) {
 	case nil:
 		return &types.GetTeamResponse{
@@ -448,32 +322,16 @@ func (db *Neo4jDB) GetTeam(uuid string) (*types.GetTeamResponse, error) {
 			&storetypes.EntityNotFoundError{}
 	case *neo4j.Record:
 		record := out.(*neo4j.Record)
-		members, _ := record.Values[0].([]interface{})
-		managedBy, _ := record.Values[1].(string)
-		personas, _ := record.Values[2].([]interface{})
+		members, _ := record.Get("members")
+		manager, _ := record.Get("manager")
+		personas, _ := record.Get("personas")
 
-		memberSlc := make([]string, len(members))
-		for i, v := range members {
-			memberSlc[i] = fmt.Sprint(v)
-		}
-
-		personaSlc := make([]string, len(personas))
-		for i, v := range personas {
-			personaSlc[i] = fmt.Sprint(v)
-		}
-
-		if len(memberSlc) == 0 && len(personaSlc) == 0 && managedBy == "" {
-			return &types.GetTeamResponse{
-					NodeID:    uuid,
-					Status:    storetypes.StatusDeleted,
-					ManagedBy: "",
-					Members:   nil,
-					Personas:  nil,
-				},
-				&storetypes.EntityNotFoundError{}
-		}
+		managedBy, _ := manager.(string)
+		memberSlc := toStrings(members)
+		personaSlc := toStrings(personas)
 
 		return &types.GetTeamResponse{
+			Name:      toString(record, "name"),
 			NodeID:    uuid,
 			Status:    storetypes.StatusAvailable,
 			ManagedBy: managedBy,
@@ -489,30 +347,563 @@ func (db *Neo4jDB) GetTeam(uuid string) (*types.GetTeamResponse, error) {
 			Members:   nil,
 			Personas:  nil,
 		},
-		&transaction.InternalError{Message: "internal server error"}
+		&storetypes.InternalError{}
 }
 
-func (db *Neo4jDB) UpdateTeam(uuid string, teamparams *v1alpha1.TeamParameters) error {
-	session := db.Driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
-	defer session.Close()
-
-	if _, err := session.WriteTransaction(transaction.UpdateTeamTxFunc(uuid,
+func (db *Neo4jDB) UpdateTeam(ctx context.Context, uuid string, teamparams *v1alpha1.TeamParameters) (types.Changes, error) {
+	return db.update(ctx, transaction.UpdateTeamTxFunc(uuid,
+		teamparams.Name,
 		teamparams.ManagedBy.User,
 		teamparams.Members,
-		teamparams.Personas)); err != nil {
+		teamparams.Personas))
+}
+
+func (db *Neo4jDB) DeleteTeam(ctx context.Context, uuid string) error {
+	if _, err := db.write(ctx, transaction.DeleteTeamTxFunc(uuid)); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (db *Neo4jDB) DeleteTeam(uuid string) error {
-	session := db.Driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
-	defer session.Close()
+// CreateAccount has the Account with the supplied uid manage the account
+// with its cloud and id, adding the account if it does not exist yet. It
+// returns the id, which is the external name of the Account, or a
+// ConflictError if another Account already manages the account.
+func (db *Neo4jDB) CreateAccount(ctx context.Context, uid string, account *v1alpha1.AccountParameters) (string, error) {
+	return db.manage(ctx, "account", uid, transaction.ManageAccountTxFunc(uid,
+		types.DefaultCloud(account.Cloud),
+		account.ID,
+		account.Alias,
+		account.Class,
+		account.Owner,
+		account.Environment,
+		account.Lifecycle))
+}
 
-	if _, err := session.WriteTransaction(transaction.DeleteTeamTxFunc(uuid)); err != nil {
-		return err
+func (db *Neo4jDB) LookupAccount(ctx context.Context, uid string) (string, error) {
+	return db.lookupManaged(ctx, transaction.LookupAccountTxFunc(uid))
+}
+
+func (db *Neo4jDB) GetAccount(ctx context.Context, cloud, accountID string) (*types.GetAccountResponse, error) {
+	out, err := db.read(ctx, transaction.GetAccountTxFunc(cloud, accountID))
+	if err != nil {
+		if storetypes.IsEntityNotFoundNeo4jErr(err) {
+			return &types.GetAccountResponse{
+				NodeID: accountID,
+				Status: storetypes.StatusDeleted,
+			}, &storetypes.EntityNotFoundError{}
+		}
+		return &types.GetAccountResponse{
+			NodeID: accountID,
+			Status: storetypes.StatusUnavailable,
+		}, err
+	}
+
+	record, ok := out.(*neo4j.Record)
+	if !ok {
+		return &types.GetAccountResponse{
+			NodeID: accountID,
+			Status: storetypes.StatusDeleted,
+		}, &storetypes.EntityNotFoundError{}
+	}
+
+	return &types.GetAccountResponse{
+		Account: v1alpha1.AccountParameters{
+			Cloud:       cloud,
+			ID:          toString(record, "id"),
+			Alias:       toString(record, "alias"),
+			Class:       toString(record, "class"),
+			Owner:       toString(record, "owner"),
+			Environment: toString(record, "environment"),
+			Lifecycle:   toString(record, "lifecycle"),
+		},
+		NodeID: accountID,
+		Status: storetypes.StatusAvailable,
+	}, nil
+}
+
+func (db *Neo4jDB) UpdateAccount(ctx context.Context, cloud, accountID string, account *v1alpha1.AccountParameters) error {
+	_, err := db.write(ctx, transaction.UpdateAccountTxFunc(cloud,
+		accountID,
+		account.Alias,
+		account.Class,
+		account.Owner,
+		account.Environment,
+		account.Lifecycle))
+
+	return err
+}
+
+func (db *Neo4jDB) DeleteAccount(ctx context.Context, cloud, accountID string) error {
+	_, err := db.write(ctx, transaction.DeleteAccountTxFunc(cloud, accountID))
+
+	return err
+}
+
+func (db *Neo4jDB) WhoCanAccess(ctx context.Context, cloud, accountID, roleName string) ([]types.Grant, error) {
+	records, err := db.read(ctx, transaction.WhoCanAccessTxFunc(cloud, accountID, roleName))
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot find who can access account")
+	}
+
+	out := []types.Grant{}
+	for _, r := range records.([]*neo4j.Record) {
+		out = append(out, types.Grant{Access: toAccess(r), User: toString(r, "user"), UserName: toString(r, "userName")})
+	}
+
+	return out, nil
+}
+
+// CreateRole has the Role with the supplied uid manage the role with its
+// cloud and name, adding the role if it does not exist yet. It returns the
+// name, which is the external name of the Role, or a ConflictError if
+// another Role already manages the role.
+func (db *Neo4jDB) CreateRole(ctx context.Context, uid string, role *v1alpha1.RoleParameters) (string, error) {
+	return db.manage(ctx, "role", uid, transaction.ManageRoleTxFunc(uid,
+		types.DefaultCloud(role.Cloud),
+		role.Name,
+		role.Description,
+		role.Tier,
+		role.PolicyDocument,
+		storetypes.FormatDuration(role.MaxSessionDuration)))
+}
+
+func (db *Neo4jDB) LookupRole(ctx context.Context, uid string) (string, error) {
+	return db.lookupManaged(ctx, transaction.LookupRoleTxFunc(uid))
+}
+
+func (db *Neo4jDB) GetRole(ctx context.Context, cloud, roleName string) (*types.GetRoleResponse, error) {
+	out, err := db.read(ctx, transaction.GetRoleTxFunc(cloud, roleName))
+	if err != nil {
+		if storetypes.IsEntityNotFoundNeo4jErr(err) {
+			return &types.GetRoleResponse{
+				NodeID: roleName,
+				Status: storetypes.StatusDeleted,
+			}, &storetypes.EntityNotFoundError{}
+		}
+		return &types.GetRoleResponse{
+			NodeID: roleName,
+			Status: storetypes.StatusUnavailable,
+		}, err
+	}
+
+	record, ok := out.(*neo4j.Record)
+	if !ok {
+		return &types.GetRoleResponse{
+			NodeID: roleName,
+			Status: storetypes.StatusDeleted,
+		}, &storetypes.EntityNotFoundError{}
+	}
+
+	return &types.GetRoleResponse{
+		Role: v1alpha1.RoleParameters{
+			Cloud:              cloud,
+			Name:               toString(record, "name"),
+			Description:        toString(record, "description"),
+			Tier:               toString(record, "tier"),
+			PolicyDocument:     toString(record, "policyDocument"),
+			MaxSessionDuration: storetypes.ParseDuration(toString(record, "maxSessionDuration")),
+		},
+		NodeID: roleName,
+		Status: storetypes.StatusAvailable,
+	}, nil
+}
+
+func (db *Neo4jDB) UpdateRole(ctx context.Context, cloud, roleName string, role *v1alpha1.RoleParameters) error {
+	_, err := db.write(ctx, transaction.UpdateRoleTxFunc(cloud,
+		roleName,
+		role.Description,
+		role.Tier,
+		role.PolicyDocument,
+		storetypes.FormatDuration(role.MaxSessionDuration)))
+
+	return err
+}
+
+func (db *Neo4jDB) DeleteRole(ctx context.Context, cloud, roleName string) error {
+	_, err := db.write(ctx, transaction.DeleteRoleTxFunc(cloud, roleName))
+
+	return err
+}
+
+// MissingCapabilities returns the functions required by the IDStrategy
+// which the database does not have.
+func (db *Neo4jDB) MissingCapabilities(ctx context.Context) ([]string, error) {
+	required := db.ids().Requires()
+	if len(required) == 0 {
+		return nil, nil
+	}
+
+	out, err := db.read(ctx, transaction.MissingFunctionsTxFunc(required))
+	if err != nil {
+		return nil, err
+	}
+
+	missing, _ := out.(*neo4j.Record).Get("missing")
+
+	return toStrings(missing), nil
+}
+
+// MarkOrphans marks every account and role which no permission set
+// delegates access to as orphaned since now, unless it already is, and
+// unmarks those which are referenced again. Each kind of orphan is marked in
+// a transaction of its own.
+func (db *Neo4jDB) MarkOrphans(ctx context.Context, now time.Time) error {
+	for _, kind := range transaction.OrphanKinds {
+		if _, err := db.write(ctx, transaction.MarkOrphansTxFunc(kind, now.Unix())); err != nil {
+			return errors.Wrapf(err, "cannot mark orphaned %s nodes", kind.Label)
+		}
 	}
 
 	return nil
 }
+
+// Orphans returns every account and role which no permission set delegates
+// access to. Each kind of orphan is found in a transaction of its own.
+func (db *Neo4jDB) Orphans(ctx context.Context, now time.Time) ([]storetypes.Orphan, error) {
+	out := []storetypes.Orphan{}
+	for _, kind := range transaction.OrphanKinds {
+		records, err := db.read(ctx, transaction.OrphansTxFunc(kind, now.Unix()))
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot find orphaned %s nodes", kind.Label)
+		}
+
+		for _, r := range records.([]*neo4j.Record) {
+			since, _ := r.Get("since")
+			seconds, _ := since.(int64)
+			out = append(out, storetypes.Orphan{Label: kind.Label, Cloud: toString(r, "cloud"), ID: toString(r, "id"), Since: time.Unix(seconds, 0)})
+		}
+	}
+
+	return out, nil
+}
+
+// Collect deletes the supplied orphans which are still unreferenced, in a
+// transaction for each kind of orphan.
+func (db *Neo4jDB) Collect(ctx context.Context, orphans []storetypes.Orphan) ([]storetypes.Orphan, error) {
+	out := []storetypes.Orphan{}
+	for _, kind := range transaction.OrphanKinds {
+		byID := map[string]storetypes.Orphan{}
+		ids := []map[string]interface{}{}
+		for _, o := range orphans {
+			if o.Label == kind.Label {
+				byID[o.Cloud+"/"+o.ID] = o
+				ids = append(ids, map[string]interface{}{"cloud": o.Cloud, "id": o.ID})
+			}
+		}
+		if len(ids) == 0 {
+			continue
+		}
+
+		record, err := db.write(ctx, transaction.CollectTxFunc(kind, ids))
+		if err != nil {
+			return out, errors.Wrapf(err, "cannot delete orphaned %s nodes", kind.Label)
+		}
+
+		deleted, _ := record.(*neo4j.Record).Get("deleted")
+		values, _ := deleted.([]interface{})
+		for _, v := range values {
+			m, _ := v.(map[string]interface{})
+			cloud, _ := m["cloud"].(string)
+			id, _ := m["id"].(string)
+			out = append(out, byID[cloud+"/"+id])
+		}
+	}
+
+	return out, nil
+}
+
+// ExplainAccess returns every distinct path which grants the user access to
+// the account with the role in the cloud. Accounts and roles are named by
+// their id and name.
+func (db *Neo4jDB) ExplainAccess(ctx context.Context, userUuid, cloud, accountID, roleName string) ([]types.Path, error) {
+	records, err := db.read(ctx, transaction.ExplainAccessTxFunc(userUuid, cloud, accountID, roleName))
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot explain access")
+	}
+
+	out := []types.Path{}
+	for _, r := range records.([]*neo4j.Record) {
+		hop := func(label, key string) types.Hop {
+			return types.Hop{Label: label, ID: toString(r, key), Name: toString(r, key+"Name"), UID: toString(r, key+"Uid")}
+		}
+
+		p := types.Path{Target: toTarget(r), Hops: []types.Hop{hop(v1alpha1.UserKind, "user")}}
+		if toString(r, "team") != "" {
+			p.Hops = append(p.Hops, hop(v1alpha1.TeamKind, "team"))
+		}
+		p.Hops = append(p.Hops,
+			hop(v1alpha1.PersonaKind, "persona"),
+			hop(v1alpha1.PermissionSetKind, "permissionSet"),
+			types.Hop{Label: v1alpha1.AccountKind, ID: p.Account, Name: p.Account, UID: toString(r, "accountUid")},
+			types.Hop{Label: v1alpha1.RoleKind, ID: p.Role, Name: p.Role, UID: toString(r, "roleUid")},
+		)
+		out = append(out, p)
+	}
+
+	return out, nil
+}
+
+func (db *Neo4jDB) ids() transaction.IDStrategy {
+	if db.IDs == nil {
+		return transaction.GoIDs{}
+	}
+
+	return db.IDs
+}
+
+// update runs one of the Update*TxFuncs in a write transaction bounded by
+// ctx and returns the relationships it added and removed.
+func (db *Neo4jDB) update(ctx context.Context, work neo4j.TransactionWork) (types.Changes, error) {
+	out, err := db.write(ctx, work)
+	if err != nil {
+		return types.Changes{}, err
+	}
+
+	record, ok := out.(*neo4j.Record)
+	if !ok {
+		return types.Changes{}, nil
+	}
+
+	added, _ := record.Get("added")
+	removed, _ := record.Get("removed")
+
+	return types.Changes{Added: toRelationships(added), Removed: toRelationships(removed)}, nil
+}
+
+// read runs work in a read transaction bounded by ctx.
+func (db *Neo4jDB) read(ctx context.Context, work neo4j.TransactionWork) (interface{}, error) {
+	return db.run(ctx, neo4j.AccessModeRead, work)
+}
+
+// write runs work in a write transaction bounded by ctx.
+func (db *Neo4jDB) write(ctx context.Context, work neo4j.TransactionWork) (interface{}, error) {
+	return db.run(ctx, neo4j.AccessModeWrite, work)
+}
+
+// run runs work in a transaction of its own session. The deadline of ctx
+// becomes the timeout of the transaction, so that Neo4j terminates it once
+// the caller has given up on it. run returns as soon as ctx is done, leaving
+// the session to be closed once the transaction returns. Errors returned by
+// the driver are classified into the error types of the storage package.
+//
+// If ctx carries the UID of a managed resource, the session starts from the
+// bookmark of the last transaction run for that resource, so that it reads
+// what the resource last wrote.
+func (db *Neo4jDB) run(ctx context.Context, mode neo4j.AccessMode, work neo4j.TransactionWork) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var configurers []func(*neo4j.TransactionConfig)
+	if deadline, ok := ctx.Deadline(); ok {
+		configurers = append(configurers, neo4j.WithTxTimeout(time.Until(deadline)))
+	}
+
+	type result struct {
+		out interface{}
+		err error
+	}
+	done := make(chan result, 1)
+
+	config := neo4j.SessionConfig{AccessMode: mode, DatabaseName: db.Database}
+	uid, causal := storetypes.ResourceFrom(ctx)
+	if causal {
+		config.Bookmarks = db.bookmarks.get(uid)
+	}
+
+	go func() {
+		session := db.Driver.NewSession(config)
+		defer session.Close()
+
+		var r result
+		if mode == neo4j.AccessModeRead {
+			r.out, r.err = session.ReadTransaction(work, configurers...)
+		} else {
+			r.out, r.err = session.WriteTransaction(work, configurers...)
+		}
+		if causal && r.err == nil {
+			db.bookmarks.set(uid, session.LastBookmark())
+		}
+		done <- r
+	}()
+
+	select {
+	case r := <-done:
+		return r.out, classify(r.err)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// create runs one of the composite create transactions and returns the uuid
+// of the entity it created. Creates MERGE on the unique uid of the entity,
+// so a create which raced another for the same uid violates its constraint,
+// and is retried once to return the entity the other one created.
+func (db *Neo4jDB) create(ctx context.Context, work neo4j.TransactionWork) (string, error) {
+	out, err := db.write(ctx, work)
+	if storetypes.IsConflictError(err) {
+		out, err = db.write(ctx, work)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return out.(string), nil
+}
+
+// manage runs ManageAccountTxFunc or ManageRoleTxFunc and returns the key of
+// the node it merged, or a ConflictError if a managed resource other than
+// the one with the supplied uid owns it.
+func (db *Neo4jDB) manage(ctx context.Context, entity, uid string, work neo4j.TransactionWork) (string, error) {
+	out, err := db.write(ctx, work)
+	if err != nil {
+		return "", err
+	}
+
+	record := out.(*neo4j.Record)
+	if owner := toString(record, "uid"); owner != uid {
+		return "", &storetypes.ConflictError{Err: errors.Errorf("%s %s is managed by %s", entity, toString(record, "id"), owner)}
+	}
+
+	return toString(record, "id"), nil
+}
+
+// lookupManaged runs LookupAccountTxFunc or LookupRoleTxFunc and returns the
+// key of the node it found.
+func (db *Neo4jDB) lookupManaged(ctx context.Context, work neo4j.TransactionWork) (string, error) {
+	out, err := db.read(ctx, work)
+	if err != nil {
+		return "", err
+	}
+
+	return toString(out.(*neo4j.Record), "id"), nil
+}
+
+// lookup returns the uuid of the node with the supplied label owned by uid.
+func (db *Neo4jDB) lookup(ctx context.Context, label, uid string) (string, error) {
+	out, err := db.read(ctx, transaction.LookupTxFunc(label, uid))
+	if err != nil {
+		return "", err
+	}
+
+	uuid, _ := out.(*neo4j.Record).Values[0].(string)
+
+	return uuid, nil
+}
+
+// lookupByName returns the uuid of the only node of label with the supplied
+// name.
+func (db *Neo4jDB) lookupByName(ctx context.Context, label, name string) (string, error) {
+	out, err := db.read(ctx, transaction.LookupByNameTxFunc(label, name))
+	if err != nil {
+		return "", err
+	}
+
+	uuids, _ := out.(*neo4j.Record).Get("uuids")
+
+	return storetypes.Unique(toStrings(uuids))
+}
+
+// toString returns the string value of key in record, or an empty string
+// if the value is null or missing.
+func toString(record *neo4j.Record, key string) string {
+	v, _ := record.Get(key)
+	s, _ := v.(string)
+
+	return s
+}
+
+// toAccess returns the access on a path returned by GetEffectiveAccessTxFunc
+// or WhoCanAccessTxFunc.
+func toAccess(record *neo4j.Record) types.Access {
+	return types.Access{
+		Target:        toTarget(record),
+		PermissionSet: toString(record, "permissionSet"),
+		Persona:       toString(record, "persona"),
+		Team:          toString(record, "team"),
+	}
+}
+
+// toTarget returns the target at the end of a path returned by a query for
+// access. An account created out of band may have no cloud, and is then an
+// aws account.
+func toTarget(record *neo4j.Record) types.Target {
+	t := types.Target{Cloud: toString(record, "cloud"), Account: toString(record, "account"), Role: toString(record, "role"), Scope: toString(record, "scope")}
+	if t.Cloud == "" {
+		t.Cloud = v1alpha1.CloudAWS
+	}
+
+	return t
+}
+
+// toStrings converts a list returned by collect(), which the driver decodes
+// as []interface{}, into a slice of strings.
+func toStrings(v interface{}) []string {
+	values, _ := v.([]interface{})
+
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = fmt.Sprint(v)
+	}
+
+	return out
+}
+
+// bindingParams converts bindings into the maps UpdatePermissionSetTxFunc
+// takes, once each.
+func bindingParams(bindings []v1alpha1.AccountRoleBinding) []map[string]interface{} {
+	bindings = types.UniqueBindings(bindings)
+
+	out := make([]map[string]interface{}, 0, len(bindings))
+	for _, b := range bindings {
+		t := types.TargetOf(b)
+		out = append(out, map[string]interface{}{
+			"key":     types.BindingKey(b),
+			"cloud":   t.Cloud,
+			"account": t.Account,
+			"alias":   b.Alias,
+			"class":   b.AccountClass,
+			"role":    t.Role,
+			"scope":   t.Scope,
+		})
+	}
+
+	return out
+}
+
+// toBindings converts a list of {cloud, id, scope, alias, class, roleName}
+// maps returned by GetPermissionSetTxFunc into bindings. Missing values, such
+// as the alias of an account created out of band, are empty, and an account
+// without a cloud is an aws account.
+func toBindings(v interface{}) []v1alpha1.AccountRoleBinding {
+	values, _ := v.([]interface{})
+
+	out := make([]v1alpha1.AccountRoleBinding, 0, len(values))
+	for _, v := range values {
+		m, _ := v.(map[string]interface{})
+		str := func(key string) string {
+			s, _ := m[key].(string)
+			return s
+		}
+		t := types.Target{Cloud: str("cloud"), Account: str("id"), Role: str("roleName"), Scope: str("scope")}
+		out = append(out, t.Binding(str("alias"), str("class")))
+	}
+
+	return types.UniqueBindings(out)
+}
+
+// toRelationships converts a list of {type, node} maps returned by a query
+// into relationships.
+func toRelationships(v interface{}) []types.Relationship {
+	values, _ := v.([]interface{})
+
+	var out []types.Relationship
+	for _, v := range values {
+		m, _ := v.(map[string]interface{})
+		out = append(out, types.Relationship{Type: fmt.Sprint(m["type"]), Node: fmt.Sprint(m["node"])})
+	}
+
+	return out
+}
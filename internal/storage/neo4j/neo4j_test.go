@@ -0,0 +1,885 @@
+package storage_test
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j/db"
+	"github.com/pkg/errors"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	"github.com/VariableExp0rt/powerbroker/apis/powerbroker/v1alpha1"
+	"github.com/VariableExp0rt/powerbroker/internal/service"
+	"github.com/VariableExp0rt/powerbroker/internal/service/types"
+	"github.com/VariableExp0rt/powerbroker/internal/storage/conformance"
+	neo4jstore "github.com/VariableExp0rt/powerbroker/internal/storage/neo4j"
+	"github.com/VariableExp0rt/powerbroker/internal/storage/neo4j/fake"
+	"github.com/VariableExp0rt/powerbroker/internal/storage/neo4j/transaction"
+	storetypes "github.com/VariableExp0rt/powerbroker/internal/storage/types"
+)
+
+var _ service.Repository = &neo4jstore.Neo4jDB{}
+
+// TestConformance runs the conformance suite against the Neo4j database at
+// NEO4J_URI, generating uuids in Go so that no plugin need be installed. It
+// is skipped when NEO4J_URI is not set.
+func TestConformance(t *testing.T) {
+	uri := os.Getenv("NEO4J_URI")
+	if uri == "" {
+		t.Skip("NEO4J_URI is not set")
+	}
+
+	driver, err := neo4j.NewDriver(uri, neo4j.BasicAuth(os.Getenv("NEO4J_USERNAME"), os.Getenv("NEO4J_PASSWORD"), ""))
+	if err != nil {
+		t.Fatalf("NewDriver(...): %v", err)
+	}
+	t.Cleanup(func() { _ = driver.Close() })
+
+	if err := driver.VerifyConnectivity(); err != nil {
+		t.Fatalf("VerifyConnectivity(): %v", err)
+	}
+
+	if _, err := (&neo4jstore.Neo4jDB{Driver: driver}).Migrate(context.Background()); err != nil {
+		t.Fatalf("Migrate(): %v", err)
+	}
+
+	conformance.Run(t, func(t *testing.T) service.Repository {
+		return &neo4jstore.Neo4jDB{Driver: driver}
+	})
+}
+
+// recorder is a driver whose sessions run transaction work against a fake
+// transaction, failing the query numbered failAt (counting from one).
+type recorder struct {
+	failAt       int
+	transactions int
+	queries      int
+}
+
+func (r *recorder) driver() neo4j.Driver {
+	tx := &fake.MockTransaction{
+		MockRun: func(_ string, _ map[string]interface{}) (neo4j.Result, error) {
+			r.queries++
+			if r.queries == r.failAt {
+				return nil, errBoom
+			}
+			return &fake.MockResult{
+				MockSingle: func() (*neo4j.Record, error) {
+					return &neo4j.Record{Keys: []string{"uuid"}, Values: []interface{}{"cool-uuid"}}, nil
+				},
+				MockConsume: func() (neo4j.ResultSummary, error) { return nil, nil },
+			}, nil
+		},
+	}
+
+	session := fake.MockSession{
+		MockWriteTransaction: func(work neo4j.TransactionWork, _ ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+			r.transactions++
+			return work(tx)
+		},
+		MockClose: func() error { return nil },
+	}
+
+	return &fake.MockDriver{
+		MockNewSession: func(neo4j.SessionConfig) neo4j.Session { return session },
+	}
+}
+
+var errBoom = errors.New("boom")
+
+func TestCreateIsOneTransaction(t *testing.T) {
+	bindings := []v1alpha1.AccountRoleBinding{{Account: "123456789012", RoleName: "ReadOnly"}}
+	team := &v1alpha1.TeamParameters{Name: "koopa-troop", Members: []string{"bowser"}}
+
+	creates := map[string]struct {
+		create  func(*neo4jstore.Neo4jDB) (string, error)
+		queries int
+	}{
+		"User": {
+			create: func(db *neo4jstore.Neo4jDB) (string, error) {
+				return db.CreateUser(context.Background(), "uid", "mario", []string{"p"})
+			},
+			queries: 2,
+		},
+		"Persona": {
+			create: func(db *neo4jstore.Neo4jDB) (string, error) {
+				return db.CreatePersona(context.Background(), "uid", "auditor", []string{"ps"})
+			},
+			queries: 2,
+		},
+		"PermissionSet": {
+			create: func(db *neo4jstore.Neo4jDB) (string, error) {
+				return db.CreatePermissionSet(context.Background(), "uid", "readonly", bindings)
+			},
+			queries: 2,
+		},
+		"Team": {
+			create:  func(db *neo4jstore.Neo4jDB) (string, error) { return db.CreateTeam(context.Background(), "uid", team) },
+			queries: 4,
+		},
+	}
+
+	for name, tc := range creates {
+		t.Run(name, func(t *testing.T) {
+			// Every query succeeds, then each one fails in turn. The
+			// whole create must run as a single transaction either way,
+			// so that the driver rolls all of it back on failure.
+			for failAt := 0; failAt <= tc.queries; failAt++ {
+				r := &recorder{failAt: failAt}
+
+				uuid, err := tc.create(&neo4jstore.Neo4jDB{Driver: r.driver()})
+
+				want, wantErr := "cool-uuid", error(nil)
+				if failAt > 0 {
+					want, wantErr = "", errBoom
+				}
+				if diff := cmp.Diff(wantErr, err, test.EquateErrors()); diff != "" {
+					t.Errorf("failAt %d: -want error, +got error:\n%s", failAt, diff)
+				}
+				if uuid != want {
+					t.Errorf("failAt %d: want uuid %q, got %q", failAt, want, uuid)
+				}
+				if r.transactions != 1 {
+					t.Errorf("failAt %d: want 1 transaction, got %d", failAt, r.transactions)
+				}
+				if failAt == 0 && r.queries != tc.queries {
+					t.Errorf("want %d queries, got %d", tc.queries, r.queries)
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateReturnsChanges(t *testing.T) {
+	var params map[string]interface{}
+	tx := &fake.MockTransaction{
+		MockRun: func(_ string, p map[string]interface{}) (neo4j.Result, error) {
+			params = p
+			return &fake.MockResult{
+				MockSingle: func() (*neo4j.Record, error) {
+					return &neo4j.Record{
+						Keys: []string{"uuid", "added", "removed"},
+						Values: []interface{}{
+							"cool-uuid",
+							[]interface{}{map[string]interface{}{"type": "MANAGED_BY", "node": "wario"}},
+							[]interface{}{
+								map[string]interface{}{"type": "MANAGED_BY", "node": "bowser"},
+								map[string]interface{}{"type": "MEMBER_OF", "node": "wario"},
+							},
+						},
+					}, nil
+				},
+			}, nil
+		},
+	}
+	store := &neo4jstore.Neo4jDB{
+		Driver: &fake.MockDriver{MockNewSession: func(neo4j.SessionConfig) neo4j.Session {
+			return fake.MockSession{
+				MockWriteTransaction: func(work neo4j.TransactionWork, _ ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+					return work(tx)
+				},
+				MockLastBookmark: func() string { return "" },
+				MockClose:        func() error { return nil },
+			}
+		}},
+	}
+
+	changes, err := store.UpdateTeam(context.Background(), "cool-uuid", &v1alpha1.TeamParameters{
+		Name:      "koopa-troop",
+		ManagedBy: v1alpha1.ManagedByParameters{User: "wario"},
+	})
+	if err != nil {
+		t.Fatalf("UpdateTeam(...): %v", err)
+	}
+
+	want := types.Changes{
+		Added: []types.Relationship{{Type: types.RelationManagedBy, Node: "wario"}},
+		Removed: []types.Relationship{
+			{Type: types.RelationManagedBy, Node: "bowser"},
+			{Type: types.RelationMemberOf, Node: "wario"},
+		},
+	}
+	if diff := cmp.Diff(want, changes); diff != "" {
+		t.Errorf("UpdateTeam(...): -want, +got:\n%s", diff)
+	}
+
+	// Nothing is NOT IN null, so no references must be sent as an empty
+	// list for the stored ones to be removed.
+	for _, k := range []string{"memberRefs", "personaRefs"} {
+		if diff := cmp.Diff([]string{}, params[k]); diff != "" {
+			t.Errorf("UpdateTeam(...): %s: -want, +got:\n%s", k, diff)
+		}
+	}
+}
+
+func TestCollect(t *testing.T) {
+	now := time.Unix(2000, 0)
+	var deleting []interface{}
+	var marked []string
+	tx := &fake.MockTransaction{
+		MockRun: func(cypher string, p map[string]interface{}) (neo4j.Result, error) {
+			switch {
+			case strings.Contains(cypher, "REMOVE n.orphanedAt"), strings.Contains(cypher, "SET n.orphanedAt"):
+				marked = append(marked, cypher)
+				return &fake.MockResult{MockConsume: func() (neo4j.ResultSummary, error) { return nil, nil }}, nil
+			case strings.Contains(cypher, "DETACH DELETE"):
+				for _, o := range p["orphans"].([]map[string]interface{}) {
+					deleting = append(deleting, o)
+				}
+				return &fake.MockResult{MockSingle: func() (*neo4j.Record, error) {
+					return &neo4j.Record{Keys: []string{"deleted"}, Values: []interface{}{deleting}}, nil
+				}}, nil
+			}
+
+			// Every account is an orphan found earlier; no role is.
+			var records []*neo4j.Record
+			if strings.Contains(cypher, "n:Account") {
+				records = append(records, &neo4j.Record{Keys: []string{"cloud", "id", "since"}, Values: []interface{}{"aws", "111111111111", int64(1000)}})
+			}
+			return &fake.MockResult{MockCollect: func() ([]*neo4j.Record, error) { return records, nil }}, nil
+		},
+	}
+	store := &neo4jstore.Neo4jDB{
+		Driver: &fake.MockDriver{MockNewSession: func(neo4j.SessionConfig) neo4j.Session {
+			return fake.MockSession{
+				MockReadTransaction: func(work neo4j.TransactionWork, _ ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+					return work(tx)
+				},
+				MockWriteTransaction: func(work neo4j.TransactionWork, _ ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+					return work(tx)
+				},
+				MockLastBookmark: func() string { return "" },
+				MockClose:        func() error { return nil },
+			}
+		}},
+	}
+
+	// Finding orphans never marks them, so that a dry run does not change
+	// the graph.
+	orphans, err := store.Orphans(context.Background(), now)
+	if err != nil {
+		t.Fatalf("Orphans(...): %v", err)
+	}
+	if len(marked) != 0 {
+		t.Errorf("Orphans(...): want no orphans marked, got %d statements", len(marked))
+	}
+	if err := store.MarkOrphans(context.Background(), now); err != nil {
+		t.Fatalf("MarkOrphans(...): %v", err)
+	}
+	if got := len(marked); got != 2*len(transaction.OrphanKinds) {
+		t.Errorf("MarkOrphans(...): want %d statements, got %d", 2*len(transaction.OrphanKinds), got)
+	}
+	want := []storetypes.Orphan{{Label: storetypes.OrphanAccount, Cloud: v1alpha1.CloudAWS, ID: "111111111111", Since: time.Unix(1000, 0)}}
+	if diff := cmp.Diff(want, orphans); diff != "" {
+		t.Errorf("Orphans(...): -want, +got:\n%s", diff)
+	}
+
+	collected, err := store.Collect(context.Background(), orphans)
+	if err != nil {
+		t.Fatalf("Collect(...): %v", err)
+	}
+	if diff := cmp.Diff(want, collected); diff != "" {
+		t.Errorf("Collect(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestGetPermissionSetWithNulls(t *testing.T) {
+	tx := &fake.MockTransaction{
+		MockRun: func(string, map[string]interface{}) (neo4j.Result, error) {
+			return &fake.MockResult{
+				MockSingle: func() (*neo4j.Record, error) {
+					return &neo4j.Record{
+						Keys: []string{"name", "bindings"},
+						Values: []interface{}{"admin", []interface{}{
+							map[string]interface{}{"id": "123456789012", "alias": nil, "class": nil, "roleName": "ReadOnly"},
+						}},
+					}, nil
+				},
+			}, nil
+		},
+	}
+	store := &neo4jstore.Neo4jDB{
+		Driver: &fake.MockDriver{MockNewSession: func(neo4j.SessionConfig) neo4j.Session {
+			return fake.MockSession{
+				MockReadTransaction: func(work neo4j.TransactionWork, _ ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+					return work(tx)
+				},
+				MockLastBookmark: func() string { return "" },
+				MockClose:        func() error { return nil },
+			}
+		}},
+	}
+
+	got, err := store.GetPermissionSet(context.Background(), "cool-uuid")
+	if err != nil {
+		t.Fatalf("GetPermissionSet(...): %v", err)
+	}
+
+	// An account created out of band may have no alias, class or cloud,
+	// and is then an aws account.
+	want := &types.GetPermissionSetResponse{
+		Name:     "admin",
+		Bindings: []v1alpha1.AccountRoleBinding{{Cloud: v1alpha1.CloudAWS, Account: "123456789012", RoleName: "ReadOnly"}},
+		Status:   storetypes.StatusAvailable,
+		NodeID:   "cool-uuid",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("GetPermissionSet(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestExplainAccess(t *testing.T) {
+	keys := []string{
+		"user", "userName", "userUid",
+		"team", "teamName", "teamUid",
+		"persona", "personaName", "personaUid",
+		"permissionSet", "permissionSetName", "permissionSetUid",
+		"cloud", "account", "accountUid", "scope",
+		"role", "roleUid",
+	}
+	records := []*neo4j.Record{
+		{Keys: keys, Values: []interface{}{
+			"u-1", "mario", "uid-mario",
+			nil, nil, nil,
+			"pe-1", "auditor", "uid-auditor",
+			"ps-1", "readonly", "uid-readonly",
+			nil, "123456789012", nil, nil,
+			"ReadOnly", "uid-readonly-role",
+		}},
+		{Keys: keys, Values: []interface{}{
+			"u-1", "mario", "uid-mario",
+			"t-1", "plumbers", "uid-plumbers",
+			"pe-1", "auditor", "uid-auditor",
+			"ps-1", "readonly", "uid-readonly",
+			"aws", "123456789012", "uid-account", nil,
+			"ReadOnly", nil,
+		}},
+	}
+	tx := &fake.MockTransaction{
+		MockRun: func(string, map[string]interface{}) (neo4j.Result, error) {
+			return &fake.MockResult{MockCollect: func() ([]*neo4j.Record, error) { return records, nil }}, nil
+		},
+	}
+	store := &neo4jstore.Neo4jDB{
+		Driver: &fake.MockDriver{MockNewSession: func(neo4j.SessionConfig) neo4j.Session {
+			return fake.MockSession{
+				MockReadTransaction: func(work neo4j.TransactionWork, _ ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+					return work(tx)
+				},
+				MockLastBookmark: func() string { return "" },
+				MockClose:        func() error { return nil },
+			}
+		}},
+	}
+
+	got, err := store.ExplainAccess(context.Background(), "u-1", v1alpha1.CloudAWS, "123456789012", "ReadOnly")
+	if err != nil {
+		t.Fatalf("ExplainAccess(...): %v", err)
+	}
+
+	// A path through a persona granted to the user directly has no team.
+	target := types.Target{Cloud: v1alpha1.CloudAWS, Account: "123456789012", Role: "ReadOnly"}
+	user := types.Hop{Label: v1alpha1.UserKind, ID: "u-1", Name: "mario", UID: "uid-mario"}
+	persona := types.Hop{Label: v1alpha1.PersonaKind, ID: "pe-1", Name: "auditor", UID: "uid-auditor"}
+	ps := types.Hop{Label: v1alpha1.PermissionSetKind, ID: "ps-1", Name: "readonly", UID: "uid-readonly"}
+	want := []types.Path{
+		{Target: target, Hops: []types.Hop{
+			user, persona, ps,
+			{Label: v1alpha1.AccountKind, ID: "123456789012", Name: "123456789012"},
+			{Label: v1alpha1.RoleKind, ID: "ReadOnly", Name: "ReadOnly", UID: "uid-readonly-role"},
+		}},
+		{Target: target, Hops: []types.Hop{
+			user,
+			{Label: v1alpha1.TeamKind, ID: "t-1", Name: "plumbers", UID: "uid-plumbers"},
+			persona, ps,
+			{Label: v1alpha1.AccountKind, ID: "123456789012", Name: "123456789012", UID: "uid-account"},
+			{Label: v1alpha1.RoleKind, ID: "ReadOnly", Name: "ReadOnly"},
+		}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ExplainAccess(...): -want, +got:\n%s", diff)
+	}
+
+	wantPath := "User mario → Team plumbers → Persona auditor → PermissionSet readonly → Account 123456789012/Role ReadOnly"
+	if diff := cmp.Diff(wantPath, got[1].String()); diff != "" {
+		t.Errorf("String(): -want, +got:\n%s", diff)
+	}
+}
+
+func TestIDStrategy(t *testing.T) {
+	cases := map[string]struct {
+		ids      transaction.IDStrategy
+		expr     string
+		assigned bool
+	}{
+		"Default": {expr: "n.uuid = $id", assigned: true},
+		"Go":      {ids: transaction.GoIDs{}, expr: "n.uuid = $id", assigned: true},
+		"Native":  {ids: transaction.NativeIDs{}, expr: "n.uuid = randomUUID()"},
+		"APOC":    {ids: transaction.APOCIDs{}, expr: "n.uuid = apoc.create.uuid()"},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var query string
+			var params map[string]interface{}
+
+			tx := &fake.MockTransaction{
+				MockRun: func(cypher string, p map[string]interface{}) (neo4j.Result, error) {
+					// The user is added by the first query.
+					if query == "" {
+						query, params = cypher, p
+					}
+					return &fake.MockResult{
+						MockSingle: func() (*neo4j.Record, error) {
+							return &neo4j.Record{Keys: []string{"uuid"}, Values: []interface{}{"cool-uuid"}}, nil
+						},
+						MockConsume: func() (neo4j.ResultSummary, error) { return nil, nil },
+					}, nil
+				},
+			}
+			session := fake.MockSession{
+				MockWriteTransaction: func(work neo4j.TransactionWork, _ ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+					return work(tx)
+				},
+				MockClose: func() error { return nil },
+			}
+			db := &neo4jstore.Neo4jDB{
+				Driver: &fake.MockDriver{MockNewSession: func(neo4j.SessionConfig) neo4j.Session { return session }},
+				IDs:    tc.ids,
+			}
+
+			if _, err := db.CreateUser(context.Background(), "uid", "mario", nil); err != nil {
+				t.Fatalf("CreateUser(...): %v", err)
+			}
+			if !strings.Contains(query, tc.expr) {
+				t.Errorf("CreateUser(...): want query setting %q, got:\n%s", tc.expr, query)
+			}
+			if _, ok := params["id"].(string); ok != tc.assigned {
+				t.Errorf("CreateUser(...): want id parameter %t, got %v", tc.assigned, params)
+			}
+		})
+	}
+}
+
+func TestMissingCapabilities(t *testing.T) {
+	type want struct {
+		missing  []string
+		err      error
+		sessions int
+	}
+
+	cases := map[string]struct {
+		ids    transaction.IDStrategy
+		result *neo4j.Record
+		err    error
+		want   want
+	}{
+		"NothingRequired": {
+			ids:  transaction.GoIDs{},
+			want: want{},
+		},
+		"APOCMissing": {
+			ids:    transaction.APOCIDs{},
+			result: &neo4j.Record{Keys: []string{"missing"}, Values: []interface{}{[]interface{}{"apoc.create.uuid"}}},
+			want:   want{missing: []string{"apoc.create.uuid"}, sessions: 1},
+		},
+		"APOCInstalled": {
+			ids:    transaction.APOCIDs{},
+			result: &neo4j.Record{Keys: []string{"missing"}, Values: []interface{}{[]interface{}{}}},
+			want:   want{missing: []string{}, sessions: 1},
+		},
+		"CheckFailed": {
+			ids:  transaction.APOCIDs{},
+			err:  errBoom,
+			want: want{err: errBoom, sessions: 1},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			sessions := 0
+			session := fake.MockSession{
+				MockReadTransaction: func(neo4j.TransactionWork, ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+					return tc.result, tc.err
+				},
+				MockClose: func() error { return nil },
+			}
+			db := &neo4jstore.Neo4jDB{
+				Driver: &fake.MockDriver{MockNewSession: func(neo4j.SessionConfig) neo4j.Session {
+					sessions++
+					return session
+				}},
+				IDs: tc.ids,
+			}
+
+			missing, err := db.MissingCapabilities(context.Background())
+
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("MissingCapabilities(): -want error, +got error:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.missing, missing); diff != "" {
+				t.Errorf("MissingCapabilities(): -want, +got:\n%s", diff)
+			}
+			if sessions != tc.want.sessions {
+				t.Errorf("MissingCapabilities(): want %d sessions, got %d", tc.want.sessions, sessions)
+			}
+		})
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	statements := 0
+	for _, m := range neo4jstore.Migrations {
+		statements += len(m.Statements)
+	}
+
+	type want struct {
+		version  int
+		err      bool
+		versions []int
+		schema   int
+	}
+
+	cases := map[string]struct {
+		from   int64
+		failAt int
+		want   want
+	}{
+		"FromEmpty": {
+			want: want{version: neo4jstore.SchemaVersion(), versions: []int{1, 2, 3, 4, 5, 6, 7}, schema: statements},
+		},
+		"UpToDate": {
+			from: int64(neo4jstore.SchemaVersion()),
+			want: want{version: neo4jstore.SchemaVersion()},
+		},
+		"FromPrevious": {
+			from: 6,
+			want: want{version: 7, versions: []int{7}, schema: len(neo4jstore.Migrations[6].Statements)},
+		},
+		"FailedPartWay": {
+			// The first statement of the second migration fails.
+			failAt: len(neo4jstore.Migrations[0].Statements) + 2,
+			want:   want{version: 1, err: true, versions: []int{1}, schema: len(neo4jstore.Migrations[0].Statements)},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var versions []int
+			schema, writes := 0, 0
+
+			tx := &fake.MockTransaction{
+				MockRun: func(cypher string, params map[string]interface{}) (neo4j.Result, error) {
+					if v, ok := params["version"]; ok {
+						versions = append(versions, v.(int))
+					} else {
+						schema++
+					}
+					return &fake.MockResult{
+						MockConsume: func() (neo4j.ResultSummary, error) { return nil, nil },
+					}, nil
+				},
+			}
+			session := fake.MockSession{
+				MockReadTransaction: func(neo4j.TransactionWork, ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+					return &neo4j.Record{Keys: []string{"version"}, Values: []interface{}{tc.from}}, nil
+				},
+				MockWriteTransaction: func(work neo4j.TransactionWork, _ ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+					writes++
+					if writes == tc.failAt {
+						return nil, errBoom
+					}
+					return work(tx)
+				},
+				MockClose: func() error { return nil },
+			}
+			db := &neo4jstore.Neo4jDB{
+				Driver: &fake.MockDriver{MockNewSession: func(neo4j.SessionConfig) neo4j.Session { return session }},
+			}
+
+			version, err := db.Migrate(context.Background())
+
+			if (err != nil) != tc.want.err {
+				t.Errorf("Migrate(): want error %t, got %v", tc.want.err, err)
+			}
+			if version != tc.want.version {
+				t.Errorf("Migrate(): want version %d, got %d", tc.want.version, version)
+			}
+			if diff := cmp.Diff(tc.want.versions, versions); diff != "" {
+				t.Errorf("Migrate(): recorded versions: -want, +got:\n%s", diff)
+			}
+			if schema != tc.want.schema {
+				t.Errorf("Migrate(): want %d schema statements, got %d", tc.want.schema, schema)
+			}
+		})
+	}
+}
+
+func TestMigrationsAreOrdered(t *testing.T) {
+	for i, m := range neo4jstore.Migrations {
+		if m.Version != i+1 {
+			t.Errorf("Migrations[%d]: want version %d, got %d", i, i+1, m.Version)
+		}
+	}
+}
+
+func TestCreateRetriesConstraintViolation(t *testing.T) {
+	violation := &db.Neo4jError{Code: "Neo.ClientError.Schema.ConstraintViolation"}
+
+	transactions := 0
+	session := fake.MockSession{
+		MockWriteTransaction: func(neo4j.TransactionWork, ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+			transactions++
+			if transactions == 1 {
+				return nil, violation
+			}
+			return "cool-uuid", nil
+		},
+		MockClose: func() error { return nil },
+	}
+	store := &neo4jstore.Neo4jDB{
+		Driver: &fake.MockDriver{MockNewSession: func(neo4j.SessionConfig) neo4j.Session { return session }},
+	}
+
+	uuid, err := store.CreateUser(context.Background(), "uid", "mario", nil)
+	if err != nil {
+		t.Fatalf("CreateUser(...): %v", err)
+	}
+	if uuid != "cool-uuid" {
+		t.Errorf("CreateUser(...): want uuid %q, got %q", "cool-uuid", uuid)
+	}
+	if transactions != 2 {
+		t.Errorf("CreateUser(...): want 2 transactions, got %d", transactions)
+	}
+}
+
+func TestCreateAccountManagedByAnother(t *testing.T) {
+	transactions := 0
+	session := fake.MockSession{
+		MockWriteTransaction: func(neo4j.TransactionWork, ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+			transactions++
+			return &neo4j.Record{Keys: []string{"id", "uid"}, Values: []interface{}{"123456789012", "other-uid"}}, nil
+		},
+		MockClose: func() error { return nil },
+	}
+	store := &neo4jstore.Neo4jDB{
+		Driver: &fake.MockDriver{MockNewSession: func(neo4j.SessionConfig) neo4j.Session { return session }},
+	}
+
+	_, err := store.CreateAccount(context.Background(), "uid", &v1alpha1.AccountParameters{ID: "123456789012"})
+	if !storetypes.IsConflictError(err) {
+		t.Errorf("CreateAccount(...): want ConflictError, got %v", err)
+	}
+	if transactions != 1 {
+		t.Errorf("CreateAccount(...): want 1 transaction, got %d", transactions)
+	}
+}
+
+func TestErrorClassification(t *testing.T) {
+	cases := map[string]struct {
+		err  error
+		is   func(error) bool
+		want bool
+	}{
+		"NoRecordsIsNotFound": {
+			err:  &neo4j.UsageError{Message: "Result contains no more records"},
+			is:   storetypes.IsEntityNotFoundNeo4jErr,
+			want: true,
+		},
+		"OtherUsageErrorIsUnclassified": {
+			err:  &neo4j.UsageError{Message: "Session is closed"},
+			is:   storetypes.IsEntityNotFoundNeo4jErr,
+			want: false,
+		},
+		"ConstraintViolationIsConflict": {
+			err:  &db.Neo4jError{Code: "Neo.ClientError.Schema.ConstraintViolation"},
+			is:   storetypes.IsConflictError,
+			want: true,
+		},
+		"DeadlockIsTransient": {
+			err:  &db.Neo4jError{Code: "Neo.TransientError.Transaction.DeadlockDetected"},
+			is:   storetypes.IsTransientError,
+			want: true,
+		},
+		"NotALeaderIsTransient": {
+			err:  &db.Neo4jError{Code: "Neo.ClientError.Cluster.NotALeader"},
+			is:   storetypes.IsTransientError,
+			want: true,
+		},
+		"TerminatedIsUnavailable": {
+			err:  &db.Neo4jError{Code: "Neo.TransientError.Transaction.Terminated"},
+			is:   storetypes.IsUnavailableError,
+			want: true,
+		},
+		"DatabaseErrorIsUnavailable": {
+			err:  &db.Neo4jError{Code: "Neo.DatabaseError.General.UnknownError"},
+			is:   storetypes.IsUnavailableError,
+			want: true,
+		},
+		"ConnectivityErrorIsUnavailable": {
+			err:  &neo4j.ConnectivityError{},
+			is:   storetypes.IsUnavailableError,
+			want: true,
+		},
+		"UnauthorizedIsAuth": {
+			err:  &db.Neo4jError{Code: "Neo.ClientError.Security.Unauthorized"},
+			is:   storetypes.IsAuthError,
+			want: true,
+		},
+		"TokenExpiredIsAuth": {
+			err:  &neo4j.TokenExpiredError{Code: "Neo.ClientError.Security.TokenExpired"},
+			is:   storetypes.IsAuthError,
+			want: true,
+		},
+		"IndexNotFoundIsSchema": {
+			err:  &db.Neo4jError{Code: "Neo.ClientError.Schema.IndexNotFound"},
+			is:   storetypes.IsSchemaError,
+			want: true,
+		},
+		"SyntaxErrorIsNotRetryable": {
+			err:  &db.Neo4jError{Code: "Neo.ClientError.Statement.SyntaxError"},
+			is:   storetypes.IsRetryable,
+			want: false,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			session := fake.MockSession{
+				MockReadTransaction: func(neo4j.TransactionWork, ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+					return nil, tc.err
+				},
+				MockClose: func() error { return nil },
+			}
+			store := &neo4jstore.Neo4jDB{
+				Driver: &fake.MockDriver{MockNewSession: func(neo4j.SessionConfig) neo4j.Session { return session }},
+			}
+
+			_, err := store.LookupUser(context.Background(), "uid")
+			if got := tc.is(err); got != tc.want {
+				t.Errorf("LookupUser(...): classified %v as %v, want %v", err, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBookmarks(t *testing.T) {
+	var configs []neo4j.SessionConfig
+	session := fake.MockSession{
+		MockWriteTransaction: func(neo4j.TransactionWork, ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+			return "cool-uuid", nil
+		},
+		MockReadTransaction: func(neo4j.TransactionWork, ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+			return nil, errors.New("boom")
+		},
+		MockLastBookmark: func() string { return "bookmark-1" },
+		MockClose:        func() error { return nil },
+	}
+	store := &neo4jstore.Neo4jDB{
+		Driver: &fake.MockDriver{MockNewSession: func(c neo4j.SessionConfig) neo4j.Session {
+			configs = append(configs, c)
+			return session
+		}},
+	}
+
+	mario := storetypes.WithResource(context.Background(), "mario-uid")
+	luigi := storetypes.WithResource(context.Background(), "luigi-uid")
+
+	if _, err := store.CreateUser(mario, "mario-uid", "mario", nil); err != nil {
+		t.Fatalf("CreateUser(...): %v", err)
+	}
+	_, _ = store.GetUser(mario, "cool-uuid")
+	_, _ = store.GetUser(luigi, "cool-uuid")
+	_, _ = store.GetUser(context.Background(), "cool-uuid")
+
+	want := [][]string{nil, {"bookmark-1"}, nil, nil}
+	for i, w := range want {
+		if diff := cmp.Diff(w, configs[i].Bookmarks); diff != "" {
+			t.Errorf("session %d: -want bookmarks, +got bookmarks:\n%s", i, diff)
+		}
+	}
+}
+
+func TestContext(t *testing.T) {
+	cases := map[string]struct {
+		ctx     func() (context.Context, context.CancelFunc)
+		hang    bool
+		timeout bool
+		err     error
+	}{
+		"Cancelled": {
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx, cancel
+			},
+			err: context.Canceled,
+		},
+		"DeadlineBecomesTxTimeout": {
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), time.Minute)
+			},
+			timeout: true,
+		},
+		"NoDeadline": {
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithCancel(context.Background())
+			},
+		},
+		"Hung": {
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 10*time.Millisecond)
+			},
+			hang:    true,
+			timeout: true,
+			err:     context.DeadlineExceeded,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			release := make(chan struct{})
+			defer close(release)
+
+			timeouts := make(chan time.Duration, 1)
+			session := fake.MockSession{
+				MockReadTransaction: func(_ neo4j.TransactionWork, configurers ...func(*neo4j.TransactionConfig)) (interface{}, error) {
+					config := &neo4j.TransactionConfig{}
+					for _, c := range configurers {
+						c(config)
+					}
+					timeouts <- config.Timeout
+					if tc.hang {
+						<-release
+					}
+					return &neo4j.Record{Keys: []string{"uuid"}, Values: []interface{}{"cool-uuid"}}, nil
+				},
+				MockClose: func() error { return nil },
+			}
+			db := &neo4jstore.Neo4jDB{
+				Driver: &fake.MockDriver{MockNewSession: func(neo4j.SessionConfig) neo4j.Session { return session }},
+			}
+
+			ctx, cancel := tc.ctx()
+			defer cancel()
+
+			_, err := db.LookupUser(ctx, "uid")
+			if diff := cmp.Diff(tc.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("LookupUser(...): -want error, +got error:\n%s", diff)
+			}
+
+			if tc.err == context.Canceled {
+				return
+			}
+			if timeout := <-timeouts; (timeout > 0) != tc.timeout {
+				t.Errorf("LookupUser(...): want transaction timeout %t, got %s", tc.timeout, timeout)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package transaction
+
+import (
+	"github.com/google/uuid"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+// An IDStrategy decides how the uuid of a new node is generated.
+type IDStrategy interface {
+	// ID returns the Cypher expression a new node's uuid is set to, and a
+	// new map of the parameters the expression refers to.
+	ID() (string, map[string]interface{})
+
+	// Requires returns the names of the functions the expression calls
+	// which are not built into Neo4j.
+	Requires() []string
+}
+
+// GoIDs generates uuids in the provider, so it works against any Neo4j.
+type GoIDs struct{}
+
+func (GoIDs) ID() (string, map[string]interface{}) {
+	return "$id", map[string]interface{}{"id": uuid.NewString()}
+}
+
+func (GoIDs) Requires() []string { return nil }
+
+// NativeIDs generates uuids with Neo4j's built-in randomUUID().
+type NativeIDs struct{}
+
+func (NativeIDs) ID() (string, map[string]interface{}) {
+	return "randomUUID()", map[string]interface{}{}
+}
+
+func (NativeIDs) Requires() []string { return nil }
+
+// APOCIDs generates uuids with apoc.create.uuid(), which requires the APOC
+// plugin to be installed.
+type APOCIDs struct{}
+
+func (APOCIDs) ID() (string, map[string]interface{}) {
+	return "apoc.create.uuid()", map[string]interface{}{}
+}
+
+func (APOCIDs) Requires() []string { return []string{"apoc.create.uuid"} }
+
+// Returns the names of the supplied functions which the database does not
+// have, in a record with the key "missing".
+func MissingFunctionsTxFunc(names []string) neo4j.TransactionWork {
+	return func(tx neo4j.Transaction) (interface{}, error) {
+		result, err := tx.Run(`
+		SHOW FUNCTIONS YIELD name
+		WITH collect(name) AS found
+		RETURN [n IN $names WHERE NOT n IN found] AS missing
+		`, map[string]interface{}{
+			"names": names,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return result.Single()
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"fmt"
 
 	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
-	"github.com/neo4j/neo4j-go-driver/v4/neo4j/db"
 )
 
 var (
@@ -24,57 +23,73 @@ Some considerations:
 - check*TxFunc is the only set of functions which return values
 	used for comparison in the providers *.Observe() method. All
 	others return summaries which are largely ignored.
-- error handling is done by creating function satisfying resource.ErrorIs interface
-	and checking that specific error in the ExternalClient interface function of
-		the provider.
+- errors are returned as the driver reports them, and classified into the
+	types of internal/storage/types by the Neo4jDB which runs the transaction.
 */
 
-func IsConstraintViolationNeo4jErr(err error) bool {
-	nerr, _ := err.(*db.Neo4jError)
-	return nerr.Code == "Neo.ClientError.Schema.ConstraintViolation"
+type AtProviderResponse struct {
+	ID     string
+	Status string
 }
 
-type EntityNotFoundError struct {
-	Entity string
+func (ap *AtProviderResponse) GetID() string {
+	return ap.ID
 }
 
-func (e *EntityNotFoundError) Error() string {
-	return fmt.Sprintf("entity with uuid = %s not found", e.Entity)
+func (ap *AtProviderResponse) GetStatus() string {
+	return ap.Status
 }
 
-func IsEntityNotFoundNeo4jErr(err error) bool {
-	_, ok := err.(*EntityNotFoundError)
-	return ok
+// The Add*TxFuncs below MERGE an entity on the UID of the managed resource it
+// belongs to, so that retrying a create whose external name was never
+// recorded returns the entity created the first time. A new entity's uuid
+// is generated by the supplied IDStrategy.
+func AddTeamTxFunc(ids IDStrategy, uid, teamName string) neo4j.TransactionWork {
+	return addTxFunc(ids, "Team", uid, teamName)
 }
 
-type InternalError struct {
-	Message string
+func AddUserTxFunc(ids IDStrategy, uid, userName string) neo4j.TransactionWork {
+	return addTxFunc(ids, "User", uid, userName)
 }
 
-func (e *InternalError) Error() string {
-	return e.Message
+func AddPersonaTxFunc(ids IDStrategy, uid, name string) neo4j.TransactionWork {
+	return addTxFunc(ids, "Persona", uid, name)
 }
 
-type AtProviderResponse struct {
-	ID     string
-	Status string
+func AddPermissionSetTxFunc(ids IDStrategy, uid, name string) neo4j.TransactionWork {
+	return addTxFunc(ids, "PermissionSet", uid, name)
 }
 
-func (ap *AtProviderResponse) GetID() string {
-	return ap.ID
-}
+// addTxFunc MERGEs an entity with the supplied label on uid. The label must
+// be one of the constant node labels, never user input.
+func addTxFunc(ids IDStrategy, label, uid, name string) neo4j.TransactionWork {
+	return func(tx neo4j.Transaction) (interface{}, error) {
+		id, params := ids.ID()
+		params["uid"] = uid
+		params["name"] = name
+
+		result, err := tx.Run(fmt.Sprintf(`
+		MERGE (n:%s {uid: $uid})
+		ON CREATE SET n.uuid = %s, n.name = $name
+		RETURN n.uuid as uuid
+		`, label, id), params)
+		if err != nil {
+			return nil, err
+		}
 
-func (ap *AtProviderResponse) GetStatus() string {
-	return ap.Status
+		return result.Single()
+	}
 }
 
-func AddTeamTxFunc(teamName string) neo4j.TransactionWork {
+// Returns the uuid of the entity with the supplied label owned by uid. The
+// label must be one of the constant node labels, never user input.
+func LookupTxFunc(label, uid string) neo4j.TransactionWork {
 	return func(tx neo4j.Transaction) (interface{}, error) {
-		result, err := tx.Run(`
-		CREATE (t:Team {uuid: apoc.create.uuid(), name: $teamName})
-		RETURN t.uuid as uuid
-		`, map[string]interface{}{
-			"teamName": teamName,
+		result, err := tx.Run(fmt.Sprintf(`
+		MATCH (n:%s {uid: $uid})
+		RETURN n.uuid as uuid
+		`, label), map[string]interface{}{
+			"uid": uid,
 		})
 		if err != nil {
 			return nil, err
@@ -84,13 +99,15 @@ func AddTeamTxFunc(teamName string) neo4j.TransactionWork {
 	}
 }
 
-func AddUserTxFunc(userName string) neo4j.TransactionWork {
+// Returns the uuids of the nodes of label with the supplied name, in a
+// record with the key "uuids".
+func LookupByNameTxFunc(label, name string) neo4j.TransactionWork {
 	return func(tx neo4j.Transaction) (interface{}, error) {
-		result, err := tx.Run(`
-		CREATE (u:User {uuid: apoc.create.uuid(), name: $userName})
-		RETURN u.uuid as uuid
-		`, map[string]interface{}{
-			"userName": userName,
+		result, err := tx.Run(fmt.Sprintf(`
+		MATCH (n:%s {name: $name})
+		RETURN collect(n.uuid) AS uuids
+		`, label), map[string]interface{}{
+			"name": name,
 		})
 		if err != nil {
 			return nil, err
@@ -100,13 +117,36 @@ func AddUserTxFunc(userName string) neo4j.TransactionWork {
 	}
 }
 
-func AddPersonaTxFunc(name string) neo4j.TransactionWork {
+// Merges the account with the supplied id in the cloud and has the Account
+// with the supplied uid manage it, unless another Account already does.
+// Returns the id of the account and the uid of the Account which manages it
+// in a record with the keys "id" and "uid".
+func ManageAccountTxFunc(uid, cloud, accountId, alias, class, owner, environment, lifecycle string) neo4j.TransactionWork {
+	return manageTxFunc("Account", "id", cloud, accountId, uid, accountProps(alias, class, owner, environment, lifecycle))
+}
+
+// Returns the id of the account managed by the Account with the supplied
+// uid, in a record with the key "id".
+func LookupAccountTxFunc(uid string) neo4j.TransactionWork {
+	return lookupManagedTxFunc("Account", "id", uid)
+}
+
+// Returns the properties of the account with the supplied id in the cloud,
+// or no record if no Account manages it.
+func GetAccountTxFunc(cloud, accountId string) neo4j.TransactionWork {
 	return func(tx neo4j.Transaction) (interface{}, error) {
 		result, err := tx.Run(`
-		CREATE (p:Persona {uuid: apoc.create.uuid(), name: $name})
-		RETURN p.uuid as uuid
+		MATCH (ac:Account {cloud: $cloud, id: $accountId})
+		WHERE ac.uid IS NOT NULL
+		RETURN ac.id AS id,
+			ac.alias AS alias,
+			ac.class AS class,
+			ac.owner AS owner,
+			ac.environment AS environment,
+			ac.lifecycle AS lifecycle
 		`, map[string]interface{}{
-			"name": name,
+			"cloud":     cloud,
+			"accountId": accountId,
 		})
 		if err != nil {
 			return nil, err
@@ -116,45 +156,303 @@ func AddPersonaTxFunc(name string) neo4j.TransactionWork {
 	}
 }
 
-func AddPermissionSetTxFunc(name string) neo4j.TransactionWork {
+// Sets the properties of the account with the supplied id in the cloud.
+// Returns its id in a record with the key "id", or no record if no Account
+// manages it.
+func UpdateAccountTxFunc(cloud, accountId, alias, class, owner, environment, lifecycle string) neo4j.TransactionWork {
+	return updateManagedTxFunc("Account", "id", cloud, accountId, accountProps(alias, class, owner, environment, lifecycle))
+}
+
+// Deletes the account with the supplied id in the cloud, unless a permission
+// set still delegates access to it. In that case only the uid of the Account
+// which manages it and the properties it set are removed, leaving the
+// account to be collected once it is orphaned.
+func DeleteAccountTxFunc(cloud, accountId string) neo4j.TransactionWork {
+	return releaseManagedTxFunc("Account", "id", "DELEGATES_ACCESS_TO", cloud, accountId, accountProps("", "", "", "", ""))
+}
+
+// Returns a record for each path from a user to the account with the
+// supplied id and the role with the supplied name in the cloud, walking back
+// through the permission sets which bind them and the personas attached to
+// those.
+func WhoCanAccessTxFunc(cloud, accountId, roleName string) neo4j.TransactionWork {
 	return func(tx neo4j.Transaction) (interface{}, error) {
 		result, err := tx.Run(`
-		CREATE (p:PermissionSet {uuid: apoc.create.uuid(), name: $name})
-		RETURN p.uuid as uuid
+		MATCH (ac:Account {cloud: $cloud, id: $accountId})<-[to:DELEGATES_ACCESS_TO]-(ps:PermissionSet)
+		MATCH (ps)-[:DELEGATES_ACCESS_WITH {binding: to.binding}]->(r:Role {cloud: $cloud, name: $roleName})
+		MATCH (ps)-[:ATTACHED_TO]->(pe:Persona)
+		CALL {
+			WITH pe
+			MATCH (u:User)-[:GRANTED]->(pe)
+			RETURN u, null AS t
+			UNION
+			WITH pe
+			MATCH (u:User)-[:MEMBER_OF]->(t:Team)-[:INHERITS]->(pe)
+			RETURN u, t
+		}
+		RETURN u.uuid AS user, u.name AS userName,
+			ac.cloud AS cloud, ac.id AS account, r.name AS role, to.scope AS scope,
+			ps.name AS permissionSet, pe.name AS persona, t.name AS team
 		`, map[string]interface{}{
-			"name": name,
+			"cloud":     cloud,
+			"accountId": accountId,
+			"roleName":  roleName,
 		})
 		if err != nil {
 			return nil, err
 		}
 
-		return result.Single()
+		return result.Collect()
 	}
 }
 
-func AddAccountTxFunc(accountId, accountAlias, accountClass string) neo4j.TransactionWork {
+// Returns a record for each distinct path from the user with the supplied
+// uuid to the account with the supplied id and the role with the supplied
+// name in the cloud, with the uuid, or id, name and uid of each node on it.
+// The team of a path through a persona granted to the user directly is null.
+func ExplainAccessTxFunc(userUuid, cloud, accountId, roleName string) neo4j.TransactionWork {
 	return func(tx neo4j.Transaction) (interface{}, error) {
 		result, err := tx.Run(`
-		MERGE (:Account {id: $accountId, alias: $accountAlias, class: $accountClass})
+		MATCH (u:User {uuid: $userUuid})
+		CALL {
+			WITH u
+			MATCH (u)-[:GRANTED]->(pe:Persona)
+			RETURN pe, null AS t
+			UNION
+			WITH u
+			MATCH (u)-[:MEMBER_OF]->(t:Team)-[:INHERITS]->(pe:Persona)
+			RETURN pe, t
+		}
+		MATCH (ps:PermissionSet)-[:ATTACHED_TO]->(pe)
+		MATCH (ps)-[to:DELEGATES_ACCESS_TO]->(ac:Account {cloud: $cloud, id: $accountId})
+		MATCH (ps)-[:DELEGATES_ACCESS_WITH {binding: to.binding}]->(r:Role {cloud: $cloud, name: $roleName})
+		RETURN DISTINCT u.uuid AS user, u.name AS userName, u.uid AS userUid,
+			t.uuid AS team, t.name AS teamName, t.uid AS teamUid,
+			pe.uuid AS persona, pe.name AS personaName, pe.uid AS personaUid,
+			ps.uuid AS permissionSet, ps.name AS permissionSetName, ps.uid AS permissionSetUid,
+			ac.cloud AS cloud, ac.id AS account, ac.uid AS accountUid, to.scope AS scope,
+			r.name AS role, r.uid AS roleUid
 		`, map[string]interface{}{
-			"accountId":    accountId,
-			"accountAlias": accountAlias,
-			"accountClass": accountClass,
+			"userUuid":  userUuid,
+			"cloud":     cloud,
+			"accountId": accountId,
+			"roleName":  roleName,
 		})
 		if err != nil {
 			return nil, err
 		}
 
-		return result.Consume()
+		return result.Collect()
 	}
 }
 
-func AddRoleTxFunc(name string) neo4j.TransactionWork {
+// Merges the role with the supplied name in the cloud and has the Role with
+// the supplied uid manage it, unless another Role already does. Returns the
+// name of the role and the uid of the Role which manages it in a record with
+// the keys "id" and "uid".
+func ManageRoleTxFunc(uid, cloud, roleName, description, tier, policyDocument, maxSessionDuration string) neo4j.TransactionWork {
+	return manageTxFunc("Role", "name", cloud, roleName, uid, roleProps(description, tier, policyDocument, maxSessionDuration))
+}
+
+// Returns the name of the role managed by the Role with the supplied uid, in
+// a record with the key "id".
+func LookupRoleTxFunc(uid string) neo4j.TransactionWork {
+	return lookupManagedTxFunc("Role", "name", uid)
+}
+
+// Returns the properties of the role with the supplied name in the cloud, or
+// no record if no Role manages it.
+func GetRoleTxFunc(cloud, roleName string) neo4j.TransactionWork {
 	return func(tx neo4j.Transaction) (interface{}, error) {
 		result, err := tx.Run(`
-		MERGE (:Role {name: $roleName})
+		MATCH (r:Role {cloud: $cloud, name: $roleName})
+		WHERE r.uid IS NOT NULL
+		RETURN r.name AS name,
+			r.description AS description,
+			r.tier AS tier,
+			r.policyDocument AS policyDocument,
+			r.maxSessionDuration AS maxSessionDuration
 		`, map[string]interface{}{
-			"roleName": name,
+			"cloud":    cloud,
+			"roleName": roleName,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return result.Single()
+	}
+}
+
+// Sets the properties of the role with the supplied name in the cloud.
+// Returns its name in a record with the key "id", or no record if no Role
+// manages it.
+func UpdateRoleTxFunc(cloud, roleName, description, tier, policyDocument, maxSessionDuration string) neo4j.TransactionWork {
+	return updateManagedTxFunc("Role", "name", cloud, roleName, roleProps(description, tier, policyDocument, maxSessionDuration))
+}
+
+// Deletes the role with the supplied name in the cloud, unless a permission
+// set still delegates access with it. In that case only the uid of the Role
+// which manages it and the properties it set are removed, leaving the role to
+// be collected once it is orphaned.
+func DeleteRoleTxFunc(cloud, roleName string) neo4j.TransactionWork {
+	return releaseManagedTxFunc("Role", "name", "DELEGATES_ACCESS_WITH", cloud, roleName, roleProps("", "", "", ""))
+}
+
+// cloudLabels are the labels of the accounts in each cloud.
+var cloudLabels = []struct{ cloud, label string }{
+	{cloud: "aws", label: "AWS"},
+	{cloud: "gcp", label: "GCP"},
+	{cloud: "azure", label: "Azure"},
+	{cloud: "kubernetes", label: "Kubernetes"},
+}
+
+// labelCloud returns the clauses which label the account bound to the
+// variable v with its cloud. A label cannot be a parameter, so there is a
+// clause for each cloud.
+func labelCloud(v string) string {
+	out := ""
+	for _, c := range cloudLabels {
+		out += fmt.Sprintf("FOREACH (_ IN CASE WHEN %s.cloud = '%s' THEN [1] ELSE [] END | SET %s:%s)\n", v, c.cloud, v, c.label)
+	}
+
+	return out
+}
+
+func accountProps(alias, class, owner, environment, lifecycle string) map[string]interface{} {
+	return map[string]interface{}{
+		"alias":       alias,
+		"class":       class,
+		"owner":       owner,
+		"environment": environment,
+		"lifecycle":   lifecycle,
+	}
+}
+
+func roleProps(description, tier, policyDocument, maxSessionDuration string) map[string]interface{} {
+	return map[string]interface{}{
+		"description":        description,
+		"tier":               tier,
+		"policyDocument":     policyDocument,
+		"maxSessionDuration": maxSessionDuration,
+	}
+}
+
+// manageTxFunc merges the node with the supplied label in the cloud whose key
+// is id, and has the managed resource with the supplied uid own it and set
+// its properties, unless another managed resource already owns it. Unlike
+// the nodes of addTxFunc, these nodes are identified by their cloud and key
+// rather than a uuid, since they may be merged by a binding before a managed
+// resource owns them, and are merged on their cloud and key together, which
+// is unique. Accounts are also labelled with their cloud. The label and key
+// must be constants, never user input.
+func manageTxFunc(label, key, cloud, id, uid string, props map[string]interface{}) neo4j.TransactionWork {
+	labels := ""
+	if label == "Account" {
+		labels = labelCloud("n")
+	}
+
+	return func(tx neo4j.Transaction) (interface{}, error) {
+		result, err := tx.Run(fmt.Sprintf(`
+		MERGE (n:%s {key: $cloud + ':' + $id})
+		ON CREATE SET n.cloud = $cloud, n.%s = $id
+		%s
+		WITH n, coalesce(n.uid, $uid) AS owner
+		FOREACH (_ IN CASE WHEN owner = $uid THEN [1] ELSE [] END |
+			SET n += $props, n.uid = $uid
+		)
+		RETURN n.%s AS id, owner AS uid
+		`, label, key, labels, key), map[string]interface{}{
+			"cloud": cloud,
+			"id":    id,
+			"uid":   uid,
+			"props": props,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return result.Single()
+	}
+}
+
+// lookupManagedTxFunc returns the key of the node with the supplied label
+// owned by uid. The label and key must be constants, never user input.
+func lookupManagedTxFunc(label, key, uid string) neo4j.TransactionWork {
+	return func(tx neo4j.Transaction) (interface{}, error) {
+		result, err := tx.Run(fmt.Sprintf(`
+		MATCH (n:%s {uid: $uid})
+		RETURN n.%s AS id
+		`, label, key), map[string]interface{}{
+			"uid": uid,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return result.Single()
+	}
+}
+
+// updateManagedTxFunc sets the properties of the node with the supplied
+// label in the cloud whose key is id, if a managed resource owns it. The
+// label and key must be constants, never user input.
+func updateManagedTxFunc(label, key, cloud, id string, props map[string]interface{}) neo4j.TransactionWork {
+	return func(tx neo4j.Transaction) (interface{}, error) {
+		result, err := tx.Run(fmt.Sprintf(`
+		MATCH (n:%s {cloud: $cloud, %s: $id})
+		WHERE n.uid IS NOT NULL
+		SET n += $props
+		RETURN n.%s AS id
+		`, label, key, key), map[string]interface{}{
+			"cloud": cloud,
+			"id":    id,
+			"props": props,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return result.Single()
+	}
+}
+
+// releaseManagedTxFunc deletes the node with the supplied label in the cloud
+// whose key is id, unless a relationship of the type referencedBy still
+// points to it. A node which is still referenced is released instead: its
+// uid and the supplied properties are removed, so that no managed resource
+// owns it and it is collected once it is orphaned. The label, key and
+// relationship type must be constants, never user input.
+func releaseManagedTxFunc(label, key, referencedBy, cloud, id string, props map[string]interface{}) neo4j.TransactionWork {
+	released := map[string]interface{}{"uid": nil}
+	for k := range props {
+		released[k] = nil
+	}
+
+	return func(tx neo4j.Transaction) (interface{}, error) {
+		result, err := tx.Run(fmt.Sprintf(`
+		MATCH (n:%s {cloud: $cloud, %s: $id})
+		WHERE ()-[:%s]->(n)
+		SET n += $released
+		`, label, key, referencedBy), map[string]interface{}{
+			"cloud":    cloud,
+			"id":       id,
+			"released": released,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := result.Consume(); err != nil {
+			return nil, err
+		}
+
+		result, err = tx.Run(fmt.Sprintf(`
+		MATCH (n:%s {cloud: $cloud, %s: $id})
+		WHERE NOT ()-[:%s]->(n)
+		DETACH DELETE n
+		`, label, key, referencedBy), map[string]interface{}{
+			"cloud": cloud,
+			"id":    id,
 		})
 		if err != nil {
 			return nil, err
@@ -216,7 +514,7 @@ func AddTeamPersonaRelationTxFunc(teamUuid string, personaRefs []string) neo4j.T
 	return func(tx neo4j.Transaction) (interface{}, error) {
 		result, err := tx.Run(`
 		MATCH (t:Team {uuid: $teamUuid})
-		UNWIND personaRefs as persona
+		UNWIND $personaRefs as persona
 		WITH t, persona
 		MATCH (p:Persona {uuid: persona})
 		MERGE (t)-[:INHERITS]->(p)
@@ -237,15 +535,10 @@ func AddTeamMemberRelationTxFunc(teamUuid string, memberRefs []string) neo4j.Tra
 	return func(tx neo4j.Transaction) (interface{}, error) {
 		result, err := tx.Run(`
 		MATCH (t:Team {uuid: $teamUuid})
-		UNWIND memberRefs as member
+		UNWIND $memberRefs as member
 		WITH member, t
 		MATCH (u:User {uuid: member})
-		WITH
-		CASE
-			WHEN (t)-[:MANAGED_BY]->(u) THEN 1
-			ELSE 0
-		END AS is_already_manager
-		WHERE is_already_manager = 0
+		WHERE NOT (t)-[:MANAGED_BY]->(u)
 		MERGE (t)<-[:MEMBER_OF]-(u)
 		`, map[string]interface{}{
 			"teamUuid":   teamUuid,
@@ -267,12 +560,7 @@ func AddTeamManagedByRelationTxFunc(teamUuid, managerUuid string) neo4j.Transact
 	return func(tx neo4j.Transaction) (interface{}, error) {
 		result, err := tx.Run(`
 		MATCH (t:Team {uuid: $teamUuid}), (u:User {uuid: $managerUuid})
-		WITH
-		CASE
-			WHEN (u)-[:MEMBER_OF]->(t) THEN 1
-			ELSE 0
-		END AS is_already_member
-		WHERE is_already_member = 0
+		WHERE NOT (u)-[:MEMBER_OF]->(t)
 		SET u.isManager = true
 		MERGE (t)-[:MANAGED_BY]->(u)
 		`, map[string]interface{}{
@@ -304,59 +592,93 @@ func AddManagerPersonaNoInheritRelationTxFunc(teamUuid, managerUuid string) neo4
 	}
 }
 
-func UpdateTeamTxFunc(teamUuid, manager string, personaRefs, memberRefs []string) neo4j.TransactionWork {
+// Renames a team and relates it to the supplied manager, members and
+// personas. Only the relationships which differ from those stored are
+// deleted or merged, and the ones which were are returned as added and
+// removed. A manager of the team is never also made a member of it.
+func UpdateTeamTxFunc(teamUuid, teamName, manager string, memberRefs, personaRefs []string) neo4j.TransactionWork {
 	return func(tx neo4j.Transaction) (interface{}, error) {
 		result, err := tx.Run(`
 		MATCH (t:Team {uuid: $teamUuid})
+		SET t.name = $teamName
+
+		WITH t
 		CALL {
-    		WITH t
-    		MATCH p=()-[*]-(t)-[*]-()
-    		FOREACH (x IN relationships(p) | DELETE x)
+			WITH t
+			MATCH (t)-[r:MANAGED_BY]->(u:User)
+			WHERE u.uuid <> $managerUuid
+			DELETE r
+			RETURN collect({type: 'MANAGED_BY', node: u.uuid}) AS removedManagers
 		}
-
-		UNWIND personaRefs AS persona
 		CALL {
-    		WITH t, persona
-    		MATCH (p:Persona {uuid: persona})
-    		MERGE (t)-[:INHERITS]-(p)
+			WITH t
+			MATCH (t)<-[r:MEMBER_OF]-(u:User)
+			WHERE NOT u.uuid IN $memberRefs OR u.uuid = $managerUuid
+			DELETE r
+			RETURN collect({type: 'MEMBER_OF', node: u.uuid}) AS removedMembers
 		}
-
-		UNWIND memberRefs AS member
 		CALL {
-    		WITH t, member
-    		MATCH (u:User {uuid: member})
-    		MERGE (t)<-[:MEMBER_OF]-(u)
+			WITH t
+			MATCH (t)-[r:INHERITS]->(p:Persona)
+			WHERE NOT p.uuid IN $personaRefs
+			DELETE r
+			RETURN collect({type: 'INHERITS', node: p.uuid}) AS removedPersonas
 		}
-
 		CALL {
 			WITH t
 			MATCH (u:User {uuid: $managerUuid})
+			WHERE NOT (t)-[:MANAGED_BY]->(u)
 			MERGE (t)-[:MANAGED_BY]->(u)
+			RETURN collect({type: 'MANAGED_BY', node: u.uuid}) AS addedManagers
+		}
+		CALL {
+			WITH t
+			MATCH (u:User)
+			WHERE u.uuid IN $memberRefs AND u.uuid <> $managerUuid AND NOT (t)<-[:MEMBER_OF]-(u)
+			MERGE (t)<-[:MEMBER_OF]-(u)
+			RETURN collect({type: 'MEMBER_OF', node: u.uuid}) AS addedMembers
+		}
+		CALL {
+			WITH t
+			MATCH (p:Persona)
+			WHERE p.uuid IN $personaRefs AND NOT (t)-[:INHERITS]->(p)
+			MERGE (t)-[:INHERITS]->(p)
+			RETURN collect({type: 'INHERITS', node: p.uuid}) AS addedPersonas
 		}
+
+		RETURN t.uuid AS uuid,
+			addedManagers + addedMembers + addedPersonas AS added,
+			removedManagers + removedMembers + removedPersonas AS removed
 		`, map[string]interface{}{
 			"teamUuid":    teamUuid,
-			"memberRefs":  memberRefs,
-			"personaRefs": personaRefs,
+			"teamName":    teamName,
+			"memberRefs":  orEmpty(memberRefs),
+			"personaRefs": orEmpty(personaRefs),
 			"managerUuid": manager,
 		})
 		if err != nil {
 			return nil, err
 		}
 
-		return result.Consume()
+		return result.Single()
 	}
 }
 
+// Returns the relationships of a team. Each of them is optional, so a team
+// without any is returned with empty collections rather than no record.
 func GetTeamTxFunc(teamUuid string) neo4j.TransactionWork {
 	return func(tx neo4j.Transaction) (interface{}, error) {
 		result, err := tx.Run(`
 		MATCH (team:Team {uuid: $teamUuid})
-		WITH team
-		MATCH (member:User)-[:MEMBER_OF]->(team),
-    		(manager:User)<-[:MANAGED_BY]-(team),
-    		(persona:Persona)<-[:INHERITS]-(team)
-
-		RETURN collect(member.uuid) AS members,
+		OPTIONAL MATCH (team)-[:MANAGED_BY]->(manager:User)
+		WITH team, manager
+		OPTIONAL MATCH (member:User)-[:MEMBER_OF]->(team)
+		WITH team, manager, collect(member.uuid) AS members
+		OPTIONAL MATCH (team)-[:INHERITS]->(persona:Persona)
+
+		RETURN team.uuid AS uuid,
+			team.name AS name,
+			members,
 			manager.uuid AS manager,
 			collect(persona.uuid) AS personas
 		`, map[string]interface{}{
@@ -370,27 +692,6 @@ func GetTeamTxFunc(teamUuid string) neo4j.TransactionWork {
 	}
 }
 
-// Creates a relationship between the provided permissionSet with the corresponding
-// account and role
-func AddPermissionSetAccountRoleRelationTxFunc(permissionSetUuid, accountId, roleName string) neo4j.TransactionWork {
-	return func(tx neo4j.Transaction) (interface{}, error) {
-		result, err := tx.Run(`
-		MATCH (p:PermissionSet {uuid: $permissionSetUuid}), (ac:Account {id: $accountId}), (r:Role {name: $roleName})
-		WITH p as permissionset, ac as account, r as role
-		MERGE (account)<-[:DELEGATES_ACCESS_TO]-(permissionset)-[:DELEGATES_ACCESS_WITH]->(role)
-		`, map[string]interface{}{
-			"permissionSetUuid": permissionSetUuid,
-			"accountId":         accountId,
-			"roleName":          roleName,
-		})
-		if err != nil {
-			return nil, err
-		}
-
-		return result.Consume()
-	}
-}
-
 // Creates a relationship between the provided User and the referenced
 // Personas (one or many)
 func AddUserPersonaRelationTxFunc(userUuid string, personaRefs []string) neo4j.TransactionWork {
@@ -433,57 +734,121 @@ func AddPersonaPermissionSetRelationTxFunc(personaUuid string, permissionSetRefs
 	}
 }
 
-func UpdatePermissionSetTxFunc(permissionSetUuid, name, accountId, accountAlias, accountClass, roleName string) neo4j.TransactionWork {
+// Renames a permission set and delegates access through the supplied
+// bindings, and only those, creating their accounts and roles if they do not
+// exist yet. Each binding is a map with the keys key, cloud, account, alias,
+// class, role and scope, and is stored as a pair of delegations carrying its
+// key, the delegation to its account also carrying its scope. Delegations
+// which are already in place are left alone, and those which were not are
+// returned as added and removed. The account and role of a binding are
+// those in its cloud, and the alias and class of an account managed by an
+// Account are left alone.
+func UpdatePermissionSetTxFunc(permissionSetUuid, name string, bindings []map[string]interface{}) neo4j.TransactionWork {
+	keys := make([]interface{}, 0, len(bindings))
+	for _, b := range bindings {
+		keys = append(keys, b["key"])
+	}
+
 	return func(tx neo4j.Transaction) (interface{}, error) {
-		result, err := tx.Run(`
-		MATCH (p:PermissionSet {uuid: $permissionSetUuid}), (ac:Account {id: $accountId}), (ro:Role {name: $roleName})
-		WITH p as permissionset, ac as account, ro as role
-		SET account.id = $accountId, role.name = $roleName, permissionset.name = $name,
-			account.alias = $accountAlias, account.class = $accountClass
-		MERGE (account)<-[:DELEGATES_ACCESS_TO]-(PermissionSet)-[:DELEGATES_ACCESS_WITH]->(role)
-		`, map[string]interface{}{
+		result, err := tx.Run(fmt.Sprintf(`
+		MATCH (permissionset:PermissionSet {uuid: $permissionSetUuid})
+		SET permissionset.name = $name
+
+		WITH permissionset
+		CALL {
+			WITH permissionset
+			MATCH (permissionset)-[r:DELEGATES_ACCESS_TO|DELEGATES_ACCESS_WITH]->(n)
+			WHERE r.binding IS NULL OR NOT r.binding IN $keys
+			WITH r, {type: type(r), node: coalesce(n.id, n.name)} AS delegation
+			DELETE r
+			RETURN collect(delegation) AS removed
+		}
+		CALL {
+			WITH permissionset
+			OPTIONAL MATCH (permissionset)-[r:DELEGATES_ACCESS_TO]->(:Account)
+			RETURN collect(r.binding) AS accounts
+		}
+		CALL {
+			WITH permissionset
+			OPTIONAL MATCH (permissionset)-[r:DELEGATES_ACCESS_WITH]->(:Role)
+			RETURN collect(r.binding) AS roles
+		}
+
+		FOREACH (binding IN $bindings |
+			MERGE (account:Account {key: binding.cloud + ':' + binding.account})
+			ON CREATE SET account.cloud = binding.cloud, account.id = binding.account
+			SET account.alias = CASE WHEN account.uid IS NULL THEN binding.alias ELSE account.alias END,
+				account.class = CASE WHEN account.uid IS NULL THEN binding.class ELSE account.class END
+			%s
+			MERGE (role:Role {key: binding.cloud + ':' + binding.role})
+			ON CREATE SET role.cloud = binding.cloud, role.name = binding.role
+			MERGE (permissionset)-[to:DELEGATES_ACCESS_TO {binding: binding.key}]->(account)
+			SET to.scope = binding.scope
+			MERGE (permissionset)-[:DELEGATES_ACCESS_WITH {binding: binding.key}]->(role)
+		)
+
+		RETURN permissionset.uuid AS uuid,
+			[b IN $bindings WHERE NOT b.key IN accounts | {type: 'DELEGATES_ACCESS_TO', node: b.account}] +
+			[b IN $bindings WHERE NOT b.key IN roles | {type: 'DELEGATES_ACCESS_WITH', node: b.role}] AS added,
+			removed
+		`, labelCloud("account")), map[string]interface{}{
 			"permissionSetUuid": permissionSetUuid,
 			"name":              name,
-			"accountId":         accountId,
-			"accountAlias":      accountAlias,
-			"accountClass":      accountClass,
-			"roleName":          roleName,
+			"bindings":          bindings,
+			"keys":              keys,
 		})
 		if err != nil {
 			return nil, err
 		}
 
-		return result.Consume()
+		return result.Single()
 	}
 }
 
-func UpdatePersonaTxFunc(personaName string, permissionSetRefs []string) neo4j.TransactionWork {
+// Renames a persona and attaches it to the supplied permission sets, and
+// only those, returning the attachments it added and removed.
+func UpdatePersonaTxFunc(personaUuid, personaName string, permissionSetRefs []string) neo4j.TransactionWork {
 	return func(tx neo4j.Transaction) (interface{}, error) {
 		result, err := tx.Run(`
-		MATCH (p:Persona {name: $personaName})<-[r:ATTACHED_TO]-(pe:PermissionSet)
-		DELETE r
+		MATCH (p:Persona {uuid: $personaUuid})
+		SET p.name = $personaName
 
 		WITH p
-		UNWIND $permissionSetUuids as permissionSet
-		MATCH (pe:PermissionSet {uuid: permissionSet})
-		MERGE (p)<-[:ATTACHED_TO]-(pe)
+		CALL {
+			WITH p
+			MATCH (p)<-[r:ATTACHED_TO]-(pe:PermissionSet)
+			WHERE NOT pe.uuid IN $permissionSetUuids
+			DELETE r
+			RETURN collect({type: 'ATTACHED_TO', node: pe.uuid}) AS removed
+		}
+		CALL {
+			WITH p
+			MATCH (pe:PermissionSet)
+			WHERE pe.uuid IN $permissionSetUuids AND NOT (p)<-[:ATTACHED_TO]-(pe)
+			MERGE (p)<-[:ATTACHED_TO]-(pe)
+			RETURN collect({type: 'ATTACHED_TO', node: pe.uuid}) AS added
+		}
+
+		RETURN p.uuid AS uuid, added, removed
 		`, map[string]interface{}{
-			"permissionSetUuids": permissionSetRefs,
+			"permissionSetUuids": orEmpty(permissionSetRefs),
+			"personaUuid":        personaUuid,
 			"personaName":        personaName,
 		})
 		if err != nil {
 			return nil, err
 		}
 
-		return result.Consume()
+		return result.Single()
 	}
 }
 
 func GetPersonaTxFunc(personaUuid string) neo4j.TransactionWork {
 	return func(tx neo4j.Transaction) (interface{}, error) {
 		result, err := tx.Run(`
-		MATCH (:Persona {uuid: $personaUuid})<--(p:PermissionSet)
-		RETURN collect(p.uuid) as permissionSetRefs
+		MATCH (persona:Persona {uuid: $personaUuid})
+		OPTIONAL MATCH (persona)<-[:ATTACHED_TO]-(p:PermissionSet)
+		RETURN persona.uuid AS uuid, persona.name AS name, collect(p.uuid) as permissionSetRefs
 		`, map[string]interface{}{
 			"personaUuid": personaUuid,
 		})
@@ -495,33 +860,50 @@ func GetPersonaTxFunc(personaUuid string) neo4j.TransactionWork {
 	}
 }
 
-func UpdateUserTxFunc(userName string, personaRefs []string) neo4j.TransactionWork {
+// Renames a user and grants it the supplied personas, and only those,
+// returning the grants it added and removed.
+func UpdateUserTxFunc(userUuid, userName string, personaRefs []string) neo4j.TransactionWork {
 	return func(tx neo4j.Transaction) (interface{}, error) {
 		result, err := tx.Run(`
-		MATCH (u:User {name: $userName})-[r:DESIGNATED]->(:Persona)
-		DELETE r
+		MATCH (u:User {uuid: $userUuid})
+		SET u.name = $userName
 
 		WITH u
-		UNWIND $personaUuids as persona
-		MATCH (p:Persona {uuid: persona})
-		MERGE (u)-[:DESIGNATED]->(p)
+		CALL {
+			WITH u
+			MATCH (u)-[r:GRANTED]->(p:Persona)
+			WHERE NOT p.uuid IN $personaUuids
+			DELETE r
+			RETURN collect({type: 'GRANTED', node: p.uuid}) AS removed
+		}
+		CALL {
+			WITH u
+			MATCH (p:Persona)
+			WHERE p.uuid IN $personaUuids AND NOT (u)-[:GRANTED]->(p)
+			MERGE (u)-[:GRANTED]->(p)
+			RETURN collect({type: 'GRANTED', node: p.uuid}) AS added
+		}
+
+		RETURN u.uuid AS uuid, added, removed
 		`, map[string]interface{}{
-			"personaUuids": personaRefs,
+			"personaUuids": orEmpty(personaRefs),
+			"userUuid":     userUuid,
 			"userName":     userName,
 		})
 		if err != nil {
 			return nil, err
 		}
 
-		return result.Consume()
+		return result.Single()
 	}
 }
 
 func GetUserTxFunc(userUuid string) neo4j.TransactionWork {
 	return func(tx neo4j.Transaction) (interface{}, error) {
 		result, err := tx.Run(`
-		MATCH (:User {uuid: $userUuid})-->(p:Persona)
-		RETURN collect(p.uuid) as personaRefs
+		MATCH (u:User {uuid: $userUuid})
+		OPTIONAL MATCH (u)-[:GRANTED]->(p:Persona)
+		RETURN u.uuid AS uuid, u.name AS name, collect(p.uuid) as personaRefs
 		`, map[string]interface{}{
 			"userUuid": userUuid,
 		})
@@ -533,6 +915,40 @@ func GetUserTxFunc(userUuid string) neo4j.TransactionWork {
 	}
 }
 
+// Returns every binding of the permission sets attached to the personas a
+// user is granted, or inherits from the teams it is a member of, once for
+// each path to it. Each is a record with the keys cloud, account, role,
+// scope, permissionSet, persona and team, which is null for personas granted
+// to the user directly. Bindings without a role are not returned.
+func GetEffectiveAccessTxFunc(userUuid string) neo4j.TransactionWork {
+	return func(tx neo4j.Transaction) (interface{}, error) {
+		result, err := tx.Run(`
+		MATCH (u:User {uuid: $userUuid})
+		CALL {
+			WITH u
+			MATCH (u)-[:GRANTED]->(pe:Persona)
+			RETURN pe, null AS t
+			UNION
+			WITH u
+			MATCH (u)-[:MEMBER_OF]->(t:Team)-[:INHERITS]->(pe:Persona)
+			RETURN pe, t
+		}
+		MATCH (ps:PermissionSet)-[:ATTACHED_TO]->(pe)
+		MATCH (ps)-[to:DELEGATES_ACCESS_TO]->(ac:Account)
+		MATCH (ps)-[:DELEGATES_ACCESS_WITH {binding: to.binding}]->(r:Role)
+		RETURN ac.cloud AS cloud, ac.id AS account, r.name AS role, to.scope AS scope,
+			ps.name AS permissionSet, pe.name AS persona, t.name AS team
+		`, map[string]interface{}{
+			"userUuid": userUuid,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return result.Collect()
+	}
+}
+
 func DeletePermissionSetTxFunc(uuid string) neo4j.TransactionWork {
 	return func(tx neo4j.Transaction) (interface{}, error) {
 		result, err := tx.Run(`
@@ -549,14 +965,28 @@ func DeletePermissionSetTxFunc(uuid string) neo4j.TransactionWork {
 	}
 }
 
+// Returns the name and bindings of a permission set. Each binding is an
+// account it delegates access to, paired with the role it delegates access
+// with under the same key, if any. A permission set without delegations is
+// still found, so that bindings removed out of band are corrected. The alias
+// and class of an account managed by an Account are its own, not the
+// binding's, so they are not returned.
 func GetPermissionSetTxFunc(uuid string) neo4j.TransactionWork {
 	return func(tx neo4j.Transaction) (interface{}, error) {
 		result, err := tx.Run(`
-		MATCH (r:Role)<--(p:PermissionSet {uuid: $uuid})-->(ac:Account)
-		RETURN 	ac.id as id,
-			ac.alias as alias,
-			ac.class as class,
-			r.name as roleName`, map[string]interface{}{
+		MATCH (p:PermissionSet {uuid: $uuid})
+		OPTIONAL MATCH (p)-[to:DELEGATES_ACCESS_TO]->(ac:Account)
+		OPTIONAL MATCH (p)-[w:DELEGATES_ACCESS_WITH {binding: to.binding}]->(r:Role)
+		WITH p, to, ac, head(collect(r)) AS r
+		RETURN 	p.name as name,
+			collect(CASE WHEN ac IS NOT NULL THEN {
+				cloud: ac.cloud,
+				id: ac.id,
+				scope: to.scope,
+				alias: CASE WHEN ac.uid IS NULL THEN ac.alias END,
+				class: CASE WHEN ac.uid IS NULL THEN ac.class END,
+				roleName: r.name
+			} END) as bindings`, map[string]interface{}{
 			"uuid": uuid,
 		})
 		if err != nil {
@@ -567,8 +997,262 @@ func GetPermissionSetTxFunc(uuid string) neo4j.TransactionWork {
 	}
 }
 
+// CreateUser adds a user and grants it the referenced personas. It returns
+// the uuid of the user.
+func CreateUser(ids IDStrategy, uid, userName string, personaRefs []string) neo4j.TransactionWork {
+	return func(tx neo4j.Transaction) (interface{}, error) {
+		uuid, err := created(tx, "user", AddUserTxFunc(ids, uid, userName))
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := AddUserPersonaRelationTxFunc(uuid, personaRefs)(tx); err != nil {
+			return nil, err
+		}
+
+		return uuid, nil
+	}
+}
+
+// CreatePersona adds a persona and attaches the referenced permission sets
+// to it. It returns the uuid of the persona.
+func CreatePersona(ids IDStrategy, uid, personaName string, permissionSetRefs []string) neo4j.TransactionWork {
+	return func(tx neo4j.Transaction) (interface{}, error) {
+		uuid, err := created(tx, "persona", AddPersonaTxFunc(ids, uid, personaName))
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := AddPersonaPermissionSetRelationTxFunc(uuid, permissionSetRefs)(tx); err != nil {
+			return nil, err
+		}
+
+		return uuid, nil
+	}
+}
+
+// CreatePermissionSet adds a permission set which delegates access through
+// the supplied bindings, adding their accounts and roles if they do not
+// exist yet. It returns the uuid of the permission set.
+func CreatePermissionSet(ids IDStrategy, uid, name string, bindings []map[string]interface{}) neo4j.TransactionWork {
+	return func(tx neo4j.Transaction) (interface{}, error) {
+		uuid, err := created(tx, "permissionset", AddPermissionSetTxFunc(ids, uid, name))
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := UpdatePermissionSetTxFunc(uuid, name, bindings)(tx); err != nil {
+			return nil, err
+		}
+
+		return uuid, nil
+	}
+}
+
+// CreateTeam adds a team with a manager, members and the personas they
+// inherit. The manager is related first so that it is never also made a
+// member. It returns the uuid of the team.
+func CreateTeam(ids IDStrategy, uid, teamName, manager string, memberRefs, personaRefs []string) neo4j.TransactionWork {
+	return func(tx neo4j.Transaction) (interface{}, error) {
+		uuid, err := created(tx, "team", AddTeamTxFunc(ids, uid, teamName))
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := AddTeamManagedByRelationTxFunc(uuid, manager)(tx); err != nil {
+			return nil, err
+		}
+
+		if _, err := AddTeamMemberRelationTxFunc(uuid, memberRefs)(tx); err != nil {
+			return nil, err
+		}
+
+		if _, err := AddTeamPersonaRelationTxFunc(uuid, personaRefs)(tx); err != nil {
+			return nil, err
+		}
+
+		return uuid, nil
+	}
+}
+
+// created runs one of the Add*TxFuncs and returns the uuid of the entity it
+// created.
+func created(tx neo4j.Transaction, entity string, work neo4j.TransactionWork) (string, error) {
+	out, err := work(tx)
+	if err != nil {
+		return "", err
+	}
+
+	record, ok := out.(*neo4j.Record)
+	if !ok || len(record.Values) == 0 {
+		return "", fmt.Errorf("no %s was created", entity)
+	}
+
+	uuid, ok := record.Values[0].(string)
+	if !ok {
+		return "", fmt.Errorf("no %s was created", entity)
+	}
+
+	return uuid, nil
+}
+
 // TODO: PLACEHOLDER, as the tool needs new features
 // likely one will be defining teams of users who directly
 // inherit personas by default (which may not be too)
 // difficult to implement
 // func makeTeamRefTxFunc() {}
+
+// Returns the version of the schema recorded in the graph, which is zero if
+// no migration was ever applied.
+func SchemaVersionTxFunc() neo4j.TransactionWork {
+	return func(tx neo4j.Transaction) (interface{}, error) {
+		result, err := tx.Run(`
+		OPTIONAL MATCH (s:Schema {id: 'powerbroker'})
+		RETURN coalesce(s.version, 0) AS version
+		`, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		return result.Single()
+	}
+}
+
+// An OrphanKind is a kind of node which is created as a side effect of a
+// managed resource, and is orphaned once nothing references it.
+type OrphanKind struct {
+	// Label of the nodes.
+	Label string
+	// Key is the property which identifies a node among those with its
+	// label.
+	Key string
+	// ReferencedBy is the type of the relationships which reference it.
+	ReferencedBy string
+}
+
+// OrphanKinds are the kinds of node which are never deleted along with the
+// nodes which reference them. Each is identified by its cloud and key.
+var OrphanKinds = []OrphanKind{
+	{Label: "Account", Key: "id", ReferencedBy: "DELEGATES_ACCESS_TO"},
+	{Label: "Role", Key: "name", ReferencedBy: "DELEGATES_ACCESS_WITH"},
+}
+
+// Marks the nodes of kind which nothing references, and no managed resource
+// owns, as orphaned at now, the unix time in seconds, unless they already
+// are, and unmarks those which are referenced again.
+func MarkOrphansTxFunc(kind OrphanKind, now int64) neo4j.TransactionWork {
+	return func(tx neo4j.Transaction) (interface{}, error) {
+		result, err := tx.Run(fmt.Sprintf(`
+		MATCH (n:%s)
+		WHERE n.orphanedAt IS NOT NULL AND ()-[:%s]->(n)
+		REMOVE n.orphanedAt
+		`, kind.Label, kind.ReferencedBy), nil)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := result.Consume(); err != nil {
+			return nil, err
+		}
+
+		result, err = tx.Run(fmt.Sprintf(`
+		MATCH (n:%s)
+		WHERE n.uid IS NULL AND n.orphanedAt IS NULL AND NOT ()-[:%s]->(n)
+		SET n.orphanedAt = $now
+		`, kind.Label, kind.ReferencedBy), map[string]interface{}{
+			"now": now,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return result.Consume()
+	}
+}
+
+// Returns the cloud and id of every node of kind which nothing references,
+// and no managed resource owns, and when it was marked orphaned, or now if
+// it is not marked yet, in records with the keys "cloud", "id" and "since".
+func OrphansTxFunc(kind OrphanKind, now int64) neo4j.TransactionWork {
+	return func(tx neo4j.Transaction) (interface{}, error) {
+		result, err := tx.Run(fmt.Sprintf(`
+		MATCH (n:%s)
+		WHERE n.uid IS NULL AND NOT ()-[:%s]->(n)
+		RETURN n.cloud AS cloud, n.%s AS id, coalesce(n.orphanedAt, $now) AS since
+		`, kind.Label, kind.ReferencedBy, kind.Key), map[string]interface{}{
+			"now": now,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return result.Collect()
+	}
+}
+
+// Deletes the nodes of kind which nothing references and no managed resource
+// owns, among those supplied as maps with the keys cloud and id. Returns the
+// nodes it deleted as maps with the same keys in a record with the key
+// "deleted".
+func CollectTxFunc(kind OrphanKind, orphans []map[string]interface{}) neo4j.TransactionWork {
+	if orphans == nil {
+		orphans = []map[string]interface{}{}
+	}
+
+	return func(tx neo4j.Transaction) (interface{}, error) {
+		result, err := tx.Run(fmt.Sprintf(`
+		UNWIND $orphans AS orphan
+		MATCH (n:%s {cloud: orphan.cloud, %s: orphan.id})
+		WHERE n.uid IS NULL AND NOT ()-[:%s]->(n)
+		WITH n, {cloud: n.cloud, id: n.%s} AS id
+		DETACH DELETE n
+		RETURN collect(id) AS deleted
+		`, kind.Label, kind.Key, kind.ReferencedBy, kind.Key), map[string]interface{}{
+			"orphans": orphans,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return result.Single()
+	}
+}
+
+// Records the version of the schema in the graph.
+func SetSchemaVersionTxFunc(version int) neo4j.TransactionWork {
+	return func(tx neo4j.Transaction) (interface{}, error) {
+		result, err := tx.Run(`
+		MERGE (s:Schema {id: 'powerbroker'})
+		SET s.version = $version
+		`, map[string]interface{}{
+			"version": version,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return result.Consume()
+	}
+}
+
+// Runs a single schema statement, such as creating a constraint or index.
+// Neo4j does not allow schema and data changes in the same transaction.
+func SchemaTxFunc(statement string) neo4j.TransactionWork {
+	return func(tx neo4j.Transaction) (interface{}, error) {
+		result, err := tx.Run(statement, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		return result.Consume()
+	}
+}
+
+// orEmpty returns refs, or an empty list if it is nil. A nil list is sent
+// to Neo4j as null, and nothing is IN, or NOT IN, null.
+func orEmpty(refs []string) []string {
+	if refs == nil {
+		return []string{}
+	}
+
+	return refs
+}
@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+
+	"github.com/VariableExp0rt/powerbroker/internal/service"
+)
+
+// A Closer is a Repository which holds resources, such as a database
+// driver, that must be released once it is no longer used.
+type Closer interface {
+	Close() error
+}
+
+type pooled struct {
+	hash string
+	repo service.Repository
+}
+
+// A pending Repository is being built for a ProviderConfig. Callers which
+// want the same Repository wait for it to be built rather than build their
+// own.
+type pending struct {
+	hash string
+	done chan struct{}
+	repo service.Repository
+	err  error
+}
+
+// A Pool caches one Repository per ProviderConfig so that connections are
+// reused across reconciles. A cached Repository is rebuilt when the storage
+// type, options or credentials of its ProviderConfig change, and closed when
+// it is replaced or evicted.
+//
+// Repositories are built without holding the lock of the Pool, since
+// building one may connect to its storage. Only one Repository is built at
+// a time for each ProviderConfig.
+type Pool struct {
+	mu      sync.Mutex
+	repos   map[string]pooled
+	pending map[string]*pending
+	build   Factory
+}
+
+// NewPool returns a Pool which builds Repositories using the supplied
+// Factory.
+func NewPool(f Factory) *Pool {
+	return &Pool{repos: map[string]pooled{}, pending: map[string]*pending{}, build: f}
+}
+
+// DefaultPool is the Pool shared by every controller. It builds Repositories
+// using the registered backends, which retry transient failures and stop
+// calling storage that keeps failing.
+var DefaultPool = NewPool(WithResilience(New))
+
+// Get returns the cached Repository of cfg.ProviderConfig, building it if
+// there is none or cfg no longer matches the one it was built from. A
+// Repository which is replaced is closed, so callers must not hold on to a
+// Repository beyond a single reconcile. Callers which want a Repository
+// another caller is building wait for it, and share its result.
+func (p *Pool) Get(cfg Config) (service.Repository, error) {
+	h := hash(cfg)
+
+	for {
+		p.mu.Lock()
+		if cached, ok := p.repos[cfg.ProviderConfig]; ok && cached.hash == h {
+			p.mu.Unlock()
+			return cached.repo, nil
+		}
+
+		b, ok := p.pending[cfg.ProviderConfig]
+		if !ok {
+			break
+		}
+		p.mu.Unlock()
+
+		<-b.done
+		if b.hash == h {
+			return b.repo, b.err
+		}
+		// The Repository built was for another configuration of the
+		// ProviderConfig, so look again.
+	}
+
+	b := &pending{hash: h, done: make(chan struct{})}
+	p.pending[cfg.ProviderConfig] = b
+	p.mu.Unlock()
+
+	b.repo, b.err = p.build(cfg)
+
+	var replaced service.Repository
+	p.mu.Lock()
+	delete(p.pending, cfg.ProviderConfig)
+	if b.err == nil {
+		if cached, ok := p.repos[cfg.ProviderConfig]; ok {
+			replaced = cached.repo
+		}
+		p.repos[cfg.ProviderConfig] = pooled{hash: h, repo: b.repo}
+	}
+	p.mu.Unlock()
+	close(b.done)
+
+	if replaced != nil {
+		// The old Repository is unusable from here on, so there is no
+		// one to report a failure to close it to.
+		_ = closeRepo(replaced)
+	}
+
+	return b.repo, b.err
+}
+
+// Evict closes and forgets the cached Repository of the named
+// ProviderConfig, if any.
+func (p *Pool) Evict(providerConfig string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	cached, ok := p.repos[providerConfig]
+	if !ok {
+		return nil
+	}
+	delete(p.repos, providerConfig)
+
+	return closeRepo(cached.repo)
+}
+
+// Len returns the number of cached Repositories.
+func (p *Pool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return len(p.repos)
+}
+
+func closeRepo(repo service.Repository) error {
+	if c, ok := repo.(Closer); ok {
+		return c.Close()
+	}
+
+	return nil
+}
+
+func hash(cfg Config) string {
+	h := sha256.New()
+	h.Write([]byte(cfg.Type))
+	h.Write([]byte{0})
+	h.Write(cfg.Credentials)
+	h.Write([]byte{0})
+	h.Write(cfg.CABundle)
+	if cfg.Neo4j != nil {
+		// The options are plain data, so cannot fail to marshal.
+		o, _ := json.Marshal(cfg.Neo4j)
+		h.Write([]byte{0})
+		h.Write(o)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	apisv1alpha1 "github.com/VariableExp0rt/powerbroker/apis/v1alpha1"
+	"github.com/VariableExp0rt/powerbroker/internal/service"
+)
+
+type closable struct {
+	service.MockRepository
+
+	closed bool
+}
+
+func (c *closable) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestPool(t *testing.T) {
+	built := 0
+	p := NewPool(func(c Config) (service.Repository, error) {
+		if string(c.Credentials) == "bad" {
+			return nil, errors.New("boom")
+		}
+		built++
+		return &closable{}, nil
+	})
+
+	get := func(cfg Config) *closable {
+		t.Helper()
+		repo, err := p.Get(cfg)
+		if err != nil {
+			t.Fatalf("Get(...): %v", err)
+		}
+		return repo.(*closable)
+	}
+
+	a := get(Config{Type: "neo4j", ProviderConfig: "a", Credentials: []byte("v1")})
+	if again := get(Config{Type: "neo4j", ProviderConfig: "a", Credentials: []byte("v1")}); again != a {
+		t.Errorf("Get(...): want the cached repository for unchanged credentials")
+	}
+
+	b := get(Config{Type: "neo4j", ProviderConfig: "b", Credentials: []byte("v1")})
+	if b == a {
+		t.Errorf("Get(...): want a different repository per ProviderConfig")
+	}
+
+	if _, err := p.Get(Config{Type: "neo4j", ProviderConfig: "a", Credentials: []byte("bad")}); err == nil {
+		t.Errorf("Get(...): want error from factory")
+	}
+	if a.closed {
+		t.Errorf("Get(...): a failed rebuild should keep the cached repository open")
+	}
+
+	rotated := get(Config{Type: "neo4j", ProviderConfig: "a", Credentials: []byte("v2")})
+	if rotated == a {
+		t.Errorf("Get(...): want a new repository after credentials change")
+	}
+	if !a.closed {
+		t.Errorf("Get(...): want the replaced repository to be closed")
+	}
+
+	retyped := get(Config{Type: "spicedb", ProviderConfig: "a", Credentials: []byte("v2")})
+	if retyped == rotated {
+		t.Errorf("Get(...): want a new repository after storage type change")
+	}
+
+	native := &apisv1alpha1.Neo4jStorage{IDStrategy: apisv1alpha1.IDStrategyNative}
+	reconfigured := get(Config{Type: "spicedb", ProviderConfig: "a", Credentials: []byte("v2"), Neo4j: native})
+	if reconfigured == retyped {
+		t.Errorf("Get(...): want a new repository after storage options change")
+	}
+
+	routed := &apisv1alpha1.Neo4jStorage{IDStrategy: apisv1alpha1.IDStrategyNative, Routing: apisv1alpha1.RoutingRouting}
+	rerouted := get(Config{Type: "spicedb", ProviderConfig: "a", Credentials: []byte("v2"), Neo4j: routed})
+	if rerouted == reconfigured {
+		t.Errorf("Get(...): want a new repository after connection options change")
+	}
+
+	if retrusted := get(Config{Type: "spicedb", ProviderConfig: "a", Credentials: []byte("v2"), Neo4j: routed, CABundle: []byte("pem")}); retrusted == rerouted {
+		t.Errorf("Get(...): want a new repository after CA bundle change")
+	}
+
+	if err := p.Evict("b"); err != nil {
+		t.Fatalf("Evict(...): %v", err)
+	}
+	if !b.closed {
+		t.Errorf("Evict(...): want the evicted repository to be closed")
+	}
+	if err := p.Evict("b"); err != nil {
+		t.Errorf("Evict(...): evicting twice should not fail: %v", err)
+	}
+
+	if got := p.Len(); got != 1 {
+		t.Errorf("Len(): want 1, got %d", got)
+	}
+	if got := built; got != 7 {
+		t.Errorf("want 7 repositories built, got %d", got)
+	}
+}
+
+func TestPoolBuildsOutsideLock(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	var mu sync.Mutex
+	built := map[string]int{}
+	p := NewPool(func(c Config) (service.Repository, error) {
+		mu.Lock()
+		built[c.ProviderConfig]++
+		mu.Unlock()
+		if c.ProviderConfig == "slow" {
+			close(started)
+			<-release
+		}
+		return &closable{}, nil
+	})
+
+	var wg sync.WaitGroup
+	repos := make([]service.Repository, 3)
+	for i := range repos {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			repos[i], _ = p.Get(Config{Type: "spicedb", ProviderConfig: "slow"})
+		}(i)
+		if i == 0 {
+			<-started
+		}
+	}
+
+	// Building the Repository of one ProviderConfig does not block getting
+	// that of another.
+	if _, err := p.Get(Config{Type: "spicedb", ProviderConfig: "fast"}); err != nil {
+		t.Fatalf("Get(...): %v", err)
+	}
+
+	close(release)
+	wg.Wait()
+
+	for i, r := range repos {
+		if r == nil || r != repos[0] {
+			t.Errorf("Get(...): caller %d: want the repository built once for every caller", i)
+		}
+	}
+	if got := built["slow"]; got != 1 {
+		t.Errorf("want 1 repository built for concurrent callers, got %d", got)
+	}
+}
+
+func TestConnectTimeout(t *testing.T) {
+	if got := ConnectTimeout(Config{}); got != DefaultConnectTimeout {
+		t.Errorf("ConnectTimeout(...): want %v without a timeout, got %v", DefaultConnectTimeout, got)
+	}
+	if got := ConnectTimeout(Config{Timeout: time.Second}); got != time.Second {
+		t.Errorf("ConnectTimeout(...): want %v, got %v", time.Second, got)
+	}
+}
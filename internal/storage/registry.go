@@ -0,0 +1,233 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/resource"
+
+	apisv1alpha1 "github.com/VariableExp0rt/powerbroker/apis/v1alpha1"
+	"github.com/VariableExp0rt/powerbroker/internal/service"
+	"github.com/VariableExp0rt/powerbroker/internal/service/types"
+	storetypes "github.com/VariableExp0rt/powerbroker/internal/storage/types"
+)
+
+// Config is the storage configuration a backend is constructed from. It is
+// resolved from a ProviderConfig and the credentials it references.
+type Config struct {
+	// Type is the name the backend was registered under, which is the
+	// value of the ProviderConfig's spec.storage.type.
+	Type string
+
+	// ProviderConfig is the name of the ProviderConfig the configuration
+	// was resolved from.
+	ProviderConfig string
+
+	// Credentials are the raw bytes extracted from the ProviderConfig's
+	// credentials source. Their format is defined by each backend.
+	Credentials []byte
+
+	// Timeout bounds each operation on the storage for a managed resource.
+	// Operations are unbounded when it is zero.
+	Timeout time.Duration
+
+	// Neo4j configures the neo4j backend. It is nil unless the
+	// ProviderConfig sets spec.storage.neo4j.
+	Neo4j *apisv1alpha1.Neo4jStorage
+
+	// CABundle holds the PEM encoded certificates of the authorities the
+	// certificate of the storage is verified against. It is read from the
+	// secret the storage options of the ProviderConfig reference, if any.
+	CABundle []byte
+}
+
+// ConfigFor returns the Config of the supplied ProviderConfig, given the
+// credentials extracted from it.
+func ConfigFor(pc *apisv1alpha1.ProviderConfig, creds []byte) Config {
+	cfg := Config{
+		Type:           pc.Spec.Storage.Type,
+		ProviderConfig: pc.GetName(),
+		Credentials:    creds,
+		Neo4j:          pc.Spec.Storage.Neo4j,
+	}
+	if t := pc.Spec.Storage.Timeout; t != nil {
+		cfg.Timeout = t.Duration
+	}
+
+	return cfg
+}
+
+// ResolveConfig returns the Config of the supplied ProviderConfig, given the
+// credentials extracted from it, reading any other secrets its storage
+// options reference.
+func ResolveConfig(ctx context.Context, kube client.Client, pc *apisv1alpha1.ProviderConfig, creds []byte) (Config, error) {
+	cfg := ConfigFor(pc, creds)
+
+	if o := pc.Spec.Storage.Neo4j; o != nil && o.TLS != nil && o.TLS.CABundleSecretRef != nil {
+		ca, err := resource.CommonCredentialExtractor(ctx, xpv1.CredentialsSourceSecret, kube, xpv1.CommonCredentialSelectors{
+			SecretRef: o.TLS.CABundleSecretRef,
+		})
+		if err != nil {
+			return Config{}, errors.Wrap(err, "cannot get neo4j CA bundle")
+		}
+		cfg.CABundle = ca
+	}
+
+	return cfg, nil
+}
+
+// DefaultConnectTimeout bounds connecting to a storage backend when its
+// ProviderConfig sets no timeout, so that a backend which cannot be reached
+// never blocks the callers of a Pool for good.
+const DefaultConnectTimeout = 30 * time.Second
+
+// ConnectTimeout returns the timeout of connecting to the storage of cfg:
+// its Timeout, or DefaultConnectTimeout if it has none.
+func ConnectTimeout(cfg Config) time.Duration {
+	if cfg.Timeout <= 0 {
+		return DefaultConnectTimeout
+	}
+
+	return cfg.Timeout
+}
+
+// WithTimeout returns a copy of ctx which is done once the supplied timeout
+// elapses. The copy is only done with ctx if timeout is not positive.
+func WithTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
+
+// Unavailable returns true if err means the storage itself cannot be used,
+// because it cannot be reached, is failing transiently or rejects the
+// credentials of the provider, rather than that an operation on it failed.
+func Unavailable(err error) bool {
+	return storetypes.IsRetryable(err) || storetypes.IsAuthError(err)
+}
+
+// A CapabilityChecker is a Repository which can report the capabilities it
+// needs but its database lacks, such as procedures or functions provided by
+// a plugin which is not installed.
+type CapabilityChecker interface {
+	MissingCapabilities(ctx context.Context) ([]string, error)
+}
+
+// A Migrator is a Repository whose database has a schema the provider
+// manages. Migrate brings the schema up to date and returns its version.
+type Migrator interface {
+	Migrate(ctx context.Context) (int, error)
+}
+
+// An Explainer is a Repository which can explain why a user has access to
+// an account with a role.
+type Explainer interface {
+	// ExplainAccess returns every distinct path from the user with the
+	// supplied uuid to the account and role in the cloud. The resources of
+	// its hops are not set.
+	ExplainAccess(ctx context.Context, userUuid, cloud, accountID, roleName string) ([]types.Path, error)
+}
+
+// ErrCannotExplain is returned when explaining access with a storage which
+// is not an Explainer.
+var ErrCannotExplain = errors.New("storage cannot explain access")
+
+// A Collector is a Repository which can find and delete the nodes it creates
+// as a side effect of managed resources, such as the accounts and roles
+// permission sets delegate access to, once nothing references them.
+type Collector interface {
+	// MarkOrphans marks every node nothing references as orphaned since
+	// now, unless it already is, and unmarks those which are referenced
+	// again.
+	MarkOrphans(ctx context.Context, now time.Time) error
+
+	// Orphans returns every node nothing references, orphaned since it was
+	// marked, or since now if it is not marked yet. It does not change the
+	// storage.
+	Orphans(ctx context.Context, now time.Time) ([]storetypes.Orphan, error)
+
+	// Collect deletes the supplied orphans which are still unreferenced
+	// and returns those it deleted.
+	Collect(ctx context.Context, orphans []storetypes.Orphan) ([]storetypes.Orphan, error)
+}
+
+// A Factory builds a Repository from a Config.
+type Factory func(Config) (service.Repository, error)
+
+type UnknownStorageTypeError struct {
+	Type string
+}
+
+func (e *UnknownStorageTypeError) Error() string {
+	return "unknown storage type " + `"` + e.Type + `"`
+}
+
+func IsUnknownStorageTypeErr(err error) bool {
+	_, ok := errors.Cause(err).(*UnknownStorageTypeError)
+	return ok
+}
+
+var (
+	mu       sync.RWMutex
+	backends = map[string]Factory{}
+)
+
+// Register makes a storage backend available under the supplied name. It
+// panics if a backend is registered twice, or the factory is nil.
+func Register(name string, f Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if f == nil {
+		panic("storage: Register factory is nil for " + name)
+	}
+	if _, dup := backends[name]; dup {
+		panic("storage: Register called twice for " + name)
+	}
+
+	backends[name] = f
+}
+
+// IsRegistered returns true if a backend is registered under name.
+func IsRegistered(name string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	_, ok := backends[name]
+	return ok
+}
+
+// Registered returns the sorted names of every registered backend.
+func Registered() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	names := make([]string, 0, len(backends))
+	for n := range backends {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// New builds a Repository using the backend registered for cfg.Type.
+func New(cfg Config) (service.Repository, error) {
+	mu.RLock()
+	f, ok := backends[cfg.Type]
+	mu.RUnlock()
+
+	if !ok {
+		return nil, &UnknownStorageTypeError{Type: cfg.Type}
+	}
+
+	return f(cfg)
+}
@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	xpv1 "github.com/crossplane/crossplane-runtime/apis/common/v1"
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+
+	apisv1alpha1 "github.com/VariableExp0rt/powerbroker/apis/v1alpha1"
+	"github.com/VariableExp0rt/powerbroker/internal/service"
+)
+
+func TestBuiltinBackends(t *testing.T) {
+	for _, name := range []string{apisv1alpha1.StorageTypeNeo4j, apisv1alpha1.StorageTypeSpiceDB, apisv1alpha1.StorageTypeMemory} {
+		if !IsRegistered(name) {
+			t.Errorf("IsRegistered(%q): want true, got false", name)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	errBoom := errors.New("boom")
+	Register("test-new", func(c Config) (service.Repository, error) {
+		if string(c.Credentials) != "creds" {
+			return nil, errBoom
+		}
+		return service.MockRepository{}, nil
+	})
+
+	type want struct {
+		repo service.Repository
+		err  error
+	}
+
+	cases := map[string]struct {
+		cfg  Config
+		want want
+	}{
+		"UnknownType": {
+			cfg:  Config{Type: "cassandra"},
+			want: want{err: &UnknownStorageTypeError{Type: "cassandra"}},
+		},
+		"FactoryError": {
+			cfg:  Config{Type: "test-new"},
+			want: want{err: errBoom},
+		},
+		"Success": {
+			cfg:  Config{Type: "test-new", Credentials: []byte("creds")},
+			want: want{repo: service.MockRepository{}},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			repo, err := New(tc.cfg)
+
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("New(...): -want, +got:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.repo, repo); diff != "" {
+				t.Errorf("New(...): -want, +got:\n%s", diff)
+			}
+			if tc.want.err != nil && IsUnknownStorageTypeErr(err) != (name == "UnknownType") {
+				t.Errorf("IsUnknownStorageTypeErr(...): unexpected result for %v", err)
+			}
+		})
+	}
+}
+
+func TestConfigFor(t *testing.T) {
+	pc := &apisv1alpha1.ProviderConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "default"},
+		Spec: apisv1alpha1.ProviderConfigSpec{
+			Storage: apisv1alpha1.StorageType{
+				Type:    apisv1alpha1.StorageTypeNeo4j,
+				Timeout: &metav1.Duration{Duration: 30 * time.Second},
+				Neo4j:   &apisv1alpha1.Neo4jStorage{IDStrategy: apisv1alpha1.IDStrategyNative},
+			},
+		},
+	}
+
+	want := Config{
+		Type:           apisv1alpha1.StorageTypeNeo4j,
+		ProviderConfig: "default",
+		Credentials:    []byte("creds"),
+		Timeout:        30 * time.Second,
+		Neo4j:          &apisv1alpha1.Neo4jStorage{IDStrategy: apisv1alpha1.IDStrategyNative},
+	}
+	if diff := cmp.Diff(want, ConfigFor(pc, []byte("creds"))); diff != "" {
+		t.Errorf("ConfigFor(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestResolveConfig(t *testing.T) {
+	errBoom := errors.New("boom")
+	ref := &xpv1.SecretKeySelector{
+		SecretReference: xpv1.SecretReference{Name: "neo4j-ca", Namespace: "crossplane-system"},
+		Key:             "ca.crt",
+	}
+	pc := func(tls *apisv1alpha1.Neo4jTLS) *apisv1alpha1.ProviderConfig {
+		return &apisv1alpha1.ProviderConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: "default"},
+			Spec: apisv1alpha1.ProviderConfigSpec{
+				Storage: apisv1alpha1.StorageType{
+					Type:  apisv1alpha1.StorageTypeNeo4j,
+					Neo4j: &apisv1alpha1.Neo4jStorage{TLS: tls},
+				},
+			},
+		}
+	}
+
+	type want struct {
+		ca  []byte
+		err error
+	}
+
+	cases := map[string]struct {
+		kube client.Client
+		pc   *apisv1alpha1.ProviderConfig
+		want want
+	}{
+		"NoCABundle": {
+			pc: pc(&apisv1alpha1.Neo4jTLS{}),
+		},
+		"CABundle": {
+			kube: &test.MockClient{
+				MockGet: func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+					if key.Name != ref.Name || key.Namespace != ref.Namespace {
+						return errBoom
+					}
+					obj.(*corev1.Secret).Data = map[string][]byte{"ca.crt": []byte("pem")}
+					return nil
+				},
+			},
+			pc:   pc(&apisv1alpha1.Neo4jTLS{CABundleSecretRef: ref}),
+			want: want{ca: []byte("pem")},
+		},
+		"CABundleSecretMissing": {
+			kube: &test.MockClient{MockGet: test.NewMockGetFn(errBoom)},
+			pc:   pc(&apisv1alpha1.Neo4jTLS{CABundleSecretRef: ref}),
+			want: want{err: errors.Wrap(errors.Wrap(errBoom, "cannot get credentials secret"), "cannot get neo4j CA bundle")},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := ResolveConfig(context.Background(), tc.kube, tc.pc, []byte("creds"))
+
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Errorf("ResolveConfig(...): -want error, +got error:\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.want.ca, cfg.CABundle); diff != "" {
+				t.Errorf("ResolveConfig(...): -want CA bundle, +got CA bundle:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,549 @@
+package storage
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/VariableExp0rt/powerbroker/apis/powerbroker/v1alpha1"
+	"github.com/VariableExp0rt/powerbroker/internal/service"
+	"github.com/VariableExp0rt/powerbroker/internal/service/types"
+	storetypes "github.com/VariableExp0rt/powerbroker/internal/storage/types"
+)
+
+const (
+	defaultAttempts  = 3
+	defaultBaseDelay = 100 * time.Millisecond
+	defaultMaxDelay  = 2 * time.Second
+	defaultThreshold = 5
+	defaultCooldown  = 30 * time.Second
+)
+
+// ErrBreakerOpen is wrapped in the UnavailableError a Resilient returns
+// instead of calling its storage while its circuit breaker is open.
+var ErrBreakerOpen = errors.New("circuit breaker is open")
+
+// A Degradable is a Repository which stops calling its storage for a while
+// once calls to it keep failing.
+type Degradable interface {
+	// Degraded returns true, and the time the storage will next be tried,
+	// if calls to the storage are currently being short-circuited.
+	Degraded() (time.Time, bool)
+}
+
+// A Breaker is a circuit breaker. It opens once a number of consecutive
+// calls fail because the storage is unavailable, and rejects calls until a
+// cooldown elapses. It then lets a single call through, closing again if
+// the call succeeds and reopening if it fails.
+type Breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+	probing   bool
+
+	now      func() time.Time
+	onChange func(open bool)
+}
+
+// Allow returns true if a call may be made to the storage.
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return true
+	}
+	if b.probing || b.now().Before(b.openUntil) {
+		return false
+	}
+
+	b.probing = true
+	return true
+}
+
+// Record records the outcome of a call to the storage. Calls which fail
+// because the storage is unavailable, or which time out, count towards
+// opening the breaker. Any other outcome means the storage is serving
+// requests, and closes it. Calls cancelled by their caller tell nothing
+// about the storage.
+func (b *Breaker) Record(err error) {
+	b.mu.Lock()
+
+	wasOpen := b.failures >= b.threshold
+	b.probing = false
+	switch {
+	case storetypes.IsRetryable(err), errors.Is(err, context.DeadlineExceeded):
+		b.failures++
+		if b.failures >= b.threshold {
+			b.openUntil = b.now().Add(b.cooldown)
+		}
+	case errors.Is(err, context.Canceled):
+	default:
+		b.failures = 0
+	}
+	isOpen := b.failures >= b.threshold
+
+	b.mu.Unlock()
+
+	if wasOpen != isOpen && b.onChange != nil {
+		b.onChange(isOpen)
+	}
+}
+
+// Degraded returns true, and the time the storage will next be tried, if
+// the breaker is open and its cooldown has not elapsed.
+func (b *Breaker) Degraded() (time.Time, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.openUntil, b.failures >= b.threshold && b.now().Before(b.openUntil)
+}
+
+// A Resilient is a Repository which retries calls to another Repository
+// that fail transiently, with jittered exponential backoff, and which stops
+// calling it for a while once calls keep failing.
+type Resilient struct {
+	repo     service.Repository
+	breaker  *Breaker
+	attempts int
+	base     time.Duration
+	max      time.Duration
+}
+
+// A ResilientOption configures a Resilient.
+type ResilientOption func(*Resilient)
+
+// WithRetries specifies how many times a Resilient attempts a call which
+// fails transiently, and the bounds of the backoff between attempts.
+func WithRetries(attempts int, base, max time.Duration) ResilientOption {
+	return func(r *Resilient) {
+		r.attempts = attempts
+		r.base = base
+		r.max = max
+	}
+}
+
+// WithBreaker specifies how many consecutive calls must fail before a
+// Resilient stops calling its storage, and for how long it stops.
+func WithBreaker(threshold int, cooldown time.Duration) ResilientOption {
+	return func(r *Resilient) {
+		r.breaker.threshold = threshold
+		r.breaker.cooldown = cooldown
+	}
+}
+
+// WithBreakerChange specifies a function a Resilient calls whenever its
+// circuit breaker opens or closes.
+func WithBreakerChange(f func(open bool)) ResilientOption {
+	return func(r *Resilient) {
+		r.breaker.onChange = f
+	}
+}
+
+// NewResilient returns a Resilient which calls the supplied Repository.
+func NewResilient(repo service.Repository, o ...ResilientOption) *Resilient {
+	r := &Resilient{
+		repo: repo,
+		breaker: &Breaker{
+			threshold: defaultThreshold,
+			cooldown:  defaultCooldown,
+			now:       time.Now,
+		},
+		attempts: defaultAttempts,
+		base:     defaultBaseDelay,
+		max:      defaultMaxDelay,
+	}
+
+	for _, ro := range o {
+		ro(r)
+	}
+
+	return r
+}
+
+var (
+	watchersMu sync.RWMutex
+	watchers   []func(providerConfig string)
+)
+
+// WatchBreakers calls f with the name of a ProviderConfig whenever the
+// circuit breaker of its storage opens or closes.
+func WatchBreakers(f func(providerConfig string)) {
+	watchersMu.Lock()
+	defer watchersMu.Unlock()
+
+	watchers = append(watchers, f)
+}
+
+// WithResilience returns a Factory which wraps the Repositories built by f
+// in a Resilient. The watchers registered with WatchBreakers are told when
+// the circuit breaker of a Repository it built opens or closes.
+func WithResilience(f Factory, o ...ResilientOption) Factory {
+	return func(cfg Config) (service.Repository, error) {
+		repo, err := f(cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		notify := WithBreakerChange(func(bool) {
+			watchersMu.RLock()
+			defer watchersMu.RUnlock()
+
+			for _, w := range watchers {
+				w(cfg.ProviderConfig)
+			}
+		})
+
+		return NewResilient(repo, append(append([]ResilientOption{}, o...), notify)...), nil
+	}
+}
+
+// Degraded returns true, and the time the storage will next be tried, if
+// calls to the storage are currently being short-circuited.
+func (r *Resilient) Degraded() (time.Time, bool) {
+	return r.breaker.Degraded()
+}
+
+// Close closes the underlying Repository, if it is a Closer.
+func (r *Resilient) Close() error {
+	return closeRepo(r.repo)
+}
+
+// MissingCapabilities returns the capabilities the underlying Repository
+// lacks, if it is a CapabilityChecker.
+func (r *Resilient) MissingCapabilities(ctx context.Context) ([]string, error) {
+	c, ok := r.repo.(CapabilityChecker)
+	if !ok {
+		return nil, nil
+	}
+
+	return call(ctx, r, c.MissingCapabilities)
+}
+
+// Migrate migrates the schema of the underlying Repository, if it is a
+// Migrator. It returns a schema version of zero otherwise.
+func (r *Resilient) Migrate(ctx context.Context) (int, error) {
+	m, ok := r.repo.(Migrator)
+	if !ok {
+		return 0, nil
+	}
+
+	return call(ctx, r, m.Migrate)
+}
+
+// MarkOrphans marks the orphaned nodes of the underlying Repository, if it
+// is a Collector. It marks none otherwise.
+func (r *Resilient) MarkOrphans(ctx context.Context, now time.Time) error {
+	c, ok := r.repo.(Collector)
+	if !ok {
+		return nil
+	}
+
+	return do(ctx, r, func(ctx context.Context) error {
+		return c.MarkOrphans(ctx, now)
+	})
+}
+
+// Orphans returns the orphaned nodes of the underlying Repository, if it is
+// a Collector. It returns none otherwise.
+func (r *Resilient) Orphans(ctx context.Context, now time.Time) ([]storetypes.Orphan, error) {
+	c, ok := r.repo.(Collector)
+	if !ok {
+		return nil, nil
+	}
+
+	return call(ctx, r, func(ctx context.Context) ([]storetypes.Orphan, error) {
+		return c.Orphans(ctx, now)
+	})
+}
+
+// Collect deletes orphaned nodes of the underlying Repository, if it is a
+// Collector. It deletes none otherwise.
+func (r *Resilient) Collect(ctx context.Context, orphans []storetypes.Orphan) ([]storetypes.Orphan, error) {
+	c, ok := r.repo.(Collector)
+	if !ok {
+		return nil, nil
+	}
+
+	return call(ctx, r, func(ctx context.Context) ([]storetypes.Orphan, error) {
+		return c.Collect(ctx, orphans)
+	})
+}
+
+// ExplainAccess explains access with the underlying Repository, if it is an
+// Explainer. It returns ErrCannotExplain otherwise.
+func (r *Resilient) ExplainAccess(ctx context.Context, userUuid, cloud, accountID, roleName string) ([]types.Path, error) {
+	e, ok := r.repo.(Explainer)
+	if !ok {
+		return nil, ErrCannotExplain
+	}
+
+	return call(ctx, r, func(ctx context.Context) ([]types.Path, error) {
+		return e.ExplainAccess(ctx, userUuid, cloud, accountID, roleName)
+	})
+}
+
+// call calls f, retrying it while it fails transiently, unless the circuit
+// breaker is open.
+func call[T any](ctx context.Context, r *Resilient, f func(context.Context) (T, error)) (T, error) {
+	if !r.breaker.Allow() {
+		var zero T
+		return zero, &storetypes.UnavailableError{Err: ErrBreakerOpen}
+	}
+
+	out, err := f(ctx)
+	for attempt := 1; attempt < r.attempts && storetypes.IsRetryable(err); attempt++ {
+		if !sleep(ctx, r.backoff(attempt)) {
+			break
+		}
+		out, err = f(ctx)
+	}
+	r.breaker.Record(err)
+
+	return out, err
+}
+
+// do is call for functions which only return an error.
+func do(ctx context.Context, r *Resilient, f func(context.Context) error) error {
+	_, err := call(ctx, r, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, f(ctx)
+	})
+
+	return err
+}
+
+// backoff returns a random delay of up to base * 2^(attempt-1), bounded by
+// max.
+func (r *Resilient) backoff(attempt int) time.Duration {
+	d := r.base << (attempt - 1)
+	if d > r.max || d <= 0 {
+		d = r.max
+	}
+	if d <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(d)))
+}
+
+// sleep returns true once d elapses, or false if ctx is done first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (r *Resilient) CreateUser(ctx context.Context, uid, userName string, personaRefs []string) (string, error) {
+	return call(ctx, r, func(ctx context.Context) (string, error) {
+		return r.repo.CreateUser(ctx, uid, userName, personaRefs)
+	})
+}
+
+func (r *Resilient) LookupUser(ctx context.Context, uid string) (string, error) {
+	return call(ctx, r, func(ctx context.Context) (string, error) {
+		return r.repo.LookupUser(ctx, uid)
+	})
+}
+
+func (r *Resilient) LookupUserByName(ctx context.Context, name string) (string, error) {
+	return call(ctx, r, func(ctx context.Context) (string, error) {
+		return r.repo.LookupUserByName(ctx, name)
+	})
+}
+
+func (r *Resilient) GetUser(ctx context.Context, uuid string) (*types.GetUserResponse, error) {
+	return call(ctx, r, func(ctx context.Context) (*types.GetUserResponse, error) {
+		return r.repo.GetUser(ctx, uuid)
+	})
+}
+
+func (r *Resilient) GetEffectiveAccess(ctx context.Context, userUuid string) ([]types.Access, error) {
+	return call(ctx, r, func(ctx context.Context) ([]types.Access, error) {
+		return r.repo.GetEffectiveAccess(ctx, userUuid)
+	})
+}
+
+func (r *Resilient) UpdateUser(ctx context.Context, userName string, userUuid string, personaRefs []string) (types.Changes, error) {
+	return call(ctx, r, func(ctx context.Context) (types.Changes, error) {
+		return r.repo.UpdateUser(ctx, userName, userUuid, personaRefs)
+	})
+}
+
+func (r *Resilient) DeleteUser(ctx context.Context, uuid string) error {
+	return do(ctx, r, func(ctx context.Context) error {
+		return r.repo.DeleteUser(ctx, uuid)
+	})
+}
+
+func (r *Resilient) CreatePersona(ctx context.Context, uid, personaName string, permissionSetRefs []string) (string, error) {
+	return call(ctx, r, func(ctx context.Context) (string, error) {
+		return r.repo.CreatePersona(ctx, uid, personaName, permissionSetRefs)
+	})
+}
+
+func (r *Resilient) LookupPersona(ctx context.Context, uid string) (string, error) {
+	return call(ctx, r, func(ctx context.Context) (string, error) {
+		return r.repo.LookupPersona(ctx, uid)
+	})
+}
+
+func (r *Resilient) LookupPersonaByName(ctx context.Context, name string) (string, error) {
+	return call(ctx, r, func(ctx context.Context) (string, error) {
+		return r.repo.LookupPersonaByName(ctx, name)
+	})
+}
+
+func (r *Resilient) GetPersona(ctx context.Context, uuid string) (*types.GetPersonaResponse, error) {
+	return call(ctx, r, func(ctx context.Context) (*types.GetPersonaResponse, error) {
+		return r.repo.GetPersona(ctx, uuid)
+	})
+}
+
+func (r *Resilient) UpdatePersona(ctx context.Context, personaName string, personaUuid string, permissionSetUuids []string) (types.Changes, error) {
+	return call(ctx, r, func(ctx context.Context) (types.Changes, error) {
+		return r.repo.UpdatePersona(ctx, personaName, personaUuid, permissionSetUuids)
+	})
+}
+
+func (r *Resilient) DeletePersona(ctx context.Context, uuid string) error {
+	return do(ctx, r, func(ctx context.Context) error {
+		return r.repo.DeletePersona(ctx, uuid)
+	})
+}
+
+func (r *Resilient) CreatePermissionSet(ctx context.Context, uid, name string, bindings []v1alpha1.AccountRoleBinding) (string, error) {
+	return call(ctx, r, func(ctx context.Context) (string, error) {
+		return r.repo.CreatePermissionSet(ctx, uid, name, bindings)
+	})
+}
+
+func (r *Resilient) LookupPermissionSet(ctx context.Context, uid string) (string, error) {
+	return call(ctx, r, func(ctx context.Context) (string, error) {
+		return r.repo.LookupPermissionSet(ctx, uid)
+	})
+}
+
+func (r *Resilient) GetPermissionSet(ctx context.Context, uuid string) (*types.GetPermissionSetResponse, error) {
+	return call(ctx, r, func(ctx context.Context) (*types.GetPermissionSetResponse, error) {
+		return r.repo.GetPermissionSet(ctx, uuid)
+	})
+}
+
+func (r *Resilient) UpdatePermissionSet(ctx context.Context, permissionSetUuid, crName string, bindings []v1alpha1.AccountRoleBinding) (types.Changes, error) {
+	return call(ctx, r, func(ctx context.Context) (types.Changes, error) {
+		return r.repo.UpdatePermissionSet(ctx, permissionSetUuid, crName, bindings)
+	})
+}
+
+func (r *Resilient) DeletePermissionSet(ctx context.Context, uuid string) error {
+	return do(ctx, r, func(ctx context.Context) error {
+		return r.repo.DeletePermissionSet(ctx, uuid)
+	})
+}
+
+func (r *Resilient) CreateTeam(ctx context.Context, uid string, teamparams *v1alpha1.TeamParameters) (string, error) {
+	return call(ctx, r, func(ctx context.Context) (string, error) {
+		return r.repo.CreateTeam(ctx, uid, teamparams)
+	})
+}
+
+func (r *Resilient) LookupTeam(ctx context.Context, uid string) (string, error) {
+	return call(ctx, r, func(ctx context.Context) (string, error) {
+		return r.repo.LookupTeam(ctx, uid)
+	})
+}
+
+func (r *Resilient) GetTeam(ctx context.Context, uuid string) (*types.GetTeamResponse, error) {
+	return call(ctx, r, func(ctx context.Context) (*types.GetTeamResponse, error) {
+		return r.repo.GetTeam(ctx, uuid)
+	})
+}
+
+func (r *Resilient) UpdateTeam(ctx context.Context, uuid string, teamparams *v1alpha1.TeamParameters) (types.Changes, error) {
+	return call(ctx, r, func(ctx context.Context) (types.Changes, error) {
+		return r.repo.UpdateTeam(ctx, uuid, teamparams)
+	})
+}
+
+func (r *Resilient) DeleteTeam(ctx context.Context, uuid string) error {
+	return do(ctx, r, func(ctx context.Context) error {
+		return r.repo.DeleteTeam(ctx, uuid)
+	})
+}
+
+func (r *Resilient) CreateAccount(ctx context.Context, uid string, account *v1alpha1.AccountParameters) (string, error) {
+	return call(ctx, r, func(ctx context.Context) (string, error) {
+		return r.repo.CreateAccount(ctx, uid, account)
+	})
+}
+
+func (r *Resilient) LookupAccount(ctx context.Context, uid string) (string, error) {
+	return call(ctx, r, func(ctx context.Context) (string, error) {
+		return r.repo.LookupAccount(ctx, uid)
+	})
+}
+
+func (r *Resilient) GetAccount(ctx context.Context, cloud, id string) (*types.GetAccountResponse, error) {
+	return call(ctx, r, func(ctx context.Context) (*types.GetAccountResponse, error) {
+		return r.repo.GetAccount(ctx, cloud, id)
+	})
+}
+
+func (r *Resilient) UpdateAccount(ctx context.Context, cloud, id string, account *v1alpha1.AccountParameters) error {
+	return do(ctx, r, func(ctx context.Context) error {
+		return r.repo.UpdateAccount(ctx, cloud, id, account)
+	})
+}
+
+func (r *Resilient) DeleteAccount(ctx context.Context, cloud, id string) error {
+	return do(ctx, r, func(ctx context.Context) error {
+		return r.repo.DeleteAccount(ctx, cloud, id)
+	})
+}
+
+func (r *Resilient) WhoCanAccess(ctx context.Context, cloud, accountID, roleName string) ([]types.Grant, error) {
+	return call(ctx, r, func(ctx context.Context) ([]types.Grant, error) {
+		return r.repo.WhoCanAccess(ctx, cloud, accountID, roleName)
+	})
+}
+
+func (r *Resilient) CreateRole(ctx context.Context, uid string, role *v1alpha1.RoleParameters) (string, error) {
+	return call(ctx, r, func(ctx context.Context) (string, error) {
+		return r.repo.CreateRole(ctx, uid, role)
+	})
+}
+
+func (r *Resilient) LookupRole(ctx context.Context, uid string) (string, error) {
+	return call(ctx, r, func(ctx context.Context) (string, error) {
+		return r.repo.LookupRole(ctx, uid)
+	})
+}
+
+func (r *Resilient) GetRole(ctx context.Context, cloud, name string) (*types.GetRoleResponse, error) {
+	return call(ctx, r, func(ctx context.Context) (*types.GetRoleResponse, error) {
+		return r.repo.GetRole(ctx, cloud, name)
+	})
+}
+
+func (r *Resilient) UpdateRole(ctx context.Context, cloud, name string, role *v1alpha1.RoleParameters) error {
+	return do(ctx, r, func(ctx context.Context) error {
+		return r.repo.UpdateRole(ctx, cloud, name, role)
+	})
+}
+
+func (r *Resilient) DeleteRole(ctx context.Context, cloud, name string) error {
+	return do(ctx, r, func(ctx context.Context) error {
+		return r.repo.DeleteRole(ctx, cloud, name)
+	})
+}
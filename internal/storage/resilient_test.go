@@ -0,0 +1,195 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/VariableExp0rt/powerbroker/internal/service"
+	"github.com/VariableExp0rt/powerbroker/internal/service/types"
+	storetypes "github.com/VariableExp0rt/powerbroker/internal/storage/types"
+)
+
+var (
+	errTransient   = &storetypes.TransientError{Err: errors.New("deadlock")}
+	errUnavailable = &storetypes.UnavailableError{Err: errors.New("connection refused")}
+	errNotFound    = &storetypes.EntityNotFoundError{}
+)
+
+// failing returns a Repository whose GetUser fails with each of the supplied
+// errors in turn, then succeeds, and a pointer to the number of calls made.
+func failing(errs ...error) (service.Repository, *int) {
+	calls := 0
+	return service.MockRepository{
+		MockGetUser: func(context.Context, string) (*types.GetUserResponse, error) {
+			calls++
+			if calls <= len(errs) {
+				return nil, errs[calls-1]
+			}
+			return &types.GetUserResponse{NodeID: "cool-uuid"}, nil
+		},
+	}, &calls
+}
+
+func TestResilientRetries(t *testing.T) {
+	cases := map[string]struct {
+		errs  []error
+		calls int
+		err   error
+	}{
+		"Succeeds": {
+			calls: 1,
+		},
+		"RetriesTransient": {
+			errs:  []error{errTransient, errUnavailable},
+			calls: 3,
+		},
+		"GivesUpAfterAttempts": {
+			errs:  []error{errTransient, errTransient, errTransient, errTransient},
+			calls: 3,
+			err:   errTransient,
+		},
+		"DoesNotRetryOtherErrors": {
+			errs:  []error{errNotFound},
+			calls: 1,
+			err:   errNotFound,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			repo, calls := failing(tc.errs...)
+			r := NewResilient(repo, WithRetries(3, 0, 0))
+
+			_, err := r.GetUser(context.Background(), "cool-uuid")
+			if err != tc.err {
+				t.Errorf("GetUser(...): want error %v, got %v", tc.err, err)
+			}
+			if *calls != tc.calls {
+				t.Errorf("GetUser(...): want %d calls, got %d", tc.calls, *calls)
+			}
+		})
+	}
+}
+
+func TestResilientStopsRetryingWhenDone(t *testing.T) {
+	repo, calls := failing(errTransient, errTransient, errTransient)
+	r := NewResilient(repo, WithRetries(3, time.Hour, time.Hour))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := r.GetUser(ctx, "cool-uuid"); err != errTransient {
+		t.Errorf("GetUser(...): want error %v, got %v", errTransient, err)
+	}
+	if *calls != 1 {
+		t.Errorf("GetUser(...): want 1 call, got %d", *calls)
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	now := time.Now()
+	var changes []bool
+
+	repo, calls := failing(errUnavailable, errUnavailable, errUnavailable)
+	r := NewResilient(repo,
+		WithRetries(1, 0, 0),
+		WithBreaker(2, time.Minute),
+		WithBreakerChange(func(open bool) { changes = append(changes, open) }))
+	r.breaker.now = func() time.Time { return now }
+
+	get := func() error {
+		_, err := r.GetUser(context.Background(), "cool-uuid")
+		return err
+	}
+
+	// Two consecutive failures open the breaker.
+	_ = get()
+	if _, degraded := r.Degraded(); degraded {
+		t.Errorf("Degraded(): want false below the threshold")
+	}
+	_ = get()
+	until, degraded := r.Degraded()
+	if !degraded || !until.Equal(now.Add(time.Minute)) {
+		t.Errorf("Degraded(): want true until %v, got %t until %v", now.Add(time.Minute), degraded, until)
+	}
+
+	// Calls are rejected without reaching the storage while it is open.
+	if err := get(); !errors.Is(err, ErrBreakerOpen) || !storetypes.IsUnavailableError(err) {
+		t.Errorf("GetUser(...): want unavailable error wrapping %v, got %v", ErrBreakerOpen, err)
+	}
+	if *calls != 2 {
+		t.Errorf("GetUser(...): want 2 calls while open, got %d", *calls)
+	}
+
+	// A failed probe once the cooldown elapses opens it again.
+	now = now.Add(time.Minute)
+	if _, degraded := r.Degraded(); degraded {
+		t.Errorf("Degraded(): want false once the cooldown elapses")
+	}
+	if err := get(); err != errUnavailable {
+		t.Errorf("GetUser(...): want error %v from probe, got %v", errUnavailable, err)
+	}
+	if _, degraded := r.Degraded(); !degraded {
+		t.Errorf("Degraded(): want true after a failed probe")
+	}
+
+	// A successful probe closes it.
+	now = now.Add(time.Minute)
+	if err := get(); err != nil {
+		t.Errorf("GetUser(...): %v", err)
+	}
+	if err := get(); err != nil {
+		t.Errorf("GetUser(...): want calls to reach the storage once closed, got %v", err)
+	}
+	if *calls != 5 {
+		t.Errorf("GetUser(...): want 5 calls, got %d", *calls)
+	}
+
+	if len(changes) != 2 || !changes[0] || changes[1] {
+		t.Errorf("WithBreakerChange(...): want the breaker to open then close, got %v", changes)
+	}
+}
+
+func TestBreakerIgnoresOtherErrors(t *testing.T) {
+	repo, _ := failing(errUnavailable, errNotFound, errUnavailable)
+	r := NewResilient(repo, WithRetries(1, 0, 0), WithBreaker(2, time.Minute))
+
+	for i := 0; i < 3; i++ {
+		_, _ = r.GetUser(context.Background(), "cool-uuid")
+	}
+
+	if _, degraded := r.Degraded(); degraded {
+		t.Errorf("Degraded(): want an error which is not the storage's fault to reset the failures")
+	}
+}
+
+type migrator struct {
+	service.MockRepository
+
+	version int
+}
+
+func (m *migrator) Migrate(context.Context) (int, error) {
+	return m.version, nil
+}
+
+func TestResilientForwardsOptionalInterfaces(t *testing.T) {
+	r := NewResilient(&migrator{version: 2})
+	if v, err := r.Migrate(context.Background()); v != 2 || err != nil {
+		t.Errorf("Migrate(): want version 2 of the underlying Migrator, got %d, %v", v, err)
+	}
+	if missing, err := r.MissingCapabilities(context.Background()); missing != nil || err != nil {
+		t.Errorf("MissingCapabilities(): want none from a Repository which is not a CapabilityChecker, got %v, %v", missing, err)
+	}
+	if orphans, err := r.Orphans(context.Background(), time.Now()); orphans != nil || err != nil {
+		t.Errorf("Orphans(): want none from a Repository which is not a Collector, got %v, %v", orphans, err)
+	}
+
+	c := &closable{}
+	if err := NewResilient(c).Close(); err != nil || !c.closed {
+		t.Errorf("Close(): want the underlying Repository closed")
+	}
+}
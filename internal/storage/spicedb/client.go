@@ -0,0 +1,272 @@
+package spicedb
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+/*
+
+Client is a deliberately small client for the SpiceDB v1 HTTP gateway
+(`spicedb serve --http-enabled`). Only the handful of permissions service
+and schema service endpoints the repository needs are implemented, which
+keeps the gRPC client (and its dependency tree) out of the provider.
+
+Request and response bodies follow the grpc-gateway JSON mapping of the
+authzed.api.v1 protobufs, i.e. lowerCamelCase field names and enums encoded
+as their string names.
+*/
+
+const (
+	pathWriteSchema         = "/v1/schema/write"
+	pathWriteRelationships  = "/v1/relationships/write"
+	pathReadRelationships   = "/v1/relationships/read"
+	pathDeleteRelationships = "/v1/relationships/delete"
+)
+
+// Relationship update operations.
+const (
+	OperationCreate = "OPERATION_CREATE"
+	OperationTouch  = "OPERATION_TOUCH"
+	OperationDelete = "OPERATION_DELETE"
+)
+
+// Precondition operations.
+const (
+	OperationMustMatch    = "OPERATION_MUST_MATCH"
+	OperationMustNotMatch = "OPERATION_MUST_NOT_MATCH"
+)
+
+type ObjectReference struct {
+	ObjectType string `json:"objectType"`
+	ObjectID   string `json:"objectId"`
+}
+
+type SubjectReference struct {
+	Object           ObjectReference `json:"object"`
+	OptionalRelation string          `json:"optionalRelation,omitempty"`
+}
+
+type Relationship struct {
+	Resource ObjectReference  `json:"resource"`
+	Relation string           `json:"relation"`
+	Subject  SubjectReference `json:"subject"`
+}
+
+type RelationshipUpdate struct {
+	Operation    string       `json:"operation"`
+	Relationship Relationship `json:"relationship"`
+}
+
+type SubjectRelationFilter struct {
+	Relation string `json:"relation"`
+}
+
+type SubjectFilter struct {
+	SubjectType       string                 `json:"subjectType"`
+	OptionalSubjectID string                 `json:"optionalSubjectId,omitempty"`
+	OptionalRelation  *SubjectRelationFilter `json:"optionalRelation,omitempty"`
+}
+
+type RelationshipFilter struct {
+	ResourceType          string         `json:"resourceType"`
+	OptionalResourceID    string         `json:"optionalResourceId,omitempty"`
+	OptionalRelation      string         `json:"optionalRelation,omitempty"`
+	OptionalSubjectFilter *SubjectFilter `json:"optionalSubjectFilter,omitempty"`
+}
+
+type Precondition struct {
+	Operation string             `json:"operation"`
+	Filter    RelationshipFilter `json:"filter"`
+}
+
+type ZedToken struct {
+	Token string `json:"token"`
+}
+
+type Consistency struct {
+	FullyConsistent bool `json:"fullyConsistent,omitempty"`
+}
+
+type WriteSchemaRequest struct {
+	Schema string `json:"schema"`
+}
+
+type WriteRelationshipsRequest struct {
+	Updates               []RelationshipUpdate `json:"updates"`
+	OptionalPreconditions []Precondition       `json:"optionalPreconditions,omitempty"`
+}
+
+type WriteRelationshipsResponse struct {
+	WrittenAt ZedToken `json:"writtenAt"`
+}
+
+type ReadRelationshipsRequest struct {
+	Consistency        *Consistency       `json:"consistency,omitempty"`
+	RelationshipFilter RelationshipFilter `json:"relationshipFilter"`
+}
+
+type ReadRelationshipsResponse struct {
+	ReadAt       ZedToken     `json:"readAt"`
+	Relationship Relationship `json:"relationship"`
+}
+
+type DeleteRelationshipsRequest struct {
+	RelationshipFilter    RelationshipFilter `json:"relationshipFilter"`
+	OptionalPreconditions []Precondition     `json:"optionalPreconditions,omitempty"`
+}
+
+type DeleteRelationshipsResponse struct {
+	DeletedAt ZedToken `json:"deletedAt"`
+}
+
+// Error is the gateway representation of a gRPC status.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("spicedb: rpc error: code = %d desc = %s", e.Code, e.Message)
+}
+
+// gRPC status codes surfaced by the gateway which the repository inspects.
+const (
+	CodeNotFound           = 5
+	CodeAlreadyExists      = 6
+	CodeFailedPrecondition = 9
+)
+
+func IsFailedPreconditionErr(err error) bool {
+	serr, ok := errors.Cause(err).(*Error)
+	return ok && serr.Code == CodeFailedPrecondition
+}
+
+type Client struct {
+	Endpoint   string
+	Token      string
+	HTTPClient *http.Client
+}
+
+func NewClient(endpoint, token string) *Client {
+	return &Client{
+		Endpoint:   strings.TrimSuffix(endpoint, "/"),
+		Token:      token,
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+func (c *Client) WriteSchema(ctx context.Context, schema string) error {
+	return c.do(ctx, pathWriteSchema, &WriteSchemaRequest{Schema: schema}, nil)
+}
+
+func (c *Client) WriteRelationships(ctx context.Context, req *WriteRelationshipsRequest) (*WriteRelationshipsResponse, error) {
+	resp := &WriteRelationshipsResponse{}
+	if err := c.do(ctx, pathWriteRelationships, req, resp); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (c *Client) DeleteRelationships(ctx context.Context, req *DeleteRelationshipsRequest) (*DeleteRelationshipsResponse, error) {
+	resp := &DeleteRelationshipsResponse{}
+	if err := c.do(ctx, pathDeleteRelationships, req, resp); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// ReadRelationships drains the server stream returned by the gateway. Each
+// line of the body is a JSON object holding either a result or an error.
+func (c *Client) ReadRelationships(ctx context.Context, req *ReadRelationshipsRequest) ([]Relationship, error) {
+	body, err := c.post(ctx, pathReadRelationships, req)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var rels []Relationship
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var msg struct {
+			Result *ReadRelationshipsResponse `json:"result"`
+			Error  *Error                     `json:"error"`
+		}
+		if err := json.Unmarshal(line, &msg); err != nil {
+			return nil, errors.Wrap(err, "cannot decode read relationships stream")
+		}
+		if msg.Error != nil {
+			return nil, msg.Error
+		}
+		if msg.Result != nil {
+			rels = append(rels, msg.Result.Relationship)
+		}
+	}
+
+	return rels, errors.Wrap(scanner.Err(), "cannot read relationships stream")
+}
+
+func (c *Client) do(ctx context.Context, path string, in, out interface{}) error {
+	body, err := c.post(ctx, path, in)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	if out == nil {
+		_, err = io.Copy(io.Discard, body)
+		return err
+	}
+
+	return errors.Wrap(json.NewDecoder(body).Decode(out), "cannot decode response")
+}
+
+func (c *Client) post(ctx context.Context, path string, in interface{}) (io.ReadCloser, error) {
+	b, err := json.Marshal(in)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot encode request")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.Endpoint+path, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+
+		serr := &Error{}
+		if err := json.NewDecoder(resp.Body).Decode(serr); err != nil || serr.Message == "" {
+			return nil, errors.Errorf("spicedb: unexpected response status %s", resp.Status)
+		}
+		return nil, serr
+	}
+
+	return resp.Body, nil
+}
@@ -0,0 +1,224 @@
+package fake
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/VariableExp0rt/powerbroker/internal/storage/spicedb"
+)
+
+// Server is an in-process stand-in for the SpiceDB HTTP gateway. It keeps
+// relationships in memory and implements the subset of the v1 API used by
+// the spicedb store, which is enough to exercise the store without a real
+// SpiceDB binary. Permissions are never computed.
+type Server struct {
+	*httptest.Server
+
+	Token string
+
+	mu     sync.Mutex
+	schema string
+	rels   map[spicedb.Relationship]bool
+	token  int
+}
+
+// NewServer starts a Server. Requests must carry the supplied bearer token,
+// unless it is empty. Callers must Close the server when done.
+func NewServer(token string) *Server {
+	s := &Server{Token: token, rels: map[spicedb.Relationship]bool{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/schema/write", s.authenticated(s.writeSchema))
+	mux.HandleFunc("/v1/relationships/write", s.authenticated(s.writeRelationships))
+	mux.HandleFunc("/v1/relationships/read", s.authenticated(s.readRelationships))
+	mux.HandleFunc("/v1/relationships/delete", s.authenticated(s.deleteRelationships))
+
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Schema returns the last schema written to the server.
+func (s *Server) Schema() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.schema
+}
+
+// Relationships returns a snapshot of every stored relationship.
+func (s *Server) Relationships() []spicedb.Relationship {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]spicedb.Relationship, 0, len(s.rels))
+	for r := range s.rels {
+		out = append(out, r)
+	}
+
+	return out
+}
+
+func (s *Server) authenticated(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.Token != "" && r.Header.Get("Authorization") != "Bearer "+s.Token {
+			fail(w, http.StatusUnauthorized, 16, "invalid preshared key")
+			return
+		}
+		if r.Method != http.MethodPost {
+			fail(w, http.StatusMethodNotAllowed, 12, "method not allowed")
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		next(w, r)
+	}
+}
+
+func (s *Server) writeSchema(w http.ResponseWriter, r *http.Request) {
+	req := &spicedb.WriteSchemaRequest{}
+	if !decode(w, r, req) {
+		return
+	}
+	if strings.TrimSpace(req.Schema) == "" {
+		fail(w, http.StatusBadRequest, 3, "empty schema")
+		return
+	}
+
+	s.schema = req.Schema
+	reply(w, struct{}{})
+}
+
+func (s *Server) writeRelationships(w http.ResponseWriter, r *http.Request) {
+	req := &spicedb.WriteRelationshipsRequest{}
+	if !decode(w, r, req) {
+		return
+	}
+
+	for _, p := range req.OptionalPreconditions {
+		matched := len(s.match(p.Filter)) > 0
+		if (p.Operation == spicedb.OperationMustMatch) != matched {
+			fail(w, http.StatusBadRequest, spicedb.CodeFailedPrecondition, "unable to satisfy write precondition")
+			return
+		}
+	}
+
+	seen := map[spicedb.Relationship]bool{}
+	for _, u := range req.Updates {
+		if seen[u.Relationship] {
+			fail(w, http.StatusBadRequest, 3, "found duplicate update operation for relationship")
+			return
+		}
+		seen[u.Relationship] = true
+
+		if u.Operation == spicedb.OperationCreate && s.rels[u.Relationship] {
+			fail(w, http.StatusConflict, spicedb.CodeAlreadyExists, "could not CREATE relationship, as it already existed")
+			return
+		}
+	}
+
+	for _, u := range req.Updates {
+		switch u.Operation {
+		case spicedb.OperationCreate, spicedb.OperationTouch:
+			s.rels[u.Relationship] = true
+		case spicedb.OperationDelete:
+			delete(s.rels, u.Relationship)
+		}
+	}
+
+	reply(w, &spicedb.WriteRelationshipsResponse{WrittenAt: s.revision()})
+}
+
+func (s *Server) readRelationships(w http.ResponseWriter, r *http.Request) {
+	req := &spicedb.ReadRelationshipsRequest{}
+	if !decode(w, r, req) {
+		return
+	}
+
+	at := s.revision()
+	enc := json.NewEncoder(w)
+	for _, rel := range s.match(req.RelationshipFilter) {
+		_ = enc.Encode(map[string]interface{}{
+			"result": &spicedb.ReadRelationshipsResponse{ReadAt: at, Relationship: rel},
+		})
+	}
+}
+
+func (s *Server) deleteRelationships(w http.ResponseWriter, r *http.Request) {
+	req := &spicedb.DeleteRelationshipsRequest{}
+	if !decode(w, r, req) {
+		return
+	}
+
+	for _, rel := range s.match(req.RelationshipFilter) {
+		delete(s.rels, rel)
+	}
+
+	reply(w, &spicedb.DeleteRelationshipsResponse{DeletedAt: s.revision()})
+}
+
+func (s *Server) match(f spicedb.RelationshipFilter) []spicedb.Relationship {
+	var out []spicedb.Relationship
+	for rel := range s.rels {
+		if matches(f, rel) {
+			out = append(out, rel)
+		}
+	}
+
+	return out
+}
+
+func (s *Server) revision() spicedb.ZedToken {
+	s.token++
+	return spicedb.ZedToken{Token: strconv.Itoa(s.token)}
+}
+
+func matches(f spicedb.RelationshipFilter, r spicedb.Relationship) bool {
+	if f.ResourceType != r.Resource.ObjectType {
+		return false
+	}
+	if f.OptionalResourceID != "" && f.OptionalResourceID != r.Resource.ObjectID {
+		return false
+	}
+	if f.OptionalRelation != "" && f.OptionalRelation != r.Relation {
+		return false
+	}
+
+	sf := f.OptionalSubjectFilter
+	if sf == nil {
+		return true
+	}
+	if sf.SubjectType != r.Subject.Object.ObjectType {
+		return false
+	}
+	if sf.OptionalSubjectID != "" && sf.OptionalSubjectID != r.Subject.Object.ObjectID {
+		return false
+	}
+
+	return sf.OptionalRelation == nil || sf.OptionalRelation.Relation == r.Subject.OptionalRelation
+}
+
+func decode(w http.ResponseWriter, r *http.Request, into interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(into); err != nil {
+		fail(w, http.StatusBadRequest, 3, err.Error())
+		return false
+	}
+
+	return true
+}
+
+func reply(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func fail(w http.ResponseWriter, status, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(&spicedb.Error{Code: code, Message: msg})
+}
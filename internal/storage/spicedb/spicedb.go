@@ -0,0 +1,1536 @@
+package spicedb
+
+import (
+	"context"
+	_ "embed"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/VariableExp0rt/powerbroker/apis/powerbroker/v1alpha1"
+	"github.com/VariableExp0rt/powerbroker/internal/service/types"
+	storetypes "github.com/VariableExp0rt/powerbroker/internal/storage/types"
+)
+
+// Schema is the SpiceDB schema the repository writes relationships under.
+//
+//go:embed schema.zed
+var Schema string
+
+const (
+	typePlatform      = "powerbroker/platform"
+	typeLabel         = "powerbroker/label"
+	typeUser          = "powerbroker/user"
+	typeTeam          = "powerbroker/team"
+	typePersona       = "powerbroker/persona"
+	typePermissionSet = "powerbroker/permissionset"
+	typeAccount       = "powerbroker/account"
+	typeRole          = "powerbroker/role"
+	typeBinding       = "powerbroker/binding"
+
+	relRegistry = "registry"
+	relName     = "name"
+	relOwner    = "owner"
+	relMember   = "member"
+	relManager  = "manager"
+	relGrantee  = "grantee"
+	relPersona  = "persona"
+	relAlias    = "alias"
+	relClass    = "class"
+	relDelegate = "delegate"
+
+	relPermissionSet = "permissionset"
+	relAccount       = "account"
+	relRole          = "role"
+	relScope         = "scope"
+	relCloud         = "cloud"
+
+	relAccountOwner       = "account_owner"
+	relEnvironment        = "environment"
+	relLifecycle          = "lifecycle"
+	relDescription        = "description"
+	relTier               = "tier"
+	relPolicy             = "policy"
+	relMaxSessionDuration = "max_session_duration"
+
+	// maxChunk is the length of the longest encoded label written. SpiceDB
+	// limits object ids to 1024 characters, so longer values, such as
+	// policy documents, are split into several labels.
+	maxChunk = 1000
+
+	platformID = "powerbroker"
+)
+
+/*
+
+Entities map onto SpiceDB as follows, mirroring the edges written by the
+neo4j store:
+
+- (:User)-[:GRANTED]->(:Persona)            persona:P#grantee@user:U
+- (:Team)-[:INHERITS]->(:Persona)           persona:P#grantee@team:T#member
+- (:User)-[:MEMBER_OF]->(:Team)             team:T#member@user:U
+- (:Team)-[:MANAGED_BY]->(:User)            team:T#manager@user:U
+- (:PermissionSet)-[:ATTACHED_TO]->(:Persona) permissionset:S#persona@persona:P
+- (:PermissionSet)-[:DELEGATES_ACCESS_TO]->(:Account) account:A#delegate@permissionset:S
+- (:PermissionSet)-[:DELEGATES_ACCESS_WITH]->(:Role)  role:R#delegate@permissionset:S
+
+Relationships carry no properties, so the account and role of each binding
+of a permission set are paired by a binding object instead of a key:
+
+- binding:S|K#permissionset@permissionset:S
+- binding:S|K#account@account:A
+- binding:S|K#role@role:R
+- binding:S|K#scope@label:L
+
+Each entity is also related to the UID of its managed resource through its
+owner relation, much like the uid property of a neo4j node.
+
+Free-form strings (names, account ids, role names, UIDs) are not guaranteed to be
+valid SpiceDB object ids, so they are base64 encoded before being written.
+Accounts and roles are identified by their cloud as well as their id or
+name, so their object id is the encoded cloud and key, separated by a |.
+*/
+
+type SpiceDB struct {
+	Client *Client
+}
+
+// ApplySchema writes the shipped schema. Writing an unchanged schema is a
+// no-op in SpiceDB so this is safe to call on every connect.
+func (s *SpiceDB) ApplySchema(ctx context.Context) error {
+	return errors.Wrap(s.Client.WriteSchema(ctx, Schema), "cannot write spicedb schema")
+}
+
+func (s *SpiceDB) CreateUser(ctx context.Context, uid, userName string, personaRefs []string) (string, error) {
+	id, updates, err := s.claim(ctx, typeUser, uid, userName)
+	if err != nil {
+		return "", err
+	}
+
+	personas, err := s.existing(ctx, typePersona, personaRefs)
+	if err != nil {
+		return "", err
+	}
+
+	for _, p := range personas {
+		updates = append(updates, touch(typePersona, p, relGrantee, typeUser, id, ""))
+	}
+
+	if err := s.write(ctx, updates); err != nil {
+		return "", errors.Wrap(err, "no user was created")
+	}
+
+	return id, nil
+}
+
+func (s *SpiceDB) LookupUser(ctx context.Context, uid string) (string, error) {
+	return s.lookup(ctx, typeUser, uid)
+}
+
+func (s *SpiceDB) LookupUserByName(ctx context.Context, name string) (string, error) {
+	return s.lookupByName(ctx, typeUser, name)
+}
+
+func (s *SpiceDB) GetUser(ctx context.Context, userUuid string) (*types.GetUserResponse, error) {
+	if err := s.mustExist(ctx, typeUser, userUuid); err != nil {
+		return &types.GetUserResponse{
+			NodeID: userUuid,
+			Status: statusFor(err),
+		}, err
+	}
+
+	name, err := s.name(ctx, typeUser, userUuid)
+	if err != nil {
+		return &types.GetUserResponse{
+			NodeID: userUuid,
+			Status: storetypes.StatusUnavailable,
+		}, err
+	}
+
+	personas, err := s.resources(ctx, typePersona, relGrantee, typeUser, userUuid, "")
+	if err != nil {
+		return &types.GetUserResponse{
+			NodeID: userUuid,
+			Status: storetypes.StatusUnavailable,
+		}, err
+	}
+
+	return &types.GetUserResponse{
+		Name:       name,
+		NodeID:     userUuid,
+		Status:     storetypes.StatusAvailable,
+		References: personas,
+	}, nil
+}
+
+func (s *SpiceDB) UpdateUser(ctx context.Context, userName string, userUuid string, personaRefs []string) (types.Changes, error) {
+	current, err := s.resources(ctx, typePersona, relGrantee, typeUser, userUuid, "")
+	if err != nil {
+		return types.Changes{}, err
+	}
+
+	desired, err := s.existing(ctx, typePersona, personaRefs)
+	if err != nil {
+		return types.Changes{}, err
+	}
+
+	updates, err := s.rename(ctx, typeUser, userUuid, userName)
+	if err != nil {
+		return types.Changes{}, err
+	}
+
+	changes := types.Diff(types.RelationGranted, current, desired)
+	for _, p := range changes.Removed {
+		updates = append(updates, remove(typePersona, p.Node, relGrantee, typeUser, userUuid, ""))
+	}
+	for _, p := range changes.Added {
+		updates = append(updates, touch(typePersona, p.Node, relGrantee, typeUser, userUuid, ""))
+	}
+
+	return written(changes, s.write(ctx, updates, mustMatch(typeUser, userUuid)))
+}
+
+func (s *SpiceDB) DeleteUser(ctx context.Context, userUuid string) error {
+	return s.delete(ctx, typeUser, userUuid,
+		RelationshipFilter{ResourceType: typePersona, OptionalRelation: relGrantee},
+		RelationshipFilter{ResourceType: typeTeam, OptionalRelation: relMember},
+		RelationshipFilter{ResourceType: typeTeam, OptionalRelation: relManager},
+	)
+}
+
+func (s *SpiceDB) GetEffectiveAccess(ctx context.Context, userUuid string) ([]types.Access, error) {
+	out := []types.Access{}
+	through := func(persona, team string) error {
+		personaName, err := s.name(ctx, typePersona, persona)
+		if err != nil {
+			return err
+		}
+		sets, err := s.resources(ctx, typePermissionSet, relPersona, typePersona, persona, "")
+		if err != nil {
+			return err
+		}
+		for _, ps := range sets {
+			name, err := s.name(ctx, typePermissionSet, ps)
+			if err != nil {
+				return err
+			}
+			bindings, err := s.bindings(ctx, ps)
+			if err != nil {
+				return err
+			}
+			for _, b := range bindings {
+				t := types.TargetOf(b)
+				if t.Role == "" {
+					continue
+				}
+				out = append(out, types.Access{Target: t, PermissionSet: name, Persona: personaName, Team: team})
+			}
+		}
+		return nil
+	}
+
+	personas, err := s.resources(ctx, typePersona, relGrantee, typeUser, userUuid, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range personas {
+		if err := through(p, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	teams, err := s.resources(ctx, typeTeam, relMember, typeUser, userUuid, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range teams {
+		name, err := s.name(ctx, typeTeam, t)
+		if err != nil {
+			return nil, err
+		}
+		inherited, err := s.resources(ctx, typePersona, relGrantee, typeTeam, t, relMember)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range inherited {
+			if err := through(p, name); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return out, nil
+}
+
+func (s *SpiceDB) CreatePersona(ctx context.Context, uid, personaName string, permissionSetRefs []string) (string, error) {
+	id, updates, err := s.claim(ctx, typePersona, uid, personaName)
+	if err != nil {
+		return "", err
+	}
+
+	permissionSets, err := s.existing(ctx, typePermissionSet, permissionSetRefs)
+	if err != nil {
+		return "", err
+	}
+
+	for _, ps := range permissionSets {
+		updates = append(updates, touch(typePermissionSet, ps, relPersona, typePersona, id, ""))
+	}
+
+	if err := s.write(ctx, updates); err != nil {
+		return "", errors.Wrap(err, "no persona was created")
+	}
+
+	return id, nil
+}
+
+func (s *SpiceDB) LookupPersona(ctx context.Context, uid string) (string, error) {
+	return s.lookup(ctx, typePersona, uid)
+}
+
+func (s *SpiceDB) LookupPersonaByName(ctx context.Context, name string) (string, error) {
+	return s.lookupByName(ctx, typePersona, name)
+}
+
+func (s *SpiceDB) GetPersona(ctx context.Context, personaUuid string) (*types.GetPersonaResponse, error) {
+	if err := s.mustExist(ctx, typePersona, personaUuid); err != nil {
+		return &types.GetPersonaResponse{
+			NodeID: personaUuid,
+			Status: statusFor(err),
+		}, err
+	}
+
+	name, err := s.name(ctx, typePersona, personaUuid)
+	if err != nil {
+		return &types.GetPersonaResponse{
+			NodeID: personaUuid,
+			Status: storetypes.StatusUnavailable,
+		}, err
+	}
+
+	permissionSets, err := s.resources(ctx, typePermissionSet, relPersona, typePersona, personaUuid, "")
+	if err != nil {
+		return &types.GetPersonaResponse{
+			NodeID: personaUuid,
+			Status: storetypes.StatusUnavailable,
+		}, err
+	}
+
+	return &types.GetPersonaResponse{
+		Name:       name,
+		NodeID:     personaUuid,
+		Status:     storetypes.StatusAvailable,
+		References: permissionSets,
+	}, nil
+}
+
+func (s *SpiceDB) UpdatePersona(ctx context.Context, personaName string, personaUuid string, permissionSetUuids []string) (types.Changes, error) {
+	current, err := s.resources(ctx, typePermissionSet, relPersona, typePersona, personaUuid, "")
+	if err != nil {
+		return types.Changes{}, err
+	}
+
+	desired, err := s.existing(ctx, typePermissionSet, permissionSetUuids)
+	if err != nil {
+		return types.Changes{}, err
+	}
+
+	updates, err := s.rename(ctx, typePersona, personaUuid, personaName)
+	if err != nil {
+		return types.Changes{}, err
+	}
+
+	changes := types.Diff(types.RelationAttachedTo, current, desired)
+	for _, ps := range changes.Removed {
+		updates = append(updates, remove(typePermissionSet, ps.Node, relPersona, typePersona, personaUuid, ""))
+	}
+	for _, ps := range changes.Added {
+		updates = append(updates, touch(typePermissionSet, ps.Node, relPersona, typePersona, personaUuid, ""))
+	}
+
+	return written(changes, s.write(ctx, updates, mustMatch(typePersona, personaUuid)))
+}
+
+func (s *SpiceDB) DeletePersona(ctx context.Context, personaUuid string) error {
+	return s.delete(ctx, typePersona, personaUuid,
+		RelationshipFilter{ResourceType: typePermissionSet, OptionalRelation: relPersona},
+	)
+}
+
+func (s *SpiceDB) CreatePermissionSet(ctx context.Context, uid, name string, bindings []v1alpha1.AccountRoleBinding) (string, error) {
+	id, updates, err := s.claim(ctx, typePermissionSet, uid, name)
+	if err != nil {
+		return "", err
+	}
+
+	bound, err := s.bind(ctx, id, bindings)
+	if err != nil {
+		return "", err
+	}
+	updates = append(updates, bound...)
+
+	if err := s.write(ctx, updates); err != nil {
+		return "", errors.Wrap(err, "no permissionset was created")
+	}
+
+	return id, nil
+}
+
+func (s *SpiceDB) LookupPermissionSet(ctx context.Context, uid string) (string, error) {
+	return s.lookup(ctx, typePermissionSet, uid)
+}
+
+func (s *SpiceDB) GetPermissionSet(ctx context.Context, permissionSetUuid string) (*types.GetPermissionSetResponse, error) {
+	if err := s.mustExist(ctx, typePermissionSet, permissionSetUuid); err != nil {
+		return &types.GetPermissionSetResponse{
+			NodeID: permissionSetUuid,
+			Status: statusFor(err),
+		}, err
+	}
+
+	name, err := s.name(ctx, typePermissionSet, permissionSetUuid)
+	if err != nil {
+		return &types.GetPermissionSetResponse{
+			NodeID: permissionSetUuid,
+			Status: storetypes.StatusUnavailable,
+		}, err
+	}
+
+	bindings, err := s.bindings(ctx, permissionSetUuid)
+	if err != nil {
+		return &types.GetPermissionSetResponse{
+			NodeID: permissionSetUuid,
+			Status: storetypes.StatusUnavailable,
+		}, err
+	}
+
+	return &types.GetPermissionSetResponse{
+		Name:     name,
+		Bindings: bindings,
+		NodeID:   permissionSetUuid,
+		Status:   storetypes.StatusAvailable,
+	}, nil
+}
+
+func (s *SpiceDB) UpdatePermissionSet(ctx context.Context, permissionSetUuid, crName string, bindings []v1alpha1.AccountRoleBinding) (types.Changes, error) {
+	bindings = types.UniqueBindings(bindings)
+
+	current, err := s.bindings(ctx, permissionSetUuid)
+	if err != nil {
+		return types.Changes{}, err
+	}
+
+	updates, err := s.rename(ctx, typePermissionSet, permissionSetUuid, crName)
+	if err != nil {
+		return types.Changes{}, err
+	}
+
+	// Remove every binding, and every delegation, the permission set no
+	// longer has. Bindings whose account was deleted are removed too.
+	desired := map[string]bool{}
+	accounts, roles := map[string]bool{}, map[string]bool{}
+	for _, b := range bindings {
+		t := types.TargetOf(b)
+		desired[bindingID(permissionSetUuid, b)] = true
+		accounts[cloudID(t.Cloud, t.Account)], roles[cloudID(t.Cloud, t.Role)] = true, true
+	}
+	rels, err := s.bindingRelationships(ctx, permissionSetUuid)
+	if err != nil {
+		return types.Changes{}, err
+	}
+	for _, r := range rels {
+		if !desired[r.Resource.ObjectID] {
+			updates = append(updates, RelationshipUpdate{Operation: OperationDelete, Relationship: r})
+		}
+	}
+	delegated, err := s.resources(ctx, typeAccount, relDelegate, typePermissionSet, permissionSetUuid, "")
+	if err != nil {
+		return types.Changes{}, err
+	}
+	for _, a := range delegated {
+		if !accounts[a] {
+			updates = append(updates, remove(typeAccount, a, relDelegate, typePermissionSet, permissionSetUuid, ""))
+		}
+	}
+	delegated, err = s.resources(ctx, typeRole, relDelegate, typePermissionSet, permissionSetUuid, "")
+	if err != nil {
+		return types.Changes{}, err
+	}
+	for _, r := range delegated {
+		if !roles[r] {
+			updates = append(updates, remove(typeRole, r, relDelegate, typePermissionSet, permissionSetUuid, ""))
+		}
+	}
+
+	bound, err := s.bind(ctx, permissionSetUuid, bindings)
+	if err != nil {
+		return types.Changes{}, err
+	}
+	updates = append(updates, bound...)
+
+	changes := types.DiffBindings(current, bindings)
+
+	return written(changes, s.write(ctx, updates, mustMatch(typePermissionSet, permissionSetUuid)))
+}
+
+func (s *SpiceDB) DeletePermissionSet(ctx context.Context, permissionSetUuid string) error {
+	ids, err := s.resources(ctx, typeBinding, relPermissionSet, typePermissionSet, permissionSetUuid, "")
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := s.delete(ctx, typeBinding, id); err != nil {
+			return err
+		}
+	}
+
+	return s.delete(ctx, typePermissionSet, permissionSetUuid,
+		RelationshipFilter{ResourceType: typeAccount, OptionalRelation: relDelegate},
+		RelationshipFilter{ResourceType: typeRole, OptionalRelation: relDelegate},
+	)
+}
+
+func (s *SpiceDB) CreateTeam(ctx context.Context, uid string, teamparams *v1alpha1.TeamParameters) (string, error) {
+	id, updates, err := s.claim(ctx, typeTeam, uid, teamparams.Name)
+	if err != nil {
+		return "", err
+	}
+
+	members, err := s.existing(ctx, typeUser, teamparams.Members)
+	if err != nil {
+		return "", err
+	}
+
+	personas, err := s.existing(ctx, typePersona, teamparams.Personas)
+	if err != nil {
+		return "", err
+	}
+
+	updates = append(updates, team(id, teamparams.ManagedBy.User, members, personas)...)
+
+	if err := s.write(ctx, updates); err != nil {
+		return "", errors.Wrap(err, "no team was created")
+	}
+
+	return id, nil
+}
+
+func (s *SpiceDB) LookupTeam(ctx context.Context, uid string) (string, error) {
+	return s.lookup(ctx, typeTeam, uid)
+}
+
+func (s *SpiceDB) GetTeam(ctx context.Context, teamUuid string) (*types.GetTeamResponse, error) {
+	if err := s.mustExist(ctx, typeTeam, teamUuid); err != nil {
+		return &types.GetTeamResponse{
+			NodeID: teamUuid,
+			Status: statusFor(err),
+		}, err
+	}
+
+	name, err := s.name(ctx, typeTeam, teamUuid)
+	if err != nil {
+		return &types.GetTeamResponse{NodeID: teamUuid, Status: storetypes.StatusUnavailable}, err
+	}
+
+	members, err := s.subjects(ctx, typeTeam, teamUuid, relMember)
+	if err != nil {
+		return &types.GetTeamResponse{NodeID: teamUuid, Status: storetypes.StatusUnavailable}, err
+	}
+
+	managers, err := s.subjects(ctx, typeTeam, teamUuid, relManager)
+	if err != nil {
+		return &types.GetTeamResponse{NodeID: teamUuid, Status: storetypes.StatusUnavailable}, err
+	}
+
+	personas, err := s.resources(ctx, typePersona, relGrantee, typeTeam, teamUuid, relMember)
+	if err != nil {
+		return &types.GetTeamResponse{NodeID: teamUuid, Status: storetypes.StatusUnavailable}, err
+	}
+
+	var manager string
+	if len(managers) > 0 {
+		manager = managers[0]
+	}
+
+	return &types.GetTeamResponse{
+		Name:      name,
+		NodeID:    teamUuid,
+		Status:    storetypes.StatusAvailable,
+		ManagedBy: manager,
+		Members:   members,
+		Personas:  personas,
+	}, nil
+}
+
+func (s *SpiceDB) UpdateTeam(ctx context.Context, teamUuid string, teamparams *v1alpha1.TeamParameters) (types.Changes, error) {
+	currentManagers, err := s.subjects(ctx, typeTeam, teamUuid, relManager)
+	if err != nil {
+		return types.Changes{}, err
+	}
+
+	currentMembers, err := s.subjects(ctx, typeTeam, teamUuid, relMember)
+	if err != nil {
+		return types.Changes{}, err
+	}
+
+	currentPersonas, err := s.resources(ctx, typePersona, relGrantee, typeTeam, teamUuid, relMember)
+	if err != nil {
+		return types.Changes{}, err
+	}
+
+	var manager []string
+	if teamparams.ManagedBy.User != "" {
+		manager = []string{teamparams.ManagedBy.User}
+	}
+
+	managers, err := s.existing(ctx, typeUser, manager)
+	if err != nil {
+		return types.Changes{}, err
+	}
+
+	members, err := s.existing(ctx, typeUser, teamparams.Members)
+	if err != nil {
+		return types.Changes{}, err
+	}
+
+	personas, err := s.existing(ctx, typePersona, teamparams.Personas)
+	if err != nil {
+		return types.Changes{}, err
+	}
+
+	updates, err := s.rename(ctx, typeTeam, teamUuid, teamparams.Name)
+	if err != nil {
+		return types.Changes{}, err
+	}
+
+	// A manager of a team is never also a member of it.
+	nonManagers := make([]string, 0, len(members))
+	for _, m := range members {
+		if m != teamparams.ManagedBy.User {
+			nonManagers = append(nonManagers, m)
+		}
+	}
+
+	changes := types.Diff(types.RelationManagedBy, currentManagers, managers).
+		Merge(types.Diff(types.RelationMemberOf, currentMembers, nonManagers)).
+		Merge(types.Diff(types.RelationInherits, currentPersonas, personas))
+	for _, r := range changes.Removed {
+		switch r.Type {
+		case types.RelationManagedBy:
+			updates = append(updates, remove(typeTeam, teamUuid, relManager, typeUser, r.Node, ""))
+		case types.RelationMemberOf:
+			updates = append(updates, remove(typeTeam, teamUuid, relMember, typeUser, r.Node, ""))
+		case types.RelationInherits:
+			updates = append(updates, remove(typePersona, r.Node, relGrantee, typeTeam, teamUuid, relMember))
+		}
+	}
+	for _, r := range changes.Added {
+		switch r.Type {
+		case types.RelationManagedBy:
+			updates = append(updates, touch(typeTeam, teamUuid, relManager, typeUser, r.Node, ""))
+		case types.RelationMemberOf:
+			updates = append(updates, touch(typeTeam, teamUuid, relMember, typeUser, r.Node, ""))
+		case types.RelationInherits:
+			updates = append(updates, touch(typePersona, r.Node, relGrantee, typeTeam, teamUuid, relMember))
+		}
+	}
+
+	return written(changes, s.write(ctx, updates, mustMatch(typeTeam, teamUuid)))
+}
+
+func (s *SpiceDB) DeleteTeam(ctx context.Context, teamUuid string) error {
+	return s.delete(ctx, typeTeam, teamUuid,
+		RelationshipFilter{ResourceType: typePersona, OptionalRelation: relGrantee},
+	)
+}
+
+// CreateAccount registers the account with the cloud and id of the supplied
+// parameters as owned by uid, and returns the id. Unlike other entities, an
+// account is identified by its cloud and id rather than a generated uuid, so
+// it may already be delegated access to, in which case the Account takes it
+// over.
+func (s *SpiceDB) CreateAccount(ctx context.Context, uid string, account *v1alpha1.AccountParameters) (string, error) {
+	return s.manage(ctx, typeAccount, types.DefaultCloud(account.Cloud), account.ID, uid, accountLabels(account))
+}
+
+func (s *SpiceDB) LookupAccount(ctx context.Context, uid string) (string, error) {
+	return s.lookupManaged(ctx, typeAccount, uid)
+}
+
+func (s *SpiceDB) GetAccount(ctx context.Context, cloud, accountID string) (*types.GetAccountResponse, error) {
+	labels, err := s.labels(ctx, typeAccount, cloud, accountID)
+	if err != nil {
+		return &types.GetAccountResponse{
+			NodeID: accountID,
+			Status: statusFor(err),
+		}, err
+	}
+
+	return &types.GetAccountResponse{
+		Account: v1alpha1.AccountParameters{
+			Cloud:       cloud,
+			ID:          accountID,
+			Alias:       labels[relAlias],
+			Class:       labels[relClass],
+			Owner:       labels[relAccountOwner],
+			Environment: labels[relEnvironment],
+			Lifecycle:   labels[relLifecycle],
+		},
+		NodeID: accountID,
+		Status: storetypes.StatusAvailable,
+	}, nil
+}
+
+func (s *SpiceDB) UpdateAccount(ctx context.Context, cloud, accountID string, account *v1alpha1.AccountParameters) error {
+	return s.relabel(ctx, typeAccount, cloud, accountID, accountLabels(account))
+}
+
+// DeleteAccount deletes the account with the supplied id in the cloud, unless
+// a permission set still delegates access to it, in which case the Account
+// only releases it.
+func (s *SpiceDB) DeleteAccount(ctx context.Context, cloud, accountID string) error {
+	return s.release(ctx, typeAccount, cloudID(cloud, accountID), accountLabels(&v1alpha1.AccountParameters{}),
+		RelationshipFilter{ResourceType: typeBinding, OptionalRelation: relAccount},
+	)
+}
+
+// WhoCanAccess walks back from the permission sets which delegate access to
+// the account in the cloud, through the personas attached to them, to the
+// users granted those personas directly or through a team.
+func (s *SpiceDB) WhoCanAccess(ctx context.Context, cloud, accountID, roleName string) ([]types.Grant, error) {
+	out := []types.Grant{}
+	user := func(id string, access types.Access) error {
+		name, err := s.name(ctx, typeUser, id)
+		if err != nil {
+			return err
+		}
+		out = append(out, types.Grant{Access: access, User: id, UserName: name})
+		return nil
+	}
+
+	granted := func(persona string, access types.Access) error {
+		rels, err := s.Client.ReadRelationships(ctx, &ReadRelationshipsRequest{
+			Consistency:        fullyConsistent(),
+			RelationshipFilter: RelationshipFilter{ResourceType: typePersona, OptionalResourceID: persona, OptionalRelation: relGrantee},
+		})
+		if err != nil {
+			return err
+		}
+		for _, r := range rels {
+			id := r.Subject.Object.ObjectID
+			if r.Subject.Object.ObjectType == typeUser {
+				if err := user(id, access); err != nil {
+					return err
+				}
+				continue
+			}
+
+			inherited := access
+			if inherited.Team, err = s.name(ctx, typeTeam, id); err != nil {
+				return err
+			}
+			members, err := s.subjects(ctx, typeTeam, id, relMember)
+			if err != nil {
+				return err
+			}
+			for _, m := range members {
+				if err := user(m, inherited); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	sets, err := s.subjects(ctx, typeAccount, cloudID(cloud, accountID), relDelegate)
+	if err != nil {
+		return nil, err
+	}
+	for _, ps := range sets {
+		bindings, err := s.bindings(ctx, ps)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range bindings {
+			t := types.TargetOf(b)
+			if t.Role == "" || t.Cloud != cloud || t.Account != accountID || t.Role != roleName {
+				continue
+			}
+			name, err := s.name(ctx, typePermissionSet, ps)
+			if err != nil {
+				return nil, err
+			}
+			personas, err := s.subjects(ctx, typePermissionSet, ps, relPersona)
+			if err != nil {
+				return nil, err
+			}
+			for _, p := range personas {
+				persona, err := s.name(ctx, typePersona, p)
+				if err != nil {
+					return nil, err
+				}
+				if err := granted(p, types.Access{Target: t, PermissionSet: name, Persona: persona}); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	return out, nil
+}
+
+// CreateRole registers the role with the cloud and name of the supplied
+// parameters as owned by uid, and returns the name. Like an account, a role
+// is identified by its cloud and name, so a Role takes over a role
+// permission sets already delegate access with.
+func (s *SpiceDB) CreateRole(ctx context.Context, uid string, role *v1alpha1.RoleParameters) (string, error) {
+	return s.manage(ctx, typeRole, types.DefaultCloud(role.Cloud), role.Name, uid, roleLabels(role))
+}
+
+func (s *SpiceDB) LookupRole(ctx context.Context, uid string) (string, error) {
+	return s.lookupManaged(ctx, typeRole, uid)
+}
+
+func (s *SpiceDB) GetRole(ctx context.Context, cloud, roleName string) (*types.GetRoleResponse, error) {
+	labels, err := s.labels(ctx, typeRole, cloud, roleName)
+	if err != nil {
+		return &types.GetRoleResponse{
+			NodeID: roleName,
+			Status: statusFor(err),
+		}, err
+	}
+
+	return &types.GetRoleResponse{
+		Role: v1alpha1.RoleParameters{
+			Cloud:              cloud,
+			Name:               roleName,
+			Description:        labels[relDescription],
+			Tier:               labels[relTier],
+			PolicyDocument:     labels[relPolicy],
+			MaxSessionDuration: storetypes.ParseDuration(labels[relMaxSessionDuration]),
+		},
+		NodeID: roleName,
+		Status: storetypes.StatusAvailable,
+	}, nil
+}
+
+func (s *SpiceDB) UpdateRole(ctx context.Context, cloud, roleName string, role *v1alpha1.RoleParameters) error {
+	return s.relabel(ctx, typeRole, cloud, roleName, roleLabels(role))
+}
+
+// DeleteRole deletes the role with the supplied name in the cloud, unless a
+// permission set still delegates access with it, in which case the Role only
+// releases it.
+func (s *SpiceDB) DeleteRole(ctx context.Context, cloud, roleName string) error {
+	return s.release(ctx, typeRole, cloudID(cloud, roleName), roleLabels(&v1alpha1.RoleParameters{}),
+		RelationshipFilter{ResourceType: typeBinding, OptionalRelation: relRole},
+	)
+}
+
+// managed returns true if an Account manages the account with the supplied
+// id in the cloud.
+func (s *SpiceDB) managed(ctx context.Context, cloud, accountID string) (bool, error) {
+	err := s.mustExist(ctx, typeAccount, cloudID(cloud, accountID))
+	if storetypes.IsEntityNotFoundNeo4jErr(err) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// manage registers the account or role with the supplied key in the cloud
+// as owned by uid, replacing its labels with those supplied, and returns the
+// key. It returns a ConflictError if it is owned by another uid.
+func (s *SpiceDB) manage(ctx context.Context, objectType, cloud, key, uid string, labels map[string]string) (string, error) {
+	if owned, err := s.lookupManaged(ctx, objectType, uid); err == nil || !storetypes.IsEntityNotFoundNeo4jErr(err) {
+		return owned, err
+	}
+
+	id := cloudID(cloud, key)
+	err := s.mustExist(ctx, objectType, id)
+	if err == nil {
+		return "", &storetypes.ConflictError{Err: errors.Errorf("%s is managed by another resource", key)}
+	}
+	if !storetypes.IsEntityNotFoundNeo4jErr(err) {
+		return "", err
+	}
+
+	updates, err := s.replace(ctx, objectType, id, labels)
+	if err != nil {
+		return "", err
+	}
+	updates = append(updates,
+		RelationshipUpdate{
+			Operation:    OperationCreate,
+			Relationship: relationship(objectType, id, relRegistry, typePlatform, platformID, ""),
+		},
+		touch(objectType, id, relOwner, typeLabel, encode(uid), ""),
+	)
+
+	if err := s.write(ctx, updates); err != nil {
+		return "", errors.Wrapf(err, "no %s was created", objectType)
+	}
+
+	return key, nil
+}
+
+// lookupManaged returns the key of the account or role owned by uid.
+func (s *SpiceDB) lookupManaged(ctx context.Context, objectType, uid string) (string, error) {
+	id, err := s.lookup(ctx, objectType, uid)
+	if err != nil {
+		return "", err
+	}
+	_, key := fromCloudID(id)
+
+	return key, nil
+}
+
+// labels returns the value of each label of the registered account or role
+// with the supplied key in the cloud, by relation.
+func (s *SpiceDB) labels(ctx context.Context, objectType, cloud, key string) (map[string]string, error) {
+	id := cloudID(cloud, key)
+	if err := s.mustExist(ctx, objectType, id); err != nil {
+		return nil, err
+	}
+
+	rels, err := s.Client.ReadRelationships(ctx, &ReadRelationshipsRequest{
+		Consistency:        fullyConsistent(),
+		RelationshipFilter: RelationshipFilter{ResourceType: objectType, OptionalResourceID: id},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := map[string][]string{}
+	for _, r := range rels {
+		if r.Subject.Object.ObjectType == typeLabel && r.Relation != relOwner && r.Relation != relName {
+			chunks[r.Relation] = append(chunks[r.Relation], r.Subject.Object.ObjectID)
+		}
+	}
+
+	out := make(map[string]string, len(chunks))
+	for relation, c := range chunks {
+		out[relation] = unchunk(c)
+	}
+
+	return out, nil
+}
+
+// relabel replaces the labels of the registered account or role with the
+// supplied key in the cloud.
+func (s *SpiceDB) relabel(ctx context.Context, objectType, cloud, key string, labels map[string]string) error {
+	id := cloudID(cloud, key)
+	updates, err := s.replace(ctx, objectType, id, labels)
+	if err != nil {
+		return err
+	}
+
+	return s.write(ctx, updates, mustMatch(objectType, id))
+}
+
+// replace returns the updates which replace every label of an entity
+// through the relations of labels with their values. Empty values are not
+// written.
+func (s *SpiceDB) replace(ctx context.Context, objectType, id string, labels map[string]string) ([]RelationshipUpdate, error) {
+	rels, err := s.Client.ReadRelationships(ctx, &ReadRelationshipsRequest{
+		Consistency:        fullyConsistent(),
+		RelationshipFilter: RelationshipFilter{ResourceType: objectType, OptionalResourceID: id},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var updates []RelationshipUpdate
+	for _, r := range rels {
+		if _, ok := labels[r.Relation]; ok {
+			updates = append(updates, RelationshipUpdate{Operation: OperationDelete, Relationship: r})
+		}
+	}
+
+	relations := make([]string, 0, len(labels))
+	for relation := range labels {
+		relations = append(relations, relation)
+	}
+	sort.Strings(relations)
+
+	for _, relation := range relations {
+		for _, c := range chunk(labels[relation]) {
+			updates = append(updates, touch(objectType, id, relation, typeLabel, c, ""))
+		}
+	}
+
+	return updates, nil
+}
+
+func accountLabels(account *v1alpha1.AccountParameters) map[string]string {
+	return map[string]string{
+		relAlias:        account.Alias,
+		relClass:        account.Class,
+		relAccountOwner: account.Owner,
+		relEnvironment:  account.Environment,
+		relLifecycle:    account.Lifecycle,
+	}
+}
+
+func roleLabels(role *v1alpha1.RoleParameters) map[string]string {
+	return map[string]string{
+		relDescription:        role.Description,
+		relTier:               role.Tier,
+		relPolicy:             role.PolicyDocument,
+		relMaxSessionDuration: storetypes.FormatDuration(role.MaxSessionDuration),
+	}
+}
+
+func (s *SpiceDB) write(ctx context.Context, updates []RelationshipUpdate, preconditions ...Precondition) error {
+	_, err := s.Client.WriteRelationships(ctx, &WriteRelationshipsRequest{
+		Updates:               dedupe(updates),
+		OptionalPreconditions: preconditions,
+	})
+	if IsFailedPreconditionErr(err) {
+		return &storetypes.EntityNotFoundError{}
+	}
+
+	return err
+}
+
+// delete removes every relationship which references the entity as a
+// subject, then every relationship the entity owns. The registry tuple goes
+// last so that a partially failed delete is still observable, and retried.
+func (s *SpiceDB) delete(ctx context.Context, objectType, id string, referencedBy ...RelationshipFilter) error {
+	for _, f := range referencedBy {
+		f.OptionalSubjectFilter = &SubjectFilter{SubjectType: objectType, OptionalSubjectID: id}
+		if _, err := s.Client.DeleteRelationships(ctx, &DeleteRelationshipsRequest{RelationshipFilter: f}); err != nil {
+			return err
+		}
+	}
+
+	_, err := s.Client.DeleteRelationships(ctx, &DeleteRelationshipsRequest{
+		RelationshipFilter: RelationshipFilter{ResourceType: objectType, OptionalResourceID: id},
+	})
+
+	return err
+}
+
+// release deletes the account or role with the supplied object id, unless a
+// permission set still delegates access to or with it. In that case only its
+// registration, its owner and the supplied labels are removed, keeping the
+// delegations and bindings which reference it.
+func (s *SpiceDB) release(ctx context.Context, objectType, id string, labels map[string]string, referencedBy RelationshipFilter) error {
+	delegates, err := s.subjects(ctx, objectType, id, relDelegate)
+	if err != nil {
+		return err
+	}
+	if len(delegates) == 0 {
+		return s.delete(ctx, objectType, id, referencedBy)
+	}
+
+	rels, err := s.Client.ReadRelationships(ctx, &ReadRelationshipsRequest{
+		Consistency:        fullyConsistent(),
+		RelationshipFilter: RelationshipFilter{ResourceType: objectType, OptionalResourceID: id},
+	})
+	if err != nil {
+		return err
+	}
+
+	var updates []RelationshipUpdate
+	for _, r := range rels {
+		if _, ok := labels[r.Relation]; ok || r.Relation == relOwner || r.Relation == relRegistry {
+			updates = append(updates, RelationshipUpdate{Operation: OperationDelete, Relationship: r})
+		}
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+
+	return s.write(ctx, updates)
+}
+
+// rename replaces every name label of an entity with the supplied name.
+func (s *SpiceDB) rename(ctx context.Context, objectType, id, name string) ([]RelationshipUpdate, error) {
+	names, err := s.subjects(ctx, objectType, id, relName)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := make([]RelationshipUpdate, 0, len(names)+1)
+	for _, n := range names {
+		updates = append(updates, remove(objectType, id, relName, typeLabel, n, ""))
+	}
+
+	return append(updates, touch(objectType, id, relName, typeLabel, encode(name), "")), nil
+}
+
+// name returns the name label of an entity.
+func (s *SpiceDB) name(ctx context.Context, objectType, id string) (string, error) {
+	names, err := s.subjects(ctx, objectType, id, relName)
+	if err != nil || len(names) == 0 {
+		return "", err
+	}
+
+	return decode(names[0]), nil
+}
+
+// claim returns the id of the entity of objectType owned by uid. If there is
+// none, it returns a new id along with the updates which register it.
+func (s *SpiceDB) claim(ctx context.Context, objectType, uid, name string) (string, []RelationshipUpdate, error) {
+	id, err := s.lookup(ctx, objectType, uid)
+	if err == nil {
+		return id, nil, nil
+	}
+	if !storetypes.IsEntityNotFoundNeo4jErr(err) {
+		return "", nil, err
+	}
+
+	id = uuid.NewString()
+	updates := append(register(objectType, id, name), touch(objectType, id, relOwner, typeLabel, encode(uid), ""))
+
+	return id, updates, nil
+}
+
+// lookup returns the id of the entity of objectType owned by uid.
+func (s *SpiceDB) lookup(ctx context.Context, objectType, uid string) (string, error) {
+	ids, err := s.resources(ctx, objectType, relOwner, typeLabel, encode(uid), "")
+	if err != nil {
+		return "", err
+	}
+	if len(ids) == 0 {
+		return "", &storetypes.EntityNotFoundError{}
+	}
+
+	return ids[0], nil
+}
+
+// lookupByName returns the id of the only entity of objectType with the
+// supplied name.
+func (s *SpiceDB) lookupByName(ctx context.Context, objectType, name string) (string, error) {
+	ids, err := s.resources(ctx, objectType, relName, typeLabel, encode(name), "")
+	if err != nil {
+		return "", err
+	}
+
+	return storetypes.Unique(ids)
+}
+
+func (s *SpiceDB) mustExist(ctx context.Context, objectType, id string) error {
+	rels, err := s.Client.ReadRelationships(ctx, &ReadRelationshipsRequest{
+		Consistency: fullyConsistent(),
+		RelationshipFilter: RelationshipFilter{
+			ResourceType:       objectType,
+			OptionalResourceID: id,
+			OptionalRelation:   relRegistry,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if len(rels) == 0 {
+		return &storetypes.EntityNotFoundError{}
+	}
+
+	return nil
+}
+
+// existing filters ids down to those registered in SpiceDB, matching the
+// neo4j store where an edge to a node that does not exist is never created.
+func (s *SpiceDB) existing(ctx context.Context, objectType string, ids []string) ([]string, error) {
+	out := make([]string, 0, len(ids))
+	for _, id := range ids {
+		err := s.mustExist(ctx, objectType, id)
+		if storetypes.IsEntityNotFoundNeo4jErr(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, id)
+	}
+
+	return out, nil
+}
+
+// resources returns the ids of every resource of objectType related to the
+// given subject through relation.
+func (s *SpiceDB) resources(ctx context.Context, objectType, relation, subjectType, subjectID, subjectRelation string) ([]string, error) {
+	filter := &SubjectFilter{SubjectType: subjectType, OptionalSubjectID: subjectID}
+	if subjectRelation != "" {
+		filter.OptionalRelation = &SubjectRelationFilter{Relation: subjectRelation}
+	}
+
+	rels, err := s.Client.ReadRelationships(ctx, &ReadRelationshipsRequest{
+		Consistency: fullyConsistent(),
+		RelationshipFilter: RelationshipFilter{
+			ResourceType:          objectType,
+			OptionalRelation:      relation,
+			OptionalSubjectFilter: filter,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]string, 0, len(rels))
+	for _, r := range rels {
+		if r.Subject.OptionalRelation == subjectRelation {
+			out = append(out, r.Resource.ObjectID)
+		}
+	}
+
+	return out, nil
+}
+
+// subjects returns the ids of every subject related to the given resource
+// through relation.
+func (s *SpiceDB) subjects(ctx context.Context, objectType, id, relation string) ([]string, error) {
+	rels, err := s.Client.ReadRelationships(ctx, &ReadRelationshipsRequest{
+		Consistency: fullyConsistent(),
+		RelationshipFilter: RelationshipFilter{
+			ResourceType:       objectType,
+			OptionalResourceID: id,
+			OptionalRelation:   relation,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]string, 0, len(rels))
+	for _, r := range rels {
+		out = append(out, r.Subject.Object.ObjectID)
+	}
+
+	return out, nil
+}
+
+// bindingRelationships returns every relationship of the bindings of a
+// permission set.
+func (s *SpiceDB) bindingRelationships(ctx context.Context, permissionSetUuid string) ([]Relationship, error) {
+	ids, err := s.resources(ctx, typeBinding, relPermissionSet, typePermissionSet, permissionSetUuid, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var out []Relationship
+	for _, id := range ids {
+		rels, err := s.Client.ReadRelationships(ctx, &ReadRelationshipsRequest{
+			Consistency:        fullyConsistent(),
+			RelationshipFilter: RelationshipFilter{ResourceType: typeBinding, OptionalResourceID: id},
+		})
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rels...)
+	}
+
+	return out, nil
+}
+
+// bindings returns the bindings of a permission set. A binding whose account
+// was deleted is not returned, and one whose role was deleted has none. The
+// alias and class of an account managed by an Account are its own, so they
+// are not returned.
+func (s *SpiceDB) bindings(ctx context.Context, permissionSetUuid string) ([]v1alpha1.AccountRoleBinding, error) {
+	rels, err := s.bindingRelationships(ctx, permissionSetUuid)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := map[string]*types.Target{}
+	ids := []string{}
+	for _, r := range rels {
+		t, ok := byID[r.Resource.ObjectID]
+		if !ok {
+			t = &types.Target{}
+			byID[r.Resource.ObjectID] = t
+			ids = append(ids, r.Resource.ObjectID)
+		}
+		switch r.Relation {
+		case relAccount:
+			t.Cloud, t.Account = fromCloudID(r.Subject.Object.ObjectID)
+		case relRole:
+			_, t.Role = fromCloudID(r.Subject.Object.ObjectID)
+		case relScope:
+			t.Scope = decode(r.Subject.Object.ObjectID)
+		}
+	}
+
+	out := make([]v1alpha1.AccountRoleBinding, 0, len(ids))
+	for _, id := range ids {
+		t := byID[id]
+		if t.Account == "" {
+			continue
+		}
+
+		account := cloudID(t.Cloud, t.Account)
+		managed, err := s.managed(ctx, t.Cloud, t.Account)
+		if err != nil {
+			return nil, err
+		}
+		if managed {
+			out = append(out, t.Binding("", ""))
+			continue
+		}
+
+		alias, err := s.label(ctx, typeAccount, account, relAlias)
+		if err != nil {
+			return nil, err
+		}
+		class, err := s.label(ctx, typeAccount, account, relClass)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, t.Binding(alias, class))
+	}
+
+	return types.UniqueBindings(out), nil
+}
+
+// label returns the decoded value of the first label of an entity through
+// relation, if any.
+func (s *SpiceDB) label(ctx context.Context, objectType, id, relation string) (string, error) {
+	labels, err := s.subjects(ctx, objectType, id, relation)
+	if err != nil || len(labels) == 0 {
+		return "", err
+	}
+
+	return decode(labels[0]), nil
+}
+
+// bind returns the updates which pair the account and role of each binding
+// in a binding object and delegate access to them from the permission set.
+// The account and role of a binding are those in its cloud, and the alias
+// and class of a binding replace those of accounts no Account manages.
+func (s *SpiceDB) bind(ctx context.Context, permissionSetUuid string, bindings []v1alpha1.AccountRoleBinding) ([]RelationshipUpdate, error) {
+	var updates []RelationshipUpdate
+	for _, b := range types.UniqueBindings(bindings) {
+		t := types.TargetOf(b)
+		account, role, id := cloudID(t.Cloud, t.Account), cloudID(t.Cloud, t.Role), bindingID(permissionSetUuid, b)
+
+		updates = append(updates,
+			touch(typeBinding, id, relPermissionSet, typePermissionSet, permissionSetUuid, ""),
+			touch(typeBinding, id, relAccount, typeAccount, account, ""),
+			touch(typeBinding, id, relRole, typeRole, role, ""),
+			touch(typeAccount, account, relDelegate, typePermissionSet, permissionSetUuid, ""),
+			touch(typeRole, role, relDelegate, typePermissionSet, permissionSetUuid, ""),
+		)
+		if t.Scope != "" {
+			updates = append(updates, touch(typeBinding, id, relScope, typeLabel, encode(t.Scope), ""))
+		}
+
+		managed, err := s.managed(ctx, t.Cloud, t.Account)
+		if err != nil {
+			return nil, err
+		}
+
+		labels, err := s.Client.ReadRelationships(ctx, &ReadRelationshipsRequest{
+			Consistency:        fullyConsistent(),
+			RelationshipFilter: RelationshipFilter{ResourceType: typeAccount, OptionalResourceID: account},
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range labels {
+			if !managed && (l.Relation == relAlias || l.Relation == relClass) {
+				updates = append(updates, RelationshipUpdate{Operation: OperationDelete, Relationship: l})
+			}
+		}
+		updates = append(updates, touch(typeAccount, account, relCloud, typeLabel, encode(t.Cloud), ""))
+		if managed {
+			continue
+		}
+		if b.Alias != "" {
+			updates = append(updates, touch(typeAccount, account, relAlias, typeLabel, encode(b.Alias), ""))
+		}
+		if b.AccountClass != "" {
+			updates = append(updates, touch(typeAccount, account, relClass, typeLabel, encode(b.AccountClass), ""))
+		}
+	}
+
+	return updates, nil
+}
+
+// bindingID returns the id of the object which pairs the account and role of
+// a binding of a permission set.
+func bindingID(permissionSetUuid string, b v1alpha1.AccountRoleBinding) string {
+	return permissionSetUuid + "|" + encode(types.BindingKey(b))
+}
+
+func register(objectType, id, name string) []RelationshipUpdate {
+	return []RelationshipUpdate{
+		{
+			Operation:    OperationCreate,
+			Relationship: relationship(objectType, id, relRegistry, typePlatform, platformID, ""),
+		},
+		touch(objectType, id, relName, typeLabel, encode(name), ""),
+	}
+}
+
+func team(teamUuid, manager string, members, personas []string) []RelationshipUpdate {
+	var updates []RelationshipUpdate
+	if manager != "" {
+		updates = append(updates, touch(typeTeam, teamUuid, relManager, typeUser, manager, ""))
+	}
+	for _, m := range members {
+		// A manager of a team is never also a member of it.
+		if m == manager {
+			continue
+		}
+		updates = append(updates, touch(typeTeam, teamUuid, relMember, typeUser, m, ""))
+	}
+	for _, p := range personas {
+		updates = append(updates, touch(typePersona, p, relGrantee, typeTeam, teamUuid, relMember))
+	}
+
+	return updates
+}
+
+func relationship(resourceType, resourceID, relation, subjectType, subjectID, subjectRelation string) Relationship {
+	return Relationship{
+		Resource: ObjectReference{ObjectType: resourceType, ObjectID: resourceID},
+		Relation: relation,
+		Subject: SubjectReference{
+			Object:           ObjectReference{ObjectType: subjectType, ObjectID: subjectID},
+			OptionalRelation: subjectRelation,
+		},
+	}
+}
+
+func touch(resourceType, resourceID, relation, subjectType, subjectID, subjectRelation string) RelationshipUpdate {
+	return RelationshipUpdate{
+		Operation:    OperationTouch,
+		Relationship: relationship(resourceType, resourceID, relation, subjectType, subjectID, subjectRelation),
+	}
+}
+
+func remove(resourceType, resourceID, relation, subjectType, subjectID, subjectRelation string) RelationshipUpdate {
+	return RelationshipUpdate{
+		Operation:    OperationDelete,
+		Relationship: relationship(resourceType, resourceID, relation, subjectType, subjectID, subjectRelation),
+	}
+}
+
+func mustMatch(objectType, id string) Precondition {
+	return Precondition{
+		Operation: OperationMustMatch,
+		Filter: RelationshipFilter{
+			ResourceType:       objectType,
+			OptionalResourceID: id,
+			OptionalRelation:   relRegistry,
+		},
+	}
+}
+
+// dedupe drops repeated updates, and a delete which is followed by a touch of
+// the same relationship, as SpiceDB rejects a write that updates a
+// relationship twice.
+func dedupe(updates []RelationshipUpdate) []RelationshipUpdate {
+	touched := map[Relationship]bool{}
+	for _, u := range updates {
+		if u.Operation == OperationTouch {
+			touched[u.Relationship] = true
+		}
+	}
+
+	seen := map[Relationship]bool{}
+	out := make([]RelationshipUpdate, 0, len(updates))
+	for _, u := range updates {
+		if u.Operation == OperationDelete && touched[u.Relationship] {
+			continue
+		}
+		if seen[u.Relationship] {
+			continue
+		}
+		seen[u.Relationship] = true
+		out = append(out, u)
+	}
+
+	return out
+}
+
+// written returns the changes of an update if it was written, and none
+// otherwise.
+func written(changes types.Changes, err error) (types.Changes, error) {
+	if err != nil {
+		return types.Changes{}, err
+	}
+
+	return changes, nil
+}
+
+func fullyConsistent() *Consistency {
+	return &Consistency{FullyConsistent: true}
+}
+
+func statusFor(err error) storetypes.Status {
+	if storetypes.IsEntityNotFoundNeo4jErr(err) {
+		return storetypes.StatusDeleted
+	}
+
+	return storetypes.StatusUnavailable
+}
+
+// chunk encodes s as labels of at most maxChunk characters, each prefixed
+// with its position and a separator the encoding never contains, so that
+// unchunk can join them whatever order they are read in. An empty s has no
+// labels.
+func chunk(s string) []string {
+	encoded := encode(s)
+
+	var out []string
+	for i := 0; len(encoded) > 0; i++ {
+		n := maxChunk - 5
+		if n > len(encoded) {
+			n = len(encoded)
+		}
+		out = append(out, fmt.Sprintf("%04d|%s", i, encoded[:n]))
+		encoded = encoded[n:]
+	}
+
+	return out
+}
+
+// unchunk joins and decodes the labels chunk returned. A label without a
+// position is decoded on its own.
+func unchunk(labels []string) string {
+	sort.Strings(labels)
+
+	var b strings.Builder
+	for _, l := range labels {
+		if _, c, ok := strings.Cut(l, "|"); ok {
+			l = c
+		}
+		b.WriteString(l)
+	}
+
+	return decode(b.String())
+}
+
+// cloudID returns the object id of the account or role with the supplied
+// key in the cloud.
+func cloudID(cloud, key string) string {
+	return encode(cloud) + "|" + encode(key)
+}
+
+// fromCloudID returns the cloud and key of the account or role with the
+// supplied object id.
+func fromCloudID(id string) (string, string) {
+	cloud, key, _ := strings.Cut(id, "|")
+
+	return decode(cloud), decode(key)
+}
+
+func encode(s string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(s))
+}
+
+func decode(s string) string {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return s
+	}
+
+	return string(b)
+}
@@ -0,0 +1,307 @@
+package spicedb_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/VariableExp0rt/powerbroker/apis/powerbroker/v1alpha1"
+	"github.com/VariableExp0rt/powerbroker/internal/service"
+	"github.com/VariableExp0rt/powerbroker/internal/service/types"
+	"github.com/VariableExp0rt/powerbroker/internal/storage/conformance"
+	"github.com/VariableExp0rt/powerbroker/internal/storage/spicedb"
+	"github.com/VariableExp0rt/powerbroker/internal/storage/spicedb/fake"
+	storetypes "github.com/VariableExp0rt/powerbroker/internal/storage/types"
+)
+
+var _ service.Repository = &spicedb.SpiceDB{}
+
+const token = "somerandomkeyhere"
+
+var sortStrings = cmpopts.SortSlices(func(a, b string) bool { return a < b })
+
+func newStore(t *testing.T) (*spicedb.SpiceDB, *fake.Server) {
+	t.Helper()
+
+	srv := fake.NewServer(token)
+	t.Cleanup(srv.Close)
+
+	store := &spicedb.SpiceDB{Client: spicedb.NewClient(srv.URL, token)}
+	if err := store.ApplySchema(context.Background()); err != nil {
+		t.Fatalf("ApplySchema(...): %v", err)
+	}
+
+	return store, srv
+}
+
+func TestConformance(t *testing.T) {
+	conformance.Run(t, func(t *testing.T) service.Repository {
+		store, _ := newStore(t)
+		return store
+	})
+}
+
+func TestApplySchema(t *testing.T) {
+	_, srv := newStore(t)
+
+	if diff := cmp.Diff(spicedb.Schema, srv.Schema()); diff != "" {
+		t.Errorf("ApplySchema(...): -want, +got:\n%s", diff)
+	}
+}
+
+func TestUnauthenticated(t *testing.T) {
+	srv := fake.NewServer(token)
+	defer srv.Close()
+
+	store := &spicedb.SpiceDB{Client: spicedb.NewClient(srv.URL, "wrong")}
+	if _, err := store.CreateUser(context.Background(), "uid-mario", "mario", nil); err == nil {
+		t.Errorf("CreateUser(...): expected error with invalid token")
+	}
+}
+
+func TestUser(t *testing.T) {
+	store, _ := newStore(t)
+
+	p1, err := store.CreatePersona(context.Background(), "uid-readonly", "readonly", nil)
+	if err != nil {
+		t.Fatalf("CreatePersona(...): %v", err)
+	}
+	p2, err := store.CreatePersona(context.Background(), "uid-admin", "admin", nil)
+	if err != nil {
+		t.Fatalf("CreatePersona(...): %v", err)
+	}
+
+	id, err := store.CreateUser(context.Background(), "uid-mario", "mario", []string{p1, "does-not-exist"})
+	if err != nil {
+		t.Fatalf("CreateUser(...): %v", err)
+	}
+
+	got, err := store.GetUser(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetUser(...): %v", err)
+	}
+	want := &types.GetUserResponse{Name: "mario", NodeID: id, Status: storetypes.StatusAvailable, References: []string{p1}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("GetUser(...): -want, +got:\n%s", diff)
+	}
+
+	if _, err := store.UpdateUser(context.Background(), "mario", id, []string{p2}); err != nil {
+		t.Fatalf("UpdateUser(...): %v", err)
+	}
+
+	got, err = store.GetUser(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetUser(...): %v", err)
+	}
+	want.References = []string{p2}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("GetUser(...): -want, +got:\n%s", diff)
+	}
+
+	if err := store.DeleteUser(context.Background(), id); err != nil {
+		t.Fatalf("DeleteUser(...): %v", err)
+	}
+
+	got, err = store.GetUser(context.Background(), id)
+	if !storetypes.IsEntityNotFoundNeo4jErr(err) {
+		t.Errorf("GetUser(...): want EntityNotFoundError, got %v", err)
+	}
+	if got.Status != storetypes.StatusDeleted {
+		t.Errorf("GetUser(...): want status %q, got %q", storetypes.StatusDeleted, got.Status)
+	}
+
+	if _, err := store.UpdateUser(context.Background(), "mario", id, nil); !storetypes.IsEntityNotFoundNeo4jErr(err) {
+		t.Errorf("UpdateUser(...): want EntityNotFoundError, got %v", err)
+	}
+}
+
+func TestPersona(t *testing.T) {
+	store, srv := newStore(t)
+
+	bindings := []v1alpha1.AccountRoleBinding{{Account: "123456789012", RoleName: "ReadOnly"}}
+	ps1, err := store.CreatePermissionSet(context.Background(), "uid-readonly", "readonly", bindings)
+	if err != nil {
+		t.Fatalf("CreatePermissionSet(...): %v", err)
+	}
+	ps2, err := store.CreatePermissionSet(context.Background(), "uid-readonly-too", "readonly-too", bindings)
+	if err != nil {
+		t.Fatalf("CreatePermissionSet(...): %v", err)
+	}
+
+	id, err := store.CreatePersona(context.Background(), "uid-auditor", "auditor", []string{ps1})
+	if err != nil {
+		t.Fatalf("CreatePersona(...): %v", err)
+	}
+
+	if _, err := store.UpdatePersona(context.Background(), "auditor", id, []string{ps1, ps2}); err != nil {
+		t.Fatalf("UpdatePersona(...): %v", err)
+	}
+
+	got, err := store.GetPersona(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetPersona(...): %v", err)
+	}
+	want := &types.GetPersonaResponse{Name: "auditor", NodeID: id, Status: storetypes.StatusAvailable, References: []string{ps1, ps2}}
+	if diff := cmp.Diff(want, got, sortStrings); diff != "" {
+		t.Errorf("GetPersona(...): -want, +got:\n%s", diff)
+	}
+
+	if err := store.DeletePersona(context.Background(), id); err != nil {
+		t.Fatalf("DeletePersona(...): %v", err)
+	}
+
+	for _, r := range srv.Relationships() {
+		if r.Resource.ObjectID == id || r.Subject.Object.ObjectID == id {
+			t.Errorf("DeletePersona(...): dangling relationship %+v", r)
+		}
+	}
+}
+
+func TestPermissionSet(t *testing.T) {
+	store, srv := newStore(t)
+
+	// Role names may contain characters which object ids cannot, and an
+	// account may be bound with several roles.
+	admin := v1alpha1.AccountRoleBinding{
+		Cloud:        v1alpha1.CloudAWS,
+		Account:      "123456789012",
+		Alias:        "production",
+		AccountClass: "aws:prod",
+		RoleName:     "Administrator/Access",
+	}
+	readonly := admin
+	readonly.RoleName = "ReadOnly"
+
+	id, err := store.CreatePermissionSet(context.Background(), "uid-admin", "admin", []v1alpha1.AccountRoleBinding{readonly, admin})
+	if err != nil {
+		t.Fatalf("CreatePermissionSet(...): %v", err)
+	}
+
+	got, err := store.GetPermissionSet(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetPermissionSet(...): %v", err)
+	}
+	want := &types.GetPermissionSetResponse{Name: "admin", NodeID: id, Status: storetypes.StatusAvailable, Bindings: []v1alpha1.AccountRoleBinding{admin, readonly}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("GetPermissionSet(...): -want, +got:\n%s", diff)
+	}
+
+	updated := v1alpha1.AccountRoleBinding{
+		Cloud:        v1alpha1.CloudAWS,
+		Account:      "210987654321",
+		Alias:        "staging",
+		AccountClass: "aws:nonprod",
+		RoleName:     "ReadOnly",
+	}
+	if _, err := store.UpdatePermissionSet(context.Background(), id, "admin", []v1alpha1.AccountRoleBinding{updated}); err != nil {
+		t.Fatalf("UpdatePermissionSet(...): %v", err)
+	}
+
+	got, err = store.GetPermissionSet(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetPermissionSet(...): %v", err)
+	}
+	want.Bindings = []v1alpha1.AccountRoleBinding{updated}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("GetPermissionSet(...): -want, +got:\n%s", diff)
+	}
+
+	if err := store.DeletePermissionSet(context.Background(), id); err != nil {
+		t.Fatalf("DeletePermissionSet(...): %v", err)
+	}
+	if _, err := store.GetPermissionSet(context.Background(), id); !storetypes.IsEntityNotFoundNeo4jErr(err) {
+		t.Errorf("GetPermissionSet(...): want EntityNotFoundError, got %v", err)
+	}
+
+	for _, r := range srv.Relationships() {
+		if strings.HasPrefix(r.Resource.ObjectID, id) || r.Subject.Object.ObjectID == id {
+			t.Errorf("DeletePermissionSet(...): dangling relationship %+v", r)
+		}
+	}
+}
+
+func TestTeam(t *testing.T) {
+	store, _ := newStore(t)
+
+	users := make([]string, 3)
+	for i, name := range []string{"bowser", "wario", "toad"} {
+		id, err := store.CreateUser(context.Background(), "uid-"+name, name, nil)
+		if err != nil {
+			t.Fatalf("CreateUser(...): %v", err)
+		}
+		users[i] = id
+	}
+
+	persona, err := store.CreatePersona(context.Background(), "uid-castle-entry", "castle-entry", nil)
+	if err != nil {
+		t.Fatalf("CreatePersona(...): %v", err)
+	}
+
+	params := &v1alpha1.TeamParameters{
+		Name:      "koopa-troop",
+		ManagedBy: v1alpha1.ManagedByParameters{User: users[0]},
+		Members:   users,
+		Personas:  []string{persona},
+	}
+
+	id, err := store.CreateTeam(context.Background(), "uid-koopa-troop", params)
+	if err != nil {
+		t.Fatalf("CreateTeam(...): %v", err)
+	}
+
+	got, err := store.GetTeam(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetTeam(...): %v", err)
+	}
+	want := &types.GetTeamResponse{
+		Name:      "koopa-troop",
+		NodeID:    id,
+		Status:    storetypes.StatusAvailable,
+		ManagedBy: users[0],
+		Members:   users[1:],
+		Personas:  []string{persona},
+	}
+	if diff := cmp.Diff(want, got, sortStrings); diff != "" {
+		t.Errorf("GetTeam(...): -want, +got:\n%s", diff)
+	}
+
+	params.ManagedBy.User = users[1]
+	params.Members = []string{users[2]}
+	params.Personas = nil
+	if _, err := store.UpdateTeam(context.Background(), id, params); err != nil {
+		t.Fatalf("UpdateTeam(...): %v", err)
+	}
+
+	got, err = store.GetTeam(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetTeam(...): %v", err)
+	}
+	want.ManagedBy = users[1]
+	want.Members = []string{users[2]}
+	want.Personas = []string{}
+	if diff := cmp.Diff(want, got, sortStrings); diff != "" {
+		t.Errorf("GetTeam(...): -want, +got:\n%s", diff)
+	}
+
+	// Deleting a member removes it from every team it belongs to.
+	if err := store.DeleteUser(context.Background(), users[2]); err != nil {
+		t.Fatalf("DeleteUser(...): %v", err)
+	}
+	got, err = store.GetTeam(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetTeam(...): %v", err)
+	}
+	if len(got.Members) != 0 {
+		t.Errorf("GetTeam(...): want no members, got %v", got.Members)
+	}
+
+	if err := store.DeleteTeam(context.Background(), id); err != nil {
+		t.Fatalf("DeleteTeam(...): %v", err)
+	}
+	if _, err := store.GetTeam(context.Background(), id); !storetypes.IsEntityNotFoundNeo4jErr(err) {
+		t.Errorf("GetTeam(...): want EntityNotFoundError, got %v", err)
+	}
+}
@@ -1,14 +1,62 @@
 package storage
 
 import (
+	"context"
+	"crypto/x509"
+	"net/url"
+	"strings"
+
+	apisv1alpha1 "github.com/VariableExp0rt/powerbroker/apis/v1alpha1"
+	"github.com/VariableExp0rt/powerbroker/internal/service"
 	"github.com/VariableExp0rt/powerbroker/internal/service/types"
+	"github.com/VariableExp0rt/powerbroker/internal/storage/memory"
 	neo4jstore "github.com/VariableExp0rt/powerbroker/internal/storage/neo4j"
+	"github.com/VariableExp0rt/powerbroker/internal/storage/neo4j/transaction"
+	"github.com/VariableExp0rt/powerbroker/internal/storage/spicedb"
 	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
 	"github.com/pkg/errors"
 	"gopkg.in/yaml.v2"
 )
 
-func NewNeo4jStorage(creds []byte, conf ...func(*neo4j.Config)) (*neo4jstore.Neo4jDB, error) {
+// Built-in storage backends. A new backend only needs to be registered
+// here to become selectable through a ProviderConfig.
+func init() {
+	Register(apisv1alpha1.StorageTypeNeo4j, func(c Config) (service.Repository, error) {
+		return NewNeo4jStorage(c.Credentials, c.Neo4j, c.CABundle)
+	})
+	Register(apisv1alpha1.StorageTypeSpiceDB, func(c Config) (service.Repository, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), ConnectTimeout(c))
+		defer cancel()
+		return NewSpiceDBStorage(ctx, c.Credentials)
+	})
+	Register(apisv1alpha1.StorageTypeMemory, func(c Config) (service.Repository, error) {
+		return memory.ForProviderConfig(c.ProviderConfig), nil
+	})
+}
+
+// NewIDStrategy returns the IDStrategy selected by the supplied options of
+// the neo4j backend, which generates uuids in Go unless told otherwise.
+func NewIDStrategy(o *apisv1alpha1.Neo4jStorage) (transaction.IDStrategy, error) {
+	if o == nil {
+		return transaction.GoIDs{}, nil
+	}
+
+	switch o.IDStrategy {
+	case "", apisv1alpha1.IDStrategyGo:
+		return transaction.GoIDs{}, nil
+	case apisv1alpha1.IDStrategyNative:
+		return transaction.NativeIDs{}, nil
+	case apisv1alpha1.IDStrategyAPOC:
+		return transaction.APOCIDs{}, nil
+	}
+
+	return nil, errors.Errorf("unknown neo4j id strategy %q", o.IDStrategy)
+}
+
+// NewNeo4jStorage returns the neo4j backend described by the supplied
+// credentials and options. Options which are nil or unset leave the scheme
+// of the credentials' URI and the defaults of the driver as they are.
+func NewNeo4jStorage(creds []byte, o *apisv1alpha1.Neo4jStorage, caBundle []byte) (*neo4jstore.Neo4jDB, error) {
 	var co types.Neo4jCredentialObject
 
 	err := yaml.Unmarshal(creds, &co)
@@ -16,12 +64,161 @@ func NewNeo4jStorage(creds []byte, conf ...func(*neo4j.Config)) (*neo4jstore.Neo
 		return nil, errors.Wrap(err, "cannot unmarshal secret data")
 	}
 
-	driver, err := neo4j.NewDriver(co.DatabaseURI, neo4j.BasicAuth(co.DatabaseUsername, co.DatabasePassword, ""), conf...)
+	if o == nil {
+		o = &apisv1alpha1.Neo4jStorage{}
+	}
+
+	ids, err := NewIDStrategy(o)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := neo4jTarget(co.DatabaseURI, o)
+	if err != nil {
+		return nil, err
+	}
+
+	auth, err := neo4jAuth(co, o.Auth)
+	if err != nil {
+		return nil, err
+	}
+
+	conf, err := neo4jConfig(o.ConnectionPool, caBundle)
+	if err != nil {
+		return nil, err
+	}
+
+	driver, err := neo4j.NewDriver(target, auth, conf)
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot establish authenticated session")
 	}
 
 	return &neo4jstore.Neo4jDB{
-		Driver: driver,
+		Driver:   driver,
+		IDs:      ids,
+		Database: o.Database,
 	}, nil
 }
+
+// neo4jTarget returns the URI the driver connects to. Its scheme is that of
+// the supplied URI, changed to route or not, and to encrypt or not, as the
+// supplied options decide.
+func neo4jTarget(uri string, o *apisv1alpha1.Neo4jStorage) (string, error) {
+	if o.Routing == "" && o.TLS == nil {
+		return uri, nil
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot parse neo4j URI")
+	}
+
+	// Schemes are one of neo4j or bolt, optionally followed by +s to
+	// encrypt connections or +ssc to encrypt them without verifying the
+	// certificate of the server. bolt+unix connects to a socket instead.
+	if u.Scheme == "bolt+unix" {
+		return "", errors.New("neo4j routing and TLS options cannot be used with a unix socket")
+	}
+	scheme, security, _ := strings.Cut(u.Scheme, "+")
+
+	switch o.Routing {
+	case apisv1alpha1.RoutingRouting:
+		scheme = "neo4j"
+	case apisv1alpha1.RoutingDirect:
+		scheme = "bolt"
+	case "":
+	default:
+		return "", errors.Errorf("unknown neo4j routing %q", o.Routing)
+	}
+
+	if o.TLS != nil {
+		security = "s"
+		if o.TLS.InsecureSkipVerify {
+			security = "ssc"
+		}
+	}
+
+	u.Scheme = scheme
+	if security != "" {
+		u.Scheme += "+" + security
+	}
+
+	return u.String(), nil
+}
+
+// neo4jAuth returns the token the driver authenticates with, which is built
+// from the credentials as the supplied scheme decides.
+func neo4jAuth(co types.Neo4jCredentialObject, scheme string) (neo4j.AuthToken, error) {
+	switch scheme {
+	case "", apisv1alpha1.AuthBasic:
+		return neo4j.BasicAuth(co.DatabaseUsername, co.DatabasePassword, ""), nil
+	case apisv1alpha1.AuthBearer:
+		if co.Token == "" {
+			return neo4j.AuthToken{}, errors.New("neo4j bearer auth requires a token in the credentials")
+		}
+		return neo4j.BearerAuth(co.Token), nil
+	case apisv1alpha1.AuthKerberos:
+		if co.KerberosTicket == "" {
+			return neo4j.AuthToken{}, errors.New("neo4j kerberos auth requires a kerberosTicket in the credentials")
+		}
+		return neo4j.KerberosAuth(co.KerberosTicket), nil
+	case apisv1alpha1.AuthNone:
+		return neo4j.NoAuth(), nil
+	}
+
+	return neo4j.AuthToken{}, errors.Errorf("unknown neo4j auth %q", scheme)
+}
+
+// neo4jConfig returns a function which configures the driver to trust the
+// authorities in the supplied CA bundle, if any, and to pool connections as
+// the supplied options decide.
+func neo4jConfig(o *apisv1alpha1.Neo4jConnectionPool, caBundle []byte) (func(*neo4j.Config), error) {
+	var roots *x509.CertPool
+	if len(caBundle) > 0 {
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(caBundle) {
+			return nil, errors.New("neo4j CA bundle contains no PEM encoded certificates")
+		}
+	}
+
+	return func(c *neo4j.Config) {
+		if roots != nil {
+			c.RootCAs = roots
+		}
+		if o == nil {
+			return
+		}
+		if o.MaxSize != nil {
+			c.MaxConnectionPoolSize = *o.MaxSize
+		}
+		if o.MaxLifetime != nil {
+			c.MaxConnectionLifetime = o.MaxLifetime.Duration
+		}
+		if o.AcquisitionTimeout != nil {
+			c.ConnectionAcquisitionTimeout = o.AcquisitionTimeout.Duration
+		}
+	}, nil
+}
+
+func NewSpiceDBStorage(ctx context.Context, creds []byte) (*spicedb.SpiceDB, error) {
+	var co types.SpiceDBCredentialObject
+
+	err := yaml.Unmarshal(creds, &co)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot unmarshal secret data")
+	}
+
+	if co.Endpoint == "" {
+		return nil, errors.New("spicedb endpoint is required")
+	}
+
+	store := &spicedb.SpiceDB{
+		Client: spicedb.NewClient(co.Endpoint, co.Token),
+	}
+
+	if err := store.ApplySchema(ctx); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
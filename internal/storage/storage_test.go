@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	apisv1alpha1 "github.com/VariableExp0rt/powerbroker/apis/v1alpha1"
+	"github.com/VariableExp0rt/powerbroker/internal/service/types"
+	"github.com/VariableExp0rt/powerbroker/internal/storage/neo4j/transaction"
+)
+
+func TestNewIDStrategy(t *testing.T) {
+	cases := map[string]struct {
+		o       *apisv1alpha1.Neo4jStorage
+		want    transaction.IDStrategy
+		wantErr bool
+	}{
+		"Unset":   {want: transaction.GoIDs{}},
+		"Default": {o: &apisv1alpha1.Neo4jStorage{}, want: transaction.GoIDs{}},
+		"Go":      {o: &apisv1alpha1.Neo4jStorage{IDStrategy: apisv1alpha1.IDStrategyGo}, want: transaction.GoIDs{}},
+		"Native":  {o: &apisv1alpha1.Neo4jStorage{IDStrategy: apisv1alpha1.IDStrategyNative}, want: transaction.NativeIDs{}},
+		"APOC":    {o: &apisv1alpha1.Neo4jStorage{IDStrategy: apisv1alpha1.IDStrategyAPOC}, want: transaction.APOCIDs{}},
+		"Unknown": {o: &apisv1alpha1.Neo4jStorage{IDStrategy: "Sequential"}, wantErr: true},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := NewIDStrategy(tc.o)
+
+			if (err != nil) != tc.wantErr {
+				t.Errorf("NewIDStrategy(...): want error %t, got %v", tc.wantErr, err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("NewIDStrategy(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNeo4jTarget(t *testing.T) {
+	cases := map[string]struct {
+		uri     string
+		o       *apisv1alpha1.Neo4jStorage
+		want    string
+		wantErr bool
+	}{
+		"Unset": {
+			uri:  "bolt+s://neo4j:7687",
+			o:    &apisv1alpha1.Neo4jStorage{},
+			want: "bolt+s://neo4j:7687",
+		},
+		"Routing": {
+			uri:  "bolt://neo4j:7687",
+			o:    &apisv1alpha1.Neo4jStorage{Routing: apisv1alpha1.RoutingRouting},
+			want: "neo4j://neo4j:7687",
+		},
+		"DirectKeepsEncryption": {
+			uri:  "neo4j+s://neo4j:7687",
+			o:    &apisv1alpha1.Neo4jStorage{Routing: apisv1alpha1.RoutingDirect},
+			want: "bolt+s://neo4j:7687",
+		},
+		"TLS": {
+			uri:  "neo4j://neo4j:7687",
+			o:    &apisv1alpha1.Neo4jStorage{TLS: &apisv1alpha1.Neo4jTLS{}},
+			want: "neo4j+s://neo4j:7687",
+		},
+		"TLSInsecureSkipVerify": {
+			uri:  "bolt://neo4j:7687",
+			o:    &apisv1alpha1.Neo4jStorage{TLS: &apisv1alpha1.Neo4jTLS{InsecureSkipVerify: true}},
+			want: "bolt+ssc://neo4j:7687",
+		},
+		"UnixSocket": {
+			uri:     "bolt+unix:///var/run/neo4j.sock",
+			o:       &apisv1alpha1.Neo4jStorage{TLS: &apisv1alpha1.Neo4jTLS{}},
+			wantErr: true,
+		},
+		"UnknownRouting": {
+			uri:     "bolt://neo4j:7687",
+			o:       &apisv1alpha1.Neo4jStorage{Routing: "Anycast"},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := neo4jTarget(tc.uri, tc.o)
+
+			if (err != nil) != tc.wantErr {
+				t.Errorf("neo4jTarget(...): want error %t, got %v", tc.wantErr, err)
+			}
+			if got != tc.want {
+				t.Errorf("neo4jTarget(...): want %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestNeo4jAuth(t *testing.T) {
+	co := types.Neo4jCredentialObject{
+		DatabaseUsername: "mario",
+		DatabasePassword: "its-a-me",
+		Token:            "mushroom",
+		KerberosTicket:   "c3Rhcg==",
+	}
+
+	cases := map[string]struct {
+		co      types.Neo4jCredentialObject
+		scheme  string
+		want    neo4j.AuthToken
+		wantErr bool
+	}{
+		"Default":         {co: co, want: neo4j.BasicAuth("mario", "its-a-me", "")},
+		"Basic":           {co: co, scheme: apisv1alpha1.AuthBasic, want: neo4j.BasicAuth("mario", "its-a-me", "")},
+		"Bearer":          {co: co, scheme: apisv1alpha1.AuthBearer, want: neo4j.BearerAuth("mushroom")},
+		"Kerberos":        {co: co, scheme: apisv1alpha1.AuthKerberos, want: neo4j.KerberosAuth("c3Rhcg==")},
+		"None":            {co: co, scheme: apisv1alpha1.AuthNone, want: neo4j.NoAuth()},
+		"BearerNoToken":   {scheme: apisv1alpha1.AuthBearer, wantErr: true},
+		"KerberosNoToken": {scheme: apisv1alpha1.AuthKerberos, wantErr: true},
+		"Unknown":         {co: co, scheme: "Digest", wantErr: true},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := neo4jAuth(tc.co, tc.scheme)
+
+			if (err != nil) != tc.wantErr {
+				t.Errorf("neo4jAuth(...): want error %t, got %v", tc.wantErr, err)
+			}
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(neo4j.AuthToken{})); diff != "" {
+				t.Errorf("neo4jAuth(...): -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNeo4jConfig(t *testing.T) {
+	size := 10
+	o := &apisv1alpha1.Neo4jConnectionPool{
+		MaxSize:            &size,
+		MaxLifetime:        &metav1.Duration{Duration: 10 * time.Minute},
+		AcquisitionTimeout: &metav1.Duration{Duration: 5 * time.Second},
+	}
+
+	conf, err := neo4jConfig(o, nil)
+	if err != nil {
+		t.Fatalf("neo4jConfig(...): %v", err)
+	}
+
+	c := &neo4j.Config{MaxConnectionPoolSize: 100}
+	conf(c)
+	if c.MaxConnectionPoolSize != 10 || c.MaxConnectionLifetime != 10*time.Minute || c.ConnectionAcquisitionTimeout != 5*time.Second {
+		t.Errorf("neo4jConfig(...): want the connection pool options applied, got %+v", c)
+	}
+	if c.RootCAs != nil {
+		t.Errorf("neo4jConfig(...): want the system's authorities without a CA bundle")
+	}
+
+	if _, err := neo4jConfig(nil, []byte("not a certificate")); err == nil {
+		t.Errorf("neo4jConfig(...): want error for a CA bundle without certificates")
+	}
+}
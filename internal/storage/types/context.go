@@ -0,0 +1,18 @@
+package types
+
+import "context"
+
+type resourceKey struct{}
+
+// WithResource returns a copy of ctx which carries the UID of the managed
+// resource an operation on the storage is for. Backends may use it to make
+// sure a managed resource reads what it last wrote.
+func WithResource(ctx context.Context, uid string) context.Context {
+	return context.WithValue(ctx, resourceKey{}, uid)
+}
+
+// ResourceFrom returns the UID of the managed resource ctx carries, if any.
+func ResourceFrom(ctx context.Context) (string, bool) {
+	uid, ok := ctx.Value(resourceKey{}).(string)
+	return uid, ok && uid != ""
+}
@@ -0,0 +1,28 @@
+package types
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// FormatDuration returns the string a backend stores a duration as, or an
+// empty string if there is none.
+func FormatDuration(d *metav1.Duration) string {
+	if d == nil {
+		return ""
+	}
+
+	return d.Duration.String()
+}
+
+// ParseDuration returns the duration a backend stored as s, or nil if s is
+// empty or not a duration.
+func ParseDuration(s string) *metav1.Duration {
+	d, err := time.ParseDuration(s)
+	if s == "" || err != nil {
+		return nil
+	}
+
+	return &metav1.Duration{Duration: d}
+}
@@ -1,10 +1,34 @@
 package types
 
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
 const (
 	entityNotFound = "requested object not found"
 	internalError  = "internal error occurred"
 )
 
+/*
+
+Storage backends classify the errors of their databases into the types below,
+so that controllers can decide how to report and requeue a failure without
+knowing which backend returned it.
+
+- EntityNotFoundError: the entity does not exist.
+- ConflictError: the write conflicts with an entity which already exists.
+- TransientError: the operation failed but is expected to succeed if retried.
+- UnavailableError: the database cannot be reached or cannot serve requests.
+- AuthError: the database rejected the credentials of the provider.
+- SchemaError: the graph or query does not match the schema the provider expects.
+
+Errors of the last five types wrap the error of the database, which is kept
+in their message.
+
+*/
+
 type EntityNotFoundError struct{}
 
 func (e *EntityNotFoundError) Error() string {
@@ -12,8 +36,8 @@ func (e *EntityNotFoundError) Error() string {
 }
 
 func IsEntityNotFoundNeo4jErr(err error) bool {
-	_, ok := err.(*EntityNotFoundError)
-	return ok
+	var e *EntityNotFoundError
+	return errors.As(err, &e)
 }
 
 type InternalError struct{}
@@ -23,6 +47,112 @@ func (e *InternalError) Error() string {
 }
 
 func IsInternalError(err error) bool {
-	_, ok := err.(*InternalError)
-	return ok
+	var e *InternalError
+	return errors.As(err, &e)
+}
+
+type ConflictError struct {
+	Err error
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("conflicting entity exists: %v", e.Err)
+}
+
+func (e *ConflictError) Unwrap() error {
+	return e.Err
+}
+
+func IsConflictError(err error) bool {
+	var e *ConflictError
+	return errors.As(err, &e)
+}
+
+type TransientError struct {
+	Err error
+}
+
+func (e *TransientError) Error() string {
+	return fmt.Sprintf("transient storage error: %v", e.Err)
+}
+
+func (e *TransientError) Unwrap() error {
+	return e.Err
+}
+
+func IsTransientError(err error) bool {
+	var e *TransientError
+	return errors.As(err, &e)
+}
+
+type UnavailableError struct {
+	Err error
+}
+
+func (e *UnavailableError) Error() string {
+	return fmt.Sprintf("storage unavailable: %v", e.Err)
+}
+
+func (e *UnavailableError) Unwrap() error {
+	return e.Err
+}
+
+func IsUnavailableError(err error) bool {
+	var e *UnavailableError
+	return errors.As(err, &e)
+}
+
+type AuthError struct {
+	Err error
+}
+
+func (e *AuthError) Error() string {
+	return fmt.Sprintf("storage rejected credentials: %v", e.Err)
+}
+
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
+
+func IsAuthError(err error) bool {
+	var e *AuthError
+	return errors.As(err, &e)
+}
+
+type SchemaError struct {
+	Err error
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("storage schema error: %v", e.Err)
+}
+
+func (e *SchemaError) Unwrap() error {
+	return e.Err
+}
+
+func IsSchemaError(err error) bool {
+	var e *SchemaError
+	return errors.As(err, &e)
+}
+
+// IsRetryable returns true if err is expected to go away without anyone
+// changing the provider's configuration or the graph, i.e. it is transient
+// or the storage is unavailable.
+func IsRetryable(err error) bool {
+	return IsTransientError(err) || IsUnavailableError(err)
+}
+
+// Unique returns the only id in ids, which were found by a natural key such
+// as a name. It returns an EntityNotFoundError if there are none, and a
+// ConflictError if the key is shared by several entities.
+func Unique(ids []string) (string, error) {
+	switch len(ids) {
+	case 0:
+		return "", &EntityNotFoundError{}
+	case 1:
+		return ids[0], nil
+	}
+
+	return "", &ConflictError{Err: fmt.Errorf("%d entities match: %s", len(ids), strings.Join(ids, ", "))}
 }
@@ -0,0 +1,32 @@
+package types
+
+import "time"
+
+// Labels of the nodes a storage backend creates as a side effect of a
+// managed resource, rather than for one, and which are orphaned once no
+// managed resource references them.
+const (
+	OrphanAccount = "Account"
+	OrphanRole    = "Role"
+)
+
+// An Orphan is a node which nothing references any more, such as the
+// account of a deleted permission set.
+type Orphan struct {
+	// Label of the node, e.g. Account or Role.
+	Label string
+
+	// Cloud the account or role is in.
+	Cloud string
+
+	// ID identifies the node among those with its label in its cloud: the
+	// id of an account or the name of a role.
+	ID string
+
+	// Since is when the node was first found to be orphaned.
+	Since time.Time
+}
+
+func (o Orphan) String() string {
+	return o.Label + " " + o.Cloud + "/" + o.ID
+}